@@ -0,0 +1,14 @@
+-- Lease columns for scheduled_posts claims (see calendar.FetchAndClaimDuePostsWithLease)
+-- claimed_by/lease_expires_at are set while a row is 'processing'; attempts counts claims.
+
+BEGIN;
+
+ALTER TABLE scheduled_posts ADD COLUMN IF NOT EXISTS claimed_by       TEXT;
+ALTER TABLE scheduled_posts ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMPTZ;
+ALTER TABLE scheduled_posts ADD COLUMN IF NOT EXISTS attempts         INT NOT NULL DEFAULT 0;
+
+-- Reaper scan: processing rows ordered by lease expiry
+CREATE INDEX IF NOT EXISTS idx_scheduled_posts_processing_lease
+  ON scheduled_posts(lease_expires_at) WHERE status = 'processing';
+
+COMMIT;
@@ -0,0 +1,27 @@
+-- OAuth tokens per provider account (mirrors models.OAuthAccount).
+-- accounts.Store joins these to social_accounts on (platform, external_id) to
+-- resolve posting credentials; social_accounts.auth_meta keeps non-secret settings.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS oauth_accounts (
+  id               TEXT PRIMARY KEY,
+  user_id          TEXT REFERENCES users(id) ON DELETE CASCADE,
+  provider         TEXT NOT NULL,   -- matches social_accounts.platform
+  provider_user_id TEXT NOT NULL,   -- matches social_accounts.external_id
+  access_token     TEXT,
+  refresh_token    TEXT,
+  token_secret     TEXT,            -- OAuth 1.0a (twitter)
+  token_type       TEXT,
+  expires_at       TIMESTAMPTZ,
+  scope            TEXT,
+  email            TEXT,
+  profile_data     JSONB NOT NULL DEFAULT '{}'::jsonb,
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_accounts_provider_user
+  ON oauth_accounts(provider, provider_user_id, updated_at DESC);
+
+COMMIT;
@@ -0,0 +1,28 @@
+-- Publish ledger: one row per scheduled post publish (see pkg/ledger).
+-- Intent is recorded as 'pending' before the platform call and resolved to
+-- 'published' or 'rejected' after it. A row still 'pending' when the post is
+-- retried means the earlier outcome is unknown and must be reconciled first.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS publish_ledger (
+  key               TEXT PRIMARY KEY,   -- idempotency key, stable across retries
+  scheduled_post_id TEXT NOT NULL REFERENCES scheduled_posts(id) ON DELETE CASCADE,
+  platform          TEXT NOT NULL,
+  account_id        TEXT,               -- social_accounts.id; NULL for env credentials
+  state             TEXT NOT NULL DEFAULT 'pending',  -- pending|published|rejected
+  attempts          INT NOT NULL DEFAULT 1,
+  external_id       TEXT,
+  response          JSONB,              -- platform outcome (id, status code, reconciled)
+  error             TEXT,
+  created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_publish_ledger_scheduled_post
+  ON publish_ledger(scheduled_post_id);
+-- Operators look for attempts whose outcome is still unknown
+CREATE INDEX IF NOT EXISTS idx_publish_ledger_pending
+  ON publish_ledger(updated_at) WHERE state = 'pending';
+
+COMMIT;
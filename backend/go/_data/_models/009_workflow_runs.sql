@@ -0,0 +1,45 @@
+-- EOWR workflow runs (see pkg/engine/workflows/runs.go). One row per run and
+-- one per node of the run, written as the run progresses, so a run that
+-- crashed or failed can resume from its last completed nodes.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS workflow_runs (
+  id               TEXT PRIMARY KEY,
+  workflow         TEXT NOT NULL,      -- workflow name from the .pseudo header
+  version          TEXT,
+  source           TEXT NOT NULL,      -- .pseudo path the run was started from
+  trigger          TEXT,               -- trigger fired; NULL fires all
+  input            JSONB,
+  output           JSONB,
+  status           TEXT NOT NULL DEFAULT 'running',  -- running|succeeded|failed
+  error            TEXT,
+  invocations      INT NOT NULL DEFAULT 1,           -- start plus resumes
+  claimed_by       TEXT,
+  lease_expires_at TIMESTAMPTZ,        -- renewed on every checkpoint while running
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  finished_at      TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS workflow_run_nodes (
+  run_id      TEXT NOT NULL REFERENCES workflow_runs(id) ON DELETE CASCADE,
+  node        TEXT NOT NULL,
+  status      TEXT NOT NULL,           -- running|succeeded|failed|skipped
+  input       JSONB,
+  output      JSONB,
+  error       TEXT,
+  attempts    INT NOT NULL DEFAULT 0,
+  started_at  TIMESTAMPTZ,
+  finished_at TIMESTAMPTZ,
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (run_id, node)
+);
+
+CREATE INDEX IF NOT EXISTS idx_workflow_runs_workflow_created
+  ON workflow_runs(workflow, created_at DESC);
+-- The scheduler resumes running runs whose lease expired
+CREATE INDEX IF NOT EXISTS idx_workflow_runs_running_lease
+  ON workflow_runs(lease_expires_at) WHERE status = 'running';
+
+COMMIT;
@@ -0,0 +1,14 @@
+-- Follower counts of social accounts, used by the analytics rollups
+-- (pkg/analytics/trends) for follower-normalized metrics. NULL when unknown;
+-- set by an account sync or by hand.
+
+BEGIN;
+
+ALTER TABLE social_accounts ADD COLUMN IF NOT EXISTS followers BIGINT;
+ALTER TABLE social_accounts ADD COLUMN IF NOT EXISTS followers_updated_at TIMESTAMPTZ;
+
+-- Rollups select published posts by publish time
+CREATE INDEX IF NOT EXISTS idx_scheduled_posts_org_published_at
+  ON scheduled_posts(org_id, published_at) WHERE status = 'published';
+
+COMMIT;
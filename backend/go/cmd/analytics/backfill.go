@@ -0,0 +1,38 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/analytics/storage"
+)
+
+// backfillSummary is what --backfill prints.
+type backfillSummary struct {
+    From   string    `json:"from"`
+    Posts  int       `json:"posts"`
+    Oldest time.Time `json:"oldest,omitzero"`
+}
+
+// runBackfill copies every post in the store described by spec into
+// scheduled_posts of orgID, skipping posts already there, so collect mode
+// reads their metrics into post_outcomes.
+func runBackfill(ctx context.Context, dsn, orgID, spec string) (backfillSummary, error) {
+    sum := backfillSummary{From: spec}
+    if dsn == "" { return sum, errors.New("--backfill needs --database or DATABASE_URL") }
+    if orgID == "" { return sum, errors.New("--backfill needs --org or ORG_ID") }
+    src, err := storage.Open(spec)
+    if err != nil { return sum, err }
+    defer src.Close()
+    db, err := sql.Open("postgres", dsn)
+    if err != nil { return sum, err }
+    defer db.Close()
+
+    recs, err := src.Query(ctx, storage.Query{Limit: 1})
+    if err != nil { return sum, err }
+    if len(recs) == 1 { sum.Oldest = recs[0].PublishedAt }
+    sum.Posts, err = storage.Copy(ctx, &storage.PostgresStore{DB: db, OrgID: orgID}, src, storage.Query{})
+    return sum, err
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "time"
+
+    _ "github.com/lib/pq"
+
+    "github.com/bitesinbyte/ferret/pkg/accounts"
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+    "github.com/bitesinbyte/ferret/pkg/analytics/collector"
+    "github.com/bitesinbyte/ferret/pkg/calendar"
+    "github.com/bitesinbyte/ferret/pkg/engine/cache"
+    "github.com/bitesinbyte/ferret/pkg/engine/generator"
+    "github.com/bitesinbyte/ferret/pkg/engine/ratelimit"
+)
+
+// collectSummary is what --collect prints.
+type collectSummary struct {
+    Posts     int      `json:"posts"`
+    Collected int      `json:"collected"`
+    NotDue    int      `json:"not_due"`
+    Settled   int      `json:"settled"`
+    Deferred  int      `json:"deferred"`
+    Failed    []string `json:"failed,omitempty"`
+    // Experiments lists the running experiments whose arm weights were updated.
+    Experiments []string `json:"experiments_updated,omitempty"`
+}
+
+// runCollect takes the due snapshot of every published post still within
+// the schedule (or since cutoff when set) into post_outcomes. Per-post
+// failures are reported and make the run fail after the others were
+// collected. Afterwards the arm weights of the running experiments of org
+// (every org when empty) are updated from the new snapshots.
+func runCollect(ctx context.Context, dsn string, schedule []time.Duration, cutoff time.Time, limit int, org, metric string) (collectSummary, error) {
+    if dsn == "" { return collectSummary{}, errors.New("--collect needs --database or DATABASE_URL") }
+    db, err := sql.Open("postgres", dsn)
+    if err != nil { return collectSummary{}, err }
+    defer db.Close()
+    if cutoff.IsZero() { cutoff = time.Now().Add(-collector.Horizon(schedule)) }
+    rows, err := calendar.FetchPublishedPosts(ctx, db, cutoff, limit)
+    if err != nil { return collectSummary{}, err }
+
+    // Optional Valkey (VALKEY_ADDR, as cmd/scheduler): shares the read budget
+    // between runs and replicas, so frequent runs cannot each spend a full burst.
+    var store ratelimit.Store
+    if os.Getenv("VALKEY_ADDR") != "" {
+        if vc, err := cache.NewValkey(cache.ValkeyConfig{}); err == nil {
+            defer vc.Close()
+            store = ratelimit.ValkeyStore{Valkey: vc}
+        } else {
+            log.Printf("valkey disabled, read budget is per run: %v", err)
+        }
+    }
+    c := collector.New(db, accounts.Store{DB: db})
+    c.Schedule = schedule
+    c.Limits = collector.Limiter(store, 0)
+    rep, err := c.Collect(ctx, rows)
+    sum := collectSummary{Posts: len(rows), Collected: rep.Collected, NotDue: rep.NotDue, Settled: rep.Settled, Deferred: rep.Deferred}
+    for _, f := range rep.Failed { sum.Failed = append(sum.Failed, f.Error()) }
+    if err != nil { return sum, err }
+    updated, werr := generator.UpdateRunningArmWeights(ctx, calendarrepo.Repository{DB: db}, org, metric)
+    sum.Experiments = updated
+    if werr != nil { werr = fmt.Errorf("update arm weights: %w", werr) }
+    return sum, errors.Join(rep.Err(), werr)
+}
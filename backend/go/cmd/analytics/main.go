@@ -1,7 +1,6 @@
 package main
 
 import (
-    "bufio"
     "context"
     "encoding/json"
     "errors"
@@ -10,10 +9,14 @@ import (
     "os"
     "time"
 
+    _ "modernc.org/sqlite"
+
     ig "github.com/bitesinbyte/ferret/pkg/external/instagram"
     li "github.com/bitesinbyte/ferret/pkg/external/linkedin"
     yt "github.com/bitesinbyte/ferret/pkg/external/youtube"
+    "github.com/bitesinbyte/ferret/pkg/analytics/collector"
     "github.com/bitesinbyte/ferret/pkg/analytics/storage"
+    "github.com/bitesinbyte/ferret/pkg/engine/generator"
 )
 
 func main() {
@@ -23,12 +26,69 @@ func main() {
     period := flag.String("period", "day", "instagram user insights period: day|week|days_28|month|lifetime")
     metrics := flag.String("metrics", "", "comma-separated metrics (instagram insights)")
     // Bulk mode (reads stored published IDs)
-    bulk := flag.Bool("bulk", false, "bulk mode: read published IDs from file and fetch analytics")
-    file := flag.String("file", "data/published_posts.jsonl", "path to published posts JSONL file")
+    bulk := flag.Bool("bulk", false, "bulk mode: read published IDs from the store and fetch analytics")
+    file := flag.String("file", storage.DefaultPath, "path to published posts JSONL file (when --store is not set)")
+    storeSpec := flag.String("store", "", "published posts store: jsonl:<path>, sqlite:<path> or postgres[:<dsn>] (default jsonl:<--file>)")
+    // Backfill mode (published posts store -> scheduled_posts)
+    backfill := flag.Bool("backfill", false, "copy the published posts of --store into scheduled_posts of --org (with --collect, snapshot them too)")
+    org := flag.String("org", os.Getenv("ORG_ID"), "organization owning backfilled posts; with --collect or --rollup, the only org whose experiments or trends are updated (default all)")
     since := flag.String("since", "", "RFC3339 timestamp or duration (e.g., 72h) to filter records")
+    // Collect mode (published scheduled_posts -> post_outcomes)
+    collect := flag.Bool("collect", false, "collect metrics of published scheduled_posts on every platform into post_outcomes")
+    dsn := flag.String("database", os.Getenv("DATABASE_URL"), "Postgres DSN (collect mode)")
+    limit := flag.Int("limit", 0, "max posts per collect run (0 = all)")
+    schedule := flag.String("schedule", "1h,6h,24h,72h,7d,30d", "snapshot offsets after published_at (collect mode)")
+    banditMetric := flag.String("bandit-metric", generator.RewardEngagement, "reward of the experiment arm weights updated after --collect: engagement|ctr|conversion")
+    // Rollup mode (post_outcomes -> trend_metrics)
+    rollup := flag.Bool("rollup", false, "roll post_outcomes up into hourly, daily and weekly trend_metrics (after --collect when both are set)")
     flag.Parse()
 
     ctx := context.Background()
+    if *storeSpec == "" { *storeSpec = "jsonl:" + *file }
+    switch *banditMetric {
+    case generator.RewardEngagement, generator.RewardCTR, generator.RewardConversion:
+    default:
+        log.Fatalf("unknown --bandit-metric %q", *banditMetric)
+    }
+    var cutoff time.Time
+    var collectErr error
+    if *backfill {
+        sum, err := runBackfill(ctx, *dsn, *org, *storeSpec)
+        out(sum)
+        if err != nil { log.Fatal(err) }
+        // Backfilled posts may be older than the collect horizon.
+        if !sum.Oldest.IsZero() { cutoff = sum.Oldest }
+        if !*collect { return }
+    }
+    if *collect {
+        offsets, err := collector.ParseSchedule(*schedule)
+        if err != nil { log.Fatal(err) }
+        if *since != "" {
+            if cutoff, err = parseSince(*since); err != nil { log.Fatal(err) }
+        }
+        var sum collectSummary
+        sum, collectErr = runCollect(ctx, *dsn, offsets, cutoff, *limit, *org, *banditMetric)
+        out(sum)
+        if !*rollup {
+            if collectErr != nil { log.Fatal(collectErr) }
+            return
+        }
+    }
+    if *rollup {
+        offsets, err := collector.ParseSchedule(*schedule)
+        if err != nil { log.Fatal(err) }
+        // Posts past the schedule horizon get no new snapshots.
+        from := time.Now().Add(-collector.Horizon(offsets))
+        if *since != "" {
+            if from, err = parseSince(*since); err != nil { log.Fatal(err) }
+        }
+        sum, err := runRollup(ctx, *dsn, *org, from)
+        out(sum)
+        if err != nil { log.Fatal(err) }
+        // Snapshots a failing collect did take are rolled up before it fails the run.
+        if collectErr != nil { log.Fatal(collectErr) }
+        return
+    }
     switch *platform {
     case "instagram":
         cfg := ig.NewFromEnv()
@@ -36,7 +96,9 @@ func main() {
         if *bulk {
             cutoff, err := parseSince(*since)
             if err != nil { log.Fatal(err) }
-            res, err := bulkInstagram(ctx, client, *file, cutoff, *metrics)
+            recs, err := readPublished(ctx, *storeSpec, "instagram", cutoff)
+            if err != nil { log.Fatal(err) }
+            res := bulkInstagram(ctx, client, recs, *metrics)
             if err != nil { log.Fatal(err) }
             out(res)
             return
@@ -68,7 +130,9 @@ func main() {
         if *bulk {
             cutoff, err := parseSince(*since)
             if err != nil { log.Fatal(err) }
-            res, err := bulkLinkedIn(ctx, client, *file, cutoff)
+            recs, err := readPublished(ctx, *storeSpec, "linkedin", cutoff)
+            if err != nil { log.Fatal(err) }
+            res := bulkLinkedIn(ctx, client, recs)
             if err != nil { log.Fatal(err) }
             out(res)
             return
@@ -142,58 +206,43 @@ func parseSince(s string) (time.Time, error) {
     return time.Time{}, errors.New("invalid --since; use RFC3339, YYYY-MM-DD, or duration like 72h")
 }
 
-func bulkInstagram(ctx context.Context, client *ig.Client, path string, cutoff time.Time, metrics string) (any, error) {
-    f, err := os.Open(path)
+// readPublished lists the platform's posts in the store published since
+// cutoff.
+func readPublished(ctx context.Context, spec, platform string, cutoff time.Time) ([]storage.PublishedPost, error) {
+    st, err := storage.Open(spec)
     if err != nil { return nil, err }
-    defer f.Close()
-    type rec struct {
-        Platform    string    `json:"platform"`
-        ID          string    `json:"id"`
-        Link        string    `json:"link"`
-        ContentType string    `json:"content_type"`
-        PublishedAt time.Time `json:"published_at"`
-    }
-    scanner := bufio.NewScanner(f)
+    defer st.Close()
+    return st.Query(ctx, storage.Query{Platform: platform, Since: cutoff})
+}
+
+func bulkInstagram(ctx context.Context, client *ig.Client, recs []storage.PublishedPost, metrics string) any {
     out := []map[string]any{}
     var mets []string
     if metrics != "" { mets = splitComma(metrics) }
-    for scanner.Scan() {
-        line := scanner.Bytes()
-        var r rec
-        if err := json.Unmarshal(line, &r); err != nil { continue }
-        if r.Platform != "instagram" { continue }
-        if r.PublishedAt.Before(cutoff) { continue }
-        if r.ID == "" { continue }
+    for _, r := range recs {
+        row := map[string]any{"id": r.ID, "link": r.Link, "published_at": r.PublishedAt}
         basic, err := client.GetMediaBasic(ctx, r.ID)
-        if err != nil { continue }
-        row := map[string]any{"id": r.ID, "link": r.Link, "published_at": r.PublishedAt, "basic": basic}
+        if err != nil {
+            row["error"] = err.Error()
+            out = append(out, row)
+            continue
+        }
+        row["basic"] = basic
         if len(mets) > 0 {
             ins, err := client.GetMediaInsights(ctx, r.ID, mets)
-            if err == nil { row["insights"] = ins }
+            if err != nil { row["insights_error"] = err.Error() } else { row["insights"] = ins }
         }
         out = append(out, row)
     }
-    return out, scanner.Err()
+    return out
 }
 
-func bulkLinkedIn(ctx context.Context, client *li.Client, path string, cutoff time.Time) (any, error) {
-    f, err := os.Open(path)
-    if err != nil { return nil, err }
-    defer f.Close()
-    type rec storage.PublishedPost
-    scanner := bufio.NewScanner(f)
+func bulkLinkedIn(ctx context.Context, client *li.Client, recs []storage.PublishedPost) any {
     out := []map[string]any{}
-    for scanner.Scan() {
-        line := scanner.Bytes()
-        var r rec
-        if err := json.Unmarshal(line, &r); err != nil { continue }
-        if r.Platform != "linkedin" { continue }
-        if r.PublishedAt.Before(cutoff) { continue }
-        if r.ID == "" { continue }
-        stats, err := client.GetPostStatistics(ctx, r.ID)
-        if err != nil { continue }
-        row := map[string]any{"id": r.ID, "link": r.Link, "published_at": r.PublishedAt, "stats": stats}
+    for _, r := range recs {
+        row := map[string]any{"id": r.ID, "link": r.Link, "published_at": r.PublishedAt}
+        if stats, err := client.GetPostStatistics(ctx, r.ID); err != nil { row["error"] = err.Error() } else { row["stats"] = stats }
         out = append(out, row)
     }
-    return out, scanner.Err()
+    return out
 }
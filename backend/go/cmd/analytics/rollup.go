@@ -0,0 +1,21 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+    "github.com/bitesinbyte/ferret/pkg/analytics/trends"
+)
+
+// runRollup rebuilds the trend_metrics rollups of orgID (every org when
+// empty) for the posts published since cutoff.
+func runRollup(ctx context.Context, dsn, orgID string, cutoff time.Time) (trends.Summary, error) {
+    if dsn == "" { return trends.Summary{}, errors.New("--rollup needs --database or DATABASE_URL") }
+    db, err := sql.Open("postgres", dsn)
+    if err != nil { return trends.Summary{}, err }
+    defer db.Close()
+    return trends.Run(ctx, calendarrepo.Repository{DB: db}, orgID, cutoff, time.Now())
+}
@@ -6,6 +6,8 @@ import (
     "os"
 
     "github.com/bitesinbyte/ferret/pkg/api/server"
+    appdb "github.com/bitesinbyte/ferret/pkg/db"
+    "github.com/bitesinbyte/ferret/pkg/engine/workflows"
     _ "github.com/lib/pq"
 )
 
@@ -13,6 +15,19 @@ func main() {
     port := os.Getenv("API_PORT")
     if port == "" { port = "8080" }
     s := server.New()
+    // Webhook triggers of the workflows in EOWR_TRIGGERS_DIR (cron and queue
+    // triggers run in the scheduler daemon)
+    if dir := os.Getenv("EOWR_TRIGGERS_DIR"); dir != "" {
+        h := &workflows.TriggerHost{Runs: &workflows.Scheduler{}}
+        if db, err := appdb.OpenFromEnv(); err == nil {
+            h.Runs.DB = db
+        } else {
+            log.Printf("workflow runs are not durable, db unavailable: %v", err)
+        }
+        if err := h.LoadDir(dir); err != nil { log.Fatal(err) }
+        h.Mount(s)
+        log.Printf("mounted webhook triggers from %s under /hooks", dir)
+    }
     addr := ":" + port
     log.Printf("api listening on %s", addr)
     if err := s.Run(addr); err != nil {
@@ -1,12 +1,21 @@
 package main
 
 import (
+    "context"
+    "database/sql"
+    "encoding/json"
     "errors"
+    "flag"
     "fmt"
     "os"
     "path/filepath"
+    "strings"
+    "time"
 
+    "github.com/bitesinbyte/ferret/pkg/engine/eowr"
     gen "github.com/bitesinbyte/ferret/pkg/engine/generator"
+    "github.com/bitesinbyte/ferret/pkg/engine/workflows"
+    _ "github.com/lib/pq"
 )
 
 func usage() {
@@ -14,8 +23,14 @@ func usage() {
     fmt.Println("Usage:")
     fmt.Println("  go run ./cmd/eowr compile <in.pseudo> <out.go>")
     fmt.Println("  go run ./cmd/eowr export  <in.pseudo> <out.json>")
+    fmt.Println("  go run ./cmd/eowr import  <n8n.json> <out.pseudo>")
     fmt.Println("  go run ./cmd/eowr compile-all")
     fmt.Println("  go run ./cmd/eowr export-all")
+    fmt.Println("  go run ./cmd/eowr run     <in.pseudo> [payload.json | '{...}'] [trigger]")
+    fmt.Println("  go run ./cmd/eowr lint    [in.pseudo ...]")
+    fmt.Println("  go run ./cmd/eowr runs    [-workflow name] [-status s] [-limit n]")
+    fmt.Println("  go run ./cmd/eowr runs    show <run-id> | resume <run-id>")
+    fmt.Println("With DATABASE_URL set, run records the run and its node checkpoints (see runs).")
 }
 
 func compileOne(inPath, outPath string) error {
@@ -40,6 +55,190 @@ func exportOne(inPath, outPath string) error {
     return nil
 }
 
+// importOne converts an n8n workflow to .pseudo, printing what did not map
+// cleanly, and lints the result; it reports whether the workflow can run.
+func importOne(inPath, outPath string) (bool, error) {
+    if inPath == "" || outPath == "" {
+        return false, errors.New("import requires <n8n.json> and <out.pseudo>")
+    }
+    warnings, err := gen.ImportFromN8N(inPath, outPath)
+    for _, w := range warnings {
+        fmt.Println("warning:", w)
+    }
+    if err != nil {
+        return false, err
+    }
+    fmt.Printf("Imported: %s -> %s\n", inPath, outPath)
+    return lint([]string{outPath})
+}
+
+// runOne executes a workflow in process and prints the result as JSON. With
+// DATABASE_URL set the run is durable and can be resumed with `runs resume`.
+func runOne(inPath, payload, trigger string) error {
+    in := eowr.Payload{}
+    if payload != "" {
+        raw := []byte(payload)
+        if !strings.HasPrefix(strings.TrimSpace(payload), "{") {
+            var err error
+            if raw, err = os.ReadFile(payload); err != nil {
+                return err
+            }
+        }
+        if err := json.Unmarshal(raw, &in); err != nil {
+            return fmt.Errorf("payload: %w", err)
+        }
+    }
+    var res *eowr.Result
+    var runErr error
+    if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+        db, err := sql.Open("postgres", dsn)
+        if err != nil {
+            return err
+        }
+        defer db.Close()
+        var run workflows.WorkflowRun
+        run, res, runErr = (&workflows.Scheduler{DB: db}).StartWorkflow(context.Background(), inPath, trigger, in)
+        if run.ID != "" {
+            fmt.Printf("Run %s %s\n", run.ID, run.Status)
+        }
+    } else {
+        g, err := eowr.Load(inPath, nil)
+        if err != nil {
+            return err
+        }
+        res, runErr = g.Run(context.Background(), trigger, in)
+    }
+    printResult(res)
+    return runErr
+}
+
+func printResult(res *eowr.Result) {
+    if res != nil {
+        failed := map[string]string{}
+        for name, err := range res.Failed {
+            failed[name] = err.Error()
+        }
+        out, _ := json.MarshalIndent(map[string]any{
+            "workflow": res.Workflow,
+            "output":   res.Output,
+            "skipped":  res.Skipped,
+            "failed":   failed,
+        }, "", "  ")
+        fmt.Println(string(out))
+    }
+}
+
+// runs lists workflow runs, shows one run's node checkpoints or resumes it.
+func runs(args []string) error {
+    dsn := os.Getenv("DATABASE_URL")
+    if dsn == "" {
+        return errors.New("runs needs DATABASE_URL")
+    }
+    db, err := sql.Open("postgres", dsn)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+    ctx := context.Background()
+    store := workflows.RunStore{DB: db}
+
+    if len(args) == 2 && args[0] == "show" {
+        run, err := store.Get(ctx, args[1])
+        if err != nil {
+            return err
+        }
+        printRun(run)
+        nodes, err := store.Nodes(ctx, run.ID)
+        if err != nil {
+            return err
+        }
+        fmt.Printf("\n%-28s %-10s %8s  %-20s %s\n", "NODE", "STATUS", "ATTEMPTS", "FINISHED", "ERROR")
+        for _, n := range nodes {
+            fmt.Printf("%-28s %-10s %8d  %-20s %s\n", n.Node, n.Status, n.Attempts, formatTime(n.FinishedAt), n.Error)
+        }
+        return nil
+    }
+    if len(args) == 2 && args[0] == "resume" {
+        run, res, err := (&workflows.Scheduler{DB: db}).ResumeWorkflow(ctx, args[1])
+        if run.ID != "" {
+            fmt.Printf("Run %s %s (invocation %d)\n", run.ID, run.Status, run.Invocations)
+        }
+        printResult(res)
+        return err
+    }
+
+    fs := flag.NewFlagSet("runs", flag.ExitOnError)
+    wf := fs.String("workflow", "", "only runs of this workflow")
+    status := fs.String("status", "", "only runs in this status (running, succeeded, failed)")
+    limit := fs.Int("limit", 20, "max runs to list")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    list, err := store.List(ctx, workflows.RunFilter{Workflow: *wf, Status: *status, Limit: *limit})
+    if err != nil {
+        return err
+    }
+    fmt.Printf("%-20s %-24s %-10s %4s  %-20s %-20s %s\n", "ID", "WORKFLOW", "STATUS", "INV", "STARTED", "FINISHED", "ERROR")
+    for _, r := range list {
+        fmt.Printf("%-20s %-24s %-10s %4d  %-20s %-20s %s\n", r.ID, r.Workflow, r.Status, r.Invocations,
+            formatTime(r.CreatedAt), formatTime(r.FinishedAt), firstLine(r.Error))
+    }
+    return nil
+}
+
+func printRun(r workflows.WorkflowRun) {
+    fmt.Printf("Run:         %s\n", r.ID)
+    fmt.Printf("Workflow:    %s %s (%s)\n", r.Workflow, r.Version, r.Source)
+    fmt.Printf("Status:      %s\n", r.Status)
+    fmt.Printf("Invocations: %d\n", r.Invocations)
+    fmt.Printf("Started:     %s\n", formatTime(r.CreatedAt))
+    fmt.Printf("Finished:    %s\n", formatTime(r.FinishedAt))
+    if r.Error != "" {
+        fmt.Printf("Error:       %s\n", r.Error)
+    }
+}
+
+func formatTime(t time.Time) string {
+    if t.IsZero() {
+        return "-"
+    }
+    return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+func firstLine(s string) string {
+    line, _, _ := strings.Cut(s, "\n")
+    return line
+}
+
+// lint prints file:line:col diagnostics for each file (every workflow in the
+// default directory when none are given) and reports whether any were found.
+func lint(paths []string) (bool, error) {
+    if len(paths) == 0 {
+        matches, err := filepath.Glob(filepath.Join(defaultWorkflowDir(), "*.pseudo"))
+        if err != nil {
+            return false, err
+        }
+        paths = matches
+    }
+    clean := true
+    for _, p := range paths {
+        _, err := eowr.Load(p, nil)
+        var diags gen.Diagnostics
+        switch {
+        case err == nil:
+            fmt.Println("ok", p)
+        case errors.As(err, &diags):
+            clean = false
+            for _, d := range diags {
+                fmt.Println(d.Error())
+            }
+        default:
+            return false, err
+        }
+    }
+    return clean, nil
+}
+
 func defaultWorkflowDir() string {
     return filepath.FromSlash("pkg/engine/workflows")
 }
@@ -111,6 +310,19 @@ func main() {
             fmt.Println("Error:", err)
             os.Exit(1)
         }
+    case "import":
+        if len(os.Args) < 4 {
+            usage()
+            os.Exit(2)
+        }
+        ok, err := importOne(os.Args[2], os.Args[3])
+        if err != nil {
+            fmt.Println("Error:", err)
+            os.Exit(1)
+        }
+        if !ok {
+            os.Exit(1)
+        }
     case "compile-all":
         if err := compileAll(); err != nil {
             fmt.Println("Error:", err)
@@ -121,6 +333,36 @@ func main() {
             fmt.Println("Error:", err)
             os.Exit(1)
         }
+    case "run":
+        if len(os.Args) < 3 {
+            usage()
+            os.Exit(2)
+        }
+        var payload, trigger string
+        if len(os.Args) > 3 {
+            payload = os.Args[3]
+        }
+        if len(os.Args) > 4 {
+            trigger = os.Args[4]
+        }
+        if err := runOne(os.Args[2], payload, trigger); err != nil {
+            fmt.Println("Error:", err)
+            os.Exit(1)
+        }
+    case "runs":
+        if err := runs(os.Args[2:]); err != nil {
+            fmt.Println("Error:", err)
+            os.Exit(1)
+        }
+    case "lint":
+        clean, err := lint(os.Args[2:])
+        if err != nil {
+            fmt.Println("Error:", err)
+            os.Exit(1)
+        }
+        if !clean {
+            os.Exit(1)
+        }
     default:
         usage()
         os.Exit(2)
@@ -0,0 +1,47 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "flag"
+    "log"
+    "os"
+    "time"
+
+    _ "github.com/lib/pq"
+
+    "github.com/bitesinbyte/ferret/pkg/analytics/experiments"
+)
+
+func main() {
+    var (
+        dsn        = flag.String("database", os.Getenv("DATABASE_URL"), "Postgres DSN")
+        experiment = flag.String("experiment", "", "experiment id")
+        format     = flag.String("format", experiments.FormatJSON, "output: json|csv|md")
+        metric     = flag.String("metric", experiments.MetricEngagement, "decision metric: engagement|ctr|conversion")
+        confidence = flag.Float64("confidence", 0.95, "confidence level of intervals and tests")
+        control    = flag.String("control", "", "variant id of the control arm (default: the first arm)")
+        minPosts   = flag.Int("min-posts", 5, "posts every arm needs before a decision")
+        out        = flag.String("out", "", "write the report to this file instead of stdout")
+    )
+    flag.Parse()
+    if *dsn == "" { log.Fatal("missing database DSN") }
+    if *experiment == "" { log.Fatal("missing --experiment") }
+
+    db, err := sql.Open("postgres", *dsn)
+    if err != nil { log.Fatal(err) }
+    defer db.Close()
+    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+    defer cancel()
+    r, err := experiments.Load(ctx, db, *experiment, experiments.Options{Metric: *metric, Confidence: *confidence, Control: *control, MinPosts: *minPosts})
+    if err != nil { log.Fatal(err) }
+
+    w := os.Stdout
+    if *out != "" {
+        f, err := os.Create(*out)
+        if err != nil { log.Fatal(err) }
+        defer f.Close()
+        w = f
+    }
+    if err := experiments.Write(w, r, *format); err != nil { log.Fatal(err) }
+}
@@ -1,19 +1,30 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/bitesinbyte/ferret/pkg/external"
 	"github.com/bitesinbyte/ferret/pkg/factory"
+	"io"
 	"log"
+	"os"
 	"time"
 
+	"github.com/bitesinbyte/ferret/pkg/analytics/storage"
 	"github.com/bitesinbyte/ferret/pkg/config"
 	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
 	"github.com/mmcdole/gofeed"
-    "github.com/bitesinbyte/ferret/pkg/analytics/storage"
+	_ "modernc.org/sqlite"
 )
 
 func main() {
+	dryRun := flag.Bool("dry-run", false, "write the payloads each platform would receive as JSONL instead of posting (config.json is not updated)")
+	dryRunOut := flag.String("dry-run-out", "-", "dry-run JSONL output path (- for stdout)")
+	flag.Parse()
+
 	if err := godotenv.Load(); err != nil {
 		log.Println(err)
 	}
@@ -27,12 +38,39 @@ func main() {
 		log.Fatalf("Error parsing RSS feed: %v", err)
 	}
 
+	// Progress goes to stderr in dry-run mode so stdout stays valid JSONL.
+	progress := io.Writer(os.Stdout)
+	var preview *json.Encoder
+	if *dryRun {
+		progress = os.Stderr
+		out := os.Stdout
+		if *dryRunOut != "" && *dryRunOut != "-" {
+			f, err := os.Create(*dryRunOut)
+			if err != nil {
+				log.Fatalf("Error opening dry-run output: %v", err)
+			}
+			defer f.Close()
+			out = f
+		}
+		preview = json.NewEncoder(out)
+	}
+
+	// Published IDs go to PUBLISHED_STORE (default data/published_posts.jsonl).
+	// A dry run publishes nothing, so it leaves the store alone.
+	var published storage.Store
+	if !*dryRun {
+		if published, err = storage.Open(""); err != nil {
+			log.Fatalf("Error opening published posts store: %v", err)
+		}
+		defer published.Close()
+	}
+
 	var anythingProcessedToday = false
 
 	// Check for new posts and post to Mastodon and Twitter
 	for _, item := range feed.Items {
 		if item.PublishedParsed.After(configData.LastRunTime) {
-			fmt.Printf("Processing %s", item.Title)
+			fmt.Fprintf(progress, "Processing %s", item.Title)
 
 			// Create Hashtags
 			hashTags := ""
@@ -41,22 +79,35 @@ func main() {
 			}
 
 			for _, social := range configData.Socials {
-				socialClient := factory.CreateSocialPoster(social)
+				socialClient, err := factory.CreateSocialPoster(social)
+				if err != nil {
+					log.Fatalf("Error creating poster for %s: %v", social, err)
+				}
 				post := external.Post{
 					Title:       item.Title,
 					Link:        item.Link,
 					HashTags:    hashTags,
 					Description: item.Description,
 				}
+				if preview != nil {
+					pv := external.PreviewPost(context.Background(), configData, social, external.CredentialsFromEnv(social), post)
+					if err := preview.Encode(pv); err != nil {
+						log.Fatalf("Error writing preview: %v", err)
+					}
+					continue
+				}
 				// If poster supports IDs, capture and store for analytics
 				if pwid, ok := socialClient.(external.PosterWithID); ok {
 					id, err := pwid.PostWithID(configData, post)
 					if err != nil { log.Fatalf("Error posting to %s: %v", social, err) }
-					// Persist for analytics
-					_ = storage.AppendPublishedPost(storage.PublishedPost{Platform: social, ID: id, Link: item.Link, ContentType: "post", PublishedAt: time.Now()})
+					// Persist for analytics; the post is out, so a failed write is only logged.
+					rec := storage.PublishedPost{Platform: social, ID: id, Link: item.Link, ContentType: "post", PublishedAt: time.Now()}
+					if err := published.Append(context.Background(), rec); err != nil {
+						log.Printf("Error recording %s post %s: %v", social, id, err)
+					}
 					continue
 				}
-				err := socialClient.Post(configData, post)
+				err = socialClient.Post(configData, post)
 				if err != nil {
 					log.Fatalf("Error posting to %s: %v", social, err)
 				}
@@ -65,10 +116,10 @@ func main() {
 		}
 	}
 
-	if anythingProcessedToday {
+	if anythingProcessedToday && !*dryRun {
 		// Update last run time
 		configData.LastRunTime = time.Now()
 		config.SaveConfig("config.json", configData)
 	}
-	fmt.Printf("Done")
+	fmt.Fprintf(progress, "Done")
 }
@@ -3,15 +3,19 @@ package main
 import (
     "context"
     "database/sql"
+    "errors"
     "flag"
+    "fmt"
     "log"
     "os"
+    "strings"
     "time"
 
     _ "github.com/lib/pq"
 
     "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
     generator "github.com/bitesinbyte/ferret/pkg/engine/generator"
+    "github.com/bitesinbyte/ferret/pkg/external"
 )
 
 func main() {
@@ -21,13 +25,62 @@ func main() {
         dsn          = flag.String("database", os.Getenv("DATABASE_URL"), "Postgres DSN")
         spacing      = flag.Duration("spacing", 2*time.Hour, "min spacing per platform")
         start        = flag.Duration("start-offset", 0, "offset from now for first slot")
+        org          = flag.String("org", os.Getenv("ORG_ID"), "organization id (owns the rows; selects ICP timezone and existing schedule)")
+        tz           = flag.String("timezone", "", "IANA time zone for windows and quiet hours (default: the org's ICP timezone, then UTC)")
+        platforms    = flag.String("platforms", "linkedin,twitter", "comma-separated platforms")
+        perDay       = flag.Int("per-day", 10, "max posts per platform per local day")
+        quiet        = flag.String("quiet", "", "comma-separated local quiet hours, e.g. 22:00-07:00")
+        bandit       = flag.String("bandit", "", "variant selection over post_outcomes: thompson|ucb (default: control + first variant)")
+        metric       = flag.String("bandit-metric", generator.RewardEngagement, "bandit reward: engagement|ctr|conversion")
+        experiment   = flag.String("experiment", "", "experiment id: only its arms compete and posts are linked to their arm")
+        perTopic     = flag.Int("variants-per-topic", 2, "variants scheduled per topic")
+        updateOnly   = flag.Bool("update-weights", false, "only update the experiment's arm weights from post_outcomes (cmd/analytics --collect does this for running experiments)")
+        generate     = flag.Int("generate", 0, "write this many LLM variants for topics without variants (LLM_BASE_URL, LLM_API_KEY, LLM_MODEL)")
+        icp          = flag.String("icp", "", "ICP profile whose brand voice, guidelines and pillars --generate uses (default: the org's Default profile)")
+        recycle      = flag.Int("recycle", 0, "also re-queue up to this many top published posts (evergreen recycling)")
+        recyclePct   = flag.Float64("recycle-percentile", generator.DefaultRecyclePercentile, "share of the platform's posts a recycled post must beat on --bandit-metric")
+        recycleOnly  = flag.Bool("recycle-only", false, "only recycle; skip planning trends")
     )
+    reshare := map[string]time.Duration{}
+    flag.Func("reshare-interval", "minimum time between shares of a post as platform=duration, e.g. twitter=336h (repeatable; default 720h)", func(v string) error {
+        p, d, ok := strings.Cut(v, "=")
+        if !ok { return fmt.Errorf("want platform=duration, got %q", v) }
+        dur, err := time.ParseDuration(d)
+        if err != nil { return err }
+        name, err := external.CanonicalPlatform(p)
+        if err != nil { return err }
+        reshare[name] = dur
+        return nil
+    })
+    windows := map[string][]string{}
+    flag.Func("window", "posting window as platform=HH:MM-HH:MM (repeatable)", func(v string) error {
+        p, w, ok := strings.Cut(v, "=")
+        if !ok { return fmt.Errorf("want platform=HH:MM-HH:MM, got %q", v) }
+        windows[p] = append(windows[p], w)
+        return nil
+    })
     flag.Parse()
     if *dsn == "" { log.Fatal("missing database DSN") }
+    if *org == "" { log.Fatal("missing organization (set --org or ORG_ID)") }
 
-    trends, err := generator.LoadTrends(*trendsFile)
-    if err != nil { log.Fatal(err) }
+    if *updateOnly {
+        if *experiment == "" { log.Fatal("--update-weights needs --experiment") }
+        db, err := sql.Open("postgres", *dsn)
+        if err != nil { log.Fatal(err) }
+        defer db.Close()
+        weights, err := generator.UpdateArmWeights(context.Background(), calendarrepo.Repository{DB: db}, *org, *experiment, *metric, nil)
+        if err != nil { log.Fatal(err) }
+        for id, w := range weights { fmt.Printf("%s\t%.4f\n", id, w) }
+        return
+    }
+
+    var trends []generator.Trend
+    var err error
+    if !*recycleOnly {
+        if trends, err = generator.LoadTrends(*trendsFile); err != nil { log.Fatal(err) }
+    }
     variants, err := generator.LoadVariants(*variantsFile)
+    if errors.Is(err, os.ErrNotExist) && (*generate > 0 || *recycleOnly) { variants, err = map[string][]generator.Variant{}, nil }
     if err != nil { log.Fatal(err) }
 
     db, err := sql.Open("postgres", *dsn)
@@ -35,14 +88,59 @@ func main() {
     defer db.Close()
     repo := calendarrepo.Repository{DB: db}
 
+    if *generate > 0 {
+        if err := generateVariants(context.Background(), &repo, *org, *icp, splitList(*platforms), *generate, trends, variants); err != nil { log.Fatal(err) }
+    }
+
     in := generator.PlanInput{
         Trends:    trends,
         Variants:  variants,
-        Platforms: []string{"linkedin", "twitter"},
+        Platforms: splitList(*platforms),
         StartAt:   time.Now().UTC().Add(*start),
         Spacing:   *spacing,
-        PerDayLimit: 10,
+        PerDayLimit: *perDay,
+        OrgID:      *org,
+        Timezone:   *tz,
+        Windows:    windows,
+        QuietHours: splitList(*quiet),
+        Bandit:           *bandit,
+        BanditMetric:     *metric,
+        ExperimentID:     *experiment,
+        VariantsPerTopic: *perTopic,
+    }
+
+    if !*recycleOnly {
+        if err := generator.PlanAndSchedule(context.Background(), repo, in); err != nil { log.Fatal(err) }
+    }
+    if *recycle > 0 || *recycleOnly {
+        opts := generator.RecycleOptions{Percentile: *recyclePct, Metric: *metric, MinInterval: reshare, MaxPosts: *recycle}
+        n, err := generator.RecycleAndSchedule(context.Background(), repo, in, opts)
+        if err != nil { log.Fatal(err) }
+        log.Printf("recycled %d posts", n)
     }
+}
 
-    if err := generator.PlanAndSchedule(context.Background(), repo, in); err != nil { log.Fatal(err) }
+func generateVariants(ctx context.Context, repo *calendarrepo.Repository, org, icp string, platforms []string, n int, trends []generator.Trend, variants map[string][]generator.Variant) error {
+    g, err := generator.NewLLMGeneratorFromEnv(repo)
+    if err != nil { return err }
+    brand, err := repo.ICPBrand(ctx, org, icp)
+    if err != nil && !(errors.Is(err, sql.ErrNoRows) && icp == "") { return fmt.Errorf("load icp: %w", err) }
+    platform := ""
+    if len(platforms) == 1 { platform = platforms[0] }
+    for _, t := range trends {
+        if len(variants[t.Topic]) > 0 { continue }
+        res, err := g.GenerateCopy(ctx, generator.CopyRequest{OrgID: org, Topic: t.Topic, Platform: platform, Brand: brand, Variants: n})
+        if err != nil { return fmt.Errorf("topic %q: %w", t.Topic, err) }
+        variants[t.Topic] = res.PlanVariants()
+        log.Printf("generated %d variants for %q (generation %s)", len(res.Variants), t.Topic, res.GenerationID)
+    }
+    return nil
+}
+
+func splitList(s string) []string {
+    var out []string
+    for _, v := range strings.Split(s, ",") {
+        if v = strings.TrimSpace(v); v != "" { out = append(out, v) }
+    }
+    return out
 }
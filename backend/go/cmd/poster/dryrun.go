@@ -0,0 +1,63 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "io"
+    "log"
+    "os"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/accounts"
+    "github.com/bitesinbyte/ferret/pkg/calendar"
+    "github.com/bitesinbyte/ferret/pkg/config"
+    "github.com/bitesinbyte/ferret/pkg/engine/workers"
+    "github.com/bitesinbyte/ferret/pkg/external"
+)
+
+// runDryRun writes one external.Preview per row as JSONL: the exact requests
+// each platform client would send, secrets redacted. Nothing is posted and
+// scheduled_posts is not touched; db (optional) is only read to resolve
+// account credentials.
+func runDryRun(ctx context.Context, rows []calendar.ScheduledPostRow, cfg config.Config, db *sql.DB, out io.Writer) error {
+    var src workers.CredentialSource
+    if db != nil { src = accounts.Store{DB: db} }
+    enc := json.NewEncoder(out)
+    for _, r := range rows {
+        creds, err := workers.CredentialsFor(ctx, src, r, time.Now().UTC())
+        pv := external.Preview{Platform: string(r.Platform)}
+        if err != nil {
+            pv.Error = err.Error()
+        } else {
+            pv = external.PreviewPost(ctx, cfg, string(r.Platform), creds, workers.PostFromRow(r))
+        }
+        pv.RowID = r.ID
+        if err := enc.Encode(pv); err != nil { return err }
+    }
+    return nil
+}
+
+// openDryRunOut opens path for the dry-run JSONL ("-" means stdout).
+func openDryRunOut(path string) (io.WriteCloser, error) {
+    if path == "" || path == "-" { return nopWriteCloser{os.Stdout}, nil }
+    return os.Create(path)
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func dryRunMain(ctx context.Context, rows []calendar.ScheduledPostRow, cfg config.Config, dsn, outPath string) {
+    var db *sql.DB
+    if dsn != "" {
+        d, err := sql.Open("postgres", dsn)
+        if err != nil { log.Fatal(err) }
+        defer d.Close()
+        db = d
+    }
+    out, err := openDryRunOut(outPath)
+    if err != nil { log.Fatal(err) }
+    defer out.Close()
+    if err := runDryRun(ctx, rows, cfg, db, out); err != nil { log.Fatal(err) }
+}
@@ -4,7 +4,6 @@ import (
     "context"
     "database/sql"
     "encoding/json"
-    "errors"
     "flag"
     "fmt"
     "io"
@@ -15,23 +14,28 @@ import (
 
     _ "github.com/lib/pq"
 
+    "github.com/bitesinbyte/ferret/pkg/accounts"
     "github.com/bitesinbyte/ferret/pkg/calendar"
     "github.com/bitesinbyte/ferret/pkg/config"
     "github.com/bitesinbyte/ferret/pkg/external"
     "github.com/bitesinbyte/ferret/pkg/engine/metrics"
     "github.com/bitesinbyte/ferret/pkg/engine/telemetry"
-    "strconv"
     "github.com/bitesinbyte/ferret/pkg/engine/cache"
     "github.com/bitesinbyte/ferret/pkg/engine/queue"
+    "github.com/bitesinbyte/ferret/pkg/engine/ratelimit"
+    "github.com/bitesinbyte/ferret/pkg/engine/workers"
+    "github.com/bitesinbyte/ferret/pkg/ledger"
 )
 
 func main() {
     input := flag.String("input", "go/due_posts.json", "path to JSON array of due posts")
     dsn := flag.String("database", os.Getenv("DATABASE_URL"), "Postgres DSN (or set DATABASE_URL)")
     cfgPath := flag.String("config", "config.json", "path to config.json")
+    dryRun := flag.Bool("dry-run", false, "write the payloads each platform would receive as JSONL instead of posting")
+    dryRunOut := flag.String("dry-run-out", "-", "dry-run JSONL output path (- for stdout)")
     flag.Parse()
 
-    if *dsn == "" { log.Fatal("missing database DSN (set --database or DATABASE_URL)") }
+    if *dsn == "" && !*dryRun { log.Fatal("missing database DSN (set --database or DATABASE_URL)") }
 
     // Read input file
     f, err := os.Open(*input)
@@ -47,6 +51,10 @@ func main() {
     }
 
     cfg := config.LoadConfig(*cfgPath)
+    if *dryRun {
+        dryRunMain(context.Background(), rows, cfg, *dsn, *dryRunOut)
+        return
+    }
 
     db, err := sql.Open("postgres", *dsn)
     if err != nil { log.Fatal(err) }
@@ -58,26 +66,37 @@ func main() {
     postedCounter := metrics.NewCounter("poster_posted_total")
     failedCounter := metrics.NewCounter("poster_failed_total")
     postLatency := metrics.NewHistogram("poster_post_seconds")
-    limiter := newPlatformLimiters(loadRatesFromEnv(), time.Second)
+    // Rows with a social_account_id post as that account; others use env
+    // credentials. The publish ledger keeps retries and reruns from posting twice.
+    worker := &workers.PosterWorker{
+        DB:       workers.CalendarDB{SQL: db},
+        Accounts: accounts.Store{DB: db},
+        Ledger:   ledger.Store{DB: db},
+    }
 
-    // Optional Valkey cache for dedupe/safety
+    // Optional Valkey: shares rate limit budgets between poster replicas and
+    // stops two runners working the same row at once (the ledger, not this
+    // key, is what prevents duplicate posts)
     var vcache *cache.Valkey
+    var limits ratelimit.Store
     if vc, err := cache.NewValkey(cache.ValkeyConfig{}); err == nil {
         _ = vc.Ping()
         vcache = vc
         defer vcache.Close()
+        limits = ratelimit.ValkeyStore{Valkey: vc}
     } else {
         log.Printf("valkey disabled: %v", err)
     }
+    worker.Limits = workers.PlatformLimiter(limits)
 
     for _, r := range rows {
-        platform := strings.ToLower(string(r.Platform))
-        poster, supported := createPoster(platform)
+        spec, supported := external.LookupPlatform(string(r.Platform))
         if !supported {
-            markFailed(ctx, db, r.ID, fmt.Sprintf("unsupported platform: %s", platform))
+            markFailed(ctx, db, r.ID, fmt.Sprintf("unsupported platform: %s", r.Platform))
             failedCounter.Inc(1)
             continue
         }
+        platform := spec.Name
         // Optional dedupe: skip if key exists (another runner is processing)
         if vcache != nil {
             k := "poster:processing:" + r.ID
@@ -87,34 +106,19 @@ func main() {
             }
             _ = vcache.Set(k, "1", 15*time.Minute)
         }
-        // Build the external.Post
-        title := firstNonEmpty(r.ContentTitle.String, r.CampaignName)
-        hashtags := r.Hashtags.String
-        link := r.ContentURL.String
-        post := external.Post{Title: title, Link: link, HashTags: hashtags, Description: ""}
-
         publishedAt := time.Now().UTC()
         // Optional Pulsar events emitter
         var prod *queue.PulsarProducer
         if pc, cfg, err := queue.NewPulsarClientFromEnv(); err == nil && cfg.ServiceURL != "" {
             if p, err := pc.NewProducer(cfg.Topic); err == nil { prod = p; defer prod.Close() }
         }
-        // If poster supports ID, capture and update
         var perr error
         postLatency.Time(func() {
             var end func()
             ctx, end = telemetry.StartSpan(ctx, "poster.publish", map[string]string{"platform": platform})
-            // pace
-            limiter.Take(platform)
-            perr = doWithRetry(func() error {
-                if pwid, ok := poster.(external.PosterWithID); ok {
-                    id, err := pwid.PostWithID(cfg, post)
-                    if err != nil { return err }
-                    return calendar.UpdatePostStatus(ctx, db, r.ID, calendar.StatusPublished, &id, &publishedAt, nil)
-                }
-                if err := poster.Post(cfg, post); err != nil { return err }
-                return calendar.UpdatePostStatus(ctx, db, r.ID, calendar.StatusPublished, nil, &publishedAt, nil)
-            })
+            // Each retry waits for rate limit budget and goes back through the ledger, which reconciles an
+            // attempt whose outcome is unknown before publishing again.
+            perr = doWithRetry(func() error { return worker.Post(ctx, r, cfg) })
             end()
         })
         if perr != nil {
@@ -155,25 +159,6 @@ func main() {
     }
 }
 
-func createPoster(platform string) (external.Poster, bool) {
-    switch platform {
-    case "linkedin":
-        return external.Linkedin{}, true
-    case "mastodon":
-        return external.Mastodon{}, true
-    case "twitter":
-        return external.Twitter{}, true
-    case "facebook":
-        return external.Facebook{}, true
-    case "thread":
-        return external.Thread{}, true
-    case "instagram":
-        return external.Instagram{}, true
-    default:
-        return nil, false
-    }
-}
-
 func markFailed(ctx context.Context, db *sql.DB, id, msg string) {
     meta := map[string]any{"error": msg}
     b, _ := json.Marshal(meta)
@@ -187,85 +172,19 @@ func firstNonEmpty(val ...string) string {
     return ""
 }
 
-// Retry helper with exponential backoff and jitter.
+// Retry helper driven by external.PostError: permanent errors stop at once,
+// rate limits wait for the platform's reset, other transient errors back off.
 func doWithRetry(fn func() error) error {
-    backoff := 500 * time.Millisecond
+    var err error
     for attempt := 0; attempt < 5; attempt++ {
-        if err := fn(); err != nil {
-            if !isRetryable(err) { return err }
-            time.Sleep(backoff + time.Duration(attempt*100)*time.Millisecond)
-            if backoff < 5*time.Second { backoff *= 2 }
-            continue
-        }
-        return nil
-    }
-    return errors.New("exhausted retries")
-}
-
-func isRetryable(err error) bool {
-    // naive mapping; could inspect error strings or types from platform clients
-    s := strings.ToLower(err.Error())
-    return strings.Contains(s, "429") || strings.Contains(s, "rate") || strings.Contains(s, "timeout") || strings.Contains(s, "temporar")
-}
-
-// simple token bucket: allow n tokens per window.
-type rateLimiter struct {
-    ch chan struct{}
-}
-
-func newRateLimiter(n int, window time.Duration) *rateLimiter {
-    if n <= 0 { n = 1 }
-    rl := &rateLimiter{ch: make(chan struct{}, n)}
-    // fill
-    for i := 0; i < n; i++ { rl.ch <- struct{}{} }
-    go func() {
-        ticker := time.NewTicker(window)
-        defer ticker.Stop()
-        for range ticker.C {
-            for len(rl.ch) < n { rl.ch <- struct{}{} }
-        }
-    }()
-    return rl
-}
-
-func (r *rateLimiter) Take() { <-r.ch }
-
-// per-platform limiter
-type platformLimiters struct{
-    m map[string]*rateLimiter
-    def *rateLimiter
-}
-
-func newPlatformLimiters(per map[string]int, window time.Duration) *platformLimiters {
-    m := make(map[string]*rateLimiter, len(per))
-    for k, n := range per {
-        if n <= 0 { n = 1 }
-        m[strings.ToLower(k)] = newRateLimiter(n, window)
+        if err = fn(); err == nil { return nil }
+        wait, retry := external.RetryBackoff(err, attempt)
+        if !retry { return err }
+        if wait > maxRetryWait { return err }
+        time.Sleep(wait + time.Duration(attempt*100)*time.Millisecond)
     }
-    return &platformLimiters{m: m, def: newRateLimiter(2, window)}
-}
-func (p *platformLimiters) Take(platform string) {
-    if rl, ok := p.m[strings.ToLower(platform)]; ok { rl.Take(); return }
-    p.def.Take()
+    return fmt.Errorf("exhausted retries: %w", err)
 }
 
-// read poster rates from env, like POSTER_RATE_LINKEDIN=2, POSTER_RATE_INSTAGRAM=1
-func loadRatesFromEnv() map[string]int {
-    mapping := map[string]string{
-        "linkedin":  os.Getenv("POSTER_RATE_LINKEDIN"),
-        "twitter":   os.Getenv("POSTER_RATE_TWITTER"),
-        "facebook":  os.Getenv("POSTER_RATE_FACEBOOK"),
-        "thread":    os.Getenv("POSTER_RATE_THREAD"),
-        "instagram": os.Getenv("POSTER_RATE_INSTAGRAM"),
-        "mastodon":  os.Getenv("POSTER_RATE_MASTODON"),
-    }
-    out := map[string]int{}
-    for k, v := range mapping {
-        if v == "" { continue }
-        if n, err := strconv.Atoi(v); err == nil && n > 0 { out[k] = n }
-    }
-    // defaults if unset
-    if _, ok := out["linkedin"]; !ok { out["linkedin"] = 1 }
-    if _, ok := out["instagram"]; !ok { out["instagram"] = 1 }
-    return out
-}
+// maxRetryWait caps how long a single retry may sleep; longer rate limits fail the row.
+const maxRetryWait = 2 * time.Minute
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/bitesinbyte/ferret/pkg/api/postiz"
+	"github.com/bitesinbyte/ferret/pkg/calendar"
+	"github.com/bitesinbyte/ferret/pkg/external"
+)
+
+// runDryRun renders the Postiz requests each mapped row would produce as
+// JSONL (external.Preview records, API key redacted). Nothing is sent,
+// scheduled_posts is untouched and the input file is not rewritten.
+func runDryRun(rows []calendar.ScheduledPostRow, integrations map[string]string, opts []postiz.Option, outPath string) error {
+	dry := &external.DryRun{Respond: postizDryRunResponse}
+	client := postiz.NewClient(append(opts, postiz.WithHTTPClient(dry.Client()))...)
+
+	var out io.Writer = os.Stdout
+	if outPath != "" && outPath != "-" {
+		f, err := os.Create(outPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	ctx := context.Background()
+	cache := make(map[string]postiz.MediaDto)
+	for _, row := range rows {
+		meta := decodeMetadata(row.Metadata)
+		integrationID, reason := resolveIntegration(row, meta, integrations)
+		if integrationID == "" {
+			log.Printf("[postizpublisher] dry-run skip %s: %s", row.ID, reason)
+			continue
+		}
+		pv := external.Preview{
+			RowID:     row.ID,
+			Platform:  string(row.Platform),
+			AccountID: "postiz:" + integrationID,
+			Text:      composeContent(row, meta),
+			HashTags:  nullString(row.Hashtags),
+			Media:     mediaURLs(meta),
+		}
+		if _, _, _, err := submitRow(ctx, client, row, integrationID, meta, cache); err != nil {
+			pv.Error = err.Error()
+		}
+		pv.Requests = dry.Take()
+		if err := enc.Encode(pv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postizDryRunResponse answers the Postiz endpoints submitRow calls.
+func postizDryRunResponse(req *http.Request, seq int) *http.Response {
+	id := fmt.Sprintf("dryrun-%d", seq)
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/upload-from-url"):
+		return external.JSONResponse(http.StatusOK, postiz.UploadResponse{ID: id, Path: "https://dry-run.invalid/media/" + id})
+	case strings.HasSuffix(req.URL.Path, "/posts"):
+		return external.JSONResponse(http.StatusOK, []postiz.CreateUpdateResult{{PostID: id}})
+	}
+	return nil
+}
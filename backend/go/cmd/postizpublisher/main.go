@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -24,15 +25,17 @@ func main() {
 		dsn         = flag.String("database", os.Getenv("DATABASE_URL"), "Postgres DSN or set DATABASE_URL")
 		mapPath     = flag.String("integration-map", "", "Optional path to JSON mapping {\"platform\":\"integrationId\"}")
 		skipRewrite = flag.Bool("keep-input", false, "Do not rewrite input file with remaining posts")
+		dryRun      = flag.Bool("dry-run", false, "Write the Postiz requests as JSONL instead of sending them (no DB writes, input kept)")
+		dryRunOut   = flag.String("dry-run-out", "-", "Dry-run JSONL output path (- for stdout)")
 	)
 	flag.Parse()
 
 	apiKey := strings.TrimSpace(os.Getenv("POSTIZ_API_KEY"))
-	if apiKey == "" {
+	if apiKey == "" && !*dryRun {
 		log.Println("[postizpublisher] POSTIZ_API_KEY not set; skipping.")
 		return
 	}
-	if *dsn == "" {
+	if *dsn == "" && !*dryRun {
 		log.Fatal("missing database DSN (set --database or DATABASE_URL)")
 	}
 
@@ -54,6 +57,12 @@ func main() {
 	if base := strings.TrimSpace(os.Getenv("POSTIZ_BASE_URL")); base != "" {
 		opts = append(opts, postiz.WithBaseURL(base))
 	}
+	if *dryRun {
+		if err := runDryRun(rows, integrationMap, opts, *dryRunOut); err != nil {
+			log.Fatalf("dry run: %v", err)
+		}
+		return
+	}
 	client := postiz.NewClient(opts...)
 
 	db, err := sql.Open("postgres", *dsn)
@@ -100,46 +109,12 @@ func main() {
 }
 
 func handleRow(ctx context.Context, client *postiz.Client, db *sql.DB, row calendar.ScheduledPostRow, integrationID string, meta map[string]any, cache map[string]postiz.MediaDto) bool {
-	content := composeContent(row, meta)
-	if content == "" {
-		markFailed(ctx, db, row.ID, "empty content")
-		return false
-	}
-
-	mediaInputs, err := gatherMedia(ctx, client, meta, cache)
-	if err != nil {
-		markFailed(ctx, db, row.ID, fmt.Sprintf("media upload failed: %v", err))
-		return false
-	}
-
-	postType, schedule := determinePostType(row.ScheduledAt)
-	req := postiz.CreateUpdatePostRequest{
-		Type: postType,
-		Date: schedule.Format(time.RFC3339),
-		Posts: []postiz.PostInput{
-			{
-				Integration: postiz.IntegrationInput{ID: integrationID},
-				Value: []postiz.PostContent{
-					{
-						Content: content,
-						Image:   mediaInputs,
-					},
-				},
-			},
-		},
-	}
-
-	res, err := client.Posts.CreateOrUpdate(ctx, req)
+	postID, postType, mediaInputs, err := submitRow(ctx, client, row, integrationID, meta, cache)
 	if err != nil {
 		markFailed(ctx, db, row.ID, err.Error())
 		return false
 	}
 
-	var postID string
-	if len(res) > 0 {
-		postID = res[0].PostID
-	}
-
 	updates := map[string]any{
 		"postiz": map[string]any{
 			"post_id":      postID,
@@ -174,6 +149,48 @@ func handleRow(ctx context.Context, client *postiz.Client, db *sql.DB, row calen
 	return true
 }
 
+// submitRow uploads the row's media and creates the Postiz post, returning the
+// Postiz post ID, the post type ("now" or "schedule") and the uploaded media.
+func submitRow(ctx context.Context, client *postiz.Client, row calendar.ScheduledPostRow, integrationID string, meta map[string]any, cache map[string]postiz.MediaDto) (string, string, []postiz.MediaDto, error) {
+	content := composeContent(row, meta)
+	if content == "" {
+		return "", "", nil, errors.New("empty content")
+	}
+
+	mediaInputs, err := gatherMedia(ctx, client, meta, cache)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("media upload failed: %w", err)
+	}
+
+	postType, schedule := determinePostType(row.ScheduledAt)
+	req := postiz.CreateUpdatePostRequest{
+		Type: postType,
+		Date: schedule.Format(time.RFC3339),
+		Posts: []postiz.PostInput{
+			{
+				Integration: postiz.IntegrationInput{ID: integrationID},
+				Value: []postiz.PostContent{
+					{
+						Content: content,
+						Image:   mediaInputs,
+					},
+				},
+			},
+		},
+	}
+
+	res, err := client.Posts.CreateOrUpdate(ctx, req)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var postID string
+	if len(res) > 0 {
+		postID = res[0].PostID
+	}
+	return postID, postType, mediaInputs, nil
+}
+
 func loadRows(path string) ([]calendar.ScheduledPostRow, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
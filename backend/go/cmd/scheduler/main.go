@@ -8,21 +8,38 @@ import (
     "fmt"
     "log"
     "os"
+    "os/signal"
+    "syscall"
     "time"
 
     _ "github.com/lib/pq"
 
     "github.com/bitesinbyte/ferret/pkg/calendar"
+    "github.com/bitesinbyte/ferret/pkg/config"
+    "github.com/bitesinbyte/ferret/pkg/engine/cache"
     "github.com/bitesinbyte/ferret/pkg/engine/metrics"
+    "github.com/bitesinbyte/ferret/pkg/engine/ratelimit"
     "github.com/bitesinbyte/ferret/pkg/engine/telemetry"
     "github.com/bitesinbyte/ferret/pkg/engine/queue"
+    "github.com/bitesinbyte/ferret/pkg/engine/workflows"
 )
 
 func main() {
     within := flag.Duration("within", 15*time.Minute, "time window to fetch due posts (e.g., 15m, 1h)")
     dsn := flag.String("database", os.Getenv("DATABASE_URL"), "Postgres DSN (or set DATABASE_URL)")
     jsonArray := flag.Bool("json-array", false, "output as a single JSON array instead of JSONL")
+    daemon := flag.Bool("daemon", false, "run continuously: claim and publish due posts in this process")
+    interval := flag.Duration("interval", 30*time.Second, "daemon poll interval")
+    concurrency := flag.Int("workers", 4, "daemon publishing concurrency")
+    limit := flag.Int("limit", 50, "max posts claimed per poll")
+    cfgPath := flag.String("config", "config.json", "path to config.json (daemon mode)")
+    lease := flag.Duration("lease", calendar.DefaultLeaseTTL, "claim lease; expired claims are reaped back to scheduled")
+    maxAttempts := flag.Int("max-attempts", 3, "claims allowed before the reaper marks a post failed")
+    lookback := flag.Duration("lookback", calendar.DefaultClaimLookback, "also claim posts up to this overdue, e.g. reaped ones (0: only posts not yet due)")
+    triggers := flag.String("triggers", "", "daemon: directory of .pseudo workflows whose cron and queue triggers to run")
     flag.Parse()
+    // Scheduler treats 0 as its default and a negative lookback as none.
+    if *lookback == 0 { *lookback = -1 }
 
     if *dsn == "" {
         log.Fatal("missing database DSN (set --database or DATABASE_URL)")
@@ -31,6 +48,11 @@ func main() {
     if err != nil { log.Fatal(err) }
     defer db.Close()
 
+    if *daemon {
+        runDaemon(db, *cfgPath, *triggers, *interval, *within, *limit, *concurrency, *lease, *maxAttempts, *lookback)
+        return
+    }
+
     ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
     ctx = telemetry.InitFromEnv(ctx)
     defer cancel()
@@ -43,11 +65,13 @@ func main() {
         if topic == "" { topic = "persistent://public/default/schedule-events" }
         if p, err := pc.NewProducer(topic); err == nil { prod = p; defer prod.Close() }
     }
+    sched := &workflows.Scheduler{DB: db, LeaseTTL: *lease, MaxAttempts: *maxAttempts, Lookback: *lookback}
+    if _, _, err := sched.Reap(ctx); err != nil { log.Printf("reap failed: %v", err) }
     var rows []calendar.ScheduledPostRow
     claimTimer.Time(func() {
         var end func()
         ctx, end = telemetry.StartSpan(ctx, "scheduler.claim", map[string]string{"unit":"post"})
-        rows, err = calendar.FetchAndClaimDuePosts(ctx, db, *within, 50)
+        rows, err = sched.Claim(ctx, *within, *limit)
         end()
     })
     if err != nil { log.Fatal(err) }
@@ -82,3 +106,53 @@ func main() {
         log.Printf("metrics counters=%v gauges=%v histo_keys=%d", c, g, len(h))
     }
 }
+
+// runDaemon polls, claims and publishes until SIGINT/SIGTERM, then drains.
+// With a triggers directory it also fires the workflows' cron and queue
+// triggers as durable runs.
+func runDaemon(db *sql.DB, cfgPath, triggers string, interval, within time.Duration, limit, concurrency int, lease time.Duration, maxAttempts int, lookback time.Duration) {
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+    ctx = telemetry.InitFromEnv(ctx)
+
+    s := &workflows.Scheduler{
+        DB:          db,
+        Config:      config.LoadConfig(cfgPath),
+        Interval:    interval,
+        Within:      within,
+        BatchSize:   limit,
+        Concurrency: concurrency,
+        LeaseTTL:    lease,
+        MaxAttempts: maxAttempts,
+        Lookback:    lookback,
+    }
+    // Share rate limit budgets with other runners when Valkey is configured
+    if os.Getenv("VALKEY_ADDR") != "" {
+        if vc, err := cache.NewValkey(cache.ValkeyConfig{}); err == nil {
+            defer vc.Close()
+            s.RateLimits = ratelimit.ValkeyStore{Valkey: vc}
+        } else {
+            log.Printf("valkey disabled, rate limits are per process: %v", err)
+        }
+    }
+    done := make(chan struct{})
+    if triggers != "" {
+        h := &workflows.TriggerHost{Runs: s}
+        if err := h.LoadDir(triggers); err != nil { log.Fatal(err) }
+        if pc, _, err := queue.NewPulsarClientFromEnv(); err == nil {
+            h.Queue = pc
+        }
+        log.Printf("scheduler daemon: %d workflow triggers from %s", len(h.Bindings()), triggers)
+        go func() {
+            defer close(done)
+            if err := h.Run(ctx); err != nil { log.Fatal(err) }
+        }()
+    } else {
+        close(done)
+    }
+    log.Printf("scheduler daemon: interval=%s within=%s limit=%d workers=%d", interval, within, limit, concurrency)
+    if err := s.Run(ctx); err != nil { log.Fatal(err) }
+    <-done
+    c, g, _ := metrics.Snapshot()
+    log.Printf("scheduler daemon stopped: counters=%v gauges=%v", c, g)
+}
@@ -0,0 +1,98 @@
+package accounts
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/external"
+)
+
+// ErrNotFound is returned when no social account has the given id.
+var ErrNotFound = errors.New("accounts: social account not found")
+
+// Store resolves posting credentials for social_accounts rows. Tokens come
+// from the newest matching oauth_accounts row (provider = platform,
+// provider_user_id = external_id); auth_meta supplies account settings such as
+// instance_url and, for api_key accounts, the tokens themselves.
+type Store struct {
+    DB *sql.DB
+}
+
+// Credentials loads the credential bundle for social account id. App-level
+// keys (Twitter consumer key/secret) fall back to env since they are shared
+// by every account; user tokens never do, so an account cannot post as the
+// env account by accident.
+func (s Store) Credentials(ctx context.Context, id string) (external.Credentials, error) {
+    const q = `
+SELECT sa.id, sa.platform, COALESCE(sa.external_id, ''), sa.auth_meta,
+       oa.access_token, oa.refresh_token, oa.token_secret, oa.expires_at
+FROM social_accounts sa
+LEFT JOIN LATERAL (
+    SELECT access_token, refresh_token, token_secret, expires_at
+    FROM oauth_accounts
+    WHERE provider = sa.platform AND provider_user_id = sa.external_id
+    ORDER BY updated_at DESC
+    LIMIT 1
+) oa ON TRUE
+WHERE sa.id = $1`
+
+    var (
+        c                       external.Credentials
+        meta                    []byte
+        access, refresh, secret sql.NullString
+        expires                 sql.NullTime
+    )
+    err := s.DB.QueryRowContext(ctx, q, id).Scan(&c.AccountID, &c.Platform, &c.ExternalID, &meta,
+        &access, &refresh, &secret, &expires)
+    if errors.Is(err, sql.ErrNoRows) { return external.Credentials{}, fmt.Errorf("%w: %s", ErrNotFound, id) }
+    if err != nil { return external.Credentials{}, err }
+    c.Platform = strings.ToLower(c.Platform)
+    if err := applyAuthMeta(&c, meta); err != nil { return external.Credentials{}, fmt.Errorf("accounts: auth_meta for %s: %w", id, err) }
+    if access.Valid && access.String != "" {
+        c.AccessToken = access.String
+        c.RefreshToken = refresh.String
+        if secret.Valid { c.AccessTokenSecret = secret.String }
+        if expires.Valid { c.ExpiresAt = expires.Time }
+    }
+    env := external.CredentialsFromEnv(c.Platform)
+    if c.ConsumerKey == "" { c.ConsumerKey = env.ConsumerKey }
+    if c.ConsumerSecret == "" { c.ConsumerSecret = env.ConsumerSecret }
+    return c, nil
+}
+
+// applyAuthMeta copies known auth_meta keys onto c and keeps the remaining
+// string values in c.Extra.
+func applyAuthMeta(c *external.Credentials, raw []byte) error {
+    if len(raw) == 0 { return nil }
+    var meta map[string]any
+    if err := json.Unmarshal(raw, &meta); err != nil { return err }
+    for k, v := range meta {
+        str, ok := v.(string)
+        if !ok { continue }
+        switch k {
+        case "access_token":
+            c.AccessToken = str
+        case "access_token_secret":
+            c.AccessTokenSecret = str
+        case "refresh_token":
+            c.RefreshToken = str
+        case "consumer_key":
+            c.ConsumerKey = str
+        case "consumer_secret":
+            c.ConsumerSecret = str
+        case "instance_url":
+            c.InstanceURL = str
+        case "expires_at":
+            if t, err := time.Parse(time.RFC3339, str); err == nil { c.ExpiresAt = t }
+        default:
+            if c.Extra == nil { c.Extra = map[string]string{} }
+            c.Extra[k] = str
+        }
+    }
+    return nil
+}
@@ -0,0 +1,16 @@
+package accounts
+
+import (
+    "testing"
+
+    "github.com/bitesinbyte/ferret/pkg/external"
+)
+
+func TestApplyAuthMeta(t *testing.T) {
+    var c external.Credentials
+    raw := []byte(`{"instance_url":"https://social.example","access_token":"tok","expires_at":"2030-01-02T03:04:05Z","page_name":"Brand","n":3}`)
+    if err := applyAuthMeta(&c, raw); err != nil { t.Fatal(err) }
+    if c.InstanceURL != "https://social.example" || c.AccessToken != "tok" { t.Fatalf("known keys not applied: %+v", c) }
+    if c.ExpiresAt.Year() != 2030 { t.Fatalf("expires_at = %v", c.ExpiresAt) }
+    if c.Extra["page_name"] != "Brand" || len(c.Extra) != 1 { t.Fatalf("extra = %v", c.Extra) }
+}
@@ -0,0 +1,74 @@
+package calendarrepo
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+
+    "github.com/bitesinbyte/ferret/pkg/models"
+)
+
+// Brand is the copywriting context of an ICP profile.
+type Brand struct {
+    ICPID          string
+    Name           string
+    BrandVoice     map[string]any // {"tone":"friendly","style":"concise",...}
+    Guidelines     string
+    ContentPillars map[string]any // {"pillars":["education",...]}
+}
+
+// ICPBrand loads the brand voice, guidelines and content pillars of an ICP
+// profile. An empty icpID picks the org's 'Default' profile, then the most
+// recently updated one. It returns sql.ErrNoRows when the org has none.
+func (r Repository) ICPBrand(ctx context.Context, orgID, icpID string) (Brand, error) {
+    const q = `SELECT id, name, brand_voice, COALESCE(guidelines, ''), content_pillars FROM icp_profiles
+    WHERE org_id = $1 AND ($2 = '' OR id = $2)
+    ORDER BY (name = 'Default') DESC, updated_at DESC
+    LIMIT 1`
+    var b Brand
+    var voice, pillars []byte
+    if err := r.DB.QueryRowContext(ctx, q, orgID, icpID).Scan(&b.ICPID, &b.Name, &voice, &b.Guidelines, &pillars); err != nil {
+        return b, err
+    }
+    if err := json.Unmarshal(voice, &b.BrandVoice); err != nil { return b, fmt.Errorf("icp %s brand_voice: %w", b.ICPID, err) }
+    if err := json.Unmarshal(pillars, &b.ContentPillars); err != nil { return b, fmt.Errorf("icp %s content_pillars: %w", b.ICPID, err) }
+    return b, nil
+}
+
+// SaveGeneration records an AI generation and its variants in one
+// transaction (ai_generations, ai_variants). Parameters, OutputJSON and
+// variant payloads are stored as JSON.
+func (r Repository) SaveGeneration(ctx context.Context, g models.AIGeneration, variants []models.AIVariant) error {
+    params, err := json.Marshal(g.Parameters)
+    if err != nil { return fmt.Errorf("generation parameters: %w", err) }
+    if g.Parameters == nil { params = []byte("{}") }
+    var output []byte
+    if g.OutputJSON != nil {
+        if output, err = json.Marshal(g.OutputJSON); err != nil { return fmt.Errorf("generation output: %w", err) }
+    }
+    if g.Status == "" { g.Status = "succeeded" }
+
+    tx, err := r.DB.BeginTx(ctx, nil)
+    if err != nil { return err }
+    defer func() { _ = tx.Rollback() }()
+    const qg = `INSERT INTO ai_generations
+    (id, org_id, user_id, model, prompt, parameters, output_text, output_json, content_item_id, status, error_message, created_at, updated_at)
+    VALUES ($1,$2,$3,$4,$5,$6::jsonb,NULLIF($7,''),$8::jsonb,$9,$10,NULLIF($11,''),NOW(),NOW())`
+    if _, err := tx.ExecContext(ctx, qg, g.ID, g.OrgID, g.UserID, g.Model, g.Prompt, string(params), g.OutputText,
+        nullJSON(output), g.ContentItemID, g.Status, g.ErrorMessage); err != nil {
+        return err
+    }
+    const qv = `INSERT INTO ai_variants (id, generation_id, content_item_id, variant_index, payload, created_at)
+    VALUES ($1,$2,$3,$4,$5::jsonb,NOW())`
+    for _, v := range variants {
+        payload, err := json.Marshal(v.Payload)
+        if err != nil { return fmt.Errorf("variant %d payload: %w", v.VariantIndex, err) }
+        if _, err := tx.ExecContext(ctx, qv, v.ID, g.ID, v.ContentItemID, v.VariantIndex, string(payload)); err != nil { return err }
+    }
+    return tx.Commit()
+}
+
+func nullJSON(b []byte) sql.NullString {
+    return sql.NullString{String: string(b), Valid: len(b) > 0}
+}
@@ -0,0 +1,128 @@
+package calendarrepo
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "time"
+)
+
+// PostOutcomeRow is a published post with its latest post_outcomes snapshot,
+// as listed by PostOutcomes.
+type PostOutcomeRow struct {
+    PostPerformance
+    ExternalID  string
+    Caption     string
+    CollectedAt time.Time // of the snapshot
+}
+
+// PostQuery selects an org's published posts with outcomes. Zero From, To,
+// Platform and CampaignID do not filter; From is inclusive and To
+// exclusive on published_at.
+type PostQuery struct {
+    OrgID      string
+    From       time.Time
+    To         time.Time
+    Platform   string
+    CampaignID string
+    OrderBy    string // a key of PostOrders (published_at, newest first, when empty)
+    Limit      int
+    Offset     int
+}
+
+// PostOrders maps the orders PostOutcomes accepts to their sort keys, all
+// descending. Rates sort posts without a denominator last.
+var PostOrders = map[string]string{
+    "published_at":    "sp.published_at DESC",
+    "impressions":     "o.impressions DESC",
+    "reach":           "o.reach DESC",
+    "likes":           "o.likes DESC",
+    "comments":        "o.comments DESC",
+    "shares":          "o.shares DESC",
+    "clicks":          "o.clicks DESC",
+    "saves":           "o.saves DESC",
+    "conversions":     "o.conversions DESC",
+    "engagements":     "(o.likes + o.comments + o.shares + o.saves + o.clicks) DESC",
+    "engagement_rate": "(o.likes + o.comments + o.shares + o.saves + o.clicks)::float8 / NULLIF(CASE WHEN o.impressions > 0 THEN o.impressions ELSE o.reach END, 0) DESC NULLS LAST",
+}
+
+// PostOutcomes returns a page of the posts q selects and how many it
+// selects in total.
+func (r Repository) PostOutcomes(ctx context.Context, q PostQuery) ([]PostOutcomeRow, int, error) {
+    order := PostOrders["published_at"]
+    if q.OrderBy != "" {
+        o, ok := PostOrders[q.OrderBy]
+        if !ok { return nil, 0, fmt.Errorf("unknown order %q", q.OrderBy) }
+        order = o
+    }
+    stmt := `SELECT sp.org_id, sp.id, sp.platform, COALESCE(sp.campaign_id, ''), COALESCE(sp.metadata->>'topic', ''),
+      COALESCE(sp.metadata->>'variant_id', ''), COALESCE(sa.followers, 0), sp.published_at,
+      COALESCE(sp.external_id, ''), COALESCE(sp.caption, ''), o.collected_at,
+      o.impressions, o.reach, o.likes, o.comments, o.shares, o.clicks, o.saves, o.conversions,
+      COUNT(*) OVER ()
+    FROM scheduled_posts sp
+    JOIN LATERAL (
+      SELECT * FROM post_outcomes po WHERE po.scheduled_post_id = sp.id ORDER BY po.collected_at DESC LIMIT 1
+    ) o ON TRUE
+    LEFT JOIN social_accounts sa ON sa.id = sp.social_account_id
+    WHERE sp.org_id = $1 AND sp.status = 'published'
+      AND ($2::timestamptz IS NULL OR sp.published_at >= $2)
+      AND ($3::timestamptz IS NULL OR sp.published_at < $3)
+      AND ($4 = '' OR sp.platform = $4)
+      AND ($5 = '' OR sp.campaign_id = $5)
+    ORDER BY ` + order + `, sp.id
+    LIMIT NULLIF($6, 0) OFFSET $7`
+    rows, err := r.DB.QueryContext(ctx, stmt, q.OrgID, nullTime(q.From), nullTime(q.To), q.Platform, q.CampaignID, max(q.Limit, 0), max(q.Offset, 0))
+    if err != nil { return nil, 0, err }
+    defer rows.Close()
+    var out []PostOutcomeRow
+    total := 0
+    for rows.Next() {
+        p := PostOutcomeRow{PostPerformance: PostPerformance{Outcome: VariantOutcome{Posts: 1}}}
+        o := &p.Outcome
+        if err := rows.Scan(&p.OrgID, &p.PostID, &p.Platform, &p.CampaignID, &p.Topic, &p.VariantID, &p.Followers, &p.PublishedAt,
+            &p.ExternalID, &p.Caption, &p.CollectedAt,
+            &o.Impressions, &o.Reach, &o.Likes, &o.Comments, &o.Shares, &o.Clicks, &o.Saves, &o.Conversions, &total); err != nil {
+            return nil, 0, err
+        }
+        out = append(out, p)
+    }
+    if err := rows.Err(); err != nil { return nil, 0, err }
+    // An offset past the end returns no rows, and so no window count.
+    if len(out) == 0 && q.Offset > 0 {
+        const count = `SELECT COUNT(*) FROM scheduled_posts sp
+        WHERE sp.org_id = $1 AND sp.status = 'published'
+          AND EXISTS (SELECT 1 FROM post_outcomes po WHERE po.scheduled_post_id = sp.id)
+          AND ($2::timestamptz IS NULL OR sp.published_at >= $2)
+          AND ($3::timestamptz IS NULL OR sp.published_at < $3)
+          AND ($4 = '' OR sp.platform = $4)
+          AND ($5 = '' OR sp.campaign_id = $5)`
+        if err := r.DB.QueryRowContext(ctx, count, q.OrgID, nullTime(q.From), nullTime(q.To), q.Platform, q.CampaignID).Scan(&total); err != nil {
+            return nil, 0, err
+        }
+    }
+    return out, total, nil
+}
+
+// CampaignNames maps the org's campaign ids to their names.
+func (r Repository) CampaignNames(ctx context.Context, orgID string) (map[string]string, error) {
+    rows, err := r.DB.QueryContext(ctx, `SELECT id, name FROM campaigns WHERE org_id = $1`, orgID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := map[string]string{}
+    for rows.Next() {
+        var id, name string
+        if err := rows.Scan(&id, &name); err != nil { return nil, err }
+        out[id] = name
+    }
+    return out, rows.Err()
+}
+
+// PostOrg returns the org of a scheduled post, or sql.ErrNoRows.
+func (r Repository) PostOrg(ctx context.Context, postID string) (string, error) {
+    var orgID string
+    err := r.DB.QueryRowContext(ctx, `SELECT org_id FROM scheduled_posts WHERE id = $1`, postID).Scan(&orgID)
+    return orgID, err
+}
+
+func nullTime(t time.Time) sql.NullTime { return sql.NullTime{Time: t, Valid: !t.IsZero()} }
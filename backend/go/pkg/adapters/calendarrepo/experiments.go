@@ -0,0 +1,100 @@
+package calendarrepo
+
+import (
+    "context"
+
+    "github.com/bitesinbyte/ferret/pkg/models"
+)
+
+// VariantOutcome totals the latest post_outcomes snapshot of every post
+// scheduled from one variant (scheduled_posts.metadata.variant_id).
+type VariantOutcome struct {
+    VariantID   string
+    Posts       int
+    Impressions int64
+    Reach       int64
+    Likes       int64
+    Comments    int64
+    Shares      int64
+    Clicks      int64
+    Saves       int64
+    Conversions int64
+}
+
+// VariantOutcomes returns the org's outcomes per variant. With an
+// experimentID only posts linked to that experiment's arms count. Posts
+// without collected outcomes are left out.
+func (r Repository) VariantOutcomes(ctx context.Context, orgID, experimentID string) (map[string]VariantOutcome, error) {
+    const q = `SELECT sp.metadata->>'variant_id', COUNT(*),
+      COALESCE(SUM(o.impressions),0), COALESCE(SUM(o.reach),0), COALESCE(SUM(o.likes),0), COALESCE(SUM(o.comments),0),
+      COALESCE(SUM(o.shares),0), COALESCE(SUM(o.clicks),0), COALESCE(SUM(o.saves),0), COALESCE(SUM(o.conversions),0)
+    FROM scheduled_posts sp
+    JOIN LATERAL (
+      SELECT * FROM post_outcomes po WHERE po.scheduled_post_id = sp.id ORDER BY po.collected_at DESC LIMIT 1
+    ) o ON TRUE
+    WHERE sp.org_id = $1 AND sp.metadata->>'variant_id' IS NOT NULL
+      AND ($2 = '' OR EXISTS (
+        SELECT 1 FROM scheduled_post_arms spa JOIN experiment_arms ea ON ea.id = spa.arm_id
+        WHERE spa.scheduled_post_id = sp.id AND ea.experiment_id = $2))
+    GROUP BY 1`
+    rows, err := r.DB.QueryContext(ctx, q, orgID, experimentID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := map[string]VariantOutcome{}
+    for rows.Next() {
+        var o VariantOutcome
+        if err := rows.Scan(&o.VariantID, &o.Posts, &o.Impressions, &o.Reach, &o.Likes, &o.Comments, &o.Shares, &o.Clicks, &o.Saves, &o.Conversions); err != nil {
+            return nil, err
+        }
+        out[o.VariantID] = o
+    }
+    return out, rows.Err()
+}
+
+// ExperimentArms lists the arms of an experiment in creation order.
+func (r Repository) ExperimentArms(ctx context.Context, experimentID string) ([]models.ExperimentArm, error) {
+    const q = `SELECT id, experiment_id, variant_id, weight, created_at FROM experiment_arms
+    WHERE experiment_id = $1 ORDER BY created_at, id`
+    rows, err := r.DB.QueryContext(ctx, q, experimentID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var out []models.ExperimentArm
+    for rows.Next() {
+        var a models.ExperimentArm
+        if err := rows.Scan(&a.ID, &a.ExperimentID, &a.VariantID, &a.Weight, &a.CreatedAt); err != nil { return nil, err }
+        out = append(out, a)
+    }
+    return out, rows.Err()
+}
+
+// SetArmWeights stores arm id -> weight in one transaction.
+func (r Repository) SetArmWeights(ctx context.Context, weights map[string]float64) error {
+    if len(weights) == 0 { return nil }
+    tx, err := r.DB.BeginTx(ctx, nil)
+    if err != nil { return err }
+    for id, w := range weights {
+        if _, err := tx.ExecContext(ctx, `UPDATE experiment_arms SET weight = $2 WHERE id = $1`, id, w); err != nil {
+            _ = tx.Rollback()
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+// RunningExperiments lists the experiments that have started and not ended,
+// of orgID or of every org when it is empty.
+func (r Repository) RunningExperiments(ctx context.Context, orgID string) ([]models.Experiment, error) {
+    const q = `SELECT id, org_id, name, COALESCE(hypothesis, ''), started_at, created_at, updated_at FROM experiments
+    WHERE ($1 = '' OR org_id = $1) AND started_at <= NOW() AND ended_at IS NULL
+    ORDER BY org_id, started_at, id`
+    rows, err := r.DB.QueryContext(ctx, q, orgID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var out []models.Experiment
+    for rows.Next() {
+        var e models.Experiment
+        if err := rows.Scan(&e.ID, &e.OrgID, &e.Name, &e.Hypothesis, &e.StartedAt, &e.CreatedAt, &e.UpdatedAt); err != nil { return nil, err }
+        out = append(out, e)
+    }
+    return out, rows.Err()
+}
@@ -0,0 +1,59 @@
+package calendarrepo
+
+import (
+    "context"
+    "encoding/json"
+
+    "github.com/lib/pq"
+
+    "github.com/bitesinbyte/ferret/pkg/models"
+)
+
+// UpsertOutcome writes one post_outcomes snapshot. A snapshot with the same
+// id (see the analytics collector) is overwritten, so re-running a
+// collection does not add rows.
+func (r Repository) UpsertOutcome(ctx context.Context, o models.PostOutcome) error {
+    meta := []byte("{}")
+    if o.Metadata != nil {
+        b, err := json.Marshal(o.Metadata)
+        if err != nil { return err }
+        meta = b
+    }
+    const q = `INSERT INTO post_outcomes
+    (id, scheduled_post_id, platform, external_id, impressions, reach, likes, comments, shares, clicks, saves, conversions, collected_at, metadata)
+    VALUES ($1,$2,$3,NULLIF($4,''),$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)
+    ON CONFLICT (id) DO UPDATE SET
+      impressions = EXCLUDED.impressions, reach = EXCLUDED.reach, likes = EXCLUDED.likes, comments = EXCLUDED.comments,
+      shares = EXCLUDED.shares, clicks = EXCLUDED.clicks, saves = EXCLUDED.saves, conversions = EXCLUDED.conversions,
+      collected_at = EXCLUDED.collected_at, metadata = EXCLUDED.metadata`
+    _, err := r.DB.ExecContext(ctx, q, o.ID, o.ScheduledPostID, o.Platform, o.ExternalID,
+        o.Impressions, o.Reach, o.Likes, o.Comments, o.Shares, o.Clicks, o.Saves, o.Conversions, o.CollectedAt, meta)
+    return err
+}
+
+// Snapshots returns the post_outcomes of each post in postIDs, oldest first.
+// Metadata is decoded into a map[string]any.
+func (r Repository) Snapshots(ctx context.Context, postIDs []string) (map[string][]models.PostOutcome, error) {
+    out := map[string][]models.PostOutcome{}
+    if len(postIDs) == 0 { return out, nil }
+    const q = `SELECT id, scheduled_post_id, platform, COALESCE(external_id, ''),
+      impressions, reach, likes, comments, shares, clicks, saves, conversions, collected_at, metadata
+    FROM post_outcomes WHERE scheduled_post_id = ANY($1)
+    ORDER BY scheduled_post_id, collected_at, id`
+    rows, err := r.DB.QueryContext(ctx, q, pq.Array(postIDs))
+    if err != nil { return nil, err }
+    defer rows.Close()
+    for rows.Next() {
+        var o models.PostOutcome
+        var meta []byte
+        if err := rows.Scan(&o.ID, &o.ScheduledPostID, &o.Platform, &o.ExternalID,
+            &o.Impressions, &o.Reach, &o.Likes, &o.Comments, &o.Shares, &o.Clicks, &o.Saves, &o.Conversions, &o.CollectedAt, &meta); err != nil {
+            return nil, err
+        }
+        m := map[string]any{}
+        if len(meta) > 0 { _ = json.Unmarshal(meta, &m) }
+        o.Metadata = m
+        out[o.ScheduledPostID] = append(out[o.ScheduledPostID], o)
+    }
+    return out, rows.Err()
+}
@@ -0,0 +1,80 @@
+package calendarrepo
+
+import (
+    "context"
+    "database/sql"
+    "time"
+)
+
+// PublishedPost is an original published post with its latest
+// post_outcomes snapshot.
+type PublishedPost struct {
+    ID          string
+    Platform    string
+    CampaignID  *string
+    ContentID   *string
+    Caption     string
+    Hashtags    string
+    Metadata    []byte // scheduled_posts.metadata JSON
+    PublishedAt time.Time
+    Outcome     VariantOutcome // totals of the one snapshot (Posts is 1)
+}
+
+// RecycledCopy is a post re-scheduled from an original (its metadata
+// recycled_from), in any status but failed or canceled.
+type RecycledCopy struct {
+    ID          string
+    Caption     string
+    VariantID   string
+    ScheduledAt time.Time
+}
+
+// PublishedPosts lists the org's published posts that are not recycled
+// copies themselves and have collected outcomes.
+func (r Repository) PublishedPosts(ctx context.Context, orgID string) ([]PublishedPost, error) {
+    const q = `SELECT sp.id, sp.platform, sp.campaign_id, sp.content_id, COALESCE(sp.caption, ''), COALESCE(sp.hashtags, ''),
+      sp.metadata, COALESCE(sp.published_at, sp.scheduled_at),
+      o.impressions, o.reach, o.likes, o.comments, o.shares, o.clicks, o.saves, o.conversions
+    FROM scheduled_posts sp
+    JOIN LATERAL (
+      SELECT * FROM post_outcomes po WHERE po.scheduled_post_id = sp.id ORDER BY po.collected_at DESC LIMIT 1
+    ) o ON TRUE
+    WHERE sp.org_id = $1 AND sp.status = 'published' AND sp.metadata->>'recycled_from' IS NULL
+    ORDER BY sp.published_at, sp.id`
+    rows, err := r.DB.QueryContext(ctx, q, orgID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var out []PublishedPost
+    for rows.Next() {
+        p := PublishedPost{Outcome: VariantOutcome{Posts: 1}}
+        o := &p.Outcome
+        if err := rows.Scan(&p.ID, &p.Platform, &p.CampaignID, &p.ContentID, &p.Caption, &p.Hashtags, &p.Metadata, &p.PublishedAt,
+            &o.Impressions, &o.Reach, &o.Likes, &o.Comments, &o.Shares, &o.Clicks, &o.Saves, &o.Conversions); err != nil {
+            return nil, err
+        }
+        out = append(out, p)
+    }
+    return out, rows.Err()
+}
+
+// RecycledCopies returns the org's recycled copies by original post id,
+// oldest first.
+func (r Repository) RecycledCopies(ctx context.Context, orgID string) (map[string][]RecycledCopy, error) {
+    const q = `SELECT metadata->>'recycled_from', id, COALESCE(caption, ''), metadata->>'variant_id', scheduled_at
+    FROM scheduled_posts
+    WHERE org_id = $1 AND metadata->>'recycled_from' IS NOT NULL AND status NOT IN ('failed', 'canceled')
+    ORDER BY scheduled_at, id`
+    rows, err := r.DB.QueryContext(ctx, q, orgID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := map[string][]RecycledCopy{}
+    for rows.Next() {
+        var orig string
+        var c RecycledCopy
+        var variant sql.NullString
+        if err := rows.Scan(&orig, &c.ID, &c.Caption, &variant, &c.ScheduledAt); err != nil { return nil, err }
+        c.VariantID = variant.String
+        out[orig] = append(out[orig], c)
+    }
+    return out, rows.Err()
+}
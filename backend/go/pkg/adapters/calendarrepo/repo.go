@@ -4,6 +4,8 @@ import (
     "context"
     "database/sql"
     "time"
+
+    "github.com/bitesinbyte/ferret/pkg/external"
 )
 
 // Repository provides write helpers for scheduling posts.
@@ -11,6 +13,7 @@ type Repository struct{ DB *sql.DB }
 
 type ScheduleInput struct {
     ID          string
+    OrgID       string
     CampaignID  *string
     ContentID   *string
     Platform    string
@@ -18,35 +21,85 @@ type ScheduleInput struct {
     Hashtags    *string
     ScheduledAt time.Time
     MetadataJSON *string // JSON string; nullable
+    ArmID        *string // experiment arm the post came from (scheduled_post_arms); BulkSchedule only
 }
 
-// SchedulePost inserts a single scheduled post row.
+// SchedulePost inserts a single scheduled post row. The platform must be
+// registered (aliases are stored under the canonical name); otherwise the
+// error wraps external.ErrUnknownPlatform.
 func (r Repository) SchedulePost(ctx context.Context, in ScheduleInput) error {
+    platform, err := external.CanonicalPlatform(in.Platform)
+    if err != nil { return err }
+    in.Platform = platform
     const q = `INSERT INTO scheduled_posts
-    (id, campaign_id, content_id, platform, caption, hashtags, scheduled_at, status, metadata, created_at, updated_at)
-    VALUES ($1,$2,$3,$4,$5,$6,$7,'scheduled',COALESCE($8,'{}'::json), NOW(), NOW())`
-    _, err := r.DB.ExecContext(ctx, q,
-        in.ID, in.CampaignID, in.ContentID, in.Platform, in.Caption, in.Hashtags, in.ScheduledAt, in.MetadataJSON,
+    (id, org_id, campaign_id, content_id, platform, caption, hashtags, scheduled_at, status, metadata, created_at, updated_at)
+    VALUES ($1,NULLIF($9,''),$2,$3,$4,$5,$6,$7,'scheduled',COALESCE($8,'{}'::json), NOW(), NOW())`
+    _, err = r.DB.ExecContext(ctx, q,
+        in.ID, in.CampaignID, in.ContentID, in.Platform, in.Caption, in.Hashtags, in.ScheduledAt, in.MetadataJSON, in.OrgID,
     )
     return err
 }
 
-// BulkSchedule inserts multiple posts in a transaction.
+// BulkSchedule inserts multiple posts in a transaction, linking those with
+// an ArmID to their experiment arm. Platforms are validated up front, so one
+// unknown platform rejects the whole batch.
 func (r Repository) BulkSchedule(ctx context.Context, items []ScheduleInput) error {
     if len(items) == 0 { return nil }
+    items = append([]ScheduleInput(nil), items...)
+    for i := range items {
+        platform, err := external.CanonicalPlatform(items[i].Platform)
+        if err != nil { return err }
+        items[i].Platform = platform
+    }
     tx, err := r.DB.BeginTx(ctx, nil)
     if err != nil { return err }
     const q = `INSERT INTO scheduled_posts
-    (id, campaign_id, content_id, platform, caption, hashtags, scheduled_at, status, metadata, created_at, updated_at)
-    VALUES ($1,$2,$3,$4,$5,$6,$7,'scheduled',COALESCE($8,'{}'::json), NOW(), NOW())`
+    (id, org_id, campaign_id, content_id, platform, caption, hashtags, scheduled_at, status, metadata, created_at, updated_at)
+    VALUES ($1,NULLIF($9,''),$2,$3,$4,$5,$6,$7,'scheduled',COALESCE($8,'{}'::json), NOW(), NOW())`
     stmt, err := tx.PrepareContext(ctx, q)
     if err != nil { _ = tx.Rollback(); return err }
     defer stmt.Close()
     for _, in := range items {
         if _, err := stmt.ExecContext(ctx,
-            in.ID, in.CampaignID, in.ContentID, in.Platform, in.Caption, in.Hashtags, in.ScheduledAt, in.MetadataJSON,
+            in.ID, in.CampaignID, in.ContentID, in.Platform, in.Caption, in.Hashtags, in.ScheduledAt, in.MetadataJSON, in.OrgID,
         ); err != nil { _ = tx.Rollback(); return err }
+        if in.ArmID == nil { continue }
+        if _, err := tx.ExecContext(ctx, `INSERT INTO scheduled_post_arms (scheduled_post_id, arm_id) VALUES ($1,$2)`, in.ID, *in.ArmID); err != nil {
+            _ = tx.Rollback()
+            return err
+        }
     }
     return tx.Commit()
 }
 
+// ScheduledTimes returns scheduled_at of the org's pending rows (scheduled or
+// processing) between from and to, per platform, so planners can avoid them.
+func (r Repository) ScheduledTimes(ctx context.Context, orgID string, from, to time.Time) (map[string][]time.Time, error) {
+    const q = `SELECT platform, scheduled_at FROM scheduled_posts
+    WHERE org_id = $1 AND status IN ('scheduled','processing') AND scheduled_at >= $2 AND scheduled_at < $3
+    ORDER BY scheduled_at`
+    rows, err := r.DB.QueryContext(ctx, q, orgID, from, to)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    out := map[string][]time.Time{}
+    for rows.Next() {
+        var platform string
+        var at time.Time
+        if err := rows.Scan(&platform, &at); err != nil { return nil, err }
+        out[platform] = append(out[platform], at)
+    }
+    return out, rows.Err()
+}
+
+// ICPTimezone returns the IANA time zone of the org's ICP profile, preferring
+// the 'Default' profile, or "" when none is set.
+func (r Repository) ICPTimezone(ctx context.Context, orgID string) (string, error) {
+    const q = `SELECT COALESCE(timezone, '') FROM icp_profiles
+    WHERE org_id = $1 AND COALESCE(timezone, '') <> ''
+    ORDER BY (name = 'Default') DESC, updated_at DESC
+    LIMIT 1`
+    var tz string
+    err := r.DB.QueryRowContext(ctx, q, orgID).Scan(&tz)
+    if err == sql.ErrNoRows { return "", nil }
+    return tz, err
+}
@@ -0,0 +1,116 @@
+package calendarrepo
+
+import (
+    "context"
+    "encoding/json"
+    "strings"
+    "time"
+
+    "github.com/lib/pq"
+
+    "github.com/bitesinbyte/ferret/pkg/models"
+)
+
+// PostPerformance is a published post with its latest post_outcomes
+// snapshot, the dimensions analytics rolls it up by and the follower count
+// of its social account.
+type PostPerformance struct {
+    OrgID       string
+    PostID      string
+    Platform    string
+    CampaignID  string // "" when none, likewise Topic and VariantID
+    Topic       string // metadata.topic
+    VariantID   string // metadata.variant_id
+    Followers   int64  // 0 when unknown
+    PublishedAt time.Time
+    Outcome     VariantOutcome // totals of the one snapshot (Posts is 1)
+}
+
+// PostPerformance lists the published posts with collected outcomes whose
+// published_at is in [from, to), of orgID or of every org when it is empty.
+func (r Repository) PostPerformance(ctx context.Context, orgID string, from, to time.Time) ([]PostPerformance, error) {
+    const q = `SELECT sp.org_id, sp.id, sp.platform, COALESCE(sp.campaign_id, ''), COALESCE(sp.metadata->>'topic', ''),
+      COALESCE(sp.metadata->>'variant_id', ''), COALESCE(sa.followers, 0), sp.published_at,
+      o.impressions, o.reach, o.likes, o.comments, o.shares, o.clicks, o.saves, o.conversions
+    FROM scheduled_posts sp
+    JOIN LATERAL (
+      SELECT * FROM post_outcomes po WHERE po.scheduled_post_id = sp.id ORDER BY po.collected_at DESC LIMIT 1
+    ) o ON TRUE
+    LEFT JOIN social_accounts sa ON sa.id = sp.social_account_id
+    WHERE ($1 = '' OR sp.org_id = $1) AND sp.status = 'published'
+      AND sp.published_at >= $2 AND sp.published_at < $3
+    ORDER BY sp.org_id, sp.published_at, sp.id`
+    rows, err := r.DB.QueryContext(ctx, q, orgID, from, to)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var out []PostPerformance
+    for rows.Next() {
+        p := PostPerformance{Outcome: VariantOutcome{Posts: 1}}
+        o := &p.Outcome
+        if err := rows.Scan(&p.OrgID, &p.PostID, &p.Platform, &p.CampaignID, &p.Topic, &p.VariantID, &p.Followers, &p.PublishedAt,
+            &o.Impressions, &o.Reach, &o.Likes, &o.Comments, &o.Shares, &o.Clicks, &o.Saves, &o.Conversions); err != nil {
+            return nil, err
+        }
+        out = append(out, p)
+    }
+    return out, rows.Err()
+}
+
+// ReplaceTrends swaps, in one transaction, the trend_metrics of the given
+// sources whose bucket starts in [from, to) for ms: rows of orgID (every
+// org when empty) are deleted, then ms inserted. Buckets that lost all
+// their posts thus disappear.
+func (r Repository) ReplaceTrends(ctx context.Context, orgID string, sources []string, from, to time.Time, ms []models.TrendMetric) error {
+    tx, err := r.DB.BeginTx(ctx, nil)
+    if err != nil { return err }
+    defer tx.Rollback()
+    const del = `DELETE FROM trend_metrics
+    WHERE ($1 = '' OR org_id = $1) AND source = ANY($2) AND bucket_start >= $3 AND bucket_start < $4`
+    if _, err := tx.ExecContext(ctx, del, orgID, pq.Array(sources), from, to); err != nil { return err }
+    stmt, err := tx.PrepareContext(ctx, `INSERT INTO trend_metrics
+    (org_id, source, dimension, metric, bucket_start, bucket_end, value, meta)
+    VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+    ON CONFLICT (org_id, source, dimension, metric, bucket_start, bucket_end) DO UPDATE SET
+      value = EXCLUDED.value, meta = EXCLUDED.meta, created_at = NOW()`)
+    if err != nil { return err }
+    defer stmt.Close()
+    for _, m := range ms {
+        meta := []byte("{}")
+        if m.Meta != nil {
+            if meta, err = json.Marshal(m.Meta); err != nil { return err }
+        }
+        if _, err := stmt.ExecContext(ctx, m.OrgID, m.Source, m.Dimension, m.Metric, m.BucketStart, m.BucketEnd, m.Value, meta); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+// TrendSeries reads the org's trend_metrics of source whose bucket starts in
+// [from, to), ordered by dimension, metric and bucket. A dimension ending in
+// ":" (e.g. "platform:") matches every dimension of that kind. Meta is
+// decoded into a map[string]any.
+func (r Repository) TrendSeries(ctx context.Context, orgID, source, dimension string, metrics []string, from, to time.Time) ([]models.TrendMetric, error) {
+    const q = `SELECT org_id, source, dimension, metric, bucket_start, bucket_end, value, meta, created_at
+    FROM trend_metrics
+    WHERE org_id = $1 AND source = $2 AND (dimension = $3 OR ($4 AND starts_with(dimension, $3)))
+      AND metric = ANY($5) AND bucket_start >= $6 AND bucket_start < $7
+    ORDER BY dimension, metric, bucket_start`
+    prefix := strings.HasSuffix(dimension, ":")
+    rows, err := r.DB.QueryContext(ctx, q, orgID, source, dimension, prefix, pq.Array(metrics), from, to)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var out []models.TrendMetric
+    for rows.Next() {
+        var m models.TrendMetric
+        var meta []byte
+        if err := rows.Scan(&m.OrgID, &m.Source, &m.Dimension, &m.Metric, &m.BucketStart, &m.BucketEnd, &m.Value, &meta, &m.CreatedAt); err != nil {
+            return nil, err
+        }
+        mm := map[string]any{}
+        if len(meta) > 0 { _ = json.Unmarshal(meta, &mm) }
+        m.Meta = mm
+        out = append(out, m)
+    }
+    return out, rows.Err()
+}
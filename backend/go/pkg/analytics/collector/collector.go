@@ -0,0 +1,198 @@
+// Package collector reads the metrics of published posts back from their
+// platforms and stores them as post_outcomes snapshots on a decaying
+// schedule (see DefaultSchedule).
+package collector
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "net/http"
+    "sort"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+    "github.com/bitesinbyte/ferret/pkg/calendar"
+    "github.com/bitesinbyte/ferret/pkg/engine/ratelimit"
+    "github.com/bitesinbyte/ferret/pkg/engine/workers"
+    "github.com/bitesinbyte/ferret/pkg/external"
+    "github.com/bitesinbyte/ferret/pkg/models"
+)
+
+// Outcomes stores snapshots (calendarrepo.Repository in production).
+type Outcomes interface {
+    UpsertOutcome(ctx context.Context, o models.PostOutcome) error
+    // Snapshots returns each post's snapshots, oldest first, with metadata
+    // decoded into a map[string]any.
+    Snapshots(ctx context.Context, postIDs []string) (map[string][]models.PostOutcome, error)
+}
+
+// Collector snapshots the counters of published posts. Each post is read
+// with its social account's credentials (env credentials when it has none),
+// through the platform's external.MetricsFetcher.
+type Collector struct {
+    Outcomes Outcomes
+    Accounts workers.CredentialSource // nil uses env credentials
+    HTTP     *http.Client             // nil uses the posters' default
+    // Schedule lists the snapshot offsets from published_at (DefaultSchedule
+    // when empty, ascending).
+    Schedule []time.Duration
+    // MinChange is the growth under which a post counts as settled and is
+    // not read again (DefaultMinChange when zero; negative never settles).
+    MinChange float64
+    // Limits paces reads per platform (see Limiter); nil reads without waiting.
+    Limits *ratelimit.Limiter
+    Now    func() time.Time
+}
+
+// New returns a collector that stores snapshots in db, resolves account
+// credentials from social_accounts and paces reads with an in-memory Limiter.
+func New(db *sql.DB, accounts workers.CredentialSource) *Collector {
+    return &Collector{Outcomes: calendarrepo.Repository{DB: db}, Accounts: accounts, Limits: Limiter(nil, 0)}
+}
+
+// PostError is the failure to collect one post.
+type PostError struct {
+    ScheduledPostID string
+    Platform        string
+    Err             error
+}
+
+func (e PostError) Error() string {
+    return fmt.Sprintf("collect %s (%s): %v", e.ScheduledPostID, e.Platform, e.Err)
+}
+
+func (e PostError) Unwrap() error { return e.Err }
+
+// Report summarises one collection run.
+type Report struct {
+    Collected int
+    NotDue    int // no snapshot due yet, or the due one was taken
+    Settled   int // stopped changing
+    Deferred  int // due, but the platform's read budget ran out
+    Failed    []PostError
+}
+
+// Err joins the per-post failures, or returns nil when no post failed.
+func (r Report) Err() error {
+    errs := make([]error, len(r.Failed))
+    for i, f := range r.Failed { errs[i] = f }
+    return errors.Join(errs...)
+}
+
+type job struct {
+    row   calendar.ScheduledPostRow
+    label string
+    age   time.Duration
+    step  int
+}
+
+// Collect stores the due snapshot of every row. Posts without a due
+// snapshot or that settled are skipped; the others are read youngest
+// snapshot first, since early counters move fastest. When a platform's read
+// budget runs out (Limits, or the platform answering rate limited) its
+// remaining posts are deferred to the next run. A failing post does not stop
+// the others: its error is recorded in the report. Only a canceled ctx or a
+// failure to load the snapshot history ends the run early.
+func (c *Collector) Collect(ctx context.Context, rows []calendar.ScheduledPostRow) (Report, error) {
+    now := c.now()
+    ids := make([]string, len(rows))
+    for i, r := range rows { ids[i] = r.ID }
+    history, err := c.Outcomes.Snapshots(ctx, ids)
+    if err != nil { return Report{}, fmt.Errorf("load snapshots: %w", err) }
+    jobs, rep := c.plan(rows, history, now)
+
+    exhausted := map[string]bool{}
+    for _, j := range jobs {
+        if err := ctx.Err(); err != nil { return rep, err }
+        platform := string(j.row.Platform)
+        if exhausted[platform] { rep.Deferred++; continue }
+        err := c.collectOne(ctx, j, now)
+        switch {
+        case err == nil:
+            rep.Collected++
+        case budgetSpent(err):
+            exhausted[platform] = true
+            rep.Deferred++
+        default:
+            rep.Failed = append(rep.Failed, PostError{ScheduledPostID: j.row.ID, Platform: platform, Err: err})
+        }
+    }
+    return rep, nil
+}
+
+// plan picks the rows with a snapshot due, youngest snapshot first.
+func (c *Collector) plan(rows []calendar.ScheduledPostRow, history map[string][]models.PostOutcome, now time.Time) ([]job, Report) {
+    schedule := c.Schedule
+    if len(schedule) == 0 { schedule = DefaultSchedule }
+    minChange := c.MinChange
+    if minChange == 0 { minChange = DefaultMinChange }
+    var rep Report
+    var jobs []job
+    for _, r := range rows {
+        published := r.ScheduledAt
+        if r.PublishedAt.Valid { published = r.PublishedAt.Time }
+        age := now.Sub(published)
+        snaps := history[r.ID]
+        if minChange > 0 && settled(snaps, age, minChange) { rep.Settled++; continue }
+        step, ok := dueStep(schedule, age, takenSnapshots(snaps))
+        if !ok { rep.NotDue++; continue }
+        jobs = append(jobs, job{row: r, label: SnapshotLabel(schedule[step]), age: age, step: step})
+    }
+    sort.SliceStable(jobs, func(i, k int) bool { return jobs[i].step < jobs[k].step })
+    return jobs, rep
+}
+
+func (c *Collector) collectOne(ctx context.Context, j job, now time.Time) error {
+    platform := string(j.row.Platform)
+    creds, err := workers.CredentialsFor(ctx, c.Accounts, j.row, now)
+    if err != nil { return err }
+    if c.Limits != nil {
+        if err := c.Limits.Wait(ctx, platform, creds.AccountID); err != nil { return err }
+    }
+    m, err := external.FetchMetrics(ctx, platform, creds, j.row.ExternalID.String, c.HTTP)
+    if err != nil {
+        if c.Limits != nil { c.Limits.Observe(ctx, platform, creds.AccountID, err) }
+        return err
+    }
+    return c.Outcomes.UpsertOutcome(ctx, Snapshot(j.row, j.label, m, now, j.age))
+}
+
+// Snapshot maps m onto the post_outcomes row of row's snapshot label
+// (po_<post>_<label>, so a snapshot is written once per offset). Metadata
+// keeps the label, the post's age in hours and the raw platform counters.
+func Snapshot(row calendar.ScheduledPostRow, label string, m external.PostMetrics, now time.Time, age time.Duration) models.PostOutcome {
+    return models.PostOutcome{
+        ID:              fmt.Sprintf("po_%s_%s", row.ID, label),
+        ScheduledPostID: row.ID,
+        Platform:        string(row.Platform),
+        ExternalID:      row.ExternalID.String,
+        Impressions:     m.Impressions,
+        Reach:           m.Reach,
+        Likes:           m.Likes,
+        Comments:        m.Comments,
+        Shares:          m.Shares,
+        Clicks:          m.Clicks,
+        Saves:           m.Saves,
+        Conversions:     m.Conversions,
+        CollectedAt:     now,
+        Metadata:        map[string]any{"snapshot": label, "age_hours": age.Hours(), "raw": m.Raw},
+    }
+}
+
+// budgetSpent reports whether err means the read budget is spent: a Limits
+// wait past MaxWait, or the platform answering rate limited or over quota.
+func budgetSpent(err error) bool {
+    var q interface{ QuotaExceeded() bool }
+    if errors.As(err, &q) && q.QuotaExceeded() { return true }
+    var rl interface{ RateLimitedUntil() (time.Time, bool) }
+    if !errors.As(err, &rl) { return false }
+    _, limited := rl.RateLimitedUntil()
+    return limited
+}
+
+func (c *Collector) now() time.Time {
+    if c.Now != nil { return c.Now().UTC() }
+    return time.Now().UTC()
+}
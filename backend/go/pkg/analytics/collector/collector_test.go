@@ -0,0 +1,152 @@
+package collector
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/calendar"
+    "github.com/bitesinbyte/ferret/pkg/engine/ratelimit"
+    "github.com/bitesinbyte/ferret/pkg/external"
+    "github.com/bitesinbyte/ferret/pkg/models"
+)
+
+type memOutcomes map[string]models.PostOutcome
+
+func (m memOutcomes) UpsertOutcome(_ context.Context, o models.PostOutcome) error {
+    m[o.ID] = o
+    return nil
+}
+
+func (m memOutcomes) Snapshots(_ context.Context, ids []string) (map[string][]models.PostOutcome, error) {
+    out := map[string][]models.PostOutcome{}
+    for _, o := range m { out[o.ScheduledPostID] = append(out[o.ScheduledPostID], o) }
+    for _, snaps := range out {
+        for i := 1; i < len(snaps); i++ {
+            for k := i; k > 0 && snaps[k].CollectedAt.Before(snaps[k-1].CollectedAt); k-- { snaps[k], snaps[k-1] = snaps[k-1], snaps[k] }
+        }
+    }
+    return out, nil
+}
+
+type staticAccounts map[string]external.Credentials
+
+func (s staticAccounts) Credentials(_ context.Context, id string) (external.Credentials, error) {
+    c, ok := s[id]
+    if !ok { return external.Credentials{}, errors.New("no such account") }
+    return c, nil
+}
+
+func mastodonServer(t *testing.T, favourites *int) *httptest.Server {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if !strings.HasPrefix(r.URL.Path, "/api/v1/statuses/1") { http.NotFound(w, r); return }
+        _, _ = io.WriteString(w, `{"replies_count":2,"reblogs_count":5,"favourites_count":`+strings.Repeat("1", *favourites)+`}`)
+    }))
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+func row(id, platform, externalID string, published time.Time) calendar.ScheduledPostRow {
+    return calendar.ScheduledPostRow{
+        ID: id, Platform: calendar.Platform(platform), SocialAccountID: sql.NullString{String: "acct", Valid: true},
+        ExternalID: sql.NullString{String: externalID, Valid: true}, PublishedAt: sql.NullTime{Time: published, Valid: true},
+    }
+}
+
+func TestCollectFollowsDecayingSchedule(t *testing.T) {
+    digits := 1 // favourites: 1, 11, 111, ...
+    srv := mastodonServer(t, &digits)
+    published := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+    now := published.Add(20 * time.Minute)
+    out := memOutcomes{}
+    c := &Collector{
+        Outcomes: out,
+        Accounts: staticAccounts{"acct": {Platform: "mastodon", AccountID: "acct", InstanceURL: srv.URL, AccessToken: "t"}},
+        HTTP:     srv.Client(),
+        Now:      func() time.Time { return now },
+    }
+    rows := []calendar.ScheduledPostRow{
+        row("sp1", "mastodon", "1", published),
+        row("sp2", "mastodon", "404", published.Add(-2*time.Hour)),
+        row("sp3", "myspace", "1", published.Add(-2*time.Hour)),
+    }
+    rep, err := c.Collect(context.Background(), rows)
+    if err != nil { t.Fatal(err) }
+    // sp1 is not an hour old; the others are past their 1h snapshot and fail.
+    if rep.Collected != 0 || rep.NotDue != 1 || len(rep.Failed) != 2 { t.Fatalf("report = %+v", rep) }
+    if !errors.Is(rep.Err(), external.ErrUnknownPlatform) { t.Errorf("failures = %v", rep.Err()) }
+
+    rows = rows[:1]
+    for _, step := range []struct {
+        at    time.Duration
+        label string
+    }{{65 * time.Minute, "1h"}, {7 * time.Hour, "6h"}, {50 * time.Hour, "24h"}} {
+        now = published.Add(step.at)
+        digits++
+        if rep, err := c.Collect(context.Background(), rows); err != nil || rep.Collected != 1 { t.Fatalf("at %s: %+v, %v", step.at, rep, err) }
+        o, ok := out["po_sp1_"+step.label]
+        if !ok { t.Fatalf("at %s: no %s snapshot in %v", step.at, step.label, out) }
+        if meta := o.Metadata.(map[string]any); meta["snapshot"] != step.label || meta["age_hours"] != step.at.Hours() { t.Errorf("metadata = %v", meta) }
+        // Taken: nothing is due until the next offset.
+        if rep, _ := c.Collect(context.Background(), rows); rep.NotDue != 1 { t.Fatalf("at %s: rerun %+v", step.at, rep) }
+    }
+    if len(out) != 3 || out["po_sp1_6h"].Likes != 111 { t.Fatalf("snapshots = %v", out) }
+
+    // Counters stop moving: after the next snapshot the post is settled.
+    now = published.Add(80 * time.Hour)
+    if rep, _ := c.Collect(context.Background(), rows); rep.Collected != 1 { t.Fatalf("72h: %+v", rep) }
+    now = published.Add(8 * 24 * time.Hour)
+    if rep, _ := c.Collect(context.Background(), rows); rep.Settled != 1 || rep.Collected != 0 { t.Fatalf("7d: %+v", rep) }
+}
+
+func TestCollectDefersPlatformOverBudget(t *testing.T) {
+    digits := 1
+    srv := mastodonServer(t, &digits)
+    now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+    out := memOutcomes{}
+    c := &Collector{
+        Outcomes: out,
+        Accounts: staticAccounts{"acct": {Platform: "mastodon", AccountID: "acct", InstanceURL: srv.URL, AccessToken: "t"}},
+        HTTP:     srv.Client(),
+        Limits: &ratelimit.Limiter{
+            Store: ratelimit.NewMemoryStore(), Rates: map[string]ratelimit.Rate{"mastodon": {PerSecond: 1.0 / 3600, Burst: 2}}, MaxWait: time.Millisecond,
+        },
+        Now: func() time.Time { return now },
+    }
+    rows := []calendar.ScheduledPostRow{
+        row("old", "mastodon", "1", now.Add(-10*24*time.Hour)),
+        row("mid", "mastodon", "1", now.Add(-30*time.Hour)),
+        row("new", "mastodon", "1", now.Add(-90*time.Minute)),
+    }
+    rep, err := c.Collect(context.Background(), rows)
+    if err != nil { t.Fatal(err) }
+    if rep.Collected != 2 || rep.Deferred != 1 || len(rep.Failed) != 0 { t.Fatalf("report = %+v", rep) }
+    // Youngest snapshots go first; the 7d snapshot waits for budget.
+    if _, ok := out["po_new_1h"]; !ok { t.Errorf("snapshots = %v", out) }
+    if _, ok := out["po_mid_24h"]; !ok { t.Errorf("snapshots = %v", out) }
+}
+
+func TestScheduleHelpers(t *testing.T) {
+    s, err := ParseSchedule("7d, 1h,24h,6h,30d,72h")
+    if err != nil { t.Fatal(err) }
+    var labels []string
+    for _, d := range s { labels = append(labels, SnapshotLabel(d)) }
+    if strings.Join(labels, ",") != "1h,6h,24h,72h,7d,30d" { t.Fatalf("labels = %v", labels) }
+    if _, err := ParseSchedule("1h,soon"); err == nil { t.Fatal("invalid offset accepted") }
+    if Horizon(nil) != 32*24*time.Hour { t.Errorf("horizon = %s", Horizon(nil)) }
+
+    // A late run takes the latest passed offset, never the missed ones.
+    if step, ok := dueStep(DefaultSchedule, 30*time.Hour, map[string]bool{"1h": true}); !ok || step != 2 { t.Errorf("due = %d, %v", step, ok) }
+    if _, ok := dueStep(DefaultSchedule, 40*24*time.Hour, map[string]bool{"30d": true}); ok { t.Error("due after the last snapshot") }
+
+    snap := func(likes int64) models.PostOutcome { return models.PostOutcome{Impressions: 1000, Likes: likes} }
+    if !settled([]models.PostOutcome{snap(10), snap(15)}, 3*24*time.Hour, DefaultMinChange) { t.Error("0.5% growth not settled") }
+    if settled([]models.PostOutcome{snap(10), snap(30)}, 3*24*time.Hour, DefaultMinChange) { t.Error("2% growth settled") }
+    if settled([]models.PostOutcome{snap(0), snap(0)}, 6*time.Hour, DefaultMinChange) { t.Error("settled before a day") }
+}
@@ -0,0 +1,63 @@
+package collector
+
+import (
+    "os"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/engine/ratelimit"
+    "github.com/bitesinbyte/ferret/pkg/external"
+)
+
+// DefaultMaxWait is how long a run waits for read budget before deferring
+// the platform's remaining posts to the next run.
+const DefaultMaxWait = 30 * time.Second
+
+// DefaultRates are post reads per hour, kept under the platforms' read
+// limits (Twitter's tweet lookup allows 15 per 15 minutes; Facebook and
+// Instagram make two requests per post against a per-user hourly budget;
+// a YouTube videos.list read costs 1 of the 10,000 daily quota units).
+var DefaultRates = map[string]float64{
+    "twitter":   60,
+    "facebook":  90,
+    "instagram": 90,
+    "thread":    100,
+    "linkedin":  500,
+    "mastodon":  1000,
+    "youtube":   400,
+}
+
+// Limiter builds the read budget: DefaultRates, overridden by
+// ANALYTICS_RATE_<PLATFORM> (reads per hour), and up to a quarter hour of
+// budget as burst. Buckets are prefixed "analytics:", so collecting never
+// spends publish budget. A nil store keeps state in memory; maxWait <= 0
+// means DefaultMaxWait.
+func Limiter(store ratelimit.Store, maxWait time.Duration) *ratelimit.Limiter {
+    if store == nil { store = ratelimit.NewMemoryStore() }
+    if maxWait <= 0 { maxWait = DefaultMaxWait }
+    l := &ratelimit.Limiter{
+        Store:   store,
+        Rates:   map[string]ratelimit.Rate{},
+        Default: hourly(60),
+        Prefix:  "analytics:",
+        MaxWait: maxWait,
+    }
+    // Metrics-only platforms (YouTube) are not registered posters.
+    names := map[string]bool{}
+    for _, p := range external.Platforms() { names[p.Name] = true }
+    for name := range DefaultRates { names[name] = true }
+    for name := range names {
+        perHour, ok := DefaultRates[name]
+        if !ok { perHour = 60 }
+        if v := os.Getenv("ANALYTICS_RATE_" + strings.ToUpper(name)); v != "" {
+            if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 { perHour = f }
+        }
+        l.Rates[name] = hourly(perHour)
+    }
+    return l
+}
+
+func hourly(n float64) ratelimit.Rate {
+    return ratelimit.Rate{PerSecond: n / 3600, Burst: max(1, int(n/4))}
+}
@@ -0,0 +1,101 @@
+package collector
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/models"
+)
+
+const day = 24 * time.Hour
+
+// DefaultSchedule is when a post is snapshotted, by time since publishing:
+// engagement moves fast in the first hours and hardly at all after a week.
+var DefaultSchedule = []time.Duration{time.Hour, 6 * time.Hour, day, 3 * day, 7 * day, 30 * day}
+
+// DefaultMinChange is the growth between a post's last two snapshots below
+// which it counts as settled (1%).
+const DefaultMinChange = 0.01
+
+// settleAge is the age before which a post never counts as settled: early
+// snapshots can lag behind the platform's own aggregation.
+const settleAge = day
+
+// SnapshotLabel names the snapshot taken at offset d ("1h", "72h", "7d").
+func SnapshotLabel(d time.Duration) string {
+    if d >= 7*day && d%day == 0 { return strconv.Itoa(int(d/day)) + "d" }
+    if d%time.Hour == 0 { return strconv.Itoa(int(d/time.Hour)) + "h" }
+    return d.String()
+}
+
+// ParseSchedule parses comma-separated offsets such as "1h,6h,24h,3d,7d,30d"
+// (a "d" suffix counts days) and returns them sorted.
+func ParseSchedule(s string) ([]time.Duration, error) {
+    var out []time.Duration
+    for _, part := range strings.Split(s, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" { continue }
+        var d time.Duration
+        if n, ok := strings.CutSuffix(part, "d"); ok {
+            days, err := strconv.Atoi(n)
+            if err != nil { return nil, fmt.Errorf("invalid schedule offset %q", part) }
+            d = time.Duration(days) * day
+        } else {
+            var err error
+            if d, err = time.ParseDuration(part); err != nil { return nil, fmt.Errorf("invalid schedule offset %q", part) }
+        }
+        if d <= 0 { return nil, fmt.Errorf("invalid schedule offset %q", part) }
+        out = append(out, d)
+    }
+    if len(out) == 0 { return nil, fmt.Errorf("empty schedule") }
+    sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+    return out, nil
+}
+
+// Horizon is how far back published posts can still have a snapshot due:
+// the last offset of schedule plus two days of slack for missed runs.
+func Horizon(schedule []time.Duration) time.Duration {
+    if len(schedule) == 0 { schedule = DefaultSchedule }
+    return schedule[len(schedule)-1] + 2*day
+}
+
+// dueStep returns the index of the snapshot a post of the given age needs:
+// the latest offset it has passed, unless that snapshot was taken. Earlier
+// missed offsets are not backfilled, since the counters would be recorded at
+// the wrong age.
+func dueStep(schedule []time.Duration, age time.Duration, taken map[string]bool) (int, bool) {
+    for i := len(schedule) - 1; i >= 0; i-- {
+        if schedule[i] > age { continue }
+        if taken[SnapshotLabel(schedule[i])] { return 0, false }
+        return i, true
+    }
+    return 0, false
+}
+
+// takenSnapshots lists the labels of snaps (metadata.snapshot).
+func takenSnapshots(snaps []models.PostOutcome) map[string]bool {
+    taken := map[string]bool{}
+    for _, o := range snaps {
+        if m, ok := o.Metadata.(map[string]any); ok {
+            if label, _ := m["snapshot"].(string); label != "" { taken[label] = true }
+        }
+    }
+    return taken
+}
+
+// settled reports whether a post of the given age stopped changing: its last
+// two snapshots (oldest first in snaps) differ by less than minChange of the
+// earlier one's total activity.
+func settled(snaps []models.PostOutcome, age time.Duration, minChange float64) bool {
+    if age < settleAge || len(snaps) < 2 { return false }
+    prev, last := activity(snaps[len(snaps)-2]), activity(snaps[len(snaps)-1])
+    return last-prev <= minChange*prev
+}
+
+// activity sums every counter of o.
+func activity(o models.PostOutcome) float64 {
+    return float64(o.Impressions + o.Reach + o.Likes + o.Comments + o.Shares + o.Clicks + o.Saves + o.Conversions)
+}
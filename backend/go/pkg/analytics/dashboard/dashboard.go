@@ -0,0 +1,241 @@
+// Package dashboard answers the analytics API: per-post outcomes and top
+// posts from post_outcomes, and campaign and platform summaries from the
+// trend_metrics rollups, as JSON or CSV (see Write).
+package dashboard
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+    "github.com/bitesinbyte/ferret/pkg/analytics/trends"
+    "github.com/bitesinbyte/ferret/pkg/models"
+)
+
+// Page sizes.
+const (
+    DefaultLimit = 50
+    MaxLimit     = 500
+    // DefaultRange is the range of a filter without From.
+    DefaultRange = 30 * 24 * time.Hour
+)
+
+// ErrNotFound is returned by PostHistory for unknown posts.
+var ErrNotFound = errors.New("not found")
+
+// Filter selects an org's published posts by published_at in [From, To).
+// Platform and CampaignID only filter post lists; summaries cover whole
+// UTC days.
+type Filter struct {
+    OrgID      string
+    From       time.Time
+    To         time.Time
+    Platform   string
+    CampaignID string
+    Limit      int
+    Offset     int
+}
+
+// Page is one page of a list and the size of the whole list.
+type Page[T any] struct {
+    Items  []T `json:"items"`
+    Total  int `json:"total"`
+    Limit  int `json:"limit"`
+    Offset int `json:"offset"`
+}
+
+// Post is a published post with its latest snapshot.
+type Post struct {
+    ID             string    `json:"id"`
+    Platform       string    `json:"platform"`
+    ExternalID     string    `json:"external_id,omitempty"`
+    CampaignID     string    `json:"campaign_id,omitempty"`
+    Topic          string    `json:"topic,omitempty"`
+    VariantID      string    `json:"variant_id,omitempty"`
+    Caption        string    `json:"caption,omitempty"`
+    PublishedAt    time.Time `json:"published_at"`
+    CollectedAt    time.Time `json:"collected_at"`
+    Impressions    int64     `json:"impressions"`
+    Reach          int64     `json:"reach"`
+    Likes          int64     `json:"likes"`
+    Comments       int64     `json:"comments"`
+    Shares         int64     `json:"shares"`
+    Clicks         int64     `json:"clicks"`
+    Saves          int64     `json:"saves"`
+    Conversions    int64     `json:"conversions"`
+    Engagements    int64     `json:"engagements"`
+    EngagementRate *float64  `json:"engagement_rate"` // over impressions, else reach; null without either
+}
+
+// Summary totals the posts of one campaign or platform over a range.
+type Summary struct {
+    Key                    string   `json:"key"` // campaign id or platform
+    Name                   string   `json:"name,omitempty"`
+    Posts                  int64    `json:"posts"`
+    Impressions            int64    `json:"impressions"`
+    Reach                  int64    `json:"reach"`
+    Likes                  int64    `json:"likes"`
+    Comments               int64    `json:"comments"`
+    Shares                 int64    `json:"shares"`
+    Clicks                 int64    `json:"clicks"`
+    Saves                  int64    `json:"saves"`
+    Conversions            int64    `json:"conversions"`
+    Engagements            int64    `json:"engagements"`
+    EngagementRate         *float64 `json:"engagement_rate"`
+    CTR                    *float64 `json:"ctr"`
+    FollowerEngagementRate *float64 `json:"follower_engagement_rate"`
+    FollowerReachRate      *float64 `json:"follower_reach_rate"`
+}
+
+// History is every snapshot of one post, oldest first.
+type History struct {
+    PostID    string               `json:"post_id"`
+    OrgID     string               `json:"org_id"`
+    Snapshots []models.PostOutcome `json:"snapshots"`
+}
+
+// ParseRange reads from and to as RFC 3339 times or YYYY-MM-DD dates; a
+// date as to includes that whole day. Empty to is now and empty from
+// DefaultRange before to.
+func ParseRange(from, to string, now time.Time) (time.Time, time.Time, error) {
+    var f, t time.Time
+    var err error
+    if t, err = parseTime(to, true); err != nil { return f, t, fmt.Errorf("invalid to: %w", err) }
+    if t.IsZero() { t = now }
+    if f, err = parseTime(from, false); err != nil { return f, t, fmt.Errorf("invalid from: %w", err) }
+    if f.IsZero() { f = t.Add(-DefaultRange) }
+    if !f.Before(t) { return f, t, errors.New("from must be before to") }
+    return f, t, nil
+}
+
+func parseTime(s string, end bool) (time.Time, error) {
+    if s == "" { return time.Time{}, nil }
+    if t, err := time.Parse(time.RFC3339, s); err == nil { return t, nil }
+    d, err := time.Parse(time.DateOnly, s)
+    if err != nil { return time.Time{}, errors.New("want RFC 3339 or YYYY-MM-DD") }
+    if end { d = d.AddDate(0, 0, 1) }
+    return d, nil
+}
+
+// Posts lists f's posts, newest first.
+func Posts(ctx context.Context, db *sql.DB, f Filter) (Page[Post], error) {
+    return posts(ctx, db, f, "published_at")
+}
+
+// TopPosts lists f's posts by metric (a key of calendarrepo.PostOrders,
+// engagements when empty), best first.
+func TopPosts(ctx context.Context, db *sql.DB, f Filter, metric string) (Page[Post], error) {
+    if metric == "" { metric = trends.MetricEngagements }
+    if metric == "published_at" { return Page[Post]{}, fmt.Errorf("unknown metric %q", metric) }
+    return posts(ctx, db, f, metric)
+}
+
+func posts(ctx context.Context, db *sql.DB, f Filter, order string) (Page[Post], error) {
+    f.Limit, f.Offset = pageBounds(f.Limit, f.Offset)
+    page := Page[Post]{Items: []Post{}, Limit: f.Limit, Offset: f.Offset}
+    if _, ok := calendarrepo.PostOrders[order]; !ok { return page, fmt.Errorf("unknown metric %q", order) }
+    rows, total, err := calendarrepo.Repository{DB: db}.PostOutcomes(ctx, calendarrepo.PostQuery{
+        OrgID: f.OrgID, From: f.From, To: f.To, Platform: f.Platform, CampaignID: f.CampaignID,
+        OrderBy: order, Limit: f.Limit, Offset: f.Offset,
+    })
+    if err != nil { return page, err }
+    page.Total = total
+    for _, r := range rows { page.Items = append(page.Items, toPost(r)) }
+    return page, nil
+}
+
+func toPost(r calendarrepo.PostOutcomeRow) Post {
+    o := r.Outcome
+    p := Post{
+        ID: r.PostID, Platform: r.Platform, ExternalID: r.ExternalID, CampaignID: r.CampaignID, Topic: r.Topic,
+        VariantID: r.VariantID, Caption: r.Caption, PublishedAt: r.PublishedAt, CollectedAt: r.CollectedAt,
+        Impressions: o.Impressions, Reach: o.Reach, Likes: o.Likes, Comments: o.Comments, Shares: o.Shares,
+        Clicks: o.Clicks, Saves: o.Saves, Conversions: o.Conversions,
+        Engagements: o.Likes + o.Comments + o.Shares + o.Saves + o.Clicks,
+    }
+    den := o.Impressions
+    if den == 0 { den = o.Reach }
+    if den > 0 {
+        rate := float64(p.Engagements) / float64(den)
+        p.EngagementRate = &rate
+    }
+    return p
+}
+
+// PostHistory returns every snapshot of a post. OrgID is set whenever the
+// post exists, so callers can check access before looking at the error.
+func PostHistory(ctx context.Context, db *sql.DB, postID string) (History, error) {
+    h := History{PostID: postID, Snapshots: []models.PostOutcome{}}
+    repo := calendarrepo.Repository{DB: db}
+    orgID, err := repo.PostOrg(ctx, postID)
+    if err == sql.ErrNoRows { return h, fmt.Errorf("post %s: %w", postID, ErrNotFound) }
+    if err != nil { return h, err }
+    h.OrgID = orgID
+    snaps, err := repo.Snapshots(ctx, []string{postID})
+    if err != nil { return h, err }
+    if s := snaps[postID]; s != nil { h.Snapshots = s }
+    return h, nil
+}
+
+// Campaigns summarizes f's range per campaign from the daily rollups, most
+// engagements first, with campaign names.
+func Campaigns(ctx context.Context, db *sql.DB, f Filter) (Page[Summary], error) {
+    f.Limit, f.Offset = pageBounds(f.Limit, f.Offset)
+    page := Page[Summary]{Items: []Summary{}, Limit: f.Limit, Offset: f.Offset}
+    repo := calendarrepo.Repository{DB: db}
+    all, err := summaries(ctx, repo, f, trends.DimCampaign)
+    if err != nil { return page, err }
+    names, err := repo.CampaignNames(ctx, f.OrgID)
+    if err != nil { return page, err }
+    page.Total = len(all)
+    for _, s := range all[min(f.Offset, len(all)):min(f.Offset+f.Limit, len(all))] {
+        s.Name = names[s.Key]
+        page.Items = append(page.Items, s)
+    }
+    return page, nil
+}
+
+// Platforms summarizes f's range per platform from the daily rollups, most
+// engagements first.
+func Platforms(ctx context.Context, db *sql.DB, f Filter) ([]Summary, error) {
+    return summaries(ctx, calendarrepo.Repository{DB: db}, f, trends.DimPlatform)
+}
+
+func summaries(ctx context.Context, r trends.Reader, f Filter, kind string) ([]Summary, error) {
+    totals, err := trends.QueryTotals(ctx, r, trends.SeriesQuery{
+        OrgID: f.OrgID, Granularity: trends.Daily, Dimension: kind + ":", From: f.From, To: f.To,
+    })
+    if err != nil { return nil, err }
+    out := make([]Summary, 0, len(totals))
+    for _, t := range totals { out = append(out, toSummary(t, kind)) }
+    sort.SliceStable(out, func(i, j int) bool { return out[i].Engagements > out[j].Engagements })
+    return out, nil
+}
+
+func toSummary(t trends.Total, kind string) Summary {
+    v := t.Values
+    n := func(metric string) int64 { return int64(v[metric]) }
+    rate := func(metric string) *float64 {
+        if x, ok := v[metric]; ok { return &x }
+        return nil
+    }
+    return Summary{
+        Key: strings.TrimPrefix(t.Dimension, kind+":"), Posts: n(trends.MetricPosts),
+        Impressions: n(trends.MetricImpressions), Reach: n(trends.MetricReach), Likes: n(trends.MetricLikes),
+        Comments: n(trends.MetricComments), Shares: n(trends.MetricShares), Clicks: n(trends.MetricClicks),
+        Saves: n(trends.MetricSaves), Conversions: n(trends.MetricConversions), Engagements: n(trends.MetricEngagements),
+        EngagementRate: rate(trends.MetricEngagementRate), CTR: rate(trends.MetricCTR),
+        FollowerEngagementRate: rate(trends.MetricFollowerEngagementRate), FollowerReachRate: rate(trends.MetricFollowerReachRate),
+    }
+}
+
+// pageBounds applies the default and maximum page size.
+func pageBounds(limit, offset int) (int, int) {
+    if limit <= 0 { limit = DefaultLimit }
+    return min(limit, MaxLimit), max(offset, 0)
+}
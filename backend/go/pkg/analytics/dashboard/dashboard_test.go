@@ -0,0 +1,71 @@
+package dashboard
+
+import (
+    "bytes"
+    "context"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+    "github.com/bitesinbyte/ferret/pkg/analytics/trends"
+    "github.com/bitesinbyte/ferret/pkg/models"
+)
+
+func TestParseRange(t *testing.T) {
+    now := time.Date(2026, 5, 20, 12, 0, 0, 0, time.UTC)
+    f, to, err := ParseRange("", "", now)
+    if err != nil || !to.Equal(now) || !f.Equal(now.Add(-DefaultRange)) { t.Fatalf("default = %s..%s, %v", f, to, err) }
+    // A date as to covers that whole day.
+    f, to, err = ParseRange("2026-05-01", "2026-05-31", now)
+    if err != nil || !f.Equal(time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)) { t.Fatalf("dates = %s..%s, %v", f, to, err) }
+    if _, to, _ := ParseRange("", "2026-05-10T08:00:00Z", now); !to.Equal(time.Date(2026, 5, 10, 8, 0, 0, 0, time.UTC)) { t.Errorf("rfc3339 to = %s", to) }
+    if _, _, err := ParseRange("2026-05-10", "2026-05-01", now); err == nil { t.Error("inverted range accepted") }
+    if _, _, err := ParseRange("last week", "", now); err == nil || !strings.Contains(err.Error(), "from") { t.Errorf("bad from: %v", err) }
+}
+
+func TestToPost(t *testing.T) {
+    row := calendarrepo.PostOutcomeRow{PostPerformance: calendarrepo.PostPerformance{
+        PostID: "sp1", Platform: "linkedin", Outcome: calendarrepo.VariantOutcome{Reach: 200, Likes: 8, Comments: 2},
+    }}
+    p := toPost(row)
+    if p.Engagements != 10 || p.EngagementRate == nil || *p.EngagementRate != 0.05 { t.Fatalf("post = %+v", p) }
+    row.Outcome = calendarrepo.VariantOutcome{Likes: 3}
+    if p := toPost(row); p.EngagementRate != nil { t.Errorf("rate without denominator = %v", *p.EngagementRate) }
+}
+
+type rollups []models.TrendMetric
+
+func (r rollups) TrendSeries(_ context.Context, _, source, dimension string, _ []string, from, to time.Time) ([]models.TrendMetric, error) {
+    var out []models.TrendMetric
+    for _, m := range r {
+        if m.Source == source && strings.HasPrefix(m.Dimension, dimension) && !m.BucketStart.Before(from) && m.BucketStart.Before(to) { out = append(out, m) }
+    }
+    return out, nil
+}
+
+func TestSummariesAndCSV(t *testing.T) {
+    day := time.Date(2026, 5, 4, 9, 0, 0, 0, time.UTC)
+    perf := func(id, platform string, o calendarrepo.VariantOutcome) calendarrepo.PostPerformance {
+        o.Posts = 1
+        return calendarrepo.PostPerformance{OrgID: "org1", PostID: id, Platform: platform, PublishedAt: day, Outcome: o}
+    }
+    r := rollups(trends.Rollup([]calendarrepo.PostPerformance{
+        perf("a", "mastodon", calendarrepo.VariantOutcome{Likes: 4}),
+        perf("b", "instagram", calendarrepo.VariantOutcome{Impressions: 100, Likes: 9, Clicks: 1}),
+    }))
+    got, err := summaries(context.Background(), r, Filter{OrgID: "org1", From: day, To: day.Add(time.Hour)}, trends.DimPlatform)
+    if err != nil { t.Fatal(err) }
+    if len(got) != 2 || got[0].Key != "instagram" || got[0].Engagements != 10 || *got[0].CTR != 0.01 || got[1].EngagementRate != nil { t.Fatalf("summaries = %+v", got) }
+
+    var b bytes.Buffer
+    if err := Write(&b, got, FormatCSV); err != nil { t.Fatal(err) }
+    lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+    if len(lines) != 3 || !strings.HasPrefix(lines[1], "instagram,,1,100,0,9,0,0,1,0,0,10,0.1,0.01,,") || !strings.HasSuffix(lines[2], ",,,,") { t.Errorf("csv = %q", b.String()) }
+    if err := Write(&b, 42, FormatCSV); err == nil { t.Error("CSV of an int") }
+}
+
+func TestPageBounds(t *testing.T) {
+    if l, o := pageBounds(0, -5); l != DefaultLimit || o != 0 { t.Errorf("defaults = %d, %d", l, o) }
+    if l, _ := pageBounds(10_000, 0); l != MaxLimit { t.Errorf("max = %d", l) }
+}
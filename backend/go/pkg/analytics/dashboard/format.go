@@ -0,0 +1,101 @@
+package dashboard
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "strconv"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/analytics/trends"
+)
+
+// Output formats of Write.
+const (
+    FormatJSON = "json"
+    FormatCSV  = "csv"
+)
+
+// ContentTypes maps each format to its HTTP content type.
+var ContentTypes = map[string]string{
+    FormatJSON: "application/json",
+    FormatCSV:  "text/csv; charset=utf-8",
+}
+
+// Write renders v, a result of this package or of trends.QuerySeries, as
+// JSON or CSV. CSV has one row per post, summary, snapshot or series point
+// and leaves out paging (callers send the total separately).
+func Write(w io.Writer, v any, format string) error {
+    switch format {
+    case "", FormatJSON:
+        enc := json.NewEncoder(w)
+        enc.SetIndent("", "  ")
+        return enc.Encode(v)
+    case FormatCSV:
+        return writeCSV(w, v)
+    }
+    return fmt.Errorf("unknown format %q (want json or csv)", format)
+}
+
+func writeCSV(w io.Writer, v any) error {
+    cw := csv.NewWriter(w)
+    i := func(n int64) string { return strconv.FormatInt(n, 10) }
+    ts := func(t time.Time) string { return t.UTC().Format(time.RFC3339) }
+    switch v := v.(type) {
+    case Page[Post]:
+        _ = cw.Write([]string{
+            "id", "platform", "external_id", "campaign_id", "topic", "variant_id", "published_at", "collected_at",
+            "impressions", "reach", "likes", "comments", "shares", "clicks", "saves", "conversions", "engagements", "engagement_rate", "caption",
+        })
+        for _, p := range v.Items {
+            _ = cw.Write([]string{
+                p.ID, p.Platform, p.ExternalID, p.CampaignID, p.Topic, p.VariantID, ts(p.PublishedAt), ts(p.CollectedAt),
+                i(p.Impressions), i(p.Reach), i(p.Likes), i(p.Comments), i(p.Shares), i(p.Clicks), i(p.Saves), i(p.Conversions),
+                i(p.Engagements), optional(p.EngagementRate), p.Caption,
+            })
+        }
+    case Page[Summary]:
+        return writeCSV(w, v.Items)
+    case []Summary:
+        _ = cw.Write([]string{
+            "key", "name", "posts", "impressions", "reach", "likes", "comments", "shares", "clicks", "saves", "conversions",
+            "engagements", "engagement_rate", "ctr", "follower_engagement_rate", "follower_reach_rate",
+        })
+        for _, s := range v {
+            _ = cw.Write([]string{
+                s.Key, s.Name, i(s.Posts), i(s.Impressions), i(s.Reach), i(s.Likes), i(s.Comments), i(s.Shares), i(s.Clicks),
+                i(s.Saves), i(s.Conversions), i(s.Engagements), optional(s.EngagementRate), optional(s.CTR),
+                optional(s.FollowerEngagementRate), optional(s.FollowerReachRate),
+            })
+        }
+    case History:
+        _ = cw.Write([]string{
+            "post_id", "snapshot_id", "platform", "collected_at", "impressions", "reach", "likes", "comments", "shares",
+            "clicks", "saves", "conversions",
+        })
+        for _, o := range v.Snapshots {
+            _ = cw.Write([]string{
+                v.PostID, o.ID, o.Platform, ts(o.CollectedAt), i(o.Impressions), i(o.Reach), i(o.Likes), i(o.Comments),
+                i(o.Shares), i(o.Clicks), i(o.Saves), i(o.Conversions),
+            })
+        }
+    case []trends.Series:
+        _ = cw.Write([]string{"dimension", "metric", "granularity", "start", "end", "value"})
+        for _, s := range v {
+            for _, p := range s.Points {
+                _ = cw.Write([]string{s.Dimension, s.Metric, string(s.Granularity), ts(p.Start), ts(p.End), optional(p.Value)})
+            }
+        }
+    default:
+        return fmt.Errorf("no CSV form for %T", v)
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+// optional formats a rate; null rates are empty cells.
+func optional(v *float64) string {
+    if v == nil { return "" }
+    return strconv.FormatFloat(*v, 'f', -1, 64)
+}
@@ -0,0 +1,87 @@
+package experiments
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+)
+
+// Output formats of Write.
+const (
+    FormatJSON     = "json"
+    FormatCSV      = "csv"
+    FormatMarkdown = "md"
+)
+
+// ContentTypes maps each format to its HTTP content type.
+var ContentTypes = map[string]string{
+    FormatJSON:     "application/json",
+    FormatCSV:      "text/csv; charset=utf-8",
+    FormatMarkdown: "text/markdown; charset=utf-8",
+}
+
+// Write renders r as JSON, CSV (one row per arm) or Markdown.
+func Write(w io.Writer, r Report, format string) error {
+    switch format {
+    case "", FormatJSON:
+        enc := json.NewEncoder(w)
+        enc.SetIndent("", "  ")
+        return enc.Encode(r)
+    case FormatCSV:
+        return writeCSV(w, r)
+    case FormatMarkdown, "markdown":
+        return writeMarkdown(w, r)
+    }
+    return fmt.Errorf("unknown format %q (want json, csv or md)", format)
+}
+
+func writeCSV(w io.Writer, r Report) error {
+    cw := csv.NewWriter(w)
+    _ = cw.Write([]string{
+        "experiment_id", "arm_id", "variant_id", "control", "posts", "impressions", "engagements", "clicks", "conversions",
+        "engagement_rate", "engagement_rate_low", "engagement_rate_high", "ctr", "ctr_low", "ctr_high",
+        "conversion_rate", "conversion_rate_low", "conversion_rate_high", "lift", "p_value", "significant", "decision", "winner",
+    })
+    f := func(v float64) string { return strconv.FormatFloat(v, 'f', 6, 64) }
+    for _, a := range sortArms(&r) {
+        _ = cw.Write([]string{
+            r.ExperimentID, a.ArmID, a.VariantID, strconv.FormatBool(a.Control), strconv.Itoa(a.Posts),
+            strconv.FormatInt(a.Impressions, 10), strconv.FormatInt(a.Engagements, 10), strconv.FormatInt(a.Clicks, 10), strconv.FormatInt(a.Conversions, 10),
+            f(a.EngagementRate.Value), f(a.EngagementRate.Low), f(a.EngagementRate.High), f(a.CTR.Value), f(a.CTR.Low), f(a.CTR.High),
+            f(a.ConversionRate.Value), f(a.ConversionRate.Low), f(a.ConversionRate.High), f(a.Lift), f(a.PValue),
+            strconv.FormatBool(a.Significant), r.Decision, strconv.FormatBool(a.VariantID == r.Winner),
+        })
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+func writeMarkdown(w io.Writer, r Report) error {
+    var b strings.Builder
+    title := r.ExperimentID
+    if r.Name != "" { title = r.Name + " (" + r.ExperimentID + ")" }
+    fmt.Fprintf(&b, "# Experiment %s\n\n", title)
+    fmt.Fprintf(&b, "**Decision: %s.** %s\n\n", r.Decision, r.Recommendation)
+    fmt.Fprintf(&b, "Metric: %s, %.0f%% confidence intervals.\n\n", r.Metric, 100*r.Confidence)
+    b.WriteString("| Arm | Posts | Impressions | Engagement rate | CTR | Conversions | Lift | p-value |\n")
+    b.WriteString("|---|---:|---:|---|---|---:|---:|---:|\n")
+    pct := func(x Rate) string { return fmt.Sprintf("%.2f%% (%.2f–%.2f)", 100*x.Value, 100*x.Low, 100*x.High) }
+    for _, a := range sortArms(&r) {
+        name, lift, p := a.VariantID, fmt.Sprintf("%+.1f%%", 100*a.Lift), fmt.Sprintf("%.4f", a.PValue)
+        if a.Control {
+            name += " (control)"
+            lift, p = "–", "–"
+        } else if a.Significant {
+            p += " *"
+        }
+        if a.VariantID == r.Winner { name = "**" + name + "**" }
+        fmt.Fprintf(&b, "| %s | %d | %d | %s | %s | %d (%s) | %s | %s |\n",
+            name, a.Posts, a.Impressions, pct(a.EngagementRate), pct(a.CTR), a.Conversions, pct(a.ConversionRate), lift, p)
+    }
+    b.WriteString("\n\\* significant after Bonferroni correction.\n")
+    _, err := io.WriteString(w, b.String())
+    return err
+}
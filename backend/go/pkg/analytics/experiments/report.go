@@ -0,0 +1,249 @@
+// Package experiments analyzes A/B experiments: per-arm rates with
+// confidence intervals, significance against the control arm and a
+// recommendation on whether to stop.
+package experiments
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "math"
+    "sort"
+
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+)
+
+// Metrics the report can decide on; each is a rate over impressions.
+const (
+    MetricEngagement = "engagement" // likes, comments, shares, saves and clicks
+    MetricCTR        = "ctr"        // clicks
+    MetricConversion = "conversion" // conversions
+)
+
+// Decisions of a report.
+const (
+    DecisionShip        = "ship"         // a variant beats the control
+    DecisionKeepControl = "keep_control" // every variant is worse than the control
+    DecisionContinue    = "continue"     // not enough evidence yet
+)
+
+// Rate is a proportion with its confidence interval (Wilson score).
+type Rate struct {
+    Value float64 `json:"value"`
+    Low   float64 `json:"low"`
+    High  float64 `json:"high"`
+}
+
+// ArmResult is one arm's totals, rates and comparison with the control.
+type ArmResult struct {
+    ArmID          string  `json:"arm_id"`
+    VariantID      string  `json:"variant_id"`
+    Control        bool    `json:"control"`
+    Posts          int     `json:"posts"`
+    Impressions    int64   `json:"impressions"`
+    Engagements    int64   `json:"engagements"`
+    Clicks         int64   `json:"clicks"`
+    Conversions    int64   `json:"conversions"`
+    EngagementRate Rate    `json:"engagement_rate"`
+    CTR            Rate    `json:"ctr"`
+    ConversionRate Rate    `json:"conversion_rate"`
+    Lift           float64 `json:"lift"`    // relative change of Metric vs the control
+    PValue         float64 `json:"p_value"` // two-sided two-proportion z-test of Metric vs the control
+    Significant    bool    `json:"significant"`
+}
+
+// Report is the analysis of one experiment.
+type Report struct {
+    ExperimentID string      `json:"experiment_id"`
+    OrgID        string      `json:"org_id,omitempty"`
+    Name         string      `json:"name,omitempty"`
+    Metric       string      `json:"metric"`
+    Confidence   float64     `json:"confidence"`
+    Arms         []ArmResult `json:"arms"`
+    Decision     string      `json:"decision"`
+    Winner       string      `json:"winner,omitempty"` // variant id
+    // NeededImpressions estimates the impressions per arm to detect the
+    // best variant's observed lift at 80% power, when continuing.
+    NeededImpressions int64  `json:"needed_impressions,omitempty"`
+    Recommendation    string `json:"recommendation"`
+}
+
+// Options tune Analyze; zero values use the defaults.
+type Options struct {
+    Metric     string  // decision metric (MetricEngagement)
+    Confidence float64 // confidence level (0.95)
+    Control    string  // variant id of the control (the first arm)
+    MinPosts   int     // posts every arm needs before a decision (5)
+}
+
+// Arm is the input of Analyze: an arm and its post outcomes.
+type Arm struct {
+    ArmID     string
+    VariantID string
+    Outcome   calendarrepo.VariantOutcome
+}
+
+// Analyze compares every arm with the control on opts.Metric. Comparisons
+// are Bonferroni-corrected for the number of variants. A variant ships when
+// it is significantly better than the control, the control is kept when
+// every variant is significantly worse, and otherwise the experiment should
+// continue; no decision is made before every arm has MinPosts posts.
+func Analyze(experimentID string, arms []Arm, opts Options) (Report, error) {
+    if opts.Metric == "" { opts.Metric = MetricEngagement }
+    if opts.Confidence == 0 { opts.Confidence = 0.95 }
+    if opts.MinPosts <= 0 { opts.MinPosts = 5 }
+    r := Report{ExperimentID: experimentID, Metric: opts.Metric, Confidence: opts.Confidence, Decision: DecisionContinue}
+    if opts.Confidence <= 0 || opts.Confidence >= 1 { return r, fmt.Errorf("confidence %v must be between 0 and 1", opts.Confidence) }
+    if len(arms) < 2 { return r, fmt.Errorf("experiment %s: need at least 2 arms, got %d", experimentID, len(arms)) }
+    control := 0
+    if opts.Control != "" {
+        control = -1
+        for i, a := range arms {
+            if a.VariantID == opts.Control { control = i }
+        }
+        if control < 0 { return r, fmt.Errorf("experiment %s: no arm for control variant %q", experimentID, opts.Control) }
+    }
+
+    z := zQuantile(1 - (1-opts.Confidence)/2)
+    for i, a := range arms {
+        o := a.Outcome
+        res := ArmResult{
+            ArmID: a.ArmID, VariantID: a.VariantID, Control: i == control, Posts: o.Posts,
+            Impressions: o.Impressions, Engagements: o.Likes + o.Comments + o.Shares + o.Saves + o.Clicks,
+            Clicks: o.Clicks, Conversions: o.Conversions,
+        }
+        // Platforms without impression counts still give valid rates.
+        res.Impressions = max(res.Impressions, res.Engagements, res.Conversions)
+        res.EngagementRate = wilson(res.Engagements, res.Impressions, z)
+        res.CTR = wilson(res.Clicks, res.Impressions, z)
+        res.ConversionRate = wilson(res.Conversions, res.Impressions, z)
+        r.Arms = append(r.Arms, res)
+    }
+    ctl := r.Arms[control]
+    cs, err := successes(ctl, opts.Metric)
+    if err != nil { return r, err }
+
+    alpha := (1 - opts.Confidence) / float64(len(arms)-1)
+    ready := true
+    best, worse := -1, 0
+    for i := range r.Arms {
+        a := &r.Arms[i]
+        if a.Posts < opts.MinPosts { ready = false }
+        if i == control { continue }
+        s, _ := successes(*a, opts.Metric)
+        p1, p0 := rate(s, a.Impressions), rate(cs, ctl.Impressions)
+        if p0 > 0 { a.Lift = p1/p0 - 1 }
+        a.PValue = twoProportionP(s, a.Impressions, cs, ctl.Impressions)
+        a.Significant = a.PValue < alpha
+        if a.Significant && p1 < p0 { worse++ }
+        if best < 0 || p1 > metricRate(r.Arms[best], opts.Metric) { best = i }
+    }
+    b := r.Arms[best]
+    switch {
+    case !ready:
+        r.Recommendation = fmt.Sprintf("Continue: every arm needs at least %d posts with outcomes before deciding.", opts.MinPosts)
+    case b.Significant && b.Lift > 0:
+        r.Decision, r.Winner = DecisionShip, b.VariantID
+        r.Recommendation = fmt.Sprintf("Ship %s: %s %+.1f%% vs control (p=%.4f).", b.VariantID, opts.Metric, 100*b.Lift, b.PValue)
+    case worse == len(arms)-1:
+        r.Decision, r.Winner = DecisionKeepControl, ctl.VariantID
+        r.Recommendation = fmt.Sprintf("Keep control %s: every variant has a significantly lower %s.", ctl.VariantID, opts.Metric)
+    default:
+        p0, p1 := metricRate(ctl, opts.Metric), metricRate(b, opts.Metric)
+        if p1 > p0 { r.NeededImpressions = sampleSize(p0, p1, alpha, 0.8) }
+        r.Recommendation = fmt.Sprintf("Continue: no variant differs significantly from control %s on %s yet.", ctl.VariantID, opts.Metric)
+        if r.NeededImpressions > 0 {
+            r.Recommendation += fmt.Sprintf(" About %d impressions per arm would confirm %s's %+.1f%% lift.", r.NeededImpressions, b.VariantID, 100*b.Lift)
+        }
+    }
+    return r, nil
+}
+
+// Load analyzes experimentID from experiment_arms and the latest
+// post_outcomes of the posts scheduled from each arm's variant.
+func Load(ctx context.Context, db *sql.DB, experimentID string, opts Options) (Report, error) {
+    var orgID, name string
+    err := db.QueryRowContext(ctx, `SELECT org_id, name FROM experiments WHERE id = $1`, experimentID).Scan(&orgID, &name)
+    if err == sql.ErrNoRows { return Report{}, fmt.Errorf("experiment %s: %w", experimentID, ErrNotFound) }
+    if err != nil { return Report{}, err }
+    repo := calendarrepo.Repository{DB: db}
+    rows, err := repo.ExperimentArms(ctx, experimentID)
+    if err != nil { return Report{}, err }
+    outcomes, err := repo.VariantOutcomes(ctx, orgID, experimentID)
+    if err != nil { return Report{}, err }
+    var arms []Arm
+    for _, row := range rows {
+        if row.VariantID == nil { continue }
+        arms = append(arms, Arm{ArmID: row.ID, VariantID: *row.VariantID, Outcome: outcomes[*row.VariantID]})
+    }
+    r, err := Analyze(experimentID, arms, opts)
+    r.OrgID, r.Name = orgID, name
+    return r, err
+}
+
+// ErrNotFound is returned by Load for unknown experiments.
+var ErrNotFound = errors.New("not found")
+
+func successes(a ArmResult, metric string) (int64, error) {
+    switch metric {
+    case MetricEngagement:
+        return a.Engagements, nil
+    case MetricCTR:
+        return a.Clicks, nil
+    case MetricConversion:
+        return a.Conversions, nil
+    }
+    return 0, fmt.Errorf("unknown metric %q (want %s, %s or %s)", metric, MetricEngagement, MetricCTR, MetricConversion)
+}
+
+func metricRate(a ArmResult, metric string) float64 {
+    s, _ := successes(a, metric)
+    return rate(s, a.Impressions)
+}
+
+func rate(s, n int64) float64 {
+    if n == 0 { return 0 }
+    return float64(s) / float64(n)
+}
+
+// wilson is the Wilson score interval of s successes in n trials.
+func wilson(s, n int64, z float64) Rate {
+    if n == 0 { return Rate{} }
+    p, nf := rate(s, n), float64(n)
+    d := 1 + z*z/nf
+    center := (p + z*z/(2*nf)) / d
+    half := z * math.Sqrt(p*(1-p)/nf+z*z/(4*nf*nf)) / d
+    return Rate{Value: p, Low: math.Max(0, center-half), High: math.Min(1, center+half)}
+}
+
+// twoProportionP is the two-sided p-value of the pooled two-proportion z-test.
+func twoProportionP(s1, n1, s2, n2 int64) float64 {
+    if n1 == 0 || n2 == 0 { return 1 }
+    pool := float64(s1+s2) / float64(n1+n2)
+    se := math.Sqrt(pool * (1 - pool) * (1/float64(n1) + 1/float64(n2)))
+    if se == 0 { return 1 }
+    z := (rate(s1, n1) - rate(s2, n2)) / se
+    return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// sampleSize is the per-arm sample to detect p0 -> p1 with a two-sided
+// test at alpha and the given power.
+func sampleSize(p0, p1, alpha, power float64) int64 {
+    za, zb := zQuantile(1-alpha/2), zQuantile(power)
+    n := (za + zb) * (za + zb) * (p0*(1-p0) + p1*(1-p1)) / ((p1 - p0) * (p1 - p0))
+    return int64(math.Ceil(n))
+}
+
+// zQuantile is the standard normal quantile of q.
+func zQuantile(q float64) float64 { return math.Sqrt2 * math.Erfinv(2*q-1) }
+
+// sortArms orders arms with the control first, then by metric rate.
+func sortArms(r *Report) []ArmResult {
+    out := append([]ArmResult(nil), r.Arms...)
+    sort.SliceStable(out, func(i, j int) bool {
+        if out[i].Control != out[j].Control { return out[i].Control }
+        return metricRate(out[i], r.Metric) > metricRate(out[j], r.Metric)
+    })
+    return out
+}
@@ -0,0 +1,71 @@
+package experiments
+
+import (
+    "bytes"
+    "encoding/csv"
+    "math"
+    "strings"
+    "testing"
+
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+)
+
+func arm(id string, posts int, impressions, clicks int64) Arm {
+    return Arm{ArmID: "arm_" + id, VariantID: id, Outcome: calendarrepo.VariantOutcome{Posts: posts, Impressions: impressions, Clicks: clicks}}
+}
+
+func TestAnalyzeDecisions(t *testing.T) {
+    for _, tc := range []struct {
+        name     string
+        arms     []Arm
+        decision string
+        winner   string
+    }{
+        {"ship", []Arm{arm("a", 10, 20000, 400), arm("b", 10, 20000, 520), arm("c", 10, 20000, 410)}, DecisionShip, "b"},
+        {"keep control", []Arm{arm("a", 10, 20000, 500), arm("b", 10, 20000, 380)}, DecisionKeepControl, "a"},
+        {"too close", []Arm{arm("a", 10, 20000, 400), arm("b", 10, 20000, 420)}, DecisionContinue, ""},
+        {"too few posts", []Arm{arm("a", 10, 20000, 400), arm("b", 2, 20000, 900)}, DecisionContinue, ""},
+    } {
+        r, err := Analyze("exp", tc.arms, Options{Metric: MetricCTR})
+        if err != nil { t.Fatal(err) }
+        if r.Decision != tc.decision || r.Winner != tc.winner { t.Errorf("%s: decision %s winner %q: %s", tc.name, r.Decision, r.Winner, r.Recommendation) }
+    }
+
+    r, _ := Analyze("exp", []Arm{arm("a", 10, 20000, 400), arm("b", 10, 20000, 420)}, Options{Metric: MetricCTR})
+    // 2% -> 2.1% needs about 315k impressions per arm at alpha 0.05 and 80% power.
+    if r.NeededImpressions < 310000 || r.NeededImpressions > 320000 { t.Errorf("needed impressions = %d", r.NeededImpressions) }
+    if b := r.Arms[1]; math.Abs(b.Lift-0.05) > 1e-9 || b.PValue < 0.47 || b.PValue > 0.49 { t.Errorf("arm b = %+v", b) }
+
+    if _, err := Analyze("exp", []Arm{arm("a", 1, 1, 1)}, Options{}); err == nil { t.Error("expected an error for one arm") }
+    if _, err := Analyze("exp", []Arm{arm("a", 1, 1, 1), arm("b", 1, 1, 1)}, Options{Control: "z"}); err == nil { t.Error("expected an unknown control error") }
+    if _, err := Analyze("exp", []Arm{arm("a", 1, 1, 1), arm("b", 1, 1, 1)}, Options{Metric: "views"}); err == nil { t.Error("expected an unknown metric error") }
+}
+
+func TestWilsonInterval(t *testing.T) {
+    r := wilson(50, 1000, zQuantile(0.975))
+    if math.Abs(r.Low-0.0381) > 1e-4 || math.Abs(r.High-0.0653) > 1e-4 { t.Fatalf("interval = %+v", r) }
+    if r := wilson(0, 0, 1.96); r != (Rate{}) { t.Fatalf("empty interval = %+v", r) }
+}
+
+func TestWriteFormats(t *testing.T) {
+    r, err := Analyze("exp", []Arm{arm("b", 10, 20000, 520), arm("a", 10, 20000, 400)}, Options{Metric: MetricCTR, Control: "a"})
+    if err != nil { t.Fatal(err) }
+    r.Name = "Hooks"
+
+    var buf bytes.Buffer
+    if err := Write(&buf, r, FormatCSV); err != nil { t.Fatal(err) }
+    rows, err := csv.NewReader(&buf).ReadAll()
+    if err != nil { t.Fatal(err) }
+    if len(rows) != 3 || rows[1][2] != "a" || rows[1][3] != "true" || rows[2][len(rows[2])-1] != "true" { t.Fatalf("csv = %q", rows) }
+
+    buf.Reset()
+    if err := Write(&buf, r, FormatMarkdown); err != nil { t.Fatal(err) }
+    md := buf.String()
+    for _, want := range []string{"# Experiment Hooks (exp)", "**Decision: ship.** Ship b", "| a (control) | 10 | 20000 |", "| **b** |", "+30.0%"} {
+        if !strings.Contains(md, want) { t.Errorf("missing %q in:\n%s", want, md) }
+    }
+
+    buf.Reset()
+    if err := Write(&buf, r, FormatJSON); err != nil || !strings.Contains(buf.String(), `"decision": "ship"`) { t.Fatalf("json = %s (%v)", buf.String(), err) }
+    if err := Write(&buf, r, "xml"); err == nil { t.Fatal("expected an unknown format error") }
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+    "bufio"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+)
+
+// JSONLStore keeps one JSON object per line in Path. Writers hold an
+// exclusive file lock (on Unix) and readers a shared one, so several
+// processes can append to the same file.
+type JSONLStore struct {
+    Path string
+    mu   sync.Mutex
+}
+
+func (s *JSONLStore) Append(ctx context.Context, rec PublishedPost) error {
+    rec, err := normalize(rec)
+    if err != nil { return err }
+    b, err := json.Marshal(rec)
+    if err != nil { return err }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil { return err }
+    f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+    if err != nil { return err }
+    defer f.Close()
+    if err := lockFile(f, true); err != nil { return err }
+    defer unlockFile(f)
+    _, err = f.Write(append(b, '\n'))
+    return err
+}
+
+// Query reads the whole file. A missing file has no posts; a line that is
+// not a published post is an error naming the line.
+func (s *JSONLStore) Query(ctx context.Context, q Query) ([]PublishedPost, error) {
+    f, err := os.Open(s.Path)
+    if errors.Is(err, fs.ErrNotExist) { return nil, nil }
+    if err != nil { return nil, err }
+    defer f.Close()
+    if err := lockFile(f, false); err != nil { return nil, err }
+    defer unlockFile(f)
+
+    var out []PublishedPost
+    scanner := bufio.NewScanner(f)
+    for line := 1; scanner.Scan(); line++ {
+        if err := ctx.Err(); err != nil { return nil, err }
+        if len(scanner.Bytes()) == 0 { continue }
+        var rec PublishedPost
+        if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil { return nil, fmt.Errorf("%s:%d: %w", s.Path, line, err) }
+        if q.match(rec) { out = append(out, rec) }
+    }
+    if err := scanner.Err(); err != nil { return nil, err }
+    sort.SliceStable(out, func(i, j int) bool { return out[i].PublishedAt.Before(out[j].PublishedAt) })
+    if q.Limit > 0 && len(out) > q.Limit { out = out[:q.Limit] }
+    return out, nil
+}
+
+func (s *JSONLStore) Close() error { return nil }
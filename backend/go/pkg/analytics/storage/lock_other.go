@@ -0,0 +1,11 @@
+//go:build !unix
+
+package storage
+
+import "os"
+
+// lockFile is a no-op where flock is unavailable; appends within one
+// process are still serialised by JSONLStore's mutex.
+func lockFile(f *os.File, exclusive bool) error { return nil }
+
+func unlockFile(f *os.File) {}
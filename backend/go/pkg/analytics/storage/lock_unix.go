@@ -0,0 +1,17 @@
+//go:build unix
+
+package storage
+
+import (
+    "os"
+    "syscall"
+)
+
+// lockFile takes an advisory lock on f, exclusive for writers.
+func lockFile(f *os.File, exclusive bool) error {
+    how := syscall.LOCK_SH
+    if exclusive { how = syscall.LOCK_EX }
+    return syscall.Flock(int(f.Fd()), how)
+}
+
+func unlockFile(f *os.File) { _ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN) }
@@ -0,0 +1,135 @@
+package storage
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "time"
+)
+
+// SQLiteStore keeps published posts in a published_posts table, created on
+// first use. published_at is stored as Unix nanoseconds so ranges compare
+// numerically.
+type SQLiteStore struct {
+    DB    *sql.DB
+    owned bool
+}
+
+// NewSQLite prepares the published_posts table in db (opened with a
+// "sqlite" driver, modernc.org/sqlite).
+func NewSQLite(ctx context.Context, db *sql.DB) (*SQLiteStore, error) {
+    const ddl = `CREATE TABLE IF NOT EXISTS published_posts (
+  platform     TEXT NOT NULL,
+  id           TEXT NOT NULL,
+  link         TEXT NOT NULL DEFAULT '',
+  content_type TEXT NOT NULL DEFAULT '',
+  published_at INTEGER NOT NULL,
+  PRIMARY KEY (platform, id)
+);
+CREATE INDEX IF NOT EXISTS idx_published_posts_time ON published_posts(published_at)`
+    if _, err := db.ExecContext(ctx, ddl); err != nil { return nil, err }
+    return &SQLiteStore{DB: db}, nil
+}
+
+func (s *SQLiteStore) Append(ctx context.Context, rec PublishedPost) error {
+    rec, err := normalize(rec)
+    if err != nil { return err }
+    const q = `INSERT OR IGNORE INTO published_posts (platform, id, link, content_type, published_at) VALUES (?, ?, ?, ?, ?)`
+    _, err = s.DB.ExecContext(ctx, q, rec.Platform, rec.ID, rec.Link, rec.ContentType, rec.PublishedAt.UnixNano())
+    return err
+}
+
+func (s *SQLiteStore) Query(ctx context.Context, q Query) ([]PublishedPost, error) {
+    var since, until int64 = 0, 1<<63 - 1
+    if !q.Since.IsZero() { since = q.Since.UnixNano() }
+    if !q.Until.IsZero() { until = q.Until.UnixNano() }
+    const stmt = `SELECT platform, id, link, content_type, published_at FROM published_posts
+    WHERE (? = '' OR platform = ?) AND published_at >= ? AND published_at < ?
+    ORDER BY published_at, platform, id
+    LIMIT ?`
+    platform := ""
+    if q.Platform != "" { platform = canonical(q.Platform) }
+    limit := -1
+    if q.Limit > 0 { limit = q.Limit }
+    rows, err := s.DB.QueryContext(ctx, stmt, platform, platform, since, until, limit)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var out []PublishedPost
+    for rows.Next() {
+        var rec PublishedPost
+        var ns int64
+        if err := rows.Scan(&rec.Platform, &rec.ID, &rec.Link, &rec.ContentType, &ns); err != nil { return nil, err }
+        rec.PublishedAt = time.Unix(0, ns).UTC()
+        out = append(out, rec)
+    }
+    return out, rows.Err()
+}
+
+func (s *SQLiteStore) Close() error {
+    if s.owned { return s.DB.Close() }
+    return nil
+}
+
+// PostgresStore records published posts as published scheduled_posts rows
+// of OrgID, so the analytics collector reads their metrics like any other
+// post. Rows get the id PublishedRowID; a post already in scheduled_posts
+// under the same platform and external_id (published by cmd/poster) is not
+// added again.
+type PostgresStore struct {
+    DB    *sql.DB
+    OrgID string
+    owned bool
+}
+
+// PublishedRowID is the scheduled_posts id of a post recorded by PostgresStore.
+func PublishedRowID(platform, externalID string) string {
+    return "pub_" + platform + "_" + externalID
+}
+
+func (s *PostgresStore) Append(ctx context.Context, rec PublishedPost) error {
+    rec, err := normalize(rec)
+    if err != nil { return err }
+    meta, err := json.Marshal(map[string]string{"source": "published_posts", "link": rec.Link, "content_type": rec.ContentType})
+    if err != nil { return err }
+    const q = `INSERT INTO scheduled_posts
+    (id, org_id, platform, scheduled_at, status, external_id, published_at, metadata, created_at, updated_at)
+    SELECT $1, $2, $3, $4, 'published', $5, $4, $6, NOW(), NOW()
+    WHERE NOT EXISTS (SELECT 1 FROM scheduled_posts WHERE platform = $3 AND external_id = $5)
+    ON CONFLICT (id) DO NOTHING`
+    _, err = s.DB.ExecContext(ctx, q, PublishedRowID(rec.Platform, rec.ID), s.OrgID, rec.Platform, rec.PublishedAt, rec.ID, meta)
+    return err
+}
+
+// Query lists the org's published scheduled_posts with an external_id,
+// whichever tool published them. Link falls back to the content item's URL.
+func (s *PostgresStore) Query(ctx context.Context, q Query) ([]PublishedPost, error) {
+    const stmt = `SELECT sp.platform, sp.external_id, COALESCE(NULLIF(sp.metadata->>'link', ''), ci.canonical_url, ''),
+      COALESCE(sp.metadata->>'content_type', ''), COALESCE(sp.published_at, sp.scheduled_at)
+    FROM scheduled_posts sp
+    LEFT JOIN content_items ci ON sp.content_id = ci.id
+    WHERE sp.org_id = $1 AND sp.status = 'published' AND COALESCE(sp.external_id, '') <> ''
+      AND ($2 = '' OR sp.platform = $2)
+      AND ($3::timestamptz IS NULL OR COALESCE(sp.published_at, sp.scheduled_at) >= $3)
+      AND ($4::timestamptz IS NULL OR COALESCE(sp.published_at, sp.scheduled_at) < $4)
+    ORDER BY COALESCE(sp.published_at, sp.scheduled_at), sp.id
+    LIMIT NULLIF($5, 0)`
+    platform := ""
+    if q.Platform != "" { platform = canonical(q.Platform) }
+    rows, err := s.DB.QueryContext(ctx, stmt, s.OrgID, platform, nullTime(q.Since), nullTime(q.Until), max(q.Limit, 0))
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var out []PublishedPost
+    for rows.Next() {
+        var rec PublishedPost
+        if err := rows.Scan(&rec.Platform, &rec.ID, &rec.Link, &rec.ContentType, &rec.PublishedAt); err != nil { return nil, err }
+        out = append(out, rec)
+    }
+    return out, rows.Err()
+}
+
+func (s *PostgresStore) Close() error {
+    if s.owned { return s.DB.Close() }
+    return nil
+}
+
+func nullTime(t time.Time) sql.NullTime { return sql.NullTime{Time: t, Valid: !t.IsZero()} }
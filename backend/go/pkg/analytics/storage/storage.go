@@ -1,12 +1,25 @@
+// Package storage records the posts cmd/ferret published so analytics can
+// read their metrics back. A Store is a JSONL file, a SQLite database or
+// Postgres scheduled_posts (see Open).
 package storage
 
 import (
-    "encoding/json"
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
     "os"
     "path/filepath"
+    "strings"
     "time"
+
+    "github.com/bitesinbyte/ferret/pkg/external"
 )
 
+// DefaultPath is the JSONL file used when neither a spec nor
+// PUBLISHED_STORE is set.
+var DefaultPath = filepath.Join("data", "published_posts.jsonl")
+
 type PublishedPost struct {
     Platform    string    `json:"platform"`
     ID          string    `json:"id"`
@@ -15,17 +28,94 @@ type PublishedPost struct {
     PublishedAt time.Time `json:"published_at"`
 }
 
-// AppendPublishedPost appends a JSON line to data/published_posts.jsonl under repo root.
+// Query selects published posts. Zero fields do not filter; Since is
+// inclusive and Until exclusive.
+type Query struct {
+    Platform string
+    Since    time.Time
+    Until    time.Time
+    Limit    int
+}
+
+// Store appends published posts and reads them back oldest first. Append is
+// idempotent per platform and id in the SQL stores.
+type Store interface {
+    Append(ctx context.Context, rec PublishedPost) error
+    Query(ctx context.Context, q Query) ([]PublishedPost, error)
+    Close() error
+}
+
+// Open returns the store described by spec, or by PUBLISHED_STORE when spec
+// is empty:
+//
+//	jsonl:<path>            JSONL file (default jsonl:data/published_posts.jsonl)
+//	sqlite:<path>           SQLite database (import the modernc.org/sqlite driver in main)
+//	postgres[:<dsn>]        scheduled_posts; the DSN defaults to DATABASE_URL and rows belong to ORG_ID
+func Open(spec string) (Store, error) {
+    if spec == "" { spec = os.Getenv("PUBLISHED_STORE") }
+    if spec == "" { return &JSONLStore{Path: DefaultPath}, nil }
+    kind, arg, _ := strings.Cut(spec, ":")
+    switch kind {
+    case "jsonl":
+        if arg == "" { arg = DefaultPath }
+        return &JSONLStore{Path: arg}, nil
+    case "sqlite":
+        if arg == "" { return nil, errors.New("storage: sqlite needs a path") }
+        db, err := sql.Open("sqlite", arg)
+        if err != nil { return nil, fmt.Errorf("storage: %w", err) }
+        s, err := NewSQLite(context.Background(), db)
+        if err != nil { db.Close(); return nil, err }
+        s.owned = true
+        return s, nil
+    case "postgres", "postgresql":
+        dsn := os.Getenv("DATABASE_URL")
+        // postgres://... is itself a URL DSN; postgres:<dsn> carries one.
+        if strings.HasPrefix(arg, "//") { dsn = spec } else if arg != "" { dsn = arg }
+        if dsn == "" { return nil, errors.New("storage: postgres needs a DSN or DATABASE_URL") }
+        orgID := os.Getenv("ORG_ID")
+        if orgID == "" { return nil, errors.New("storage: postgres needs ORG_ID") }
+        db, err := sql.Open("postgres", dsn)
+        if err != nil { return nil, fmt.Errorf("storage: %w", err) }
+        return &PostgresStore{DB: db, OrgID: orgID, owned: true}, nil
+    }
+    return nil, fmt.Errorf("storage: unknown store %q (want jsonl:, sqlite: or postgres)", spec)
+}
+
+// Copy appends every record of src matching q to dst and returns how many it
+// read.
+func Copy(ctx context.Context, dst, src Store, q Query) (int, error) {
+    recs, err := src.Query(ctx, q)
+    if err != nil { return 0, err }
+    for i, rec := range recs {
+        if err := dst.Append(ctx, rec); err != nil { return i, fmt.Errorf("copy %s %s: %w", rec.Platform, rec.ID, err) }
+    }
+    return len(recs), nil
+}
+
+// AppendPublishedPost appends a JSON line to DefaultPath.
 func AppendPublishedPost(rec PublishedPost) error {
-    dir := filepath.Join("data")
-    if err := os.MkdirAll(dir, 0o755); err != nil { return err }
-    path := filepath.Join(dir, "published_posts.jsonl")
-    f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
-    if err != nil { return err }
-    defer f.Close()
-    b, err := json.Marshal(rec)
-    if err != nil { return err }
-    if _, err := f.Write(append(b, '\n')); err != nil { return err }
-    return nil
+    return (&JSONLStore{Path: DefaultPath}).Append(context.Background(), rec)
+}
+
+// normalize validates rec and stores its platform under the registry's
+// canonical name (unknown platforms are kept as given, lower-cased).
+func normalize(rec PublishedPost) (PublishedPost, error) {
+    if rec.Platform == "" || rec.ID == "" { return rec, errors.New("storage: published post needs a platform and an id") }
+    rec.Platform = canonical(rec.Platform)
+    if rec.PublishedAt.IsZero() { rec.PublishedAt = time.Now() }
+    rec.PublishedAt = rec.PublishedAt.UTC()
+    return rec, nil
 }
 
+func canonical(platform string) string {
+    if name, err := external.CanonicalPlatform(platform); err == nil { return name }
+    return strings.ToLower(strings.TrimSpace(platform))
+}
+
+// match reports whether rec passes q's platform and time filters.
+func (q Query) match(rec PublishedPost) bool {
+    if q.Platform != "" && canonical(rec.Platform) != canonical(q.Platform) { return false }
+    if !q.Since.IsZero() && rec.PublishedAt.Before(q.Since) { return false }
+    if !q.Until.IsZero() && !rec.PublishedAt.Before(q.Until) { return false }
+    return true
+}
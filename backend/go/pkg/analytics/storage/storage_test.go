@@ -0,0 +1,92 @@
+package storage
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    _ "modernc.org/sqlite"
+)
+
+func exerciseStore(t *testing.T, s Store) {
+    t.Helper()
+    ctx := context.Background()
+    base := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+    for i, rec := range []PublishedPost{
+        {Platform: "LinkedIn", ID: "urn:li:share:2", PublishedAt: base.Add(2 * time.Hour)},
+        {Platform: "instagram", ID: "1789", Link: "https://example.com/a", PublishedAt: base},
+        {Platform: "linkedin", ID: "urn:li:share:1", PublishedAt: base.Add(time.Hour).In(time.FixedZone("CEST", 2*3600))},
+    } {
+        if err := s.Append(ctx, rec); err != nil { t.Fatalf("append %d: %v", i, err) }
+    }
+    if err := s.Append(ctx, PublishedPost{Platform: "linkedin"}); err == nil { t.Error("post without id accepted") }
+
+    all, err := s.Query(ctx, Query{})
+    if err != nil { t.Fatal(err) }
+    if len(all) != 3 || all[0].ID != "1789" || all[0].Link != "https://example.com/a" || !all[0].PublishedAt.Equal(base) { t.Fatalf("all = %+v", all) }
+
+    got, err := s.Query(ctx, Query{Platform: "LINKEDIN", Since: base.Add(time.Hour), Until: base.Add(2 * time.Hour)})
+    if err != nil { t.Fatal(err) }
+    if len(got) != 1 || got[0].ID != "urn:li:share:1" || got[0].Platform != "linkedin" { t.Fatalf("range = %+v", got) }
+
+    got, err = s.Query(ctx, Query{Platform: "linkedin", Limit: 1})
+    if err != nil { t.Fatal(err) }
+    if len(got) != 1 || got[0].ID != "urn:li:share:1" { t.Fatalf("limit = %+v", got) }
+}
+
+func TestJSONLStore(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "data", "published.jsonl")
+    s := &JSONLStore{Path: path}
+    if recs, err := s.Query(context.Background(), Query{}); err != nil || recs != nil { t.Fatalf("missing file: %v, %v", recs, err) }
+    exerciseStore(t, s)
+
+    f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+    if err != nil { t.Fatal(err) }
+    _, _ = f.WriteString("not json\n")
+    f.Close()
+    if _, err := s.Query(context.Background(), Query{}); err == nil || !strings.Contains(err.Error(), ":4:") { t.Errorf("malformed line: %v", err) }
+}
+
+func TestJSONLStoreConcurrentAppends(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "published.jsonl")
+    var wg sync.WaitGroup
+    for i := range 20 {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            // Separate stores share only the file lock.
+            s := &JSONLStore{Path: path}
+            if err := s.Append(context.Background(), PublishedPost{Platform: "mastodon", ID: strings.Repeat("x", i+1)}); err != nil { t.Error(err) }
+        }()
+    }
+    wg.Wait()
+    recs, err := (&JSONLStore{Path: path}).Query(context.Background(), Query{})
+    if err != nil || len(recs) != 20 { t.Fatalf("%d records, %v", len(recs), err) }
+}
+
+func TestSQLiteStore(t *testing.T) {
+    s, err := Open("sqlite:" + filepath.Join(t.TempDir(), "published.db"))
+    if err != nil { t.Fatal(err) }
+    defer s.Close()
+    exerciseStore(t, s)
+    // Appends are idempotent per platform and id.
+    if err := s.Append(context.Background(), PublishedPost{Platform: "instagram", ID: "1789"}); err != nil { t.Fatal(err) }
+    if recs, _ := s.Query(context.Background(), Query{Platform: "instagram"}); len(recs) != 1 { t.Errorf("duplicate stored: %+v", recs) }
+
+    dst := &JSONLStore{Path: filepath.Join(t.TempDir(), "copy.jsonl")}
+    if n, err := Copy(context.Background(), dst, s, Query{Platform: "linkedin"}); err != nil || n != 2 { t.Fatalf("copy = %d, %v", n, err) }
+}
+
+func TestOpen(t *testing.T) {
+    t.Setenv("PUBLISHED_STORE", "")
+    if s, err := Open(""); err != nil || s.(*JSONLStore).Path != DefaultPath { t.Fatalf("default = %v, %v", s, err) }
+    t.Setenv("PUBLISHED_STORE", "jsonl:/tmp/x.jsonl")
+    if s, err := Open(""); err != nil || s.(*JSONLStore).Path != "/tmp/x.jsonl" { t.Fatalf("env = %v, %v", s, err) }
+    t.Setenv("ORG_ID", "")
+    if _, err := Open("postgres://localhost/ferret"); err == nil { t.Error("postgres without ORG_ID opened") }
+    if _, err := Open("redis:x"); err == nil { t.Error("unknown store opened") }
+}
@@ -0,0 +1,197 @@
+package trends
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "slices"
+    "strings"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/models"
+)
+
+// MaxPoints caps the buckets of one series.
+const MaxPoints = 1000
+
+// DefaultMetrics are the metrics QuerySeries returns when none are asked for.
+var DefaultMetrics = []string{MetricPosts, MetricEngagements, MetricEngagementRate}
+
+// ErrBadQuery wraps the errors of queries rejected before reading.
+var ErrBadQuery = errors.New("trends: bad query")
+
+// Reader reads stored rollups (calendarrepo.Repository).
+type Reader interface {
+    TrendSeries(ctx context.Context, orgID, source, dimension string, metrics []string, from, to time.Time) ([]models.TrendMetric, error)
+}
+
+// SeriesQuery selects series of one org's rollups. Zero fields use the
+// defaults: daily buckets, the org dimension, DefaultMetrics, To now and
+// From 30 buckets before To. A Dimension ending in ":" (e.g. "platform:")
+// returns a series per dimension of that kind.
+type SeriesQuery struct {
+    OrgID       string
+    Granularity Granularity
+    Dimension   string
+    Metrics     []string
+    From        time.Time
+    To          time.Time
+}
+
+// Point is one bucket of a series. Value is nil for a rate in a bucket
+// without posts; counts of such buckets are 0.
+type Point struct {
+    Start time.Time `json:"start"`
+    End   time.Time `json:"end"`
+    Value *float64  `json:"value"`
+}
+
+// Series is one metric of one dimension over every bucket of the range.
+type Series struct {
+    Dimension   string      `json:"dimension"`
+    Metric      string      `json:"metric"`
+    Granularity Granularity `json:"granularity"`
+    Points      []Point     `json:"points"`
+}
+
+// QuerySeries reads rollups into series with a point for every bucket from
+// the one containing From up to To, so charts need no gap handling.
+// Series are ordered by dimension, then in the order of q.Metrics.
+func QuerySeries(ctx context.Context, r Reader, q SeriesQuery) ([]Series, error) {
+    g, starts, err := q.normalize()
+    if err != nil { return nil, err }
+    if len(q.Metrics) == 0 { q.Metrics = DefaultMetrics }
+    for _, m := range q.Metrics {
+        if !slices.Contains(Metrics, m) { return nil, fmt.Errorf("%w: unknown metric %q", ErrBadQuery, m) }
+    }
+    if q.Dimension == "" { q.Dimension = Dimension(DimOrg, q.OrgID) }
+
+    rows, err := r.TrendSeries(ctx, q.OrgID, g.Source(), q.Dimension, q.Metrics, starts[0], q.To)
+    if err != nil { return nil, err }
+    values := map[[2]string]map[time.Time]float64{}
+    dims := []string{}
+    if !strings.HasSuffix(q.Dimension, ":") { dims = append(dims, q.Dimension) }
+    for _, m := range rows {
+        if !slices.Contains(dims, m.Dimension) { dims = append(dims, m.Dimension) }
+        k := [2]string{m.Dimension, m.Metric}
+        if values[k] == nil { values[k] = map[time.Time]float64{} }
+        values[k][m.BucketStart.UTC()] = m.Value
+    }
+    slices.Sort(dims)
+
+    var out []Series
+    for _, dim := range dims {
+        for _, metric := range q.Metrics {
+            s := Series{Dimension: dim, Metric: metric, Granularity: g, Points: make([]Point, len(starts))}
+            vs := values[[2]string{dim, metric}]
+            for i, start := range starts {
+                p := Point{Start: start, End: g.Next(start)}
+                if v, ok := vs[start]; ok {
+                    p.Value = &v
+                } else if !IsRate(metric) {
+                    p.Value = new(float64)
+                }
+                s.Points[i] = p
+            }
+            out = append(out, s)
+        }
+    }
+    return out, nil
+}
+
+// Total is one dimension's rollups summed over a range. Counts are added
+// up; rates are recomputed from their summed numerators and denominators
+// and are missing when nothing had a denominator.
+type Total struct {
+    Dimension string
+    Values    map[string]float64
+}
+
+// QueryTotals sums every metric of q's dimensions over q's range (q.Metrics
+// is ignored), ordered by dimension.
+func QueryTotals(ctx context.Context, r Reader, q SeriesQuery) ([]Total, error) {
+    g, starts, err := q.normalize()
+    if err != nil { return nil, err }
+    if q.Dimension == "" { q.Dimension = Dimension(DimOrg, q.OrgID) }
+    rows, err := r.TrendSeries(ctx, q.OrgID, g.Source(), q.Dimension, Metrics, starts[0], q.To)
+    if err != nil { return nil, err }
+    type frac struct{ num, den float64 }
+    counts := map[string]map[string]float64{}
+    rates := map[string]map[string]*frac{}
+    var dims []string
+    for _, m := range rows {
+        if counts[m.Dimension] == nil {
+            dims = append(dims, m.Dimension)
+            counts[m.Dimension] = map[string]float64{}
+            rates[m.Dimension] = map[string]*frac{}
+        }
+        if !IsRate(m.Metric) {
+            counts[m.Dimension][m.Metric] += m.Value
+            continue
+        }
+        f := rates[m.Dimension][m.Metric]
+        if f == nil { f = &frac{}; rates[m.Dimension][m.Metric] = f }
+        f.num += metaNumber(m.Meta, "numerator")
+        f.den += metaNumber(m.Meta, "denominator")
+    }
+    slices.Sort(dims)
+    out := make([]Total, 0, len(dims))
+    for _, dim := range dims {
+        t := Total{Dimension: dim, Values: counts[dim]}
+        for metric, f := range rates[dim] {
+            if f.den > 0 { t.Values[metric] = f.num / f.den }
+        }
+        out = append(out, t)
+    }
+    return out, nil
+}
+
+// normalize applies q's defaults and returns the start of every bucket in
+// its range.
+func (q *SeriesQuery) normalize() (Granularity, []time.Time, error) {
+    if q.OrgID == "" { return "", nil, fmt.Errorf("%w: no org", ErrBadQuery) }
+    g, err := ParseGranularity(string(q.Granularity))
+    if err != nil { return "", nil, fmt.Errorf("%w: %v", ErrBadQuery, err) }
+    q.Granularity = g
+    if q.Dimension != "" && !strings.Contains(q.Dimension, ":") {
+        return "", nil, fmt.Errorf("%w: dimension %q is not <kind>:<value> or <kind>:", ErrBadQuery, q.Dimension)
+    }
+    if q.To.IsZero() { q.To = time.Now() }
+    if q.From.IsZero() {
+        start := g.Truncate(q.To)
+        for range 30 { start = prev(g, start) }
+        q.From = start
+    }
+    var starts []time.Time
+    for s := g.Truncate(q.From); s.Before(q.To); s = g.Next(s) {
+        if len(starts) == MaxPoints { return "", nil, fmt.Errorf("%w: more than %d %s buckets", ErrBadQuery, MaxPoints, g) }
+        starts = append(starts, s)
+    }
+    if len(starts) == 0 { return "", nil, fmt.Errorf("%w: empty time range", ErrBadQuery) }
+    return g, starts, nil
+}
+
+// metaNumber reads a number from a rollup's meta, as decoded from JSON or as
+// written by Rollup.
+func metaNumber(meta any, key string) float64 {
+    m, _ := meta.(map[string]any)
+    switch v := m[key].(type) {
+    case float64:
+        return v
+    case int64:
+        return float64(v)
+    case int:
+        return float64(v)
+    }
+    return 0
+}
+
+func prev(g Granularity, start time.Time) time.Time {
+    switch g {
+    case Hourly:
+        return start.Add(-time.Hour)
+    case Weekly:
+        return start.AddDate(0, 0, -7)
+    }
+    return start.AddDate(0, 0, -1)
+}
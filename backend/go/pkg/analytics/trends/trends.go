@@ -0,0 +1,255 @@
+// Package trends rolls post_outcomes up into trend_metrics: hourly, daily
+// and weekly buckets of the posts published in them, per org, platform,
+// campaign, topic and variant, and reads the rollups back as chartable
+// series (see QuerySeries).
+package trends
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+    "github.com/bitesinbyte/ferret/pkg/models"
+)
+
+// Granularity is the width of a bucket. Buckets are in UTC; weeks start on
+// Monday.
+type Granularity string
+
+const (
+    Hourly Granularity = "hourly"
+    Daily  Granularity = "daily"
+    Weekly Granularity = "weekly"
+)
+
+// Granularities are the bucket widths Rollup writes.
+var Granularities = []Granularity{Hourly, Daily, Weekly}
+
+// ParseGranularity accepts hourly, daily or weekly (or hour, day, week).
+func ParseGranularity(s string) (Granularity, error) {
+    switch strings.ToLower(strings.TrimSpace(s)) {
+    case "hourly", "hour":
+        return Hourly, nil
+    case "daily", "day", "":
+        return Daily, nil
+    case "weekly", "week":
+        return Weekly, nil
+    }
+    return "", fmt.Errorf("unknown granularity %q (want hourly, daily or weekly)", s)
+}
+
+// Source is the trend_metrics source of g's rollups.
+func (g Granularity) Source() string { return "post_outcomes," + string(g) }
+
+// Truncate returns the start of the bucket containing t.
+func (g Granularity) Truncate(t time.Time) time.Time {
+    t = t.UTC()
+    switch g {
+    case Hourly:
+        return t.Truncate(time.Hour)
+    case Weekly:
+        day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+        return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+    }
+    return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Next returns the start of the bucket after the one starting at start.
+func (g Granularity) Next(start time.Time) time.Time {
+    switch g {
+    case Hourly:
+        return start.Add(time.Hour)
+    case Weekly:
+        return start.AddDate(0, 0, 7)
+    }
+    return start.AddDate(0, 0, 1)
+}
+
+// Dimension kinds; a trend_metrics dimension is "<kind>:<value>".
+const (
+    DimOrg      = "org"
+    DimPlatform = "platform"
+    DimCampaign = "campaign"
+    DimTopic    = "topic"
+    DimVariant  = "variant"
+)
+
+// Dimension returns the trend_metrics dimension of kind and value.
+func Dimension(kind, value string) string { return kind + ":" + value }
+
+// Metrics of a rollup. Counts are totals of each post's latest snapshot;
+// rates are only written for buckets with a denominator.
+const (
+    MetricPosts       = "posts"
+    MetricImpressions = "impressions"
+    MetricReach       = "reach"
+    MetricLikes       = "likes"
+    MetricComments    = "comments"
+    MetricShares      = "shares"
+    MetricClicks      = "clicks"
+    MetricSaves       = "saves"
+    MetricConversions = "conversions"
+    MetricEngagements = "engagements" // likes, comments, shares, saves and clicks
+
+    // Engagements and clicks over impressions (reach for posts without
+    // impression counts).
+    MetricEngagementRate = "engagement_rate"
+    MetricCTR            = "ctr"
+    // Engagements and reach over the posting account's followers, for posts
+    // whose account has a follower count.
+    MetricFollowerEngagementRate = "follower_engagement_rate"
+    MetricFollowerReachRate      = "follower_reach_rate"
+)
+
+// Metrics lists every metric of a rollup, counts first.
+var Metrics = []string{
+    MetricPosts, MetricImpressions, MetricReach, MetricLikes, MetricComments, MetricShares, MetricClicks, MetricSaves,
+    MetricConversions, MetricEngagements, MetricEngagementRate, MetricCTR, MetricFollowerEngagementRate, MetricFollowerReachRate,
+}
+
+// IsRate reports whether metric is a ratio (undefined for empty buckets)
+// rather than a count.
+func IsRate(metric string) bool {
+    switch metric {
+    case MetricEngagementRate, MetricCTR, MetricFollowerEngagementRate, MetricFollowerReachRate:
+        return true
+    }
+    return false
+}
+
+// ratio is a rate's numerator and denominator over the posts that have one.
+type ratio struct {
+    posts    int
+    num, den int64
+}
+
+func (r *ratio) add(num, den int64) {
+    if den <= 0 { return }
+    r.posts++
+    r.num += num
+    r.den += den
+}
+
+type bucket struct {
+    counts                     calendarrepo.VariantOutcome
+    engagements                int64
+    engRate, ctr, fEng, fReach ratio
+}
+
+type bucketKey struct {
+    org, dim string
+    g        Granularity
+    start    time.Time
+}
+
+// Rollup aggregates posts into trend_metrics rows for every granularity.
+// A post counts in the bucket it was published in, with its latest
+// snapshot, once per dimension it has (posts without a campaign, topic or
+// variant only count under their org and platform). Counts carry
+// meta.posts; rates carry meta.posts, meta.numerator and meta.denominator
+// so they can be re-aggregated.
+func Rollup(posts []calendarrepo.PostPerformance) []models.TrendMetric {
+    buckets := map[bucketKey]*bucket{}
+    for _, p := range posts {
+        o := p.Outcome
+        eng := o.Likes + o.Comments + o.Shares + o.Saves + o.Clicks
+        den := o.Impressions
+        if den == 0 { den = o.Reach }
+        dims := []string{Dimension(DimOrg, p.OrgID), Dimension(DimPlatform, p.Platform)}
+        for kind, v := range map[string]string{DimCampaign: p.CampaignID, DimTopic: strings.TrimSpace(p.Topic), DimVariant: p.VariantID} {
+            if v != "" { dims = append(dims, Dimension(kind, v)) }
+        }
+        for _, g := range Granularities {
+            start := g.Truncate(p.PublishedAt)
+            for _, dim := range dims {
+                k := bucketKey{p.OrgID, dim, g, start}
+                b := buckets[k]
+                if b == nil { b = &bucket{}; buckets[k] = b }
+                c := &b.counts
+                c.Posts++
+                c.Impressions += o.Impressions
+                c.Reach += o.Reach
+                c.Likes += o.Likes
+                c.Comments += o.Comments
+                c.Shares += o.Shares
+                c.Clicks += o.Clicks
+                c.Saves += o.Saves
+                c.Conversions += o.Conversions
+                b.engagements += eng
+                b.engRate.add(eng, den)
+                b.ctr.add(o.Clicks, den)
+                b.fEng.add(eng, p.Followers)
+                b.fReach.add(o.Reach, p.Followers)
+            }
+        }
+    }
+
+    var out []models.TrendMetric
+    for k, b := range buckets {
+        row := func(metric string, value float64, meta map[string]any) {
+            out = append(out, models.TrendMetric{
+                OrgID: k.org, Source: k.g.Source(), Dimension: k.dim, Metric: metric,
+                BucketStart: k.start, BucketEnd: k.g.Next(k.start), Value: value, Meta: meta,
+            })
+        }
+        c := b.counts
+        n := map[string]any{"posts": c.Posts}
+        for metric, v := range map[string]int64{
+            MetricPosts: int64(c.Posts), MetricImpressions: c.Impressions, MetricReach: c.Reach, MetricLikes: c.Likes,
+            MetricComments: c.Comments, MetricShares: c.Shares, MetricClicks: c.Clicks, MetricSaves: c.Saves,
+            MetricConversions: c.Conversions, MetricEngagements: b.engagements,
+        } {
+            row(metric, float64(v), n)
+        }
+        for metric, r := range map[string]ratio{
+            MetricEngagementRate: b.engRate, MetricCTR: b.ctr, MetricFollowerEngagementRate: b.fEng, MetricFollowerReachRate: b.fReach,
+        } {
+            if r.den == 0 { continue }
+            row(metric, float64(r.num)/float64(r.den), map[string]any{"posts": r.posts, "numerator": r.num, "denominator": r.den})
+        }
+    }
+    sort.Slice(out, func(i, j int) bool {
+        a, b := out[i], out[j]
+        if a.OrgID != b.OrgID { return a.OrgID < b.OrgID }
+        if a.Source != b.Source { return a.Source < b.Source }
+        if a.Dimension != b.Dimension { return a.Dimension < b.Dimension }
+        if a.Metric != b.Metric { return a.Metric < b.Metric }
+        return a.BucketStart.Before(b.BucketStart)
+    })
+    return out
+}
+
+// Repo is what Run reads posts from and writes rollups to
+// (calendarrepo.Repository).
+type Repo interface {
+    PostPerformance(ctx context.Context, orgID string, from, to time.Time) ([]calendarrepo.PostPerformance, error)
+    ReplaceTrends(ctx context.Context, orgID string, sources []string, from, to time.Time, ms []models.TrendMetric) error
+}
+
+// Summary is the outcome of Run.
+type Summary struct {
+    From    time.Time `json:"from"`
+    To      time.Time `json:"to"`
+    Posts   int       `json:"posts"`
+    Metrics int       `json:"metrics"`
+}
+
+// Run recomputes the rollups of orgID (every org when empty) for the posts
+// published from since to until. The range is widened to whole weeks so
+// every bucket it touches is rebuilt from all its posts; the rollups in it
+// are replaced.
+func Run(ctx context.Context, repo Repo, orgID string, since, until time.Time) (Summary, error) {
+    from := Weekly.Truncate(since)
+    to := Weekly.Next(Weekly.Truncate(until))
+    sum := Summary{From: from, To: to}
+    posts, err := repo.PostPerformance(ctx, orgID, from, to)
+    if err != nil { return sum, err }
+    ms := Rollup(posts)
+    sum.Posts, sum.Metrics = len(posts), len(ms)
+    sources := make([]string, len(Granularities))
+    for i, g := range Granularities { sources[i] = g.Source() }
+    return sum, repo.ReplaceTrends(ctx, orgID, sources, from, to, ms)
+}
@@ -0,0 +1,139 @@
+package trends
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+    "github.com/bitesinbyte/ferret/pkg/models"
+)
+
+func post(id, platform, campaign string, published time.Time, followers int64, o calendarrepo.VariantOutcome) calendarrepo.PostPerformance {
+    o.Posts = 1
+    return calendarrepo.PostPerformance{
+        OrgID: "org1", PostID: id, Platform: platform, CampaignID: campaign, Topic: "launch", Followers: followers,
+        PublishedAt: published, Outcome: o,
+    }
+}
+
+func find(ms []models.TrendMetric, g Granularity, dim, metric string) (models.TrendMetric, bool) {
+    for _, m := range ms {
+        if m.Source == g.Source() && m.Dimension == dim && m.Metric == metric { return m, true }
+    }
+    return models.TrendMetric{}, false
+}
+
+func TestBuckets(t *testing.T) {
+    at := time.Date(2026, 5, 3, 22, 30, 0, 0, time.FixedZone("EST", -5*3600)) // Monday 03:30 UTC
+    if got := Hourly.Truncate(at); !got.Equal(time.Date(2026, 5, 4, 3, 0, 0, 0, time.UTC)) { t.Errorf("hour = %s", got) }
+    if got := Daily.Truncate(at); !got.Equal(time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC)) { t.Errorf("day = %s", got) }
+    if got := Weekly.Truncate(at); !got.Equal(time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC)) { t.Errorf("week = %s", got) }
+    if got := Weekly.Truncate(time.Date(2026, 5, 10, 23, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC)) { t.Errorf("sunday week = %s", got) }
+    if g, err := ParseGranularity("week"); err != nil || g != Weekly { t.Errorf("parse = %v, %v", g, err) }
+    if _, err := ParseGranularity("monthly"); err == nil { t.Error("monthly accepted") }
+}
+
+func TestRollup(t *testing.T) {
+    mon := time.Date(2026, 5, 4, 9, 15, 0, 0, time.UTC)
+    ms := Rollup([]calendarrepo.PostPerformance{
+        post("a", "instagram", "c1", mon, 1000, calendarrepo.VariantOutcome{Impressions: 400, Reach: 300, Likes: 30, Comments: 5, Clicks: 5}),
+        post("b", "instagram", "", mon.Add(20*time.Minute), 0, calendarrepo.VariantOutcome{Impressions: 100, Likes: 10}),
+        // No impressions: reach is the rate denominator.
+        post("c", "linkedin", "c1", mon.Add(26*time.Hour), 500, calendarrepo.VariantOutcome{Reach: 200, Likes: 10}),
+        // Nothing to divide by: counts only.
+        post("d", "mastodon", "", mon.Add(50*time.Hour), 0, calendarrepo.VariantOutcome{Likes: 7}),
+    })
+
+    m, ok := find(ms, Hourly, "platform:instagram", MetricEngagements)
+    if !ok || m.Value != 50 || !m.BucketEnd.Equal(mon.Truncate(time.Hour).Add(time.Hour)) { t.Fatalf("hourly engagements = %+v", m) }
+    if m, _ := find(ms, Hourly, "platform:instagram", MetricEngagementRate); m.Value != 0.1 { t.Errorf("hourly rate = %+v", m) }
+
+    m, _ = find(ms, Weekly, "org:org1", MetricPosts)
+    if m.Value != 4 { t.Errorf("weekly posts = %+v", m) }
+    m, _ = find(ms, Weekly, "org:org1", MetricEngagementRate)
+    if meta := m.Meta.(map[string]any); m.Value != 60.0/700 || meta["posts"] != 3 || meta["denominator"] != int64(700) { t.Errorf("weekly rate = %+v", m) }
+    // Only a and c have follower counts.
+    if m, _ := find(ms, Weekly, "org:org1", MetricFollowerEngagementRate); m.Value != 50.0/1500 { t.Errorf("follower rate = %+v", m) }
+    if m, _ := find(ms, Weekly, "campaign:c1", MetricFollowerReachRate); m.Value != 500.0/1500 { t.Errorf("follower reach = %+v", m) }
+    if m, _ := find(ms, Daily, "topic:launch", MetricPosts); m.Value != 2 { t.Errorf("topic day = %+v", m) }
+
+    if _, ok := find(ms, Daily, "platform:mastodon", MetricEngagementRate); ok { t.Error("rate without a denominator") }
+    if _, ok := find(ms, Daily, "campaign:", MetricPosts); ok { t.Error("empty campaign dimension") }
+}
+
+type fakeRepo struct {
+    posts    []calendarrepo.PostPerformance
+    from, to time.Time
+    sources  []string
+    written  []models.TrendMetric
+}
+
+func (f *fakeRepo) PostPerformance(_ context.Context, _ string, from, to time.Time) ([]calendarrepo.PostPerformance, error) {
+    return f.posts, nil
+}
+
+func (f *fakeRepo) ReplaceTrends(_ context.Context, _ string, sources []string, from, to time.Time, ms []models.TrendMetric) error {
+    f.sources, f.from, f.to, f.written = sources, from, to, ms
+    return nil
+}
+
+func (f *fakeRepo) TrendSeries(_ context.Context, _, source, dimension string, metrics []string, from, to time.Time) ([]models.TrendMetric, error) {
+    var out []models.TrendMetric
+    for _, m := range f.written {
+        dimOK := m.Dimension == dimension || (strings.HasSuffix(dimension, ":") && strings.HasPrefix(m.Dimension, dimension))
+        metricOK := false
+        for _, name := range metrics { metricOK = metricOK || name == m.Metric }
+        if m.Source == source && dimOK && metricOK && !m.BucketStart.Before(from) && m.BucketStart.Before(to) { out = append(out, m) }
+    }
+    return out, nil
+}
+
+func TestRunAndQuerySeries(t *testing.T) {
+    wed := time.Date(2026, 5, 6, 12, 0, 0, 0, time.UTC)
+    repo := &fakeRepo{posts: []calendarrepo.PostPerformance{
+        post("a", "instagram", "", wed, 0, calendarrepo.VariantOutcome{Impressions: 100, Likes: 10}),
+        post("b", "linkedin", "", wed.AddDate(0, 0, 2), 0, calendarrepo.VariantOutcome{Impressions: 50, Likes: 1}),
+    }}
+    sum, err := Run(context.Background(), repo, "org1", wed, wed.AddDate(0, 0, 3))
+    if err != nil { t.Fatal(err) }
+    // Whole weeks: Monday May 4 to Monday May 11.
+    if !repo.from.Equal(time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC)) || !repo.to.Equal(time.Date(2026, 5, 11, 0, 0, 0, 0, time.UTC)) { t.Fatalf("window = %s..%s", repo.from, repo.to) }
+    if sum.Posts != 2 || sum.Metrics != len(repo.written) || len(repo.sources) != 3 { t.Fatalf("summary = %+v, sources %v", sum, repo.sources) }
+
+    series, err := QuerySeries(context.Background(), repo, SeriesQuery{
+        OrgID: "org1", Dimension: "platform:", Metrics: []string{MetricLikes, MetricEngagementRate},
+        From: wed.AddDate(0, 0, -1), To: Daily.Truncate(wed).AddDate(0, 0, 3),
+    })
+    if err != nil { t.Fatal(err) }
+    if len(series) != 4 || series[0].Dimension != "platform:instagram" || series[0].Metric != MetricLikes || series[1].Metric != MetricEngagementRate { t.Fatalf("series = %+v", series) }
+    likes, rate := series[0].Points, series[1].Points
+    // Tuesday to Friday, one point per day; gaps are 0 for counts, null for rates.
+    if len(likes) != 4 || *likes[0].Value != 0 || *likes[1].Value != 10 { t.Fatalf("likes = %+v", likes) }
+    if rate[0].Value != nil || *rate[1].Value != 0.1 { t.Errorf("rate = %+v", rate) }
+
+    // An exact dimension without data still gets its series.
+    series, err = QuerySeries(context.Background(), repo, SeriesQuery{OrgID: "org1", Dimension: "campaign:none", Granularity: Weekly, From: wed, To: wed.AddDate(0, 0, 1)})
+    if err != nil || len(series) != len(DefaultMetrics) || len(series[0].Points) != 1 { t.Fatalf("empty = %+v, %v", series, err) }
+
+    if _, err := QuerySeries(context.Background(), repo, SeriesQuery{OrgID: "org1", Metrics: []string{"vibes"}}); !errors.Is(err, ErrBadQuery) { t.Errorf("unknown metric: %v", err) }
+    if _, err := QuerySeries(context.Background(), repo, SeriesQuery{OrgID: "org1", Granularity: Hourly, From: wed.AddDate(-1, 0, 0), To: wed}); err == nil { t.Error("too many points accepted") }
+}
+
+func TestQueryTotals(t *testing.T) {
+    mon := time.Date(2026, 5, 4, 9, 0, 0, 0, time.UTC)
+    repo := &fakeRepo{written: Rollup([]calendarrepo.PostPerformance{
+        post("a", "instagram", "c1", mon, 0, calendarrepo.VariantOutcome{Impressions: 100, Likes: 10}),
+        post("b", "instagram", "c1", mon.AddDate(0, 0, 1), 0, calendarrepo.VariantOutcome{Impressions: 300, Likes: 10}),
+        post("c", "mastodon", "", mon.AddDate(0, 0, 1), 0, calendarrepo.VariantOutcome{Likes: 3}),
+    })}
+    totals, err := QueryTotals(context.Background(), repo, SeriesQuery{OrgID: "org1", Dimension: "platform:", From: mon, To: mon.AddDate(0, 0, 7)})
+    if err != nil { t.Fatal(err) }
+    if len(totals) != 2 || totals[0].Dimension != "platform:instagram" { t.Fatalf("totals = %+v", totals) }
+    // The rate of the range, not the mean of the daily rates.
+    ig := totals[0].Values
+    if ig[MetricPosts] != 2 || ig[MetricLikes] != 20 || ig[MetricEngagementRate] != 0.05 { t.Errorf("instagram = %v", ig) }
+    if _, ok := totals[1].Values[MetricEngagementRate]; ok { t.Errorf("mastodon = %v", totals[1].Values) }
+}
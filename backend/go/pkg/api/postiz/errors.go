@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // APIError wraps HTTP error details from Postiz
@@ -23,6 +24,23 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("api error %d: %s", e.StatusCode, e.Message)
 }
 
+// Retryable reports 429 and 5xx responses; together with the methods below it
+// satisfies external.PostError.
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+func (e *APIError) Permanent() bool { return !e.Retryable() }
+
+func (e *APIError) AuthExpired() bool { return e.StatusCode == http.StatusUnauthorized }
+
+func (e *APIError) QuotaExceeded() bool { return false }
+
+// RateLimitedUntil reports 429s; Postiz does not send a reset time.
+func (e *APIError) RateLimitedUntil() (time.Time, bool) {
+	return time.Time{}, e.StatusCode == http.StatusTooManyRequests
+}
+
 func IsNotFound(err error) bool {
 	var ae *APIError
 	return errors.As(err, &ae) && ae.StatusCode == http.StatusNotFound
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+	"github.com/bitesinbyte/ferret/pkg/analytics/dashboard"
+	"github.com/bitesinbyte/ferret/pkg/analytics/trends"
+	"github.com/bitesinbyte/ferret/pkg/engine/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// analyticsOrg resolves the org of an analytics request (?org_id, or the
+// caller's profile org) and checks analytics.read in it. It answers the
+// request itself and returns false when the caller may not proceed.
+func analyticsOrg(c *gin.Context) (string, bool) {
+	uid := c.GetString(ctxUserID)
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return "", false
+	}
+	if sqlDB == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return "", false
+	}
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		var profileOrg sql.NullString
+		_ = sqlDB.QueryRowContext(c.Request.Context(), `SELECT org_id FROM user_profiles WHERE user_id=$1`, uid).Scan(&profileOrg)
+		if !profileOrg.Valid || profileOrg.String == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "org_id required (no org associated with profile)"})
+			return "", false
+		}
+		orgID = profileOrg.String
+	}
+	return orgID, checkAnalyticsRead(c, uid, orgID)
+}
+
+func checkAnalyticsRead(c *gin.Context, uid, orgID string) bool {
+	allowed, err := auth.HasOrgPermission(c.Request.Context(), sqlDB, orgID, uid, auth.PermAnalyticsRead)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "analytics.read required"})
+		return false
+	}
+	return true
+}
+
+// analyticsFilter reads the date range, post filters and paging of a
+// request.
+func analyticsFilter(c *gin.Context, orgID string) (dashboard.Filter, error) {
+	f := dashboard.Filter{OrgID: orgID, Platform: c.Query("platform"), CampaignID: c.Query("campaign_id")}
+	var err error
+	if f.From, f.To, err = dashboard.ParseRange(c.Query("from"), c.Query("to"), time.Now()); err != nil {
+		return f, err
+	}
+	for name, dst := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+		if v := c.Query(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return f, errors.New("invalid " + name)
+			}
+			*dst = n
+		}
+	}
+	return f, nil
+}
+
+// writeAnalytics answers with v as JSON or CSV (?format=json|csv). total,
+// when not negative, is sent as X-Total-Count.
+func writeAnalytics(c *gin.Context, v any, total int) {
+	format := c.DefaultQuery("format", dashboard.FormatJSON)
+	contentType, ok := dashboard.ContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+		return
+	}
+	if total >= 0 {
+		c.Header("X-Total-Count", strconv.Itoa(total))
+	}
+	if format == dashboard.FormatCSV {
+		c.Header("Content-Disposition", `attachment; filename="`+strings.TrimPrefix(c.FullPath(), "/v1/")+`.csv"`)
+	}
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", contentType)
+	if err := dashboard.Write(c.Writer, v, format); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// analyticsError answers a failed query: 400 for ranges or parameters the
+// rollups reject, 500 otherwise.
+func analyticsError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, trends.ErrBadQuery) {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// analyticsPosts lists published posts with their latest outcome, newest
+// first.
+func analyticsPosts(c *gin.Context) {
+	listPosts(c, "")
+}
+
+// analyticsTopPosts lists published posts by ?metric (engagements), best
+// first.
+func analyticsTopPosts(c *gin.Context) {
+	listPosts(c, c.DefaultQuery("metric", trends.MetricEngagements))
+}
+
+func listPosts(c *gin.Context, metric string) {
+	orgID, ok := analyticsOrg(c)
+	if !ok {
+		return
+	}
+	f, err := analyticsFilter(c, orgID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if metric != "" {
+		if _, known := calendarrepo.PostOrders[metric]; !known || metric == "published_at" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown metric " + metric})
+			return
+		}
+	}
+	var page dashboard.Page[dashboard.Post]
+	if metric == "" {
+		page, err = dashboard.Posts(c.Request.Context(), sqlDB, f)
+	} else {
+		page, err = dashboard.TopPosts(c.Request.Context(), sqlDB, f, metric)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	writeAnalytics(c, page, page.Total)
+}
+
+// analyticsPostHistory serves every outcome snapshot of one post. Requires
+// analytics.read in the post's org.
+func analyticsPostHistory(c *gin.Context) {
+	uid := c.GetString(ctxUserID)
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if sqlDB == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+	h, err := dashboard.PostHistory(c.Request.Context(), sqlDB, c.Param("id"))
+	if errors.Is(err, dashboard.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return
+	}
+	if h.OrgID == "" {
+		// Without the org neither the caller's access nor the post can be
+		// established; errors after it are only shown to readers of the org.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		}
+		return
+	}
+	if !checkAnalyticsRead(c, uid, h.OrgID) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	writeAnalytics(c, h, len(h.Snapshots))
+}
+
+// analyticsCampaigns summarizes each campaign over the range.
+func analyticsCampaigns(c *gin.Context) {
+	orgID, ok := analyticsOrg(c)
+	if !ok {
+		return
+	}
+	f, err := summaryFilter(c, orgID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := dashboard.Campaigns(c.Request.Context(), sqlDB, f)
+	if err != nil {
+		analyticsError(c, err)
+		return
+	}
+	writeAnalytics(c, page, page.Total)
+}
+
+// analyticsPlatforms compares the platforms over the range.
+func analyticsPlatforms(c *gin.Context) {
+	orgID, ok := analyticsOrg(c)
+	if !ok {
+		return
+	}
+	f, err := summaryFilter(c, orgID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	items, err := dashboard.Platforms(c.Request.Context(), sqlDB, f)
+	if err != nil {
+		analyticsError(c, err)
+		return
+	}
+	if c.DefaultQuery("format", dashboard.FormatJSON) == dashboard.FormatCSV {
+		writeAnalytics(c, items, len(items))
+		return
+	}
+	writeAnalytics(c, gin.H{"from": f.From, "to": f.To, "platforms": items}, -1)
+}
+
+// summaryFilter is analyticsFilter for the rollup summaries, which cannot
+// narrow by post.
+func summaryFilter(c *gin.Context, orgID string) (dashboard.Filter, error) {
+	f, err := analyticsFilter(c, orgID)
+	if err == nil && (f.Platform != "" || f.CampaignID != "") {
+		err = errors.New("platform and campaign_id filter post lists only")
+	}
+	return f, err
+}
+
+// analyticsSeries serves chartable time series from the rollups.
+func analyticsSeries(c *gin.Context) {
+	orgID, ok := analyticsOrg(c)
+	if !ok {
+		return
+	}
+	q := trends.SeriesQuery{OrgID: orgID, Granularity: trends.Granularity(c.Query("granularity")), Dimension: c.Query("dimension")}
+	for _, m := range strings.Split(c.Query("metrics"), ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			q.Metrics = append(q.Metrics, m)
+		}
+	}
+	var err error
+	if q.From, q.To, err = dashboard.ParseRange(c.Query("from"), c.Query("to"), time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	series, err := trends.QuerySeries(c.Request.Context(), calendarrepo.Repository{DB: sqlDB}, q)
+	if err != nil {
+		analyticsError(c, err)
+		return
+	}
+	writeAnalytics(c, series, -1)
+}
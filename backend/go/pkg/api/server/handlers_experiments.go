@@ -0,0 +1,78 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bitesinbyte/ferret/pkg/analytics/experiments"
+	"github.com/bitesinbyte/ferret/pkg/engine/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// experimentReport serves the A/B analysis of an experiment as JSON, CSV or
+// Markdown (?format=json|csv|md). Requires analytics.read in the
+// experiment's org.
+func experimentReport(c *gin.Context) {
+	uid := c.GetString(ctxUserID)
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if sqlDB == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+	format := c.DefaultQuery("format", experiments.FormatJSON)
+	contentType, ok := experiments.ContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv or md"})
+		return
+	}
+	opts := experiments.Options{Metric: c.Query("metric"), Control: c.Query("control")}
+	if v := c.Query("confidence"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid confidence"})
+			return
+		}
+		opts.Confidence = f
+	}
+	if v := c.Query("min_posts"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid min_posts"})
+			return
+		}
+		opts.MinPosts = n
+	}
+	r, err := experiments.Load(c.Request.Context(), sqlDB, c.Param("id"), opts)
+	if errors.Is(err, experiments.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "experiment not found"})
+		return
+	}
+	// Load reports the org whenever the experiment's data could be read;
+	// analysis errors are answered only after the permission check.
+	if r.OrgID == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	allowed, perr := auth.HasOrgPermission(c.Request.Context(), sqlDB, r.OrgID, uid, auth.PermAnalyticsRead)
+	if perr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": perr.Error()})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "analytics.read required"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", contentType)
+	if err := experiments.Write(c.Writer, r, format); err != nil {
+		_ = c.Error(err)
+	}
+}
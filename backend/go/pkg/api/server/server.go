@@ -47,6 +47,13 @@ func New() *gin.Engine {
 		v1.PUT("/profile", updateProfile)
 		v1.GET("/icp", getICP)
 		v1.PUT("/icp", updateICP)
+		v1.GET("/experiments/:id/report", experimentReport)
+		v1.GET("/analytics/posts", analyticsPosts)
+		v1.GET("/analytics/posts/:id", analyticsPostHistory)
+		v1.GET("/analytics/top-posts", analyticsTopPosts)
+		v1.GET("/analytics/campaigns", analyticsCampaigns)
+		v1.GET("/analytics/platforms", analyticsPlatforms)
+		v1.GET("/analytics/series", analyticsSeries)
 	}
 	return r
 }
@@ -3,9 +3,15 @@
 package server
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/bitesinbyte/ferret/pkg/analytics/dashboard"
+	"github.com/gin-gonic/gin"
 )
 
 func TestHealthz(t *testing.T) {
@@ -31,3 +37,73 @@ func TestOpenAPI(t *testing.T) {
 		t.Log("no content-type set; ok")
 	}
 }
+
+func TestAnalyticsRoutes(t *testing.T) {
+	r := New()
+	for _, path := range []string{"/v1/analytics/posts", "/v1/analytics/posts/sp1", "/v1/analytics/top-posts", "/v1/analytics/campaigns", "/v1/analytics/platforms", "/v1/analytics/series"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s without a token: %d", path, w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/openapi.json", nil)
+	r.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"/v1/analytics/series"`) {
+		t.Error("analytics endpoints not documented")
+	}
+}
+
+// TestAnalyticsPagingAndCSV runs a post list through the paging and output
+// steps of listPosts, with the database query replaced by a fixed page.
+func TestAnalyticsPagingAndCSV(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	published := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	r := gin.New()
+	r.GET("/v1/analytics/posts", func(c *gin.Context) {
+		f, err := analyticsFilter(c, "org_1")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		page := dashboard.Page[dashboard.Post]{Total: 3, Limit: f.Limit, Offset: f.Offset, Items: []dashboard.Post{
+			{ID: "sp2", Platform: "mastodon", PublishedAt: published, Likes: 7},
+		}}
+		writeAnalytics(c, page, page.Total)
+	})
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/analytics/posts?"+query, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("limit=1&offset=1")
+	if w.Code != http.StatusOK || w.Header().Get("X-Total-Count") != "3" {
+		t.Fatalf("json: %d, X-Total-Count %q", w.Code, w.Header().Get("X-Total-Count"))
+	}
+	var page dashboard.Page[dashboard.Post]
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || page.Limit != 1 || page.Offset != 1 || len(page.Items) != 1 {
+		t.Fatalf("page = %+v, %v", page, err)
+	}
+
+	w = get("limit=1&offset=1&format=csv")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("csv: %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="analytics/posts.csv"` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "sp2,mastodon") {
+		t.Errorf("csv body = %q", w.Body.String())
+	}
+
+	for _, q := range []string{"limit=-1", "offset=x", "format=xml"} {
+		if w := get(q); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d, want 400", q, w.Code)
+		}
+	}
+}
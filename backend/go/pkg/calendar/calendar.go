@@ -5,10 +5,14 @@ import (
     "database/sql"
     "encoding/json"
     "errors"
+    "fmt"
+    "os"
     "time"
 )
 
-// Platform mirrors the Python enum for platform names.
+// Platform mirrors the Python enum for platform names. Publishable platforms
+// are the ones registered in external (see external.Platforms); scheduling
+// validates against that registry.
 type Platform string
 
 const (
@@ -16,6 +20,8 @@ const (
     PlatformLinkedIn  Platform = "linkedin"
     PlatformTwitter   Platform = "twitter"
     PlatformFacebook  Platform = "facebook"
+    PlatformMastodon  Platform = "mastodon"
+    PlatformThread    Platform = "thread"
     PlatformYouTube   Platform = "youtube"
     PlatformBeehiiv   Platform = "behiiv"
 )
@@ -24,12 +30,21 @@ const (
 type ScheduledStatus string
 
 const (
-    StatusScheduled ScheduledStatus = "scheduled"
-    StatusPublished ScheduledStatus = "published"
-    StatusFailed    ScheduledStatus = "failed"
-    StatusCanceled  ScheduledStatus = "canceled"
+    StatusScheduled  ScheduledStatus = "scheduled"
+    StatusProcessing ScheduledStatus = "processing"
+    StatusPublished  ScheduledStatus = "published"
+    StatusFailed     ScheduledStatus = "failed"
+    StatusCanceled   ScheduledStatus = "canceled"
 )
 
+// DefaultLeaseTTL is how long a claim is held before the reaper may take it back.
+const DefaultLeaseTTL = 15 * time.Minute
+
+// DefaultClaimLookback is how overdue a post may be and still get claimed by
+// the scheduler daemon, so reaped or missed rows are picked up again without
+// replaying ancient ones.
+const DefaultClaimLookback = 24 * time.Hour
+
 // ScheduledPostRow represents a scheduled post with minimal joined context.
 type ScheduledPostRow struct {
     ID          string
@@ -38,6 +53,7 @@ type ScheduledPostRow struct {
     ContentID   sql.NullString
     ContentTitle sql.NullString
     ContentURL  sql.NullString
+    SocialAccountID sql.NullString
     Platform    Platform
     Caption     sql.NullString
     Hashtags    sql.NullString
@@ -46,19 +62,26 @@ type ScheduledPostRow struct {
     ExternalID  sql.NullString
     PublishedAt sql.NullTime
     Metadata    json.RawMessage
+    ClaimedBy   sql.NullString
+    LeaseExpiresAt sql.NullTime
+    Attempts    int
     CreatedAt   time.Time
     UpdatedAt   time.Time
 }
 
+// scheduledPostColumns is the select list shared by every row-returning query.
+const scheduledPostColumns = `sp.id, sp.campaign_id, c.name AS campaign_name,
+       sp.content_id, ci.title AS content_title, ci.canonical_url AS content_url,
+       sp.social_account_id, sp.platform, sp.caption, sp.hashtags,
+       sp.scheduled_at, sp.status, sp.external_id, sp.published_at, sp.metadata,
+       sp.claimed_by, sp.lease_expires_at, sp.attempts,
+       sp.created_at, sp.updated_at`
+
 // FetchScheduledPostsWithin returns posts in [start, end) with status=scheduled.
 // The caller must pass a *sql.DB connected to Postgres (import a driver in main).
 func FetchScheduledPostsWithin(ctx context.Context, db *sql.DB, start, end time.Time) ([]ScheduledPostRow, error) {
     const q = `
-SELECT sp.id, sp.campaign_id, c.name AS campaign_name,
-       sp.content_id, ci.title AS content_title, ci.canonical_url AS content_url,
-       sp.platform, sp.caption, sp.hashtags,
-       sp.scheduled_at, sp.status, sp.external_id, sp.published_at, sp.metadata,
-       sp.created_at, sp.updated_at
+SELECT ` + scheduledPostColumns + `
 FROM scheduled_posts sp
 LEFT JOIN campaigns c ON sp.campaign_id = c.id
 LEFT JOIN content_items ci ON sp.content_id = ci.id
@@ -70,8 +93,33 @@ ORDER BY sp.scheduled_at ASC`
         return nil, err
     }
     defer rows.Close()
+    return scanScheduledPosts(rows, 32)
+}
+
+// FetchPublishedPosts returns published posts with an external_id that went
+// out at or after since, newest first (limit <= 0 means no limit). Analytics
+// collection reads metrics back by their external_id.
+func FetchPublishedPosts(ctx context.Context, db *sql.DB, since time.Time, limit int) ([]ScheduledPostRow, error) {
+    const q = `
+SELECT ` + scheduledPostColumns + `
+FROM scheduled_posts sp
+LEFT JOIN campaigns c ON sp.campaign_id = c.id
+LEFT JOIN content_items ci ON sp.content_id = ci.id
+WHERE sp.status = 'published' AND COALESCE(sp.external_id, '') <> ''
+  AND COALESCE(sp.published_at, sp.scheduled_at) >= $1
+ORDER BY COALESCE(sp.published_at, sp.scheduled_at) DESC, sp.id
+LIMIT NULLIF($2, 0)`
+
+    if limit < 0 { limit = 0 }
+    rows, err := db.QueryContext(ctx, q, since, limit)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    return scanScheduledPosts(rows, 64)
+}
 
-    out := make([]ScheduledPostRow, 0, 32)
+// scanScheduledPosts reads rows selected with scheduledPostColumns.
+func scanScheduledPosts(rows *sql.Rows, capHint int) ([]ScheduledPostRow, error) {
+    out := make([]ScheduledPostRow, 0, capHint)
     for rows.Next() {
         var r ScheduledPostRow
         var platform string
@@ -80,8 +128,9 @@ ORDER BY sp.scheduled_at ASC`
         if err := rows.Scan(
             &r.ID, &r.CampaignID, &r.CampaignName,
             &r.ContentID, &r.ContentTitle, &r.ContentURL,
-            &platform, &r.Caption, &r.Hashtags,
+            &r.SocialAccountID, &platform, &r.Caption, &r.Hashtags,
             &r.ScheduledAt, &status, &r.ExternalID, &r.PublishedAt, &metaBytes,
+            &r.ClaimedBy, &r.LeaseExpiresAt, &r.Attempts,
             &r.CreatedAt, &r.UpdatedAt,
         ); err != nil {
             return nil, err
@@ -111,11 +160,23 @@ func FetchDuePosts(ctx context.Context, db *sql.DB, within time.Duration) ([]Sch
 
 // FetchAndClaimDuePosts atomically moves due posts to 'processing' and returns them.
 // This prevents multiple runners from posting the same items. Uses SKIP LOCKED.
+// Only posts scheduled from now on are claimed. The claim is leased to
+// DefaultLeaseOwner for DefaultLeaseTTL.
 func FetchAndClaimDuePosts(ctx context.Context, db *sql.DB, within time.Duration, limit int) ([]ScheduledPostRow, error) {
+    return FetchAndClaimDuePostsWithLease(ctx, db, within, limit, DefaultLeaseOwner(), DefaultLeaseTTL, 0)
+}
+
+// FetchAndClaimDuePostsWithLease is FetchAndClaimDuePosts with an explicit lease.
+// Each claim records owner, sets lease_expires_at = now+lease and bumps attempts,
+// so ReapExpiredLeases can recover rows whose runner died mid-flight. Posts up
+// to lookback overdue are claimed too; reaped rows are in the past, so without
+// a lookback they are never claimed again.
+func FetchAndClaimDuePostsWithLease(ctx context.Context, db *sql.DB, within time.Duration, limit int, owner string, lease, lookback time.Duration) ([]ScheduledPostRow, error) {
     if within <= 0 { return nil, errors.New("within must be > 0") }
     if limit <= 0 { limit = 50 }
+    if lease <= 0 { lease = DefaultLeaseTTL }
     now := time.Now().UTC()
-    end := now.Add(within)
+    from, end := claimWindow(now, within, lookback)
 
     const q = `
 WITH cte AS (
@@ -124,51 +185,139 @@ WITH cte AS (
     ORDER BY scheduled_at ASC
     FOR UPDATE SKIP LOCKED
     LIMIT $3
+), claimed AS (
+    UPDATE scheduled_posts sp
+    SET status = 'processing', claimed_by = $4, lease_expires_at = $5,
+        attempts = sp.attempts + 1, updated_at = NOW()
+    FROM cte
+    WHERE sp.id = cte.id
+    RETURNING sp.*
 )
-UPDATE scheduled_posts sp
-SET status = 'processing', updated_at = NOW()
-FROM cte
-WHERE sp.id = cte.id
-RETURNING sp.id, sp.campaign_id, c.name AS campaign_name,
-          sp.content_id, ci.title AS content_title, ci.canonical_url AS content_url,
-          sp.platform, sp.caption, sp.hashtags,
-          sp.scheduled_at, sp.status, sp.external_id, sp.published_at, sp.metadata,
-          sp.created_at, sp.updated_at
-`
-
-    rows, err := db.QueryContext(ctx, q, now, end, limit)
+SELECT ` + scheduledPostColumns + `
+FROM claimed sp
+LEFT JOIN campaigns c ON sp.campaign_id = c.id
+LEFT JOIN content_items ci ON sp.content_id = ci.id
+ORDER BY sp.scheduled_at ASC`
+
+    rows, err := db.QueryContext(ctx, q, from, end, limit, owner, now.Add(lease))
     if err != nil { return nil, err }
     defer rows.Close()
+    return scanScheduledPosts(rows, limit)
+}
 
-    out := make([]ScheduledPostRow, 0, limit)
+// claimWindow is the scheduled_at range [from, end) a claim at now takes.
+func claimWindow(now time.Time, within, lookback time.Duration) (from, end time.Time) {
+    return now.Add(-max(lookback, 0)), now.Add(within)
+}
+
+// ReapExpiredLeases returns 'processing' rows whose lease has expired to
+// 'scheduled', or to 'failed' once they have used maxAttempts claims (see
+// reapStatus). Rows claimed before leases existed (no lease_expires_at) count
+// as expired after DefaultLeaseTTL without an update. It reports how many
+// rows went each way.
+func ReapExpiredLeases(ctx context.Context, db *sql.DB, maxAttempts int) (requeued, failed int, err error) {
+    const sel = `SELECT id, attempts, COALESCE(claimed_by, '') FROM scheduled_posts
+WHERE status = 'processing'
+  AND (lease_expires_at < NOW() OR (lease_expires_at IS NULL AND updated_at < $1))
+FOR UPDATE SKIP LOCKED`
+    const requeue = `UPDATE scheduled_posts
+SET status = 'scheduled', claimed_by = NULL, lease_expires_at = NULL, updated_at = NOW()
+WHERE id = $1`
+    const fail = `UPDATE scheduled_posts
+SET status = 'failed', metadata = COALESCE(metadata, '{}'::jsonb) || $2::jsonb,
+    claimed_by = NULL, lease_expires_at = NULL, updated_at = NOW()
+WHERE id = $1`
+
+    tx, err := db.BeginTx(ctx, nil)
+    if err != nil { return 0, 0, err }
+    defer tx.Rollback()
+    type expired struct {
+        id, claimedBy string
+        attempts      int
+    }
+    rows, err := tx.QueryContext(ctx, sel, time.Now().UTC().Add(-DefaultLeaseTTL))
+    if err != nil { return 0, 0, err }
+    var batch []expired
     for rows.Next() {
-        var r ScheduledPostRow
-        var platform string
-        var status string
-        var metaBytes sql.NullString
-        if err := rows.Scan(
-            &r.ID, &r.CampaignID, &r.CampaignName,
-            &r.ContentID, &r.ContentTitle, &r.ContentURL,
-            &platform, &r.Caption, &r.Hashtags,
-            &r.ScheduledAt, &status, &r.ExternalID, &r.PublishedAt, &metaBytes,
-            &r.CreatedAt, &r.UpdatedAt,
-        ); err != nil {
-            return nil, err
-        }
-        r.Platform = Platform(platform)
-        r.Status = ScheduledStatus(status)
-        if metaBytes.Valid && len(metaBytes.String) > 0 {
-            r.Metadata = json.RawMessage(metaBytes.String)
+        var e expired
+        if err := rows.Scan(&e.id, &e.attempts, &e.claimedBy); err != nil { rows.Close(); return 0, 0, err }
+        batch = append(batch, e)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil { return 0, 0, err }
+    for _, e := range batch {
+        if reapStatus(e.attempts, maxAttempts) == StatusFailed {
+            meta, _ := json.Marshal(map[string]string{"error": fmt.Sprintf("lease expired after %d attempts", e.attempts), "claimed_by": e.claimedBy})
+            if _, err := tx.ExecContext(ctx, fail, e.id, string(meta)); err != nil { return 0, 0, err }
+            failed++
+            continue
         }
-        out = append(out, r)
+        if _, err := tx.ExecContext(ctx, requeue, e.id); err != nil { return 0, 0, err }
+        requeued++
     }
-    if err := rows.Err(); err != nil { return nil, err }
-    return out, nil
+    if err := tx.Commit(); err != nil { return 0, 0, err }
+    return requeued, failed, nil
+}
+
+// reapStatus is where ReapExpiredLeases sends an expired row that has been
+// claimed attempts times: 'failed' once attempts reaches maxAttempts
+// (default 3), else back to 'scheduled'.
+func reapStatus(attempts, maxAttempts int) ScheduledStatus {
+    if maxAttempts <= 0 { maxAttempts = 3 }
+    if attempts >= maxAttempts { return StatusFailed }
+    return StatusScheduled
+}
+
+// DefaultLeaseOwner identifies this process as host:pid for claimed_by.
+func DefaultLeaseOwner() string {
+    host, err := os.Hostname()
+    if err != nil || host == "" { host = "unknown" }
+    return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// ReleaseClaimedPosts returns claimed rows that were never handed to a poster
+// back to 'scheduled' so the next claim picks them up. The unused attempt is
+// given back. Rows that have already moved on (published/failed) are left untouched.
+func ReleaseClaimedPosts(ctx context.Context, db *sql.DB, ids []string) error {
+    if len(ids) == 0 { return nil }
+    q := `UPDATE scheduled_posts
+SET status = 'scheduled', claimed_by = NULL, lease_expires_at = NULL,
+    attempts = GREATEST(attempts - 1, 0), updated_at = NOW()
+WHERE status = 'processing' AND id IN (`
+    args := make([]any, 0, len(ids))
+    for i, id := range ids {
+        if i > 0 { q += ", " }
+        q += "$" + itoa(i+1)
+        args = append(args, id)
+    }
+    q += ")"
+    _, err := db.ExecContext(ctx, q, args...)
+    return err
+}
+
+// RequeuePost hands a claimed row back to 'scheduled' at the given time after a
+// retryable failure. The attempt used by the claim is kept, so the reaper's
+// max-attempts cut-off still applies.
+func RequeuePost(ctx context.Context, db *sql.DB, id string, at time.Time) error {
+    const q = `UPDATE scheduled_posts
+SET status = 'scheduled', scheduled_at = $2, claimed_by = NULL, lease_expires_at = NULL, updated_at = NOW()
+WHERE id = $1 AND status = 'processing'`
+    _, err := db.ExecContext(ctx, q, id, at)
+    return err
 }
 
 // UpdatePostStatus updates status and optional external metadata after publish/fail.
-// Pass publishedAt non-zero when marking as published.
+// Pass publishedAt non-zero when marking as published. metadata is merged into
+// the row's existing metadata: its top-level keys are set and all other keys
+// (variant/arm attribution, retry inputs, recycled_from) are kept.
 func UpdatePostStatus(ctx context.Context, db *sql.DB, id string, status ScheduledStatus, externalID *string, publishedAt *time.Time, metadata json.RawMessage) error {
+    q, args := statusUpdateQuery(id, status, externalID, publishedAt, metadata)
+    _, err := db.ExecContext(ctx, q, args...)
+    return err
+}
+
+// statusUpdateQuery builds the UPDATE run by UpdatePostStatus.
+func statusUpdateQuery(id string, status ScheduledStatus, externalID *string, publishedAt *time.Time, metadata json.RawMessage) (string, []any) {
     // Build dynamic update based on provided fields.
     const base = `UPDATE scheduled_posts SET status = $1, updated_at = NOW()`
     args := []any{string(status)}
@@ -185,15 +334,17 @@ func UpdatePostStatus(ctx context.Context, db *sql.DB, id string, status Schedul
         idx++
     }
     if metadata != nil {
-        set += ", metadata = $" + itoa(idx)
+        set += ", metadata = COALESCE(metadata, '{}'::jsonb) || $" + itoa(idx) + "::jsonb"
         args = append(args, string(metadata))
         idx++
     }
-    where := ", updated_at = NOW() WHERE id = $" + itoa(idx)
+    if status != StatusProcessing {
+        // Leaving 'processing' ends the lease.
+        set += ", claimed_by = NULL, lease_expires_at = NULL"
+    }
+    where := " WHERE id = $" + itoa(idx)
     args = append(args, id)
-    q := base + set + where
-    _, err := db.ExecContext(ctx, q, args...)
-    return err
+    return base + set + where, args
 }
 
 // itoa is a tiny helper avoiding strconv to keep deps minimal.
@@ -0,0 +1,49 @@
+package calendar
+
+import (
+    "encoding/json"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestStatusUpdateQueryMergesMetadata(t *testing.T) {
+    q, args := statusUpdateQuery("sp_1", StatusFailed, nil, nil, json.RawMessage(`{"error":"boom"}`))
+    if !strings.Contains(q, "metadata = COALESCE(metadata, '{}'::jsonb) || $2::jsonb") { t.Fatalf("metadata not merged: %s", q) }
+    if !strings.Contains(q, "claimed_by = NULL") { t.Fatalf("lease not cleared: %s", q) }
+    if !strings.HasSuffix(q, "WHERE id = $3") { t.Fatalf("where: %s", q) }
+    if len(args) != 3 || args[0] != "failed" || args[1] != `{"error":"boom"}` || args[2] != "sp_1" { t.Fatalf("args = %v", args) }
+}
+
+func TestStatusUpdateQueryWithoutMetadataLeavesItAlone(t *testing.T) {
+    ext := "123"
+    q, args := statusUpdateQuery("sp_1", StatusPublished, &ext, nil, nil)
+    if strings.Contains(q, "metadata") { t.Fatalf("metadata touched: %s", q) }
+    if len(args) != 3 { t.Fatalf("args = %v", args) }
+}
+
+func TestReapStatusFailsAtMaxAttempts(t *testing.T) {
+    cases := []struct {
+        attempts, max int
+        want          ScheduledStatus
+    }{
+        {1, 3, StatusScheduled},
+        {2, 3, StatusScheduled},
+        {3, 3, StatusFailed},
+        {4, 3, StatusFailed},
+        {1, 1, StatusFailed},
+        {2, 0, StatusScheduled}, // 0 means the default of 3
+        {3, 0, StatusFailed},
+    }
+    for _, c := range cases {
+        if got := reapStatus(c.attempts, c.max); got != c.want { t.Errorf("reapStatus(%d, %d) = %s, want %s", c.attempts, c.max, got, c.want) }
+    }
+}
+
+func TestClaimWindowLookback(t *testing.T) {
+    now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+    from, end := claimWindow(now, time.Minute, 0)
+    if !from.Equal(now) || !end.Equal(now.Add(time.Minute)) { t.Fatalf("no lookback: [%s, %s)", from, end) }
+    if from, _ = claimWindow(now, time.Minute, -time.Hour); !from.Equal(now) { t.Fatalf("negative lookback: from %s", from) }
+    if from, _ = claimWindow(now, time.Minute, DefaultClaimLookback); !from.Equal(now.Add(-24 * time.Hour)) { t.Fatalf("lookback: from %s", from) }
+}
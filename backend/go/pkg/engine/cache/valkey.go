@@ -4,16 +4,20 @@ import (
     "bufio"
     "errors"
     "fmt"
+    "io"
     "net"
     "os"
     "strconv"
     "strings"
+    "sync"
     "time"
 )
 
 // Valkey is a tiny RESP2 client sufficient for basic caching against Valkey/Redis.
-// It supports AUTH, SELECT, PING, GET, SET (with EX), and DEL.
+// It supports AUTH, SELECT, PING, GET, SET (with EX), DEL and EVAL. Commands
+// are serialized, so one connection can be shared by goroutines.
 type Valkey struct {
+    mu   sync.Mutex
     conn net.Conn
     rw   *bufio.ReadWriter
 }
@@ -62,6 +66,8 @@ func (v *Valkey) Close() error {
 
 // Ping issues PING and expects PONG.
 func (v *Valkey) Ping() error {
+    v.mu.Lock()
+    defer v.mu.Unlock()
     if err := v.send("PING"); err != nil { return err }
     s, err := v.readSimpleString()
     if err != nil { return err }
@@ -71,6 +77,8 @@ func (v *Valkey) Ping() error {
 
 // Get returns the value for key, or ok=false on nil.
 func (v *Valkey) Get(key string) (string, bool, error) {
+    v.mu.Lock()
+    defer v.mu.Unlock()
     if err := v.send("GET", key); err != nil { return "", false, err }
     b, n, err := v.readBulk()
     if err != nil { return "", false, err }
@@ -80,6 +88,8 @@ func (v *Valkey) Get(key string) (string, bool, error) {
 
 // Set sets key to value with expiration ttl. If ttl<=0, it's set without expiry.
 func (v *Valkey) Set(key, value string, ttl time.Duration) error {
+    v.mu.Lock()
+    defer v.mu.Unlock()
     if ttl > 0 {
         // SET key value EX seconds
         secs := int(ttl.Seconds())
@@ -91,12 +101,24 @@ func (v *Valkey) Set(key, value string, ttl time.Duration) error {
 // Del deletes keys and returns number of removed keys.
 func (v *Valkey) Del(keys ...string) (int64, error) {
     if len(keys) == 0 { return 0, nil }
+    v.mu.Lock()
+    defer v.mu.Unlock()
     args := append([]string{"DEL"}, keys...)
     if err := v.send(args...); err != nil { return 0, err }
     n, err := v.readInteger()
     return n, err
 }
 
+// Eval runs a Lua script with EVAL and returns its reply: int64 for integers,
+// string for status and bulk strings, nil for a nil bulk and []any for arrays.
+func (v *Valkey) Eval(script string, keys []string, args ...string) (any, error) {
+    v.mu.Lock()
+    defer v.mu.Unlock()
+    cmd := append([]string{"EVAL", script, strconv.Itoa(len(keys))}, keys...)
+    if err := v.send(append(cmd, args...)...); err != nil { return nil, err }
+    return v.readReply()
+}
+
 // send writes a RESP Array of Bulk Strings for the provided args.
 func (v *Valkey) send(args ...string) error {
     if v == nil || v.rw == nil { return errors.New("valkey: closed") }
@@ -177,7 +199,7 @@ func (v *Valkey) readBulk() ([]byte, int, error) {
             return nil, -1, nil
         }
         buf := make([]byte, n+2)
-        if _, err := v.rw.Read(buf); err != nil { return nil, 0, err }
+        if _, err := io.ReadFull(v.rw, buf); err != nil { return nil, 0, err }
         // strip CRLF
         return buf[:n], n, nil
     case '-':
@@ -188,6 +210,40 @@ func (v *Valkey) readBulk() ([]byte, int, error) {
     }
 }
 
+// readReply reads any RESP2 reply (see Eval for the Go types).
+func (v *Valkey) readReply() (any, error) {
+    b, err := v.readByte()
+    if err != nil { return nil, err }
+    if b != '*' {
+        if err := v.rw.UnreadByte(); err != nil { return nil, err }
+    }
+    switch b {
+    case '+':
+        return v.readSimpleString()
+    case ':':
+        return v.readInteger()
+    case '$':
+        body, n, err := v.readBulk()
+        if err != nil || n < 0 { return nil, err }
+        return string(body), nil
+    case '-':
+        _, err := v.readSimpleString()
+        return nil, err
+    case '*':
+        s, err := v.readLine()
+        if err != nil { return nil, err }
+        n, err := strconv.Atoi(s)
+        if err != nil || n < 0 { return nil, err }
+        out := make([]any, n)
+        for i := range out {
+            if out[i], err = v.readReply(); err != nil { return nil, err }
+        }
+        return out, nil
+    default:
+        return nil, fmt.Errorf("valkey: unexpected reply type %q", b)
+    }
+}
+
 func getenv(k, def string) string { if v := os.Getenv(k); v != "" { return v }; return def }
 func firstNonEmpty(a, b string) string { if a != "" { return a }; return b }
 
@@ -0,0 +1,283 @@
+// Package caption compiles post copy into platform-ready captions: it
+// measures text the way each platform does, ranks and caps hashtags,
+// checks mentions and truncates to the limit at sentence boundaries,
+// reporting every change as a Warning.
+package caption
+
+import (
+    "fmt"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "github.com/bitesinbyte/ferret/pkg/external"
+)
+
+// Warning codes.
+const (
+    WarnHashtagInvalid   = "hashtag_invalid"   // explicit hashtag with nothing usable left, or only digits
+    WarnHashtagDuplicate = "hashtag_duplicate" // explicit hashtag repeated or already in the text
+    WarnHashtagCap       = "hashtag_cap"       // more hashtags than the platform allows
+    WarnHashtagsDropped  = "hashtags_dropped"  // hashtags removed to fit the length limit
+    WarnMentionInvalid   = "mention_invalid"   // handle the platform would not link
+    WarnMentionDuplicate = "mention_duplicate"
+    WarnMentionCap       = "mention_cap"
+    WarnTruncated        = "truncated" // body or hook shortened to fit
+    WarnDropped          = "dropped"   // body, mentions or CTA removed to fit
+    WarnOverLimit        = "over_limit"
+)
+
+// Warning reports something Compile changed or could not fix.
+type Warning struct {
+    Code    string `json:"code"`
+    Message string `json:"message"`
+}
+
+// Rules are a platform's caption constraints.
+type Rules struct {
+    Platform      string
+    MaxChars      int            // 0: unlimited
+    Weighted      bool           // X: CJK and emoji count twice
+    URLWeight     int            // every link counts this much; 0 counts its characters
+    LocalMentions bool           // @user@domain counts as @user (Mastodon)
+    MaxHashtags   int            // 0: unlimited
+    MaxMentions   int            // 0: unlimited
+    Handle        *regexp.Regexp // valid handle without '@'; nil accepts any
+    TopicTags     int            // hashtags derived from the topic (default 3)
+}
+
+// RulesFor returns the rules of a registered platform or alias; MaxChars
+// comes from its registry capabilities. MASTODON_MAX_CHARS overrides the
+// Mastodon limit for instances with longer posts. Unknown platforms have
+// no limits.
+func RulesFor(platform string) Rules {
+    r := Rules{Platform: strings.ToLower(strings.TrimSpace(platform)), TopicTags: 3}
+    p, ok := external.LookupPlatform(platform)
+    if !ok { return r }
+    r.Platform, r.MaxChars = p.Name, p.Capabilities.MaxChars
+    switch p.Name {
+    case "twitter":
+        r.Weighted, r.URLWeight, r.TopicTags = true, URLWeight, 2
+        r.Handle = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+    case "instagram":
+        r.MaxHashtags, r.MaxMentions = 30, 20
+        r.Handle = regexp.MustCompile(`^[A-Za-z0-9._]{1,30}$`)
+    case "thread":
+        r.MaxHashtags = 1 // one topic tag per post
+        r.Handle = regexp.MustCompile(`^[A-Za-z0-9._]{1,30}$`)
+    case "mastodon":
+        r.URLWeight, r.LocalMentions = URLWeight, true
+        r.Handle = regexp.MustCompile(`^[A-Za-z0-9_]+(@[A-Za-z0-9.-]+\.[A-Za-z]+)?$`)
+        if n, err := strconv.Atoi(os.Getenv("MASTODON_MAX_CHARS")); err == nil && n > 0 { r.MaxChars = n }
+    }
+    return r
+}
+
+// Length measures s like the platform: grapheme clusters, X weights, fixed
+// URL weight and local-part mentions.
+func (r Rules) Length(s string) int {
+    n := 0
+    if r.URLWeight > 0 {
+        n += len(urlRe.FindAllStringIndex(s, -1)) * r.URLWeight
+        s = urlRe.ReplaceAllString(s, "")
+    }
+    if r.LocalMentions { s = remoteMentionRe.ReplaceAllString(s, "$1") }
+    if r.Weighted { return n + xWeight(s) }
+    return n + GraphemeCount(s)
+}
+
+// Input is the copy of one post.
+type Input struct {
+    Hook     string   // opening line
+    Body     string
+    CTA      string
+    Link     string
+    Topic    string   // source of extra hashtags
+    Hashtags []string // explicit hashtags, best first; '#' optional
+    Mentions []string // handles to tag; '@' optional
+}
+
+// Result is a compiled caption.
+type Result struct {
+    Text     string    `json:"text"`
+    Hashtags string    `json:"hashtags"` // the hashtag line ending Text, "" when none
+    Length   int       `json:"length"`   // Text measured with the platform's rules
+    Limit    int       `json:"limit"`    // 0: unlimited
+    Warnings []Warning `json:"warnings,omitempty"`
+}
+
+// Compile compiles in with RulesFor(platform).
+func Compile(platform string, in Input) Result { return RulesFor(platform).Compile(in) }
+
+// Compile lays the copy out as hook, body, mentions, CTA, link and a
+// hashtag line, separated by blank lines. Explicit hashtags come first,
+// capped at MaxHashtags including those already inline, then topic words
+// ranked by use in the copy while room is left. Over MaxChars it drops hashtags from the
+// end, cuts the body at the last sentence (else word) boundary that fits,
+// then drops mentions and the CTA, and finally shortens the hook.
+func (r Rules) Compile(in Input) Result {
+    c := compiler{rules: r, hook: strings.TrimSpace(in.Hook), body: strings.TrimSpace(in.Body), cta: strings.TrimSpace(in.CTA), link: strings.TrimSpace(in.Link)}
+    c.hashtags(in)
+    c.mentions(in.Mentions)
+    c.fit()
+    text := c.text()
+    return Result{Text: text, Hashtags: strings.Join(c.tags, " "), Length: r.Length(text), Limit: r.MaxChars, Warnings: c.warnings}
+}
+
+type compiler struct {
+    rules                 Rules
+    hook, body, cta, link string
+    mention               []string
+    tags                  []string
+    warnings              []Warning
+}
+
+func (c *compiler) warn(code, format string, args ...any) {
+    c.warnings = append(c.warnings, Warning{Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *compiler) copyText() string { return c.hook + "\n" + c.body + "\n" + c.cta }
+
+func (c *compiler) hashtags(in Input) {
+    seen := map[string]bool{}
+    inline := inlineTags(c.copyText())
+    for _, t := range inline { seen[t] = true }
+    for _, raw := range in.Hashtags {
+        t := normalizeTag(raw)
+        switch {
+        case t == "":
+            c.warn(WarnHashtagInvalid, "dropped hashtag %q: no usable characters or only digits", raw)
+        case seen[strings.ToLower(t)]:
+            c.warn(WarnHashtagDuplicate, "dropped duplicate hashtag %s", t)
+        default:
+            seen[strings.ToLower(t)] = true
+            c.tags = append(c.tags, t)
+        }
+    }
+    if limit := c.rules.MaxHashtags; limit > 0 && len(inline)+len(c.tags) > limit {
+        keep := max(0, limit-len(inline))
+        if keep < len(c.tags) { c.warn(WarnHashtagCap, "%s allows %d hashtags: dropped %s", c.rules.Platform, limit, strings.Join(c.tags[keep:], " ")) }
+        c.tags = c.tags[:keep]
+        if len(inline) > limit { c.warn(WarnHashtagCap, "the copy itself has %d hashtags, over the %s cap of %d", len(inline), c.rules.Platform, limit) }
+    }
+    // Topic tags are suggestions: they only fill what the cap leaves.
+    n := c.rules.TopicTags
+    if limit := c.rules.MaxHashtags; limit > 0 { n = min(n, max(0, limit-len(inline)-len(c.tags))) }
+    var topic []string
+    for _, t := range TopicTags(in.Topic, len(seen)+n) {
+        if len(topic) == n { break }
+        if !seen[t] { topic = append(topic, t) }
+    }
+    c.tags = append(c.tags, rankByUse(topic, c.copyText())...)
+}
+
+func (c *compiler) mentions(handles []string) {
+    seen := map[string]bool{}
+    inline := inlineMentions(c.copyText())
+    for _, h := range inline { seen[h] = true }
+    for _, raw := range handles {
+        h := strings.TrimLeft(strings.TrimSpace(raw), "@")
+        switch {
+        case h == "" || (c.rules.Handle != nil && !c.rules.Handle.MatchString(h)):
+            c.warn(WarnMentionInvalid, "dropped mention %q: not a valid %s handle", raw, c.rules.Platform)
+        case seen[strings.ToLower(h)]:
+            c.warn(WarnMentionDuplicate, "dropped duplicate mention @%s", h)
+        default:
+            seen[strings.ToLower(h)] = true
+            c.mention = append(c.mention, "@"+h)
+        }
+    }
+    if limit := c.rules.MaxMentions; limit > 0 && len(inline)+len(c.mention) > limit {
+        keep := max(0, limit-len(inline))
+        if keep < len(c.mention) { c.warn(WarnMentionCap, "%s allows %d mentions: dropped %s", c.rules.Platform, limit, strings.Join(c.mention[keep:], " ")) }
+        c.mention = c.mention[:keep]
+    }
+}
+
+func (c *compiler) text() string {
+    var parts []string
+    for _, p := range []string{c.hook, c.body, strings.Join(c.mention, " "), c.cta, c.link, strings.Join(c.tags, " ")} {
+        if p != "" { parts = append(parts, p) }
+    }
+    return strings.Join(parts, "\n\n")
+}
+
+func (c *compiler) over() bool { return c.rules.MaxChars > 0 && c.rules.Length(c.text()) > c.rules.MaxChars }
+
+func (c *compiler) fit() {
+    if !c.over() { return }
+    var dropped []string
+    for len(c.tags) > 0 && c.over() {
+        dropped = append([]string{c.tags[len(c.tags)-1]}, dropped...)
+        c.tags = c.tags[:len(c.tags)-1]
+    }
+    if len(dropped) > 0 { c.warn(WarnHashtagsDropped, "dropped %s to fit %d characters", strings.Join(dropped, " "), c.rules.MaxChars) }
+    c.shorten(&c.body, "body", true)
+    if len(c.mention) > 0 && c.over() {
+        c.warn(WarnDropped, "dropped mentions %s to fit %d characters", strings.Join(c.mention, " "), c.rules.MaxChars)
+        c.mention = nil
+    }
+    if c.cta != "" && c.over() {
+        c.warn(WarnDropped, "dropped the call to action to fit %d characters", c.rules.MaxChars)
+        c.cta = ""
+    }
+    c.shorten(&c.hook, "hook", false)
+    if c.over() { c.warn(WarnOverLimit, "caption is %d characters, over the %s limit of %d", c.rules.Length(c.text()), c.rules.Platform, c.rules.MaxChars) }
+}
+
+// shorten cuts *s to the longest candidate that fits: whole sentences
+// first when sentences is set, then words with an ellipsis. A part with no
+// fitting candidate is dropped (the hook keeps its first word).
+func (c *compiler) shorten(s *string, name string, sentences bool) {
+    if *s == "" || !c.over() { return }
+    orig := *s
+    var cands []string
+    if sentences { cands = sentencePrefixes(orig) }
+    cands = append(cands, wordPrefixes(orig)...)
+    for _, cand := range cands {
+        *s = cand
+        if !c.over() {
+            c.warn(WarnTruncated, "shortened the %s from %d to %d characters to fit %d", name, c.rules.Length(orig), c.rules.Length(cand), c.rules.MaxChars)
+            return
+        }
+    }
+    if name == "hook" && len(cands) > 0 {
+        *s = cands[len(cands)-1]
+        c.warn(WarnTruncated, "shortened the %s to %q", name, *s)
+        return
+    }
+    *s = ""
+    c.warn(WarnDropped, "dropped the %s to fit %d characters", name, c.rules.MaxChars)
+}
+
+// sentencePrefixes returns s cut after each sentence end or line break,
+// longest first, excluding s itself.
+func sentencePrefixes(s string) []string {
+    var out []string
+    gs := Graphemes(s)
+    pos := len(s)
+    for i := len(gs) - 1; i > 0; i-- {
+        pos -= len(gs[i])
+        g, next := gs[i-1], gs[i]
+        if (strings.ContainsAny(g, ".!?…") && isSpace(next)) || g == "\n" {
+            if p := strings.TrimSpace(s[:pos]); p != "" { out = append(out, p) }
+        }
+    }
+    return out
+}
+
+// wordPrefixes returns s cut before each whitespace run with an
+// ellipsis, longest first. URLs and tags are never split since they hold
+// no spaces.
+func wordPrefixes(s string) []string {
+    var out []string
+    s = strings.TrimSpace(s)
+    for i := len(s) - 1; i > 0; i-- {
+        if !isSpace(s[i:i+1]) || isSpace(s[i-1:i]) { continue }
+        if p := strings.TrimRight(s[:i], ",;:-–—"); p != "" { out = append(out, p+"…") }
+    }
+    return out
+}
+
+func isSpace(g string) bool { return strings.TrimSpace(g) == "" }
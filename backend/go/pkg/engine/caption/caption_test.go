@@ -0,0 +1,97 @@
+package caption
+
+import (
+    "fmt"
+    "strings"
+    "testing"
+    "unicode/utf8"
+)
+
+func codes(ws []Warning) string {
+    var out []string
+    for _, w := range ws { out = append(out, w.Code) }
+    return strings.Join(out, ",")
+}
+
+func TestGraphemes(t *testing.T) {
+    for s, want := range map[string]int{
+        "abc":            3,
+        "e\u0301te":      3, // e + combining acute
+        "\U0001F44D\U0001F3FDok": 3, // skin tone modifier
+        "\U0001F469\u200d\U0001F469\u200d\U0001F467": 1, // ZWJ family
+        "\U0001F1E9\U0001F1EA\U0001F1EB\U0001F1F7": 2, // two flags
+        "\u2764\ufe0f":   1, // heart with VS16
+        "a\r\nb":         3,
+    } {
+        if got := GraphemeCount(s); got != want { t.Errorf("GraphemeCount(%q) = %d, want %d", s, got, want) }
+    }
+}
+
+func TestLengthWeights(t *testing.T) {
+    x := RulesFor("x")
+    if x.Platform != "twitter" || x.MaxChars != 280 { t.Fatalf("rules = %+v", x) }
+    // URLs count 23 whatever their length; CJK and emoji count twice.
+    if n := x.Length("see https://example.com/a/very/long/path?with=query 日本 \U0001F44D\U0001F3FD"); n != len("see ")+23+1+4+1+2 { t.Errorf("x length = %d", n) }
+    if n := RulesFor("linkedin").Length("日本 \U0001F44D\U0001F3FD https://example.com"); n != 5+19 { t.Errorf("linkedin length = %d", n) }
+
+    t.Setenv("MASTODON_MAX_CHARS", "5000")
+    m := RulesFor("masto")
+    if m.MaxChars != 5000 { t.Fatalf("mastodon limit = %d", m.MaxChars) }
+    if n := m.Length("hi @alice@example.social https://example.com/x"); n != len("hi @alice ")+23 { t.Errorf("mastodon length = %d", n) }
+}
+
+func TestCompileLayoutAndHashtags(t *testing.T) {
+    r := Compile("linkedin", Input{
+        Hook: "Marketing with AI", Body: "Most teams use AI for drafts. #AI is only the start.", CTA: "Follow for more",
+        Topic: "the best AI marketing tools", Hashtags: []string{"growth hacking", "#ai", "2024", "#growthhacking"},
+    })
+    // #ai is already inline, "the" is a stopword and topic words are ranked
+    // by use in the copy.
+    want := "Marketing with AI\n\nMost teams use AI for drafts. #AI is only the start.\n\nFollow for more\n\n#GrowthHacking #marketing #best #tools"
+    if r.Text != want { t.Fatalf("text =\n%s\nwant\n%s", r.Text, want) }
+    if r.Hashtags != "#GrowthHacking #marketing #best #tools" || !strings.HasSuffix(r.Text, r.Hashtags) { t.Errorf("hashtags = %q", r.Hashtags) }
+    if got := codes(r.Warnings); got != "hashtag_duplicate,hashtag_invalid,hashtag_duplicate" { t.Errorf("warnings = %s: %+v", got, r.Warnings) }
+    if r.Limit != 3000 || r.Length != GraphemeCount(r.Text) { t.Errorf("length %d / %d", r.Length, r.Limit) }
+
+    if got := strings.Join(TopicTags("How to use AI for your marketing in 2025", 3), " "); got != "#use #ai #marketing" { t.Errorf("topic tags = %q", got) }
+}
+
+func TestCompileHashtagCaps(t *testing.T) {
+    var tags []string
+    for i := 0; i < 40; i++ { tags = append(tags, fmt.Sprintf("tag%d", i)) }
+    r := Compile("instagram", Input{Hook: "Hello #one #two", Hashtags: tags})
+    if n := len(strings.Fields(r.Hashtags)); n != 28 { t.Fatalf("kept %d hashtags", n) }
+    if codes(r.Warnings) != WarnHashtagCap { t.Errorf("warnings = %+v", r.Warnings) }
+
+    r = Compile("threads", Input{Hook: "Hello", Topic: "ai marketing"})
+    if r.Hashtags != "#ai" || len(r.Warnings) != 0 { t.Errorf("threads = %q %+v", r.Hashtags, r.Warnings) }
+}
+
+func TestCompileMentions(t *testing.T) {
+    r := Compile("twitter", Input{Hook: "Thanks @bob", Mentions: []string{"@alice", "Bob", "not valid!", "alice", "averyveryverylonghandle"}})
+    if r.Text != "Thanks @bob\n\n@alice" { t.Fatalf("text = %q", r.Text) }
+    if got := codes(r.Warnings); got != "mention_duplicate,mention_invalid,mention_duplicate,mention_invalid" { t.Errorf("warnings = %s", got) }
+
+    r = Compile("mastodon", Input{Hook: "Hi", Mentions: []string{"alice@example.social"}})
+    if r.Text != "Hi\n\n@alice@example.social" || len(r.Warnings) != 0 { t.Errorf("mastodon = %q %+v", r.Text, r.Warnings) }
+}
+
+func TestCompileTruncation(t *testing.T) {
+    body := strings.Repeat("Short sentence here. ", 20) + "Final words that never fit."
+    r := Compile("twitter", Input{Hook: "Big news", Body: body, CTA: "Read more", Link: "https://example.com/" + strings.Repeat("x", 100), Topic: "launch day"})
+    if r.Length > 280 { t.Fatalf("length %d over the limit:\n%s", r.Length, r.Text) }
+    if !strings.Contains(r.Text, "here.\n\nRead more\n\nhttps://example.com/") { t.Errorf("body not cut at a sentence boundary:\n%s", r.Text) }
+    if got := codes(r.Warnings); got != "hashtags_dropped,truncated" { t.Errorf("warnings = %s: %+v", got, r.Warnings) }
+
+    // Multi-byte runes are never split and a word cut gets an ellipsis.
+    long := strings.Repeat("日本語のテキスト ", 40)
+    r = Compile("twitter", Input{Hook: "Heads up", Body: long})
+    if !utf8.ValidString(r.Text) || r.Length > 280 || !strings.HasSuffix(r.Text, "…") { t.Fatalf("text (%d) = %q", r.Length, r.Text) }
+
+    // Nothing but a hook that is too long.
+    r = Compile("twitter", Input{Hook: strings.Repeat("word ", 100), CTA: "cta"})
+    if r.Length > 280 || codes(r.Warnings) != "dropped,truncated" { t.Fatalf("hook only: %d %+v", r.Length, r.Warnings) }
+
+    // Unknown platforms have no limit.
+    if r := Compile("myspace", Input{Body: long}); len(r.Warnings) != 0 || r.Limit != 0 { t.Fatalf("unknown platform = %+v", r) }
+}
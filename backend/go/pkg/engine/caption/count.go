@@ -0,0 +1,93 @@
+package caption
+
+import (
+    "regexp"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// URLWeight is the length X and Mastodon count for any link (t.co wrapping).
+const URLWeight = 23
+
+var (
+    urlRe           = regexp.MustCompile(`https?://[^\s]+[^\s.,;:!?)\]'"]`)
+    remoteMentionRe = regexp.MustCompile(`(@[\p{L}\p{N}_]+)@[\p{L}\p{N}.-]+\.[\p{L}]+`)
+)
+
+// Graphemes splits s into user-perceived characters: a base rune with its
+// combining marks, variation selectors, emoji modifiers and tags, ZWJ emoji
+// sequences, regional-indicator flag pairs and CRLF. It covers the cases
+// that matter for captions, not all of UAX #29.
+func Graphemes(s string) []string {
+    var out []string
+    start, prevZWJ, riOpen := 0, false, false
+    for i, r := range s {
+        if i > start && !extends(s[start:i], r, prevZWJ, riOpen) {
+            out = append(out, s[start:i])
+            start, riOpen = i, false
+        }
+        if isRegional(r) { riOpen = !riOpen } else { riOpen = false }
+        prevZWJ = r == '\u200d'
+    }
+    if start < len(s) { out = append(out, s[start:]) }
+    return out
+}
+
+// GraphemeCount is len(Graphemes(s)).
+func GraphemeCount(s string) int { return len(Graphemes(s)) }
+
+func extends(cluster string, r rune, prevZWJ, riOpen bool) bool {
+    switch {
+    case prevZWJ:
+        return true
+    case r == '\n' && strings.HasSuffix(cluster, "\r"):
+        return true
+    case r == '\u200d', unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+        return true
+    case r >= 0xfe00 && r <= 0xfe0f, r >= 0xe0100 && r <= 0xe01ef: // variation selectors
+        return true
+    case r >= 0x1f3fb && r <= 0x1f3ff: // skin tone modifiers
+        return true
+    case r >= 0xe0020 && r <= 0xe007f: // emoji tag sequences (subdivision flags)
+        return true
+    case isRegional(r) && riOpen:
+        return true
+    }
+    return false
+}
+
+func isRegional(r rune) bool { return r >= 0x1f1e6 && r <= 0x1f1ff }
+
+// isEmoji reports whether a grapheme is an emoji presentation.
+func isEmoji(g string) bool {
+    r, _ := utf8.DecodeRuneInString(g)
+    switch {
+    case r >= 0x1f000 && r <= 0x1faff, isRegional(r):
+        return true
+    case r >= 0x2600 && r <= 0x27bf, r >= 0x2b00 && r <= 0x2bff:
+        return strings.ContainsRune(g, '\ufe0f') || utf8.RuneCountInString(g) == 1
+    }
+    return false
+}
+
+// xLight reports whether X counts r as one; everything else (CJK, most
+// non-Latin scripts, emoji) counts as two (twitter-text v3 weights).
+func xLight(r rune) bool {
+    return r <= 4351 || (r >= 8192 && r <= 8205) || (r >= 8208 && r <= 8223) || (r >= 8242 && r <= 8247)
+}
+
+// xWeight is the X weighted length of text without URLs.
+func xWeight(s string) int {
+    n := 0
+    for _, g := range Graphemes(s) {
+        if isEmoji(g) {
+            n += 2
+            continue
+        }
+        for _, r := range g {
+            if xLight(r) { n++ } else { n += 2 }
+        }
+    }
+    return n
+}
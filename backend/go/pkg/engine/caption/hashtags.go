@@ -0,0 +1,90 @@
+package caption
+
+import (
+    "regexp"
+    "sort"
+    "strings"
+    "unicode"
+)
+
+var (
+    nonWordRe = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+    hashtagRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/])#([\p{L}\p{N}_]*[\p{L}_][\p{L}\p{N}_]*)`)
+    mentionRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_])@([\p{L}\p{N}_.]+(?:@[\p{L}\p{N}.-]+\.[\p{L}]+)?)`)
+)
+
+// stopwords never become hashtags.
+var stopwords = map[string]bool{}
+
+func init() {
+    for _, w := range strings.Fields(`a about after all an and any are as at be been before but by can did do does for from
+        get got had has have how i if in into is it its just me more most my new no not now of on or our out over so some
+        than that the their them then there these they this to too up us vs was we what when where which who why will
+        with you your`) {
+        stopwords[w] = true
+    }
+}
+
+// TopicTags returns up to n hashtags (with '#') from the words of topic,
+// skipping stopwords, numbers and duplicates.
+func TopicTags(topic string, n int) []string {
+    var out []string
+    seen := map[string]bool{}
+    for _, w := range strings.Fields(nonWordRe.ReplaceAllString(strings.ToLower(topic), " ")) {
+        if len(out) >= n { break }
+        if stopwords[w] || seen[w] || len([]rune(w)) < 2 || isDigits(w) { continue }
+        seen[w] = true
+        out = append(out, "#"+w)
+    }
+    return out
+}
+
+// rankByUse orders tags by how often their word appears in text, keeping
+// the original order between ties.
+func rankByUse(tags []string, text string) []string {
+    words := map[string]int{}
+    for _, w := range strings.Fields(nonWordRe.ReplaceAllString(strings.ToLower(text), " ")) { words[w]++ }
+    out := append([]string(nil), tags...)
+    sort.SliceStable(out, func(i, j int) bool { return words[strings.ToLower(out[i][1:])] > words[strings.ToLower(out[j][1:])] })
+    return out
+}
+
+// normalizeTag turns "#AI marketing!" into "#AiMarketing"; it returns ""
+// when nothing usable is left or the tag is only digits.
+func normalizeTag(t string) string {
+    words := strings.Fields(nonWordRe.ReplaceAllStringFunc(strings.TrimLeft(strings.TrimSpace(t), "#"), func(s string) string {
+        if s == "_" { return s }
+        return " "
+    }))
+    if len(words) > 1 {
+        for i, w := range words {
+            r := []rune(w)
+            r[0] = unicode.ToUpper(r[0])
+            words[i] = string(r)
+        }
+    }
+    tag := strings.Join(words, "")
+    if tag == "" || isDigits(tag) { return "" }
+    return "#" + tag
+}
+
+// inlineTags lists the hashtags already written in text, lower-cased.
+func inlineTags(text string) []string {
+    var out []string
+    for _, m := range hashtagRe.FindAllStringSubmatch(text, -1) { out = append(out, "#"+strings.ToLower(m[1])) }
+    return out
+}
+
+// inlineMentions lists the handles already written in text, lower-cased.
+func inlineMentions(text string) []string {
+    var out []string
+    for _, m := range mentionRe.FindAllStringSubmatch(text, -1) { out = append(out, strings.ToLower(strings.TrimRight(m[1], "."))) }
+    return out
+}
+
+func isDigits(s string) bool {
+    for _, r := range s {
+        if !unicode.IsDigit(r) { return false }
+    }
+    return true
+}
@@ -0,0 +1,163 @@
+// Package cron parses standard five-field cron expressions and computes when
+// they next fire. Workflow cron triggers and periodic jobs use it.
+package cron
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Schedule reports the first firing strictly after t; the zero time means
+// it never fires again.
+type Schedule interface {
+    Next(t time.Time) time.Time
+}
+
+// Spec is a parsed "minute hour day-of-month month day-of-week" expression.
+type Spec struct {
+    minute, hour, dom, month, dow uint64
+    domAny, dowAny                bool
+    Location                      *time.Location
+}
+
+// Every fires at a fixed interval from the time passed to Next.
+type Every time.Duration
+
+func (e Every) Next(t time.Time) time.Time { return t.Add(time.Duration(e)) }
+
+var macros = map[string]string{
+    "@yearly":   "0 0 1 1 *",
+    "@annually": "0 0 1 1 *",
+    "@monthly":  "0 0 1 * *",
+    "@weekly":   "0 0 * * 0",
+    "@daily":    "0 0 * * *",
+    "@midnight": "0 0 * * *",
+    "@hourly":   "0 * * * *",
+}
+
+type field struct {
+    name     string
+    min, max int
+    names    map[string]int
+}
+
+var fields = []field{
+    {name: "minute", max: 59},
+    {name: "hour", max: 23},
+    {name: "day of month", min: 1, max: 31},
+    {name: "month", min: 1, max: 12, names: map[string]int{
+        "jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}},
+    {name: "day of week", max: 7, names: map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}},
+}
+
+// Parse parses expr in UTC. It accepts five fields with *, lists, ranges,
+// steps and month/weekday names (day of week 0 and 7 are Sunday), the
+// macros @hourly, @daily (@midnight), @weekly, @monthly and @yearly
+// (@annually), "@every <duration>", and a leading CRON_TZ=<zone>.
+func Parse(expr string) (Schedule, error) { return ParseIn(expr, time.UTC) }
+
+// ParseIn is Parse with fields evaluated in loc unless expr sets CRON_TZ.
+func ParseIn(expr string, loc *time.Location) (Schedule, error) {
+    s := strings.TrimSpace(expr)
+    if rest, ok := strings.CutPrefix(s, "CRON_TZ="); ok {
+        zone, tail, _ := strings.Cut(rest, " ")
+        l, err := time.LoadLocation(zone)
+        if err != nil { return nil, fmt.Errorf("cron %q: %w", expr, err) }
+        loc, s = l, strings.TrimSpace(tail)
+    }
+    if rest, ok := strings.CutPrefix(s, "@every "); ok {
+        d, err := time.ParseDuration(strings.TrimSpace(rest))
+        if err != nil || d <= 0 { return nil, fmt.Errorf("cron %q: @every needs a positive duration", expr) }
+        return Every(d), nil
+    }
+    if m, ok := macros[strings.ToLower(s)]; ok { s = m }
+    parts := strings.Fields(s)
+    if len(parts) != len(fields) { return nil, fmt.Errorf("cron %q: want 5 fields (minute hour day month weekday), got %d", expr, len(parts)) }
+    var bits [5]uint64
+    for i, p := range parts {
+        b, err := parseField(p, fields[i])
+        if err != nil { return nil, fmt.Errorf("cron %q: %s: %w", expr, fields[i].name, err) }
+        bits[i] = b
+    }
+    if bits[4]&(1<<7) != 0 { bits[4] |= 1 }
+    if loc == nil { loc = time.UTC }
+    return &Spec{
+        minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4],
+        domAny: parts[2] == "*" || parts[2] == "?", dowAny: parts[4] == "*" || parts[4] == "?",
+        Location: loc,
+    }, nil
+}
+
+func parseField(s string, f field) (uint64, error) {
+    var bits uint64
+    for _, item := range strings.Split(s, ",") {
+        rng, stepStr, hasStep := strings.Cut(item, "/")
+        step := 1
+        if hasStep {
+            n, err := strconv.Atoi(stepStr)
+            if err != nil || n <= 0 { return 0, fmt.Errorf("bad step %q", stepStr) }
+            step = n
+        }
+        lo, hi := f.min, f.max
+        switch {
+        case rng == "*" || rng == "?":
+        case strings.Contains(rng, "-"):
+            a, b, _ := strings.Cut(rng, "-")
+            var err error
+            if lo, err = f.value(a); err != nil { return 0, err }
+            if hi, err = f.value(b); err != nil { return 0, err }
+            if lo > hi { return 0, fmt.Errorf("range %q runs backwards", rng) }
+        default:
+            v, err := f.value(rng)
+            if err != nil { return 0, err }
+            lo = v
+            if !hasStep { hi = v }
+        }
+        for v := lo; v <= hi; v += step { bits |= 1 << v }
+    }
+    return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+    if v, ok := f.names[strings.ToLower(s)]; ok { return v, nil }
+    v, err := strconv.Atoi(s)
+    if err != nil { return 0, fmt.Errorf("bad value %q", s) }
+    if v < f.min || v > f.max { return 0, fmt.Errorf("%d out of range %d-%d", v, f.min, f.max) }
+    return v, nil
+}
+
+// Next returns the first matching minute after t, in t's location. When
+// both day fields are restricted a day matching either one fires, as in
+// Vixie cron. Times skipped by a DST change do not fire.
+func (s *Spec) Next(t time.Time) time.Time {
+    orig := t.Location()
+    t = t.In(s.Location).Truncate(time.Minute).Add(time.Minute)
+    limit := t.Year() + 5
+    for t.Year() <= limit {
+        y, mo, d := t.Date()
+        switch {
+        case s.month&(1<<uint(mo)) == 0:
+            t = time.Date(y, mo+1, 1, 0, 0, 0, 0, s.Location)
+        case !s.dayMatches(t):
+            t = time.Date(y, mo, d+1, 0, 0, 0, 0, s.Location)
+        // Hours and minutes advance in absolute time so a repeated DST hour
+        // never moves t backwards.
+        case s.hour&(1<<uint(t.Hour())) == 0:
+            t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+        case s.minute&(1<<uint(t.Minute())) == 0:
+            t = t.Add(time.Minute)
+        default:
+            return t.In(orig)
+        }
+    }
+    return time.Time{}
+}
+
+func (s *Spec) dayMatches(t time.Time) bool {
+    dom := s.dom&(1<<uint(t.Day())) != 0
+    dow := s.dow&(1<<uint(t.Weekday())) != 0
+    if s.domAny || s.dowAny { return dom && dow }
+    return dom || dow
+}
@@ -0,0 +1,54 @@
+package cron
+
+import (
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestNext(t *testing.T) {
+    from := time.Date(2026, 10, 16, 9, 41, 30, 0, time.UTC) // a Friday
+    for _, tc := range []struct{ expr, want string }{
+        {"* * * * *", "2026-10-16T09:42:00Z"},
+        {"*/15 * * * *", "2026-10-16T09:45:00Z"},
+        {"0 2 * * *", "2026-10-17T02:00:00Z"},
+        {"30 9-17/4 * * mon-fri", "2026-10-16T13:30:00Z"},
+        {"0 0 * * 0", "2026-10-18T00:00:00Z"},
+        {"0 0 * * 7", "2026-10-18T00:00:00Z"},
+        {"0 12 1 jan,jul *", "2027-01-01T12:00:00Z"},
+        {"0 0 13 * fri", "2026-10-23T00:00:00Z"}, // day 13 or any Friday
+        {"0 0 29 feb *", "2028-02-29T00:00:00Z"},
+        {"@hourly", "2026-10-16T10:00:00Z"},
+        {"@weekly", "2026-10-18T00:00:00Z"},
+        {"CRON_TZ=Europe/Berlin 0 8 * * *", "2026-10-17T06:00:00Z"},
+    } {
+        s, err := Parse(tc.expr)
+        if err != nil { t.Fatalf("%s: %v", tc.expr, err) }
+        if got := s.Next(from).Format(time.RFC3339); got != tc.want { t.Errorf("%s: next = %s, want %s", tc.expr, got, tc.want) }
+    }
+    if s, _ := Parse("@every 90s"); s.Next(from) != from.Add(90*time.Second) { t.Error("@every did not add its interval") }
+}
+
+func TestNextAcrossDST(t *testing.T) {
+    ny, err := time.LoadLocation("America/New_York")
+    if err != nil { t.Skip(err) }
+    s, err := ParseIn("30 2 * * *", ny)
+    if err != nil { t.Fatal(err) }
+    // 2:30 does not exist on 2027-03-14; the next firing is the following day.
+    got := s.Next(time.Date(2027, 3, 13, 3, 0, 0, 0, ny))
+    if want := time.Date(2027, 3, 15, 2, 30, 0, 0, ny); !got.Equal(want) { t.Fatalf("next = %s, want %s", got, want) }
+
+    hourly, _ := ParseIn("0 * * * *", ny)
+    at := time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC) // 01:30 EDT, before the clocks go back
+    for i := 0; i < 3; i++ {
+        next := hourly.Next(at)
+        if next.Sub(at) <= 0 || next.Sub(at) > time.Hour { t.Fatalf("step %d: %s -> %s", i, at, next) }
+        at = next
+    }
+}
+
+func TestParseErrors(t *testing.T) {
+    for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "@every -1m", "@often", "CRON_TZ=Nowhere/City * * * * *"} {
+        if _, err := Parse(expr); err == nil || !strings.HasPrefix(err.Error(), "cron ") { t.Errorf("%q: err = %v", expr, err) }
+    }
+}
@@ -0,0 +1,86 @@
+package eowr
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "time"
+)
+
+// Node states recorded by a Checkpointer.
+const (
+    NodeRunning   = "running"
+    NodeSucceeded = "succeeded"
+    NodeFailed    = "failed"
+    NodeSkipped   = "skipped"
+)
+
+// NodeState is the durable record of one node in one run.
+type NodeState struct {
+    Node       string
+    Status     string
+    Input      Payload
+    Output     Payload
+    Error      string
+    Attempts   int // tries across every invocation of the run
+    StartedAt  time.Time
+    FinishedAt time.Time
+}
+
+// Checkpointer persists node states as a run progresses so it can be resumed
+// after a crash (see workflows.RunStore).
+type Checkpointer interface {
+    SaveNode(ctx context.Context, runID string, s NodeState) error
+}
+
+// RetryPolicy bounds how often a failing node is tried within one invocation.
+// Nodes override it with `with retries=N, retry_backoff="5s"`.
+type RetryPolicy struct {
+    Attempts   int           // total tries; 0 or 1 means no retry
+    Backoff    time.Duration // before the second try, doubled after each failure (default 1s)
+    MaxBackoff time.Duration // cap on the doubled backoff (default 1m)
+}
+
+func (p RetryPolicy) wait(failures int) time.Duration {
+    b, max := p.Backoff, p.MaxBackoff
+    if b <= 0 { b = time.Second }
+    if max <= 0 { max = time.Minute }
+    for i := 1; i < failures && b < max; i++ { b *= 2 }
+    if b > max { b = max }
+    return b
+}
+
+// nodeRetry reads the retry parameters of a node declaration.
+func nodeRetry(params map[string]string) (*RetryPolicy, error) {
+    retries, hasRetries := params["retries"]
+    backoff, hasBackoff := params["retry_backoff"]
+    if !hasRetries && !hasBackoff { return nil, nil }
+    var p RetryPolicy
+    if hasRetries {
+        n, err := strconv.Atoi(retries)
+        if err != nil || n < 0 { return nil, fmt.Errorf("retries=%q: want a non-negative integer", retries) }
+        p.Attempts = n + 1
+    }
+    if hasBackoff {
+        d, err := time.ParseDuration(backoff)
+        if err != nil || d < 0 { return nil, fmt.Errorf("retry_backoff=%q: want a duration such as 5s", backoff) }
+        p.Backoff = d
+    }
+    return &p, nil
+}
+
+// Options configure Execute.
+type Options struct {
+    Trigger string  // trigger to fire; empty fires every trigger
+    Input   Payload // trigger payload
+
+    // RunID and Checkpoint make the run durable: every node start, success,
+    // failure and skip is saved under RunID.
+    RunID      string
+    Checkpoint Checkpointer
+    // Previous holds node states of an earlier invocation of the same run.
+    // Nodes that succeeded are not run again; their saved output is reused.
+    Previous map[string]NodeState
+
+    Retry RetryPolicy // default for nodes without retry parameters
+}
@@ -0,0 +1,115 @@
+package eowr
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+type memCheckpoints struct {
+    mu    sync.Mutex
+    nodes map[string]NodeState
+}
+
+func (m *memCheckpoints) SaveNode(_ context.Context, runID string, s NodeState) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if m.nodes == nil { m.nodes = map[string]NodeState{} }
+    m.nodes[s.Node] = s
+    return nil
+}
+
+func TestExecuteResumesFromCheckpoints(t *testing.T) {
+    var extractCalls, loadCalls int32
+    failLoad := true
+    reg := NewRegistry()
+    reg.Register("test/extract", func(map[string]string) (Node, error) {
+        return NodeFunc(func(_ context.Context, in Payload) (Payload, error) {
+            atomic.AddInt32(&extractCalls, 1)
+            out := in.Clone()
+            out["rows"] = 3.0
+            return out, nil
+        }), nil
+    })
+    reg.Register("test/load", func(map[string]string) (Node, error) {
+        return NodeFunc(func(_ context.Context, in Payload) (Payload, error) {
+            atomic.AddInt32(&loadCalls, 1)
+            if failLoad { return nil, errors.New("warehouse down") }
+            return in, nil
+        }), nil
+    })
+    p := writePseudo(t, t.TempDir(), "etl.pseudo", `workflow "ETL" version 1:
+  trigger "Cron"
+  action "Extract" using "test/extract"
+  action "Load" using "test/load"
+  connect "Cron" -> "Extract"
+  connect "Extract" -> "Load"
+`)
+    g, err := Load(p, reg)
+    if err != nil { t.Fatal(err) }
+
+    cp := &memCheckpoints{}
+    opts := Options{Input: Payload{"day": "2026-10-16"}, RunID: "wr_1", Checkpoint: cp}
+    if _, err := g.Execute(context.Background(), opts); err == nil { t.Fatal("first invocation should fail") }
+    if st := cp.nodes["Load"]; st.Status != NodeFailed || st.Attempts != 1 || st.Error != "warehouse down" {
+        t.Fatalf("Load checkpoint = %+v", st)
+    }
+    if st := cp.nodes["Extract"]; st.Status != NodeSucceeded || st.Output["rows"] != 3.0 { t.Fatalf("Extract checkpoint = %+v", st) }
+
+    failLoad = false
+    opts.Previous = map[string]NodeState{}
+    for k, v := range cp.nodes { opts.Previous[k] = v }
+    res, err := g.Execute(context.Background(), opts)
+    if err != nil { t.Fatal(err) }
+    if extractCalls != 1 { t.Fatalf("Extract ran %d times; completed nodes must not rerun", extractCalls) }
+    if res.Output["rows"] != 3.0 || res.Output["day"] != "2026-10-16" { t.Fatalf("output = %v", res.Output) }
+    if st := cp.nodes["Load"]; st.Status != NodeSucceeded || st.Attempts != 2 { t.Fatalf("Load checkpoint = %+v", st) }
+}
+
+func TestExecuteRetriesPerNodePolicy(t *testing.T) {
+    var calls int32
+    reg := NewRegistry()
+    reg.Register("test/flaky", func(map[string]string) (Node, error) {
+        return NodeFunc(func(_ context.Context, in Payload) (Payload, error) {
+            if atomic.AddInt32(&calls, 1) < 3 { return nil, errors.New("flaky") }
+            return in, nil
+        }), nil
+    })
+    p := writePseudo(t, t.TempDir(), "wf.pseudo", `workflow "Retry" version 1:
+  trigger "T"
+  action "Flaky" using "test/flaky" with retries=2, retry_backoff="1ms"
+  connect "T" -> "Flaky"
+`)
+    g, err := Load(p, reg)
+    if err != nil { t.Fatal(err) }
+    cp := &memCheckpoints{}
+    start := time.Now()
+    if _, err := g.Execute(context.Background(), Options{RunID: "wr_2", Checkpoint: cp}); err != nil { t.Fatal(err) }
+    if calls != 3 || cp.nodes["Flaky"].Attempts != 3 { t.Fatalf("calls = %d, checkpoint %+v", calls, cp.nodes["Flaky"]) }
+    if time.Since(start) > time.Second { t.Fatal("retry_backoff was not applied") }
+
+    bad := writePseudo(t, t.TempDir(), "bad.pseudo", `workflow "Retry" version 1:
+  trigger "T"
+  action "Flaky" using "test/flaky" with retries=lots
+  connect "T" -> "Flaky"
+`)
+    if _, err := Load(bad, reg); err == nil { t.Fatal("expected a diagnostic for retries=lots") }
+}
+
+func TestExecuteRecoversPanics(t *testing.T) {
+    reg := NewRegistry()
+    reg.Register("test/panic", func(map[string]string) (Node, error) {
+        return NodeFunc(func(context.Context, Payload) (Payload, error) { panic("nil map") }), nil
+    })
+    p := writePseudo(t, t.TempDir(), "wf.pseudo", `workflow "P" version 1:
+  trigger "T"
+  action "Boom" using "test/panic"
+  connect "T" -> "Boom"
+`)
+    g, err := Load(p, reg)
+    if err != nil { t.Fatal(err) }
+    if _, err := g.Run(context.Background(), "", nil); err == nil { t.Fatal("expected the panic as an error") }
+}
@@ -0,0 +1,178 @@
+// Package eowr executes EOWR .pseudo workflows in process. A workflow becomes
+// a DAG of nodes whose `using` paths resolve to Node implementations in a
+// Registry; payloads flow along `connect` edges and independent branches run
+// concurrently.
+package eowr
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+
+    gen "github.com/bitesinbyte/ferret/pkg/engine/generator"
+)
+
+// Node kinds understood by the runtime.
+const (
+    KindTrigger = "trigger"
+    KindAction  = "action"
+    KindSwitch  = "switch"
+    KindMerge   = "merge"
+    KindSubflow = "subflow"
+    KindOnError = "on_error"
+)
+
+// Graph is a validated workflow ready to run. It is immutable and may be run
+// concurrently.
+type Graph struct {
+    Name    string
+    Version string
+    nodes   map[string]*node
+    order   []string // declaration order, for stable results
+}
+
+type node struct {
+    name, kind, using, at string
+    pos                   gen.Pos
+    params                map[string]string
+    retry                 *RetryPolicy // from `with retries=.., retry_backoff=..`
+    impl                  Node
+    in, out               []*edge
+}
+
+// edge is a connect arrow. Edges into on_error nodes only carry failures of
+// their source; all other edges only carry successes. Edges leaving a switch
+// may carry a condition.
+type edge struct {
+    from, to  *node
+    when      gen.Cond
+    otherwise bool
+}
+
+func (e *edge) onError() bool { return e.to.kind == KindOnError }
+
+// Load parses, checks and builds the workflow at path. Subflow paths are
+// resolved relative to the file. Problems are returned as gen.Diagnostics
+// with file:line:col positions.
+func Load(path string, reg *Registry) (*Graph, error) {
+    return load(path, reg, map[string]bool{})
+}
+
+func load(path string, reg *Registry, loading map[string]bool) (*Graph, error) {
+    abs, err := filepath.Abs(path)
+    if err != nil { return nil, err }
+    if loading[abs] { return nil, fmt.Errorf("eowr: subflow cycle through %s", path) }
+    src, err := os.ReadFile(path)
+    if err != nil { return nil, fmt.Errorf("eowr: %w", err) }
+    loading[abs] = true
+    defer delete(loading, abs)
+    f, diags := gen.ParsePseudoSource(path, string(src))
+    g, err := build(f, reg, func(sub string) (*Graph, error) {
+        return load(subflowPath(filepath.Dir(path), sub), reg, loading)
+    })
+    if len(diags) > 0 {
+        var more gen.Diagnostics
+        errors.As(err, &more)
+        return nil, sorted(append(diags, more...))
+    }
+    return g, err
+}
+
+// subflowPath resolves a subflow reference: a path relative to dir, with
+// ".pseudo" implied, or a name such as "workflows/crm_sync" found next to the
+// parent file.
+func subflowPath(dir, ref string) string {
+    if filepath.Ext(ref) == "" { ref += ".pseudo" }
+    if filepath.IsAbs(ref) { return ref }
+    p := filepath.Join(dir, ref)
+    if _, err := os.Stat(p); err != nil {
+        if alt := filepath.Join(dir, filepath.Base(ref)); alt != p {
+            if _, err := os.Stat(alt); err == nil { return alt }
+        }
+    }
+    return p
+}
+
+// Build checks f and resolves every `using` against reg (DefaultRegistry when
+// nil). Subflow nodes are not supported here (use Load, which knows the file
+// location).
+func Build(f *gen.PseudoFile, reg *Registry) (*Graph, error) {
+    return build(f, reg, func(sub string) (*Graph, error) {
+        return nil, fmt.Errorf("subflow %q needs a file location; use Load", sub)
+    })
+}
+
+func build(f *gen.PseudoFile, reg *Registry, loadSub func(string) (*Graph, error)) (*Graph, error) {
+    if reg == nil { reg = DefaultRegistry }
+    diags := gen.CheckPseudo(f)
+    if f == nil || f.Workflow == nil { return nil, sorted(diags) }
+    w := f.Workflow
+    g := &Graph{Name: w.Name, Version: w.Version, nodes: map[string]*node{}}
+    for _, d := range w.Nodes {
+        if _, dup := g.nodes[d.Name]; dup { continue }
+        n := &node{name: d.Name, kind: d.Kind, using: d.Using, at: d.At, pos: d.Pos, params: d.Params}
+        impl, err := resolve(n, reg, loadSub)
+        if err == nil { n.retry, err = nodeRetry(d.Params) }
+        if err != nil {
+            var sub gen.Diagnostics
+            if errors.As(err, &sub) {
+                diags = append(diags, sub...)
+                err = errors.New("subflow has errors")
+            }
+            diags = append(diags, gen.Diagnostic{Pos: d.Pos, Msg: fmt.Sprintf("%s %q: %v", d.Kind, d.Name, err)})
+        }
+        n.impl = impl
+        g.nodes[n.name] = n
+        g.order = append(g.order, n.name)
+    }
+    if len(diags) > 0 { return nil, sorted(diags) }
+    for _, c := range w.Connects {
+        for _, from := range c.From {
+            for _, to := range c.To {
+                e := &edge{from: g.nodes[from.Name], to: g.nodes[to.Name], when: c.When, otherwise: c.Otherwise}
+                e.from.out = append(e.from.out, e)
+                e.to.in = append(e.to.in, e)
+            }
+        }
+    }
+    return g, nil
+}
+
+func sorted(ds gen.Diagnostics) gen.Diagnostics {
+    sort.SliceStable(ds, func(i, j int) bool {
+        a, b := ds[i].Pos, ds[j].Pos
+        if a.File != b.File { return a.File < b.File }
+        if a.Line != b.Line { return a.Line < b.Line }
+        return a.Col < b.Col
+    })
+    return ds
+}
+
+func resolve(n *node, reg *Registry, loadSub func(string) (*Graph, error)) (Node, error) {
+    switch n.kind {
+    case KindTrigger:
+        if n.using == "" { return passThrough, nil }
+    case KindMerge:
+        if n.using == "" { return passThrough, nil }
+    case KindSwitch:
+        if n.using == "" { return payloadRouter{}, nil }
+    case KindSubflow:
+        ref := n.at
+        if ref == "" { ref = n.using }
+        sub, err := loadSub(ref)
+        if err != nil { return nil, err }
+        return subflow{g: sub}, nil
+    }
+    return reg.New(n.using, n.params)
+}
+
+// Triggers lists the workflow's trigger nodes in declaration order.
+func (g *Graph) Triggers() []string {
+    var out []string
+    for _, name := range g.order {
+        if g.nodes[name].kind == KindTrigger { out = append(out, name) }
+    }
+    return out
+}
@@ -0,0 +1,98 @@
+package eowr
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// httpRequest is the native node for n8n httpRequest nodes. Params: url
+// (required), method (GET), body, headers (a JSON object) and timeout (30s).
+// {{field}} in the url, body and header values is replaced with the payload
+// field (dotted paths reach into objects). The response goes to "response"
+// (decoded when it is JSON) and its status to "status_code"; a non-2xx
+// status is an error, so the node's retries apply.
+type httpRequest struct {
+    method, url, body string
+    headers           map[string]string
+    client            *http.Client
+}
+
+func newHTTPRequest(params map[string]string) (Node, error) {
+    n := httpRequest{method: strings.ToUpper(params["method"]), url: params["url"], body: params["body"]}
+    if n.url == "" { return nil, fmt.Errorf("http/request needs a url param") }
+    if n.method == "" { n.method = http.MethodGet }
+    if h := params["headers"]; h != "" {
+        if err := json.Unmarshal([]byte(h), &n.headers); err != nil { return nil, fmt.Errorf("http/request headers: %w", err) }
+    }
+    timeout := 30 * time.Second
+    if t := params["timeout"]; t != "" {
+        d, err := time.ParseDuration(t)
+        if err != nil { return nil, fmt.Errorf("http/request timeout: %w", err) }
+        timeout = d
+    }
+    n.client = &http.Client{Timeout: timeout}
+    return n, nil
+}
+
+func (h httpRequest) Run(ctx context.Context, in Payload) (Payload, error) {
+    var body io.Reader
+    if h.body != "" { body = strings.NewReader(expand(h.body, in)) }
+    req, err := http.NewRequestWithContext(ctx, h.method, expand(h.url, in), body)
+    if err != nil { return nil, err }
+    if h.body != "" { req.Header.Set("Content-Type", "application/json") }
+    for k, v := range h.headers { req.Header.Set(k, expand(v, in)) }
+    resp, err := h.client.Do(req)
+    if err != nil { return nil, err }
+    defer resp.Body.Close()
+    raw, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+    if err != nil { return nil, err }
+    if resp.StatusCode/100 != 2 {
+        return nil, fmt.Errorf("%s %s: %s: %s", h.method, req.URL.Redacted(), resp.Status, strings.TrimSpace(string(raw)))
+    }
+    out := in.Clone()
+    out["status_code"] = resp.StatusCode
+    var decoded any
+    if err := json.Unmarshal(raw, &decoded); err == nil {
+        out["response"] = decoded
+    } else {
+        out["response"] = string(raw)
+    }
+    return out, nil
+}
+
+var payloadTemplate = regexp.MustCompile(`\{\{\s*([A-Za-z_][\w.]*)\s*\}\}`)
+
+// expand replaces {{field}} with the payload value; objects and lists are
+// written as JSON, missing fields as "".
+func expand(s string, in Payload) string {
+    return payloadTemplate.ReplaceAllStringFunc(s, func(m string) string {
+        var cur any = in
+        for _, part := range strings.Split(payloadTemplate.FindStringSubmatch(m)[1], ".") {
+            switch obj := cur.(type) {
+            case Payload:
+                cur = obj[part]
+            case map[string]any:
+                cur = obj[part]
+            default:
+                return ""
+            }
+        }
+        switch v := cur.(type) {
+        case nil:
+            return ""
+        case string:
+            return v
+        case map[string]any, []any:
+            b, _ := json.Marshal(v)
+            return string(b)
+        default:
+            return fmt.Sprint(v)
+        }
+    })
+}
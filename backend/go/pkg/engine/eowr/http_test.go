@@ -0,0 +1,38 @@
+package eowr
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestHTTPRequestNode(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        body, _ := io.ReadAll(r.Body)
+        if r.Method != "POST" || r.URL.Path != "/leads/42" || string(body) != `{"email":"a@b.co"}` || r.Header.Get("X-Key") != "k1" {
+            http.Error(w, r.Method+" "+r.URL.Path+" "+string(body), http.StatusBadRequest)
+            return
+        }
+        w.Write([]byte(`{"score": 91}`))
+    }))
+    defer srv.Close()
+
+    n, err := DefaultRegistry.New("http/request", map[string]string{
+        "method":  "post",
+        "url":     srv.URL + "/leads/{{ lead.id }}",
+        "body":    `{"email":"{{lead.email}}"}`,
+        "headers": `{"X-Key": "{{key}}"}`,
+    })
+    if err != nil { t.Fatal(err) }
+    out, err := n.Run(context.Background(), Payload{"key": "k1", "lead": map[string]any{"id": 42.0, "email": "a@b.co"}})
+    if err != nil { t.Fatal(err) }
+    if out["status_code"] != 200 || out["response"].(map[string]any)["score"] != 91.0 { t.Fatalf("output = %v", out) }
+
+    if _, err := n.Run(context.Background(), Payload{"key": "wrong"}); err == nil || !strings.Contains(err.Error(), "400") {
+        t.Fatalf("err = %v", err)
+    }
+    if _, err := DefaultRegistry.New("http/request", nil); err == nil { t.Fatal("expected an error without url") }
+}
@@ -0,0 +1,206 @@
+package eowr
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+    "sync"
+
+    gen "github.com/bitesinbyte/ferret/pkg/engine/generator"
+    "github.com/bitesinbyte/ferret/pkg/engine/queue"
+    "github.com/bitesinbyte/ferret/pkg/engine/telemetry"
+    "github.com/bitesinbyte/ferret/pkg/engine/workers"
+)
+
+// Payload is the data passed along an edge. Values should be JSON-compatible
+// so payloads can be logged, queued and checkpointed.
+type Payload map[string]any
+
+// Clone returns a shallow copy; nodes must not mutate their input.
+func (p Payload) Clone() Payload {
+    out := make(Payload, len(p))
+    for k, v := range p { out[k] = v }
+    return out
+}
+
+// String returns p[key] when it is a string.
+func (p Payload) String(key string) string {
+    s, _ := p[key].(string)
+    return s
+}
+
+// Node is one step of a workflow.
+type Node interface {
+    Run(ctx context.Context, in Payload) (Payload, error)
+}
+
+// NodeFunc adapts a function to Node.
+type NodeFunc func(ctx context.Context, in Payload) (Payload, error)
+
+func (f NodeFunc) Run(ctx context.Context, in Payload) (Payload, error) { return f(ctx, in) }
+
+// Router is implemented by switch nodes: Route picks which of targets (the
+// switch's outgoing connections) receive the payload.
+type Router interface {
+    Node
+    Route(ctx context.Context, in Payload, targets []string) ([]string, error)
+}
+
+// Factory builds a Node from its parameters.
+type Factory func(params map[string]string) (Node, error)
+
+// Registry maps `using` paths ("workers/crm_worker") to node factories.
+type Registry struct {
+    mu        sync.RWMutex
+    factories map[string]Factory
+}
+
+func NewRegistry() *Registry { return &Registry{factories: map[string]Factory{}} }
+
+// Register adds or replaces the factory for using.
+func (r *Registry) Register(using string, f Factory) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.factories[strings.Trim(using, "/")] = f
+}
+
+// New builds the node registered under using.
+func (r *Registry) New(using string, params map[string]string) (Node, error) {
+    r.mu.RLock()
+    f, ok := r.factories[strings.Trim(using, "/")]
+    r.mu.RUnlock()
+    if !ok { return nil, fmt.Errorf("no node registered for using %q (known: %s)", using, strings.Join(r.Names(), ", ")) }
+    return f(params)
+}
+
+// Names lists the registered paths, sorted.
+func (r *Registry) Names() []string {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]string, 0, len(r.factories))
+    for k := range r.factories { out = append(out, k) }
+    sort.Strings(out)
+    return out
+}
+
+// DefaultRegistry holds the engine's built-in nodes.
+var DefaultRegistry = NewRegistry()
+
+func init() {
+    static := func(n Node) Factory { return func(map[string]string) (Node, error) { return n, nil } }
+    DefaultRegistry.Register("workers/data_worker", static(NodeFunc(dataWorker)))
+    DefaultRegistry.Register("workers/ai_worker", static(NodeFunc(aiWorker)))
+    DefaultRegistry.Register("workers/crm_worker", static(NodeFunc(crmWorker)))
+    DefaultRegistry.Register("queue/nats_engine", static(NodeFunc(natsPublish)))
+    DefaultRegistry.Register("queue/pulsar_engine", func(params map[string]string) (Node, error) {
+        return pulsarSend{topic: params["topic"]}, nil
+    })
+    DefaultRegistry.Register("telemetry/sentry_engine", static(NodeFunc(sentryEvent)))
+    DefaultRegistry.Register(gen.HTTPNode, newHTTPRequest)
+}
+
+// passThrough forwards its input; used for triggers and merges.
+var passThrough = NodeFunc(func(_ context.Context, in Payload) (Payload, error) { return in, nil })
+
+// payloadRouter is the default switch: it follows the targets named by the
+// payload's "route" field (a string or list of strings), or all of them when
+// the field is absent.
+type payloadRouter struct{}
+
+func (payloadRouter) Run(_ context.Context, in Payload) (Payload, error) { return in, nil }
+
+func (payloadRouter) Route(_ context.Context, in Payload, targets []string) ([]string, error) {
+    var want []string
+    switch v := in["route"].(type) {
+    case nil:
+        return targets, nil
+    case string:
+        want = []string{v}
+    case []string:
+        want = v
+    case []any:
+        for _, x := range v {
+            if s, ok := x.(string); ok { want = append(want, s) }
+        }
+    default:
+        return nil, fmt.Errorf("route must be a string or list, got %T", v)
+    }
+    var out []string
+    for _, t := range targets {
+        for _, w := range want {
+            if strings.EqualFold(t, w) { out = append(out, t); break }
+        }
+    }
+    return out, nil
+}
+
+// subflow runs another workflow with the payload and returns its output.
+type subflow struct{ g *Graph }
+
+func (s subflow) Run(ctx context.Context, in Payload) (Payload, error) {
+    res, err := s.g.Run(ctx, "", in)
+    if err != nil { return nil, err }
+    return res.Output, nil
+}
+
+// The worker nodes read and write the "data" field, which holds the record
+// being processed (a string or any JSON value).
+
+func dataString(in Payload) string {
+    switch v := in["data"].(type) {
+    case nil:
+        return ""
+    case string:
+        return v
+    default:
+        b, _ := json.Marshal(v)
+        return string(b)
+    }
+}
+
+func dataWorker(_ context.Context, in Payload) (Payload, error) {
+    out := in.Clone()
+    out["data"] = workers.DataWorker{}.Transform(dataString(in))
+    return out, nil
+}
+
+func aiWorker(_ context.Context, in Payload) (Payload, error) {
+    out := in.Clone()
+    out["data"] = workers.AIWorker{}.Enrich(dataString(in))
+    return out, nil
+}
+
+func crmWorker(_ context.Context, in Payload) (Payload, error) {
+    if _, ok := in["data"]; !ok { return nil, fmt.Errorf("crm_worker: payload has no data") }
+    workers.CRMWorker{}.Push(dataString(in))
+    out := in.Clone()
+    out["crm_pushed"] = true
+    return out, nil
+}
+
+func natsPublish(_ context.Context, in Payload) (Payload, error) {
+    queue.NatsEngine{}.Publish(map[string]any(in))
+    return in, nil
+}
+
+// pulsarSend sends the payload to topic, defaulting to the payload's "topic"
+// field and then "eowr.<workflow>".
+type pulsarSend struct{ topic string }
+
+func (p pulsarSend) Run(_ context.Context, in Payload) (Payload, error) {
+    topic := p.topic
+    if topic == "" { topic = in.String("topic") }
+    if topic == "" { topic = "eowr." + in.String("workflow") }
+    queue.PulsarEngine{}.Send(topic, map[string]any(in))
+    return in, nil
+}
+
+func sentryEvent(ctx context.Context, in Payload) (Payload, error) {
+    event := "eowr." + in.String("workflow")
+    if e := in.String("error"); e != "" { event += " failed at " + in.String("failed_node") + ": " + e }
+    telemetry.Sentry{}.TrackEvent(event)
+    telemetry.RecordCounter(ctx, "eowr_error_events_total", 1, map[string]string{"workflow": in.String("workflow")})
+    return in, nil
+}
@@ -0,0 +1,309 @@
+package eowr
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "sync"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/engine/metrics"
+    "github.com/bitesinbyte/ferret/pkg/engine/telemetry"
+)
+
+// Result describes one run of a workflow.
+type Result struct {
+    Workflow string
+    Output   Payload            // merged outputs of the nodes that ended a branch
+    Outputs  map[string]Payload // per node that ran
+    Skipped  []string           // nodes no active branch reached
+    Failed   map[string]error   // every node failure, handled by on_error or not
+}
+
+// Run executes the workflow from trigger (empty fires every trigger) with in
+// as the trigger payload, without checkpoints. See Execute.
+func (g *Graph) Run(ctx context.Context, trigger string, in Payload) (*Result, error) {
+    return g.Execute(ctx, Options{Trigger: trigger, Input: in})
+}
+
+// Execute runs the workflow as configured by opts. A node runs once all of its incoming connections are
+// settled and at least one of them carries a payload; several payloads are
+// merged in connection order. Independent branches run concurrently.
+//
+// A switch follows its `when` connections whose condition holds for its
+// output, or its `otherwise` connections when none does. A switch without
+// conditions asks its Router (the payload's "route" field by default).
+//
+// A failing node sends {error, failed_node} plus its input to the on_error
+// nodes it is connected to. Without such a connection the workflow-level
+// on_error nodes (those nothing connects to) handle it; without any handler
+// the run is cancelled and Execute returns the error. A node is first retried
+// according to its retry policy; panics count as failures.
+func (g *Graph) Execute(ctx context.Context, opts Options) (*Result, error) {
+    trigger, in := opts.Trigger, opts.Input
+    var starts []*node
+    for _, name := range g.order {
+        n := g.nodes[name]
+        if n.kind == KindTrigger && (trigger == "" || n.name == trigger) { starts = append(starts, n) }
+    }
+    if trigger != "" {
+        if n, ok := g.nodes[trigger]; !ok || n.kind != KindTrigger { return nil, fmt.Errorf("eowr: %s: no trigger %q", g.Name, trigger) }
+    }
+    if in == nil { in = Payload{} }
+    if _, ok := in["workflow"]; !ok {
+        in = in.Clone()
+        in["workflow"] = g.Name
+    }
+
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    ctx, end := telemetry.StartSpan(ctx, "eowr.run", map[string]string{"workflow": g.Name})
+    defer end()
+    r := &run{
+        g: g, ctx: ctx, cancel: cancel, opts: opts,
+        pending:   map[*node]int{},
+        inputs:    map[*edge]Payload{},
+        forwarded: map[*node]bool{},
+        res: &Result{Workflow: g.Name, Outputs: map[string]Payload{}, Failed: map[string]error{}},
+    }
+    for _, n := range g.nodes { r.pending[n] = len(n.in) }
+    started := map[*node]bool{}
+    for _, n := range starts {
+        started[n] = true
+        r.wg.Add(1)
+        go r.execute(n, in)
+    }
+    for _, name := range g.order {
+        // Triggers that were not fired settle their branches as skipped.
+        if n := g.nodes[name]; n.kind == KindTrigger && !started[n] { r.skip(n) }
+    }
+    r.wg.Wait()
+
+    res := r.res
+    for _, name := range g.order {
+        n := g.nodes[name]
+        out, ran := res.Outputs[name]
+        if !ran || n.kind == KindOnError || r.forwarded[n] { continue }
+        if res.Output == nil { res.Output = Payload{} }
+        for k, v := range out { res.Output[k] = v }
+    }
+    metrics.NewCounter("eowr_runs_total").Inc(1)
+    if len(r.errs) > 0 {
+        metrics.NewCounter("eowr_runs_failed_total").Inc(1)
+        return res, fmt.Errorf("eowr: %s: %w", g.Name, errors.Join(r.errs...))
+    }
+    if err := ctx.Err(); err != nil { return res, err }
+    return res, nil
+}
+
+type run struct {
+    g      *Graph
+    ctx    context.Context
+    cancel context.CancelFunc
+    opts   Options
+    wg     sync.WaitGroup
+
+    mu        sync.Mutex
+    pending   map[*node]int     // unsettled incoming edges
+    inputs    map[*edge]Payload // payloads on settled, active edges
+    forwarded map[*node]bool    // node passed its output on to a successor
+    res       *Result
+    errs      []error // unhandled failures
+}
+
+// settle records that e carries p (nil: the branch is inactive) and starts or
+// skips e.to once all of its inputs are settled.
+func (r *run) settle(e *edge, p Payload) {
+    r.mu.Lock()
+    if p != nil {
+        r.inputs[e] = p
+        if !e.onError() { r.forwarded[e.from] = true }
+    }
+    r.pending[e.to]--
+    ready := r.pending[e.to] == 0
+    var merged Payload
+    if ready {
+        for _, in := range e.to.in {
+            p, ok := r.inputs[in]
+            if !ok { continue }
+            if merged == nil { merged = Payload{} }
+            for k, v := range p { merged[k] = v }
+        }
+    }
+    r.mu.Unlock()
+    if !ready { return }
+    if merged == nil || r.ctx.Err() != nil {
+        r.skip(e.to)
+        return
+    }
+    r.wg.Add(1)
+    go r.execute(e.to, merged)
+}
+
+func (r *run) skip(n *node) {
+    r.mu.Lock()
+    r.res.Skipped = append(r.res.Skipped, n.name)
+    r.mu.Unlock()
+    r.checkpoint(NodeState{Node: n.name, Status: NodeSkipped})
+    for _, e := range n.out { r.settle(e, nil) }
+}
+
+// checkpoint saves s when the run is durable. A failed save is logged and the
+// run goes on: the node then simply runs again if the run is resumed.
+func (r *run) checkpoint(s NodeState) {
+    if r.opts.Checkpoint == nil || r.opts.RunID == "" { return }
+    if err := r.opts.Checkpoint.SaveNode(context.WithoutCancel(r.ctx), r.opts.RunID, s); err != nil {
+        log.Printf("eowr: %s: run %s: checkpoint %q: %v", r.g.Name, r.opts.RunID, s.Node, err)
+    }
+}
+
+func (r *run) policy(n *node) RetryPolicy {
+    p := r.opts.Retry
+    if n.retry == nil { return p }
+    if n.retry.Attempts > 0 { p.Attempts = n.retry.Attempts }
+    if n.retry.Backoff > 0 { p.Backoff = n.retry.Backoff }
+    return p
+}
+
+// call runs the node once, converting a panic into an error.
+func (r *run) call(n *node, in Payload) (out Payload, routes map[*edge]bool, err error) {
+    ctx, end := telemetry.StartSpan(r.ctx, "eowr.node", map[string]string{"workflow": r.g.Name, "node": n.name})
+    defer end()
+    start := time.Now()
+    defer func() {
+        if p := recover(); p != nil { err = fmt.Errorf("panic: %v", p) }
+        metrics.NewHistogram("eowr_node_seconds").Observe(time.Since(start).Seconds())
+    }()
+    out, err = n.impl.Run(ctx, in)
+    if err == nil && n.kind == KindSwitch { routes, err = route(ctx, n, out) }
+    return out, routes, err
+}
+
+func (r *run) execute(n *node, in Payload) {
+    defer r.wg.Done()
+    prev, resumed := r.opts.Previous[n.name]
+    var out Payload
+    var routes map[*edge]bool
+    var err error
+    if resumed && prev.Status == NodeSucceeded {
+        // Completed by an earlier invocation: reuse its output.
+        out = prev.Output
+        if n.kind == KindSwitch { routes, err = route(r.ctx, n, out) }
+    } else {
+        policy := r.policy(n)
+        attempts, started := prev.Attempts, time.Now().UTC()
+        for try := 1; ; try++ {
+            attempts++
+            r.checkpoint(NodeState{Node: n.name, Status: NodeRunning, Input: in, Attempts: attempts, StartedAt: started})
+            out, routes, err = r.call(n, in)
+            if err == nil || try >= policy.Attempts || r.ctx.Err() != nil { break }
+            wait := policy.wait(try)
+            log.Printf("eowr: %s: node %q attempt %d failed, retrying in %s: %v", r.g.Name, n.name, attempts, wait, err)
+            metrics.NewCounter("eowr_node_retries_total").Inc(1)
+            t := time.NewTimer(wait)
+            select {
+            case <-r.ctx.Done():
+                t.Stop()
+            case <-t.C:
+            }
+            if r.ctx.Err() != nil { break }
+        }
+        st := NodeState{Node: n.name, Status: NodeSucceeded, Input: in, Output: out, Attempts: attempts, StartedAt: started, FinishedAt: time.Now().UTC()}
+        if err != nil {
+            st.Status, st.Output, st.Error = NodeFailed, nil, err.Error()
+        }
+        r.checkpoint(st)
+    }
+
+    if err != nil {
+        r.fail(n, in, err)
+        return
+    }
+    if out == nil { out = Payload{} }
+    r.mu.Lock()
+    r.res.Outputs[n.name] = out
+    r.mu.Unlock()
+    for _, e := range n.out {
+        switch {
+        case e.onError():
+            r.settle(e, nil)
+        case n.kind == KindSwitch && !routes[e]:
+            r.settle(e, nil)
+        default:
+            r.settle(e, out)
+        }
+    }
+}
+
+func (r *run) fail(n *node, in Payload, err error) {
+    log.Printf("eowr: %s: %s: node %q failed: %v", r.g.Name, n.pos, n.name, err)
+    metrics.NewCounter("eowr_node_failures_total").Inc(1)
+    telemetry.RecordCounter(r.ctx, "eowr_node_failures_total", 1, map[string]string{"workflow": r.g.Name, "node": n.name})
+    ep := in.Clone()
+    ep["error"] = err.Error()
+    ep["failed_node"] = n.name
+
+    handled := false
+    for _, e := range n.out {
+        if e.onError() { handled = true }
+    }
+    var global []*node
+    if !handled && n.kind != KindOnError {
+        for _, name := range r.g.order {
+            if h := r.g.nodes[name]; h.kind == KindOnError && len(h.in) == 0 { global = append(global, h) }
+        }
+    }
+    r.mu.Lock()
+    r.res.Failed[n.name] = err
+    if !handled && len(global) == 0 { r.errs = append(r.errs, fmt.Errorf("%s: node %q: %w", n.pos, n.name, err)) }
+    r.mu.Unlock()
+    if !handled && len(global) == 0 { r.cancel() }
+
+    for _, h := range global {
+        r.wg.Add(1)
+        go r.execute(h, ep)
+    }
+    for _, e := range n.out {
+        if e.onError() {
+            r.settle(e, ep)
+        } else {
+            r.settle(e, nil)
+        }
+    }
+}
+
+// route picks the outgoing edges of switch n for payload p.
+func route(ctx context.Context, n *node, p Payload) (map[*edge]bool, error) {
+    taken := map[*edge]bool{}
+    conditional := false
+    for _, e := range n.out {
+        if e.when == nil && !e.otherwise { continue }
+        conditional = true
+        if e.when != nil && e.when.Eval(p) { taken[e] = true }
+    }
+    if conditional {
+        matched := len(taken) > 0
+        for _, e := range n.out {
+            if (e.otherwise && !matched) || (e.when == nil && !e.otherwise) { taken[e] = true }
+        }
+        return taken, nil
+    }
+    router, ok := n.impl.(Router)
+    if !ok {
+        for _, e := range n.out { taken[e] = true }
+        return taken, nil
+    }
+    var targets []string
+    for _, e := range n.out {
+        if !e.onError() { targets = append(targets, e.to.name) }
+    }
+    names, err := router.Route(ctx, p, targets)
+    if err != nil { return nil, err }
+    for _, e := range n.out {
+        for _, name := range names {
+            if e.to.name == name { taken[e] = true }
+        }
+    }
+    return taken, nil
+}
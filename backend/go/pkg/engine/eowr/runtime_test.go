@@ -0,0 +1,231 @@
+package eowr
+
+import (
+    "context"
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func writePseudo(t *testing.T, dir, name, src string) string {
+    t.Helper()
+    p := filepath.Join(dir, name)
+    if err := os.WriteFile(p, []byte(src), 0o644); err != nil { t.Fatal(err) }
+    return p
+}
+
+func testRegistry() *Registry {
+    reg := NewRegistry()
+    set := func(key, val string) Factory {
+        return func(map[string]string) (Node, error) {
+            return NodeFunc(func(_ context.Context, in Payload) (Payload, error) {
+                out := in.Clone()
+                out[key] = val
+                return out, nil
+            }), nil
+        }
+    }
+    reg.Register("test/a", set("a", "1"))
+    reg.Register("test/b", set("b", "2"))
+    reg.Register("test/fail", func(map[string]string) (Node, error) {
+        return NodeFunc(func(context.Context, Payload) (Payload, error) { return nil, errors.New("boom") }), nil
+    })
+    reg.Register("test/handler", set("handled", "yes"))
+    return reg
+}
+
+func TestRunBranchesAndMerge(t *testing.T) {
+    dir := t.TempDir()
+    p := writePseudo(t, dir, "wf.pseudo", `workflow "Fan" version 1:
+  trigger "Start"
+  action "A" using "test/a"
+  action "B" using "test/b"
+  merge "Join"
+  connect "Start" -> ["A", "B"]
+  connect "A" -> "Join"
+  connect "B" -> "Join"
+`)
+    g, err := Load(p, testRegistry())
+    if err != nil { t.Fatal(err) }
+    res, err := g.Run(context.Background(), "", Payload{"data": "x"})
+    if err != nil { t.Fatal(err) }
+    if res.Output["a"] != "1" || res.Output["b"] != "2" || res.Output["data"] != "x" {
+        t.Fatalf("output = %v", res.Output)
+    }
+    if _, ok := res.Outputs["Join"]; !ok { t.Fatalf("merge did not run: %v", res.Outputs) }
+}
+
+func TestRunBranchesConcurrently(t *testing.T) {
+    reg := NewRegistry()
+    var running, peak int32
+    reg.Register("test/slow", func(map[string]string) (Node, error) {
+        return NodeFunc(func(_ context.Context, in Payload) (Payload, error) {
+            n := atomic.AddInt32(&running, 1)
+            for {
+                p := atomic.LoadInt32(&peak)
+                if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) { break }
+            }
+            time.Sleep(20 * time.Millisecond)
+            atomic.AddInt32(&running, -1)
+            return in, nil
+        }), nil
+    })
+    p := writePseudo(t, t.TempDir(), "wf.pseudo", `workflow "Par" version 1:
+  trigger "Start"
+  action "A" using "test/slow"
+  action "B" using "test/slow"
+  action "C" using "test/slow"
+  connect "Start" -> ["A", "B", "C"]
+`)
+    g, err := Load(p, reg)
+    if err != nil { t.Fatal(err) }
+    if _, err := g.Run(context.Background(), "", nil); err != nil { t.Fatal(err) }
+    if peak < 2 { t.Fatalf("branches ran sequentially (peak %d)", peak) }
+}
+
+func TestRunSwitchRoutesByPayload(t *testing.T) {
+    p := writePseudo(t, t.TempDir(), "wf.pseudo", `workflow "Route" version 1:
+  trigger "Start"
+  switch "Pick"
+  action "A" using "test/a"
+  action "B" using "test/b"
+  connect "Start" -> "Pick"
+  connect "Pick" -> ["A", "B"]
+`)
+    g, err := Load(p, testRegistry())
+    if err != nil { t.Fatal(err) }
+    res, err := g.Run(context.Background(), "", Payload{"route": "B"})
+    if err != nil { t.Fatal(err) }
+    if _, ran := res.Outputs["A"]; ran { t.Fatalf("A should be skipped: %v", res.Outputs) }
+    if res.Output["b"] != "2" { t.Fatalf("output = %v", res.Output) }
+    if strings.Join(res.Skipped, ",") != "A" { t.Fatalf("skipped = %v", res.Skipped) }
+}
+
+func TestRunOnError(t *testing.T) {
+    p := writePseudo(t, t.TempDir(), "wf.pseudo", `workflow "Err" version 1:
+  trigger "Start"
+  action "Boom" using "test/fail"
+  action "After" using "test/a"
+  on_error "Notify" using "test/handler"
+  connect "Start" -> "Boom"
+  connect "Boom" -> ["After", "Notify"]
+`)
+    g, err := Load(p, testRegistry())
+    if err != nil { t.Fatal(err) }
+    res, err := g.Run(context.Background(), "", Payload{"data": "x"})
+    if err != nil { t.Fatalf("handled failure returned %v", err) }
+    n := res.Outputs["Notify"]
+    if n["error"] != "boom" || n["failed_node"] != "Boom" || n["handled"] != "yes" { t.Fatalf("Notify got %v", n) }
+    if _, ran := res.Outputs["After"]; ran { t.Fatal("success branch ran after a failure") }
+}
+
+func TestRunUnhandledFailure(t *testing.T) {
+    p := writePseudo(t, t.TempDir(), "wf.pseudo", `workflow "Err" version 1:
+  trigger "Start"
+  action "Boom" using "test/fail"
+  connect "Start" -> "Boom"
+`)
+    g, err := Load(p, testRegistry())
+    if err != nil { t.Fatal(err) }
+    if _, err := g.Run(context.Background(), "", nil); err == nil || !strings.Contains(err.Error(), "boom") {
+        t.Fatalf("err = %v", err)
+    }
+}
+
+func TestRunSubflow(t *testing.T) {
+    dir := t.TempDir()
+    writePseudo(t, dir, "child.pseudo", `workflow "Child" version 1:
+  trigger "In"
+  action "B" using "test/b"
+  connect "In" -> "B"
+`)
+    p := writePseudo(t, dir, "parent.pseudo", `workflow "Parent" version 1:
+  trigger "Start"
+  subflow "Child" at "child.pseudo"
+  action "A" using "test/a"
+  connect "Start" -> "Child"
+  connect "Child" -> "A"
+`)
+    g, err := Load(p, testRegistry())
+    if err != nil { t.Fatal(err) }
+    res, err := g.Run(context.Background(), "", nil)
+    if err != nil { t.Fatal(err) }
+    if res.Output["a"] != "1" || res.Output["b"] != "2" { t.Fatalf("output = %v", res.Output) }
+}
+
+func TestLoadRejectsBadGraphs(t *testing.T) {
+    cases := map[string]string{
+        "cycle": `workflow "C" version 1:
+  trigger "T"
+  action "A" using "test/a"
+  action "B" using "test/b"
+  connect "T" -> "A"
+  connect "A" -> "B"
+  connect "B" -> "A"
+`,
+        "unknown using": `workflow "U" version 1:
+  trigger "T"
+  action "A" using "workers/nope"
+  connect "T" -> "A"
+`,
+        "unknown target": `workflow "U" version 1:
+  trigger "T"
+  connect "T" -> "Missing"
+`,
+    }
+    for name, src := range cases {
+        p := writePseudo(t, t.TempDir(), "wf.pseudo", src)
+        if _, err := Load(p, testRegistry()); err == nil { t.Errorf("%s: expected an error", name) }
+    }
+}
+
+func TestShippedWorkflowsRun(t *testing.T) {
+    for _, name := range []string{"crm_sync", "lead_import", "analytics_pipeline"} {
+        g, err := Load(filepath.Join("..", "workflows", name+".pseudo"), nil)
+        if err != nil { t.Fatalf("%s: %v", name, err) }
+        res, err := g.Run(context.Background(), "", Payload{"data": "record"})
+        if err != nil { t.Fatalf("%s: %v", name, err) }
+        if !strings.HasPrefix(res.Output.String("data"), "record + ") { t.Fatalf("%s: output %v", name, res.Output) }
+    }
+}
+
+func TestRunSwitchConditionsAndParams(t *testing.T) {
+    reg := testRegistry()
+    reg.Register("test/tag", func(params map[string]string) (Node, error) {
+        return NodeFunc(func(_ context.Context, in Payload) (Payload, error) {
+            out := in.Clone()
+            out["tag"] = params["label"]
+            return out, nil
+        }), nil
+    })
+    p := writePseudo(t, t.TempDir(), "wf.pseudo", `workflow "Cond" version 1:
+  trigger "Start"
+  switch "Hot?"
+  action "Hot" using "test/tag" with label="hot"
+  action "Cold" using "test/tag" with label=cold
+  connect "Start" -> "Hot?"
+  connect "Hot?" -> "Hot" when score >= 50
+  connect "Hot?" -> "Cold" otherwise
+`)
+    g, err := Load(p, reg)
+    if err != nil { t.Fatal(err) }
+    for score, want := range map[float64]string{80: "hot", 10: "cold"} {
+        res, err := g.Run(context.Background(), "", Payload{"score": score})
+        if err != nil { t.Fatal(err) }
+        if res.Output["tag"] != want { t.Errorf("score %v: output %v", score, res.Output) }
+    }
+}
+
+func TestLoadReportsPositions(t *testing.T) {
+    p := writePseudo(t, t.TempDir(), "wf.pseudo", `workflow "U" version 1:
+  trigger "T"
+  action "A" using "workers/nope"
+  connect "T" -> "A"
+`)
+    _, err := Load(p, testRegistry())
+    if err == nil || !strings.Contains(err.Error(), "wf.pseudo:3:3: action \"A\": no node registered") { t.Fatalf("err = %v", err) }
+}
@@ -0,0 +1,229 @@
+package generator
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "math"
+    "math/rand"
+    "sort"
+
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+)
+
+// Variant selection policies (PlanInput.Bandit).
+const (
+    BanditThompson = "thompson" // sample each arm's Beta posterior, highest draw wins
+    BanditUCB      = "ucb"      // UCB1: mean reward plus an exploration bonus per post
+)
+
+// Reward metrics (PlanInput.BanditMetric); each is a rate over impressions.
+const (
+    RewardEngagement = "engagement" // likes, comments, shares, saves and clicks
+    RewardCTR        = "ctr"        // clicks
+    RewardConversion = "conversion" // conversions
+)
+
+// Arm is a variant with the outcomes of the posts scheduled from it.
+type Arm struct {
+    Variant   Variant
+    ArmID     string  // experiment_arms.id; empty outside an experiment
+    Posts     int     // posts with collected outcomes
+    Successes float64 // rewarded events
+    Trials    float64 // impressions (at least Successes)
+}
+
+// Mean is the observed reward rate, 0 without trials.
+func (a Arm) Mean() float64 {
+    if a.Trials == 0 { return 0 }
+    return a.Successes / a.Trials
+}
+
+// Bandit ranks arms by policy. A nil Rand uses a time-seeded source.
+type Bandit struct {
+    Policy string
+    Rand   *rand.Rand
+}
+
+// Rank returns the arms best first. Thompson sampling draws from
+// Beta(1+successes, 1+failures) per arm, so arms with little data still get
+// picked now and then. UCB scores mean + sqrt(2 ln N / n) with n the arm's
+// posts and N all posts; arms without posts come first.
+func (b Bandit) Rank(arms []Arm) ([]Arm, error) {
+    r := b.Rand
+    if r == nil { r = rand.New(rand.NewSource(rand.Int63())) }
+    scores := make([]float64, len(arms))
+    switch b.Policy {
+    case BanditThompson:
+        for i, a := range arms { scores[i] = betaSample(r, 1+a.Successes, 1+a.Trials-a.Successes) }
+    case BanditUCB:
+        total := 0
+        for _, a := range arms { total += a.Posts }
+        for i, a := range arms {
+            if a.Posts == 0 {
+                scores[i] = math.Inf(1)
+                continue
+            }
+            scores[i] = a.Mean() + math.Sqrt(2*math.Log(float64(total))/float64(a.Posts))
+        }
+    default:
+        return nil, fmt.Errorf("unknown bandit policy %q (want %s or %s)", b.Policy, BanditThompson, BanditUCB)
+    }
+    idx := make([]int, len(arms))
+    for i := range idx { idx[i] = i }
+    sort.SliceStable(idx, func(i, j int) bool { return scores[idx[i]] > scores[idx[j]] })
+    out := make([]Arm, len(arms))
+    for i, k := range idx { out[i] = arms[k] }
+    return out, nil
+}
+
+// ArmWeights estimates each arm's probability of having the best reward
+// rate from draws Thompson samples; the weights sum to 1.
+func ArmWeights(arms []Arm, r *rand.Rand, draws int) []float64 {
+    w := make([]float64, len(arms))
+    if len(arms) == 0 { return w }
+    if r == nil { r = rand.New(rand.NewSource(rand.Int63())) }
+    if draws <= 0 { draws = 10000 }
+    for d := 0; d < draws; d++ {
+        best, bestScore := 0, -1.0
+        for i, a := range arms {
+            if s := betaSample(r, 1+a.Successes, 1+a.Trials-a.Successes); s > bestScore { best, bestScore = i, s }
+        }
+        w[best]++
+    }
+    for i := range w { w[i] /= float64(draws) }
+    return w
+}
+
+// NewArm sums the reward metric of a variant's outcomes.
+func NewArm(v Variant, armID string, o calendarrepo.VariantOutcome, metric string) (Arm, error) {
+    a := Arm{Variant: v, ArmID: armID, Posts: o.Posts, Trials: float64(o.Impressions)}
+    switch metric {
+    case "", RewardEngagement:
+        a.Successes = float64(o.Likes + o.Comments + o.Shares + o.Saves + o.Clicks)
+    case RewardCTR:
+        a.Successes = float64(o.Clicks)
+    case RewardConversion:
+        a.Successes = float64(o.Conversions)
+    default:
+        return a, fmt.Errorf("unknown bandit metric %q (want %s, %s or %s)", metric, RewardEngagement, RewardCTR, RewardConversion)
+    }
+    // Platforms without impression counts still get a valid posterior.
+    a.Trials = math.Max(a.Trials, a.Successes)
+    return a, nil
+}
+
+// variantSelector picks the variants each topic schedules.
+type variantSelector struct {
+    bandit   Bandit
+    metric   string
+    n        int
+    arms     map[string]string // variant id -> experiment arm id
+    outcomes map[string]calendarrepo.VariantOutcome
+}
+
+func newVariantSelector(ctx context.Context, repo calendarrepo.Repository, in PlanInput) (*variantSelector, error) {
+    s := &variantSelector{bandit: Bandit{Policy: in.Bandit, Rand: in.Rand}, metric: in.BanditMetric, n: in.VariantsPerTopic}
+    if s.n <= 0 { s.n = 2 }
+    if in.Bandit == "" { return s, nil }
+    // Reject a bad policy or metric before touching the database.
+    if _, err := s.bandit.Rank(nil); err != nil { return nil, err }
+    if _, err := NewArm(Variant{}, "", calendarrepo.VariantOutcome{}, s.metric); err != nil { return nil, err }
+    if in.OrgID == "" { return nil, fmt.Errorf("bandit variant selection needs an OrgID") }
+    if in.ExperimentID != "" {
+        arms, err := repo.ExperimentArms(ctx, in.ExperimentID)
+        if err != nil { return nil, fmt.Errorf("load experiment arms: %w", err) }
+        s.arms = map[string]string{}
+        for _, a := range arms {
+            if a.VariantID != nil { s.arms[*a.VariantID] = a.ID }
+        }
+    }
+    var err error
+    if s.outcomes, err = repo.VariantOutcomes(ctx, in.OrgID, in.ExperimentID); err != nil { return nil, fmt.Errorf("load variant outcomes: %w", err) }
+    return s, nil
+}
+
+// pick returns the control and first other variant, or with a bandit the n
+// best ranked arms. In an experiment only variants with an arm compete; a
+// topic with none falls back to the control and first other variant.
+func (s *variantSelector) pick(vs []Variant) ([]Arm, error) {
+    var arms []Arm
+    if s.bandit.Policy != "" {
+        for _, v := range vs {
+            armID, ok := s.arms[v.ID]
+            if s.arms != nil && !ok { continue }
+            a, err := NewArm(v, armID, s.outcomes[v.ID], s.metric)
+            if err != nil { return nil, err }
+            arms = append(arms, a)
+        }
+    }
+    if len(arms) == 0 {
+        for _, v := range pickTopVariants(vs, s.n) { arms = append(arms, Arm{Variant: v}) }
+        return arms, nil
+    }
+    ranked, err := s.bandit.Rank(arms)
+    if err != nil { return nil, err }
+    if len(ranked) > s.n { ranked = ranked[:s.n] }
+    return ranked, nil
+}
+
+// UpdateArmWeights sets every arm of the experiment to its estimated
+// probability of having the best reward (see ArmWeights) and returns the
+// weights by arm id. cmd/analytics --collect runs it for every running
+// experiment (UpdateRunningArmWeights) after each analytics pass.
+func UpdateArmWeights(ctx context.Context, repo calendarrepo.Repository, orgID, experimentID, metric string, r *rand.Rand) (map[string]float64, error) {
+    rows, err := repo.ExperimentArms(ctx, experimentID)
+    if err != nil { return nil, fmt.Errorf("load experiment arms: %w", err) }
+    outcomes, err := repo.VariantOutcomes(ctx, orgID, experimentID)
+    if err != nil { return nil, fmt.Errorf("load variant outcomes: %w", err) }
+    var arms []Arm
+    for _, row := range rows {
+        var o calendarrepo.VariantOutcome
+        v := Variant{}
+        if row.VariantID != nil { v.ID, o = *row.VariantID, outcomes[*row.VariantID] }
+        a, err := NewArm(v, row.ID, o, metric)
+        if err != nil { return nil, err }
+        arms = append(arms, a)
+    }
+    weights := map[string]float64{}
+    for i, w := range ArmWeights(arms, r, 0) { weights[arms[i].ArmID] = w }
+    return weights, repo.SetArmWeights(ctx, weights)
+}
+
+// UpdateRunningArmWeights runs UpdateArmWeights for every running experiment
+// of orgID (every org when empty) and returns the ids of those updated. An
+// experiment that fails does not stop the others; the errors are joined.
+func UpdateRunningArmWeights(ctx context.Context, repo calendarrepo.Repository, orgID, metric string) ([]string, error) {
+    exps, err := repo.RunningExperiments(ctx, orgID)
+    if err != nil { return nil, fmt.Errorf("load running experiments: %w", err) }
+    var updated []string
+    var errs []error
+    for _, e := range exps {
+        if _, err := UpdateArmWeights(ctx, repo, e.OrgID, e.ID, metric, nil); err != nil {
+            errs = append(errs, fmt.Errorf("experiment %s: %w", e.ID, err))
+            continue
+        }
+        updated = append(updated, e.ID)
+    }
+    return updated, errors.Join(errs...)
+}
+
+// betaSample draws from Beta(a, b) as X/(X+Y) with X ~ Gamma(a), Y ~ Gamma(b).
+func betaSample(r *rand.Rand, a, b float64) float64 {
+    x, y := gammaSample(r, a), gammaSample(r, b)
+    return x / (x + y)
+}
+
+// gammaSample draws from Gamma(k, 1) for k >= 1 (Marsaglia and Tsang).
+func gammaSample(r *rand.Rand, k float64) float64 {
+    d := k - 1.0/3
+    c := 1 / math.Sqrt(9*d)
+    for {
+        x := r.NormFloat64()
+        v := 1 + c*x
+        if v <= 0 { continue }
+        v = v * v * v
+        u := r.Float64()
+        if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) { return d * v }
+    }
+}
@@ -0,0 +1,80 @@
+package generator
+
+import (
+    "math"
+    "math/rand"
+    "testing"
+
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+)
+
+func testArms() []Arm {
+    return []Arm{
+        {Variant: Variant{ID: "control", IsControl: true}, Posts: 20, Successes: 200, Trials: 10000}, // 2%
+        {Variant: Variant{ID: "hook"}, Posts: 20, Successes: 500, Trials: 10000},                      // 5%
+        {Variant: Variant{ID: "new"}},
+    }
+}
+
+func TestThompsonFavorsTheBetterArm(t *testing.T) {
+    b := Bandit{Policy: BanditThompson, Rand: rand.New(rand.NewSource(1))}
+    wins := map[string]int{}
+    for i := 0; i < 1000; i++ {
+        ranked, err := b.Rank(testArms()[:2])
+        if err != nil { t.Fatal(err) }
+        wins[ranked[0].Variant.ID]++
+    }
+    if wins["hook"] < 990 { t.Fatalf("wins = %v", wins) }
+
+    // An arm without data is still explored.
+    wins = map[string]int{}
+    for i := 0; i < 1000; i++ {
+        ranked, _ := b.Rank(testArms())
+        wins[ranked[0].Variant.ID]++
+    }
+    if wins["new"] == 0 || wins["hook"] == 0 { t.Fatalf("wins = %v", wins) }
+}
+
+func TestUCBExploresThenExploits(t *testing.T) {
+    b := Bandit{Policy: BanditUCB}
+    ranked, err := b.Rank(testArms())
+    if err != nil { t.Fatal(err) }
+    if ranked[0].Variant.ID != "new" || ranked[1].Variant.ID != "hook" { t.Fatalf("ranked = %+v", ranked) }
+    if _, err := (Bandit{Policy: "greedy"}).Rank(nil); err == nil { t.Fatal("expected an unknown policy error") }
+}
+
+func TestArmWeights(t *testing.T) {
+    w := ArmWeights(testArms()[:2], rand.New(rand.NewSource(1)), 2000)
+    if math.Abs(w[0]+w[1]-1) > 1e-9 || w[1] < 0.99 { t.Fatalf("weights = %v", w) }
+    even := ArmWeights([]Arm{{}, {}}, rand.New(rand.NewSource(1)), 4000)
+    if math.Abs(even[0]-0.5) > 0.05 { t.Fatalf("weights of equal arms = %v", even) }
+}
+
+func TestNewArmRewards(t *testing.T) {
+    o := calendarrepo.VariantOutcome{Posts: 3, Impressions: 100, Likes: 5, Comments: 1, Shares: 1, Saves: 1, Clicks: 2, Conversions: 1}
+    for metric, want := range map[string]float64{"": 10, RewardCTR: 2, RewardConversion: 1} {
+        a, err := NewArm(Variant{ID: "v"}, "", o, metric)
+        if err != nil { t.Fatal(err) }
+        if a.Successes != want || a.Trials != 100 { t.Fatalf("%s: %+v", metric, a) }
+    }
+    // Without impressions the trials are at least the successes.
+    if a, _ := NewArm(Variant{}, "", calendarrepo.VariantOutcome{Likes: 4}, ""); a.Trials != 4 { t.Fatalf("trials = %v", a.Trials) }
+    if _, err := NewArm(Variant{}, "", o, "views"); err == nil { t.Fatal("expected an unknown metric error") }
+}
+
+func TestVariantSelectorPick(t *testing.T) {
+    vs := []Variant{{ID: "v1", IsControl: true}, {ID: "v2"}, {ID: "v3"}}
+    got, err := (&variantSelector{n: 2}).pick(vs)
+    if err != nil { t.Fatal(err) }
+    if len(got) != 2 || got[0].Variant.ID != "v1" || got[1].Variant.ID != "v2" { t.Fatalf("default pick = %+v", got) }
+
+    s := &variantSelector{
+        bandit:   Bandit{Policy: BanditUCB},
+        n:        1,
+        arms:     map[string]string{"v2": "arm2", "v3": "arm3"},
+        outcomes: map[string]calendarrepo.VariantOutcome{"v2": {Posts: 4, Impressions: 400, Likes: 8}, "v3": {Posts: 4, Impressions: 400, Likes: 40}},
+    }
+    got, err = s.pick(vs)
+    if err != nil { t.Fatal(err) }
+    if len(got) != 1 || got[0].Variant.ID != "v3" || got[0].ArmID != "arm3" { t.Fatalf("bandit pick = %+v", got) }
+}
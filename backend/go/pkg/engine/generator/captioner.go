@@ -1,54 +1,26 @@
 package generator
 
 import (
-    "regexp"
     "strings"
+
+    "github.com/bitesinbyte/ferret/pkg/engine/caption"
 )
 
-var nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+// CompileCaption compiles a variant into a caption for platform: hook,
+// body and CTA with the variant's hashtags and topic-derived ones, within
+// the platform's length, hashtag and mention rules (see caption.Compile).
+func CompileCaption(platform, topic string, v Variant) caption.Result {
+    return caption.Compile(platform, caption.Input{Hook: v.Title, Body: v.Body, CTA: v.CTA, Topic: topic, Hashtags: v.Hashtags})
+}
 
 // CaptionFor builds a platform-specific caption from variant metadata and topic.
-// Returns caption and a space-separated hashtag string.
+// Returns caption and the space-separated hashtag line it ends with.
 func CaptionFor(platform, topic string, v Variant) (string, string) {
-    tags := MakeTags(topic)
-    title := strings.TrimSpace(v.Title)
-    cta := strings.TrimSpace(v.CTA)
-    switch strings.ToLower(platform) {
-    case "linkedin":
-        caption := joinNonEmpty([]string{title, cta}, "\n\n")
-        return caption, tags
-    case "twitter":
-        base := joinNonEmpty([]string{title, tags}, " \n")
-        if len(base) > 260 { base = base[:260] }
-        return base, ""
-    case "instagram":
-        caption := joinNonEmpty([]string{title, tags, cta}, "\n\n")
-        return caption, tags
-    default:
-        caption := joinNonEmpty([]string{title, cta}, " \n")
-        return caption, tags
-    }
+    r := CompileCaption(platform, topic, v)
+    return r.Text, r.Hashtags
 }
 
-// MakeTags builds up to 3 hashtags from topic words.
+// MakeTags builds up to 3 hashtags from topic words, skipping stopwords.
 func MakeTags(topic string) string {
-    t := strings.ToLower(topic)
-    t = nonWord.ReplaceAllString(t, " ")
-    fields := strings.Fields(t)
-    out := make([]string, 0, 3)
-    for _, w := range fields {
-        if w == "" { continue }
-        out = append(out, "#"+strings.TrimSpace(w))
-        if len(out) >= 3 { break }
-    }
-    return strings.Join(out, " ")
+    return strings.Join(caption.TopicTags(topic, 3), " ")
 }
-
-func joinNonEmpty(parts []string, sep string) string {
-    out := make([]string, 0, len(parts))
-    for _, p := range parts {
-        if strings.TrimSpace(p) != "" { out = append(out, p) }
-    }
-    return strings.Join(out, sep)
-}
-
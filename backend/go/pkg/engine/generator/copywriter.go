@@ -0,0 +1,216 @@
+package generator
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+    "github.com/bitesinbyte/ferret/pkg/models"
+)
+
+// CopyRequest asks for post copy on a topic in an ICP's brand voice.
+type CopyRequest struct {
+    OrgID         string
+    UserID        string // optional; recorded on the generation
+    ContentItemID string // optional; recorded on the generation and its variants
+    Topic         string
+    Platform      string
+    Brand         calendarrepo.Brand // see Repository.ICPBrand
+    Variants      int                // variants to write (default 3)
+    Notes         string             // extra instructions, e.g. an offer or a link
+}
+
+// CopyVariant is one generated hook, caption and call to action.
+type CopyVariant struct {
+    ID       string   `json:"id,omitempty"` // ai_variants.id once persisted
+    Hook     string   `json:"hook"`
+    Caption  string   `json:"caption"`
+    CTA      string   `json:"cta"`
+    Hashtags []string `json:"hashtags,omitempty"`
+}
+
+// CopyResult is the outcome of GenerateCopy.
+type CopyResult struct {
+    GenerationID string // ai_generations.id; empty when not persisted
+    Model        string
+    Variants     []CopyVariant
+}
+
+// PlanVariants converts the copy into planner variants (hook as the title);
+// persisted variants keep their ai_variants id, so experiment arms can
+// point at them.
+func (r CopyResult) PlanVariants() []Variant {
+    out := make([]Variant, 0, len(r.Variants))
+    for i, v := range r.Variants {
+        id := v.ID
+        if id == "" { id = fmt.Sprintf("gen_v%d", i+1) }
+        out = append(out, Variant{ID: id, Title: v.Hook, Body: v.Caption, CTA: v.CTA, Hashtags: v.Hashtags})
+    }
+    return out
+}
+
+// LLMGenerator writes captions, hook variants, CTAs and DMs with a
+// TextGenerator. With a Repo every GenerateCopy call, failed or not, is
+// recorded in ai_generations and its variants in ai_variants.
+type LLMGenerator struct {
+    LLM         TextGenerator
+    Model       string   // empty uses the TextGenerator's default
+    Temperature *float64 // empty uses the provider's default
+    Repo        *calendarrepo.Repository
+}
+
+// NewLLMGeneratorFromEnv wires an OpenAIClient configured from the
+// environment (see NewOpenAIClientFromEnv). repo may be nil.
+func NewLLMGeneratorFromEnv(repo *calendarrepo.Repository) (*LLMGenerator, error) {
+    c, err := NewOpenAIClientFromEnv()
+    if err != nil { return nil, err }
+    return &LLMGenerator{LLM: c, Model: c.Model, Repo: repo}, nil
+}
+
+// GenerateCopy asks the model for req.Variants distinct variants as JSON.
+func (g *LLMGenerator) GenerateCopy(ctx context.Context, req CopyRequest) (CopyResult, error) {
+    if strings.TrimSpace(req.Topic) == "" { return CopyResult{}, fmt.Errorf("generate copy: empty topic") }
+    if req.Variants <= 0 { req.Variants = 3 }
+    msgs := copyMessages(req)
+    comp, err := g.LLM.Complete(ctx, CompletionRequest{Model: g.Model, Messages: msgs, Temperature: g.Temperature, JSON: true})
+    res := CopyResult{Model: comp.Model}
+    if res.Model == "" { res.Model = g.Model }
+    if err == nil {
+        res.Variants, err = parseCopy(comp.Text)
+        if err == nil && len(res.Variants) > req.Variants { res.Variants = res.Variants[:req.Variants] }
+    }
+    if g.Repo != nil {
+        // A failed generation is still recorded; its error wins over a save error.
+        if serr := g.save(ctx, req, msgs, comp.Text, &res, err); serr != nil && err == nil {
+            return res, fmt.Errorf("generate copy: save generation: %w", serr)
+        }
+    }
+    if err != nil { return res, fmt.Errorf("generate copy: %w", err) }
+    return res, nil
+}
+
+// GenerateDM fills {{name}} placeholders of prompt from variables and asks
+// the model for a short direct message.
+func (g *LLMGenerator) GenerateDM(ctx context.Context, prompt string, variables map[string]string) (string, error) {
+    keys := sortedKeys(variables)
+    var ctxLines []string
+    for _, k := range keys {
+        prompt = strings.ReplaceAll(prompt, "{{"+k+"}}", variables[k])
+        ctxLines = append(ctxLines, k+": "+variables[k])
+    }
+    user := prompt
+    if len(ctxLines) > 0 { user += "\n\nContext:\n" + strings.Join(ctxLines, "\n") }
+    comp, err := g.LLM.Complete(ctx, CompletionRequest{Model: g.Model, Temperature: g.Temperature, Messages: []Message{
+        {Role: "system", Content: "You write short, friendly direct messages replying to social media comments. Reply with the message text only."},
+        {Role: "user", Content: user},
+    }})
+    if err != nil { return "", fmt.Errorf("generate dm: %w", err) }
+    text := strings.TrimSpace(comp.Text)
+    if text == "" { return "", fmt.Errorf("generate dm: empty response") }
+    return text, nil
+}
+
+func (g *LLMGenerator) save(ctx context.Context, req CopyRequest, msgs []Message, output string, res *CopyResult, genErr error) error {
+    params := map[string]any{"topic": req.Topic, "platform": req.Platform, "variants": req.Variants, "icp_id": req.Brand.ICPID}
+    if g.Temperature != nil { params["temperature"] = *g.Temperature }
+    gen := models.AIGeneration{ID: randomID("gen_"), OrgID: req.OrgID, Model: res.Model, OutputText: output, Parameters: params, Status: "succeeded"}
+    if gen.Model == "" { gen.Model = "default" }
+    if req.UserID != "" { gen.UserID = &req.UserID }
+    if req.ContentItemID != "" { gen.ContentItemID = &req.ContentItemID }
+    var parts []string
+    for _, m := range msgs { parts = append(parts, m.Role+": "+m.Content) }
+    gen.Prompt = strings.Join(parts, "\n\n")
+    if genErr != nil {
+        gen.Status, gen.ErrorMessage = "failed", genErr.Error()
+        return g.Repo.SaveGeneration(ctx, gen, nil)
+    }
+    gen.OutputJSON = map[string]any{"variants": res.Variants}
+    rows := make([]models.AIVariant, len(res.Variants))
+    for i := range res.Variants {
+        res.Variants[i].ID = randomID("var_")
+        rows[i] = models.AIVariant{ID: res.Variants[i].ID, ContentItemID: gen.ContentItemID, VariantIndex: i, Payload: res.Variants[i]}
+    }
+    if err := g.Repo.SaveGeneration(ctx, gen, rows); err != nil {
+        for i := range res.Variants { res.Variants[i].ID = "" }
+        return err
+    }
+    res.GenerationID = gen.ID
+    return nil
+}
+
+// copyMessages builds the system prompt from the brand and the user prompt
+// from the request.
+func copyMessages(req CopyRequest) []Message {
+    var sys strings.Builder
+    sys.WriteString("You are a social media copywriter")
+    if req.Brand.Name != "" { fmt.Fprintf(&sys, " for the audience profile %q", req.Brand.Name) }
+    sys.WriteString(". Write in the brand voice and follow the guidelines.\n")
+    if s := describe(req.Brand.BrandVoice); s != "" { sys.WriteString("\nBrand voice:\n" + s) }
+    if g := strings.TrimSpace(req.Brand.Guidelines); g != "" { sys.WriteString("\nGuidelines:\n" + g + "\n") }
+    if s := describe(req.Brand.ContentPillars); s != "" { sys.WriteString("\nContent pillars:\n" + s) }
+    sys.WriteString(`
+Respond with a JSON object only: {"variants":[{"hook":"...","caption":"...","cta":"...","hashtags":["..."]}]}.
+The hook is the opening line, the caption the body after it, the cta one call to action; hashtags have no '#'.`)
+
+    user := fmt.Sprintf("Write %d distinct variants with different hooks about: %s", req.Variants, strings.TrimSpace(req.Topic))
+    if req.Platform != "" { user += fmt.Sprintf("\nPlatform: %s (respect its length limits and conventions)", req.Platform) }
+    if n := strings.TrimSpace(req.Notes); n != "" { user += "\nNotes: " + n }
+    return []Message{{Role: "system", Content: sys.String()}, {Role: "user", Content: user}}
+}
+
+// describe renders a JSON object as "- key: value" lines in key order.
+func describe(m map[string]any) string {
+    var b strings.Builder
+    for _, k := range sortedKeys(m) {
+        v := m[k]
+        var s string
+        switch x := v.(type) {
+        case string:
+            s = x
+        case []any:
+            items := make([]string, 0, len(x))
+            for _, it := range x { items = append(items, fmt.Sprint(it)) }
+            s = strings.Join(items, ", ")
+        default:
+            raw, _ := json.Marshal(x)
+            s = string(raw)
+        }
+        if strings.TrimSpace(s) != "" { fmt.Fprintf(&b, "- %s: %s\n", k, s) }
+    }
+    return b.String()
+}
+
+// parseCopy decodes the model's JSON, tolerating code fences and prose
+// around the object, and drops variants without a hook or caption.
+func parseCopy(text string) ([]CopyVariant, error) {
+    start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+    if start < 0 || end < start { return nil, fmt.Errorf("response is not JSON: %.200q", text) }
+    var out struct {
+        Variants []CopyVariant `json:"variants"`
+    }
+    if err := json.Unmarshal([]byte(text[start:end+1]), &out); err != nil { return nil, fmt.Errorf("decode variants: %w", err) }
+    var vs []CopyVariant
+    for _, v := range out.Variants {
+        v.ID = ""
+        v.Hook, v.Caption, v.CTA = strings.TrimSpace(v.Hook), strings.TrimSpace(v.Caption), strings.TrimSpace(v.CTA)
+        if v.Hook == "" && v.Caption == "" { continue }
+        tags := v.Hashtags[:0]
+        for _, t := range v.Hashtags {
+            if t = strings.TrimLeft(strings.TrimSpace(t), "#"); t != "" { tags = append(tags, t) }
+        }
+        v.Hashtags = tags
+        vs = append(vs, v)
+    }
+    if len(vs) == 0 { return nil, fmt.Errorf("response has no variants") }
+    return vs, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m { keys = append(keys, k) }
+    sort.Strings(keys)
+    return keys
+}
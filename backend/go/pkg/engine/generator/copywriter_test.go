@@ -0,0 +1,118 @@
+package generator
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+)
+
+// fakeLLM serves /chat/completions with reply and records the last request.
+func fakeLLM(t *testing.T, status int, reply string) (*httptest.Server, *chatRequest, *http.Header) {
+    t.Helper()
+    var got chatRequest
+    var hdr http.Header
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/v1/chat/completions" || r.Method != http.MethodPost { t.Errorf("unexpected %s %s", r.Method, r.URL.Path) }
+        hdr = r.Header.Clone()
+        if err := json.NewDecoder(r.Body).Decode(&got); err != nil { t.Error(err) }
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(status)
+        if status != http.StatusOK {
+            _, _ = w.Write([]byte(reply))
+            return
+        }
+        _ = json.NewEncoder(w).Encode(map[string]any{
+            "model":   "fake-model",
+            "choices": []any{map[string]any{"message": map[string]string{"role": "assistant", "content": reply}}},
+            "usage":   map[string]int{"prompt_tokens": 12, "completion_tokens": 34},
+        })
+    }))
+    t.Cleanup(srv.Close)
+    return srv, &got, &hdr
+}
+
+func TestLLMGeneratorGenerateCopy(t *testing.T) {
+    reply := "Here you go:\n```json\n" + `{"variants":[
+      {"hook":"Stop guessing your posting times","caption":"Data beats gut feeling.","cta":"Grab the checklist","hashtags":["#growth"," marketing"]},
+      {"hook":"","caption":"","cta":"dropped"},
+      {"hook":"3 hooks that doubled our CTR","caption":"Steal them.","cta":"Follow for more"},
+      {"hook":"One too many","caption":"x","cta":"y"}
+    ]}` + "\n```"
+    srv, got, hdr := fakeLLM(t, http.StatusOK, reply)
+    g := &LLMGenerator{LLM: &OpenAIClient{BaseURL: srv.URL + "/v1/", APIKey: "sk-test", Model: "local"}}
+
+    res, err := g.GenerateCopy(context.Background(), CopyRequest{
+        OrgID: "org_1", Topic: "posting times", Platform: "linkedin", Variants: 2,
+        Brand: calendarrepo.Brand{
+            Name:           "Founders",
+            BrandVoice:     map[string]any{"tone": "friendly", "style": "concise"},
+            Guidelines:     "No jargon.",
+            ContentPillars: map[string]any{"pillars": []any{"education", "case_study"}},
+        },
+    })
+    if err != nil { t.Fatal(err) }
+    if res.Model != "fake-model" || res.GenerationID != "" || len(res.Variants) != 2 { t.Fatalf("result = %+v", res) }
+    if v := res.Variants[0]; v.Hook != "Stop guessing your posting times" || v.CTA != "Grab the checklist" || strings.Join(v.Hashtags, ",") != "growth,marketing" {
+        t.Fatalf("variant = %+v", v)
+    }
+    if res.Variants[1].Hook != "3 hooks that doubled our CTR" { t.Fatalf("variant 2 = %+v", res.Variants[1]) }
+
+    if hdr.Get("Authorization") != "Bearer sk-test" { t.Errorf("authorization = %q", hdr.Get("Authorization")) }
+    if got.Model != "local" || got.ResponseFormat == nil || got.ResponseFormat.Type != "json_object" || len(got.Messages) != 2 { t.Fatalf("request = %+v", got) }
+    for _, want := range []string{`"Founders"`, "- style: concise\n- tone: friendly", "No jargon.", "- pillars: education, case_study"} {
+        if !strings.Contains(got.Messages[0].Content, want) { t.Errorf("system prompt lacks %q:\n%s", want, got.Messages[0].Content) }
+    }
+    if u := got.Messages[1].Content; !strings.Contains(u, "Write 2 distinct variants") || !strings.Contains(u, "Platform: linkedin") { t.Errorf("user prompt = %q", u) }
+
+    pv := res.PlanVariants()
+    if len(pv) != 2 || pv[0].ID != "gen_v1" || pv[0].Title != res.Variants[0].Hook || pv[0].Body != "Data beats gut feeling." { t.Fatalf("plan variants = %+v", pv) }
+}
+
+func TestLLMGeneratorErrors(t *testing.T) {
+    srv, _, _ := fakeLLM(t, http.StatusTooManyRequests, `{"error":{"message":"rate limited"}}`)
+    g := &LLMGenerator{LLM: &OpenAIClient{BaseURL: srv.URL + "/v1"}}
+    if _, err := g.GenerateCopy(context.Background(), CopyRequest{Topic: "x"}); err == nil || !strings.Contains(err.Error(), "429") || !strings.Contains(err.Error(), "rate limited") {
+        t.Fatalf("err = %v", err)
+    }
+
+    srv, _, _ = fakeLLM(t, http.StatusOK, "sorry, I can't do that")
+    g = &LLMGenerator{LLM: &OpenAIClient{BaseURL: srv.URL + "/v1"}}
+    if _, err := g.GenerateCopy(context.Background(), CopyRequest{Topic: "x"}); err == nil { t.Fatal("expected an error for a non-JSON reply") }
+    if _, err := g.GenerateCopy(context.Background(), CopyRequest{}); err == nil { t.Fatal("expected an error for an empty topic") }
+}
+
+func TestLLMGeneratorGenerateDM(t *testing.T) {
+    srv, got, hdr := fakeLLM(t, http.StatusOK, "  Hi @ana, sending the guide now!  ")
+    g := &LLMGenerator{LLM: &OpenAIClient{BaseURL: srv.URL + "/v1"}, Model: "m"}
+    text, err := g.GenerateDM(context.Background(), "Thank {{username}} for asking about the guide.", map[string]string{"username": "@ana"})
+    if err != nil { t.Fatal(err) }
+    if text != "Hi @ana, sending the guide now!" { t.Fatalf("text = %q", text) }
+    if hdr.Get("Authorization") != "" || got.ResponseFormat != nil { t.Fatalf("request = %+v, auth %q", got, hdr.Get("Authorization")) }
+    if u := got.Messages[1].Content; !strings.HasPrefix(u, "Thank @ana for asking") || !strings.Contains(u, "username: @ana") { t.Fatalf("user prompt = %q", u) }
+}
+
+func TestNewOpenAIClientFromEnv(t *testing.T) {
+    t.Setenv("LLM_BASE_URL", "")
+    t.Setenv("LLM_API_KEY", "")
+    t.Setenv("OPENROUTER_API_KEY", "")
+    if _, err := NewOpenAIClientFromEnv(); err != ErrNoLLM { t.Fatalf("err = %v", err) }
+
+    t.Setenv("OPENROUTER_API_KEY", "or-key")
+    c, err := NewOpenAIClientFromEnv()
+    if err != nil || c.BaseURL != OpenRouterBaseURL || c.APIKey != "or-key" { t.Fatalf("client = %+v (%v)", c, err) }
+
+    t.Setenv("LLM_BASE_URL", OllamaBaseURL+"/")
+    t.Setenv("LLM_MODEL", "llama3.1")
+    c, err = NewOpenAIClientFromEnv()
+    if err != nil || c.BaseURL != OllamaBaseURL || c.Model != "llama3.1" || c.Headers != nil { t.Fatalf("client = %+v (%v)", c, err) }
+}
+
+func TestNoopGeneratorCopy(t *testing.T) {
+    res, err := NoopGenerator{}.GenerateCopy(context.Background(), CopyRequest{Topic: "ai marketing", Variants: 2})
+    if err != nil || len(res.Variants) != 2 || !strings.Contains(res.Variants[0].Hook, "ai marketing") { t.Fatalf("res = %+v (%v)", res, err) }
+}
@@ -1,15 +1,25 @@
 package generator
 
-import "context"
+import (
+    "context"
+    "fmt"
+    "strings"
+)
 
-// AIMLGenerator defines the minimal interface to produce DM text
-// using the local ai-ml-models providers.
-// Implementations can shell out to Python or call a local HTTP service.
+// AIMLGenerator produces DM text and post copy. LLMGenerator implements it
+// over any OpenAI-compatible endpoint; other implementations can shell out
+// to the Python ai-ml-models providers or call a local HTTP service.
 type AIMLGenerator interface {
     GenerateDM(ctx context.Context, prompt string, variables map[string]string) (string, error)
+    GenerateCopy(ctx context.Context, req CopyRequest) (CopyResult, error)
 }
 
-// NoopGenerator is a fallback that returns a static message.
+var (
+    _ AIMLGenerator = NoopGenerator{}
+    _ AIMLGenerator = (*LLMGenerator)(nil)
+)
+
+// NoopGenerator is a fallback that returns static text.
 type NoopGenerator struct{}
 
 func (NoopGenerator) GenerateDM(ctx context.Context, prompt string, variables map[string]string) (string, error) {
@@ -19,3 +29,18 @@ func (NoopGenerator) GenerateDM(ctx context.Context, prompt string, variables ma
     return "Thanks for your comment! I’ll DM you the details shortly.", nil
 }
 
+
+// GenerateCopy returns templated variants built from the topic.
+func (NoopGenerator) GenerateCopy(ctx context.Context, req CopyRequest) (CopyResult, error) {
+    _ = ctx
+    topic := strings.TrimSpace(req.Topic)
+    if topic == "" { return CopyResult{}, fmt.Errorf("generate copy: empty topic") }
+    hooks := []string{"What nobody tells you about %s", "%s in 3 steps", "The biggest %s mistake we see"}
+    n := req.Variants
+    if n <= 0 { n = 3 }
+    res := CopyResult{Model: "noop"}
+    for i := 0; i < n && i < len(hooks); i++ {
+        res.Variants = append(res.Variants, CopyVariant{Hook: fmt.Sprintf(hooks[i], topic), CTA: "Follow for more"})
+    }
+    return res, nil
+}
@@ -7,7 +7,9 @@ import (
 
 // CompileToGo generates a simple Go program from the pseudo workflow.
 func CompileToGo(pseudoPath, outPath string) error {
-    wf := ParsePseudo(pseudoPath)
+    f, err := LoadPseudo(pseudoPath)
+    if err != nil { return err }
+    wf := f.Flat()
     var code strings.Builder
 
     code.WriteString(fmt.Sprintf("// Auto-generated workflow: %s\n", wf.Name))
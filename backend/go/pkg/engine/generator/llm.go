@@ -0,0 +1,148 @@
+package generator
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "strings"
+    "time"
+)
+
+// Default endpoints of OpenAI-compatible servers (LLM_BASE_URL).
+const (
+    OpenRouterBaseURL = "https://openrouter.ai/api/v1"
+    OllamaBaseURL     = "http://localhost:11434/v1"
+    LlamaCppBaseURL   = "http://localhost:8080/v1"
+)
+
+// Message is one chat message.
+type Message struct {
+    Role    string `json:"role"` // system, user or assistant
+    Content string `json:"content"`
+}
+
+// CompletionRequest is a provider-agnostic chat completion request; zero
+// values use the provider's defaults.
+type CompletionRequest struct {
+    Model       string
+    Messages    []Message
+    Temperature *float64
+    MaxTokens   int
+    JSON        bool // ask for a JSON object response
+}
+
+// Completion is the generated text of a CompletionRequest.
+type Completion struct {
+    Model            string
+    Text             string
+    PromptTokens     int
+    CompletionTokens int
+}
+
+// TextGenerator completes chat prompts. OpenAIClient talks to any
+// OpenAI-compatible endpoint; tests can substitute a fake.
+type TextGenerator interface {
+    Complete(ctx context.Context, req CompletionRequest) (Completion, error)
+}
+
+// ErrNoLLM is returned by NewOpenAIClientFromEnv when no endpoint is configured.
+var ErrNoLLM = errors.New("no LLM configured: set LLM_BASE_URL or an API key (LLM_API_KEY, OPENROUTER_API_KEY)")
+
+// OpenAIClient calls POST {BaseURL}/chat/completions. It works with
+// OpenRouter, OpenAI and local llama.cpp or Ollama servers.
+type OpenAIClient struct {
+    BaseURL string
+    APIKey  string // sent as a Bearer token when set; local servers need none
+    Model   string // default model of requests without one
+    Headers map[string]string
+    HTTP    *http.Client // default: 60s timeout
+}
+
+// NewOpenAIClientFromEnv configures a client from LLM_BASE_URL, LLM_API_KEY
+// (or OPENROUTER_API_KEY) and LLM_MODEL. Without LLM_BASE_URL an API key
+// selects OpenRouter; with neither it returns ErrNoLLM.
+func NewOpenAIClientFromEnv() (*OpenAIClient, error) {
+    c := &OpenAIClient{
+        BaseURL: strings.TrimRight(os.Getenv("LLM_BASE_URL"), "/"),
+        APIKey:  os.Getenv("LLM_API_KEY"),
+        Model:   os.Getenv("LLM_MODEL"),
+    }
+    if c.APIKey == "" { c.APIKey = os.Getenv("OPENROUTER_API_KEY") }
+    if c.BaseURL == "" {
+        if c.APIKey == "" { return nil, ErrNoLLM }
+        c.BaseURL = OpenRouterBaseURL
+    }
+    if c.BaseURL == OpenRouterBaseURL {
+        c.Headers = map[string]string{"X-Title": "ferret"}
+    }
+    return c, nil
+}
+
+type chatRequest struct {
+    Model          string          `json:"model,omitempty"`
+    Messages       []Message       `json:"messages"`
+    Temperature    *float64        `json:"temperature,omitempty"`
+    MaxTokens      int             `json:"max_tokens,omitempty"`
+    ResponseFormat *responseFormat `json:"response_format,omitempty"`
+}
+
+type responseFormat struct {
+    Type string `json:"type"`
+}
+
+type chatResponse struct {
+    Model   string `json:"model"`
+    Choices []struct {
+        Message Message `json:"message"`
+    } `json:"choices"`
+    Usage struct {
+        PromptTokens     int `json:"prompt_tokens"`
+        CompletionTokens int `json:"completion_tokens"`
+    } `json:"usage"`
+    Error *struct {
+        Message string `json:"message"`
+    } `json:"error"`
+}
+
+// Complete sends req and returns the first choice.
+func (c *OpenAIClient) Complete(ctx context.Context, req CompletionRequest) (Completion, error) {
+    body := chatRequest{Model: req.Model, Messages: req.Messages, Temperature: req.Temperature, MaxTokens: req.MaxTokens}
+    if body.Model == "" { body.Model = c.Model }
+    if req.JSON { body.ResponseFormat = &responseFormat{Type: "json_object"} }
+    buf, err := json.Marshal(body)
+    if err != nil { return Completion{}, err }
+    hr, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(c.BaseURL, "/")+"/chat/completions", bytes.NewReader(buf))
+    if err != nil { return Completion{}, fmt.Errorf("llm: create request: %w", err) }
+    hr.Header.Set("Content-Type", "application/json")
+    if c.APIKey != "" { hr.Header.Set("Authorization", "Bearer "+c.APIKey) }
+    for k, v := range c.Headers { hr.Header.Set(k, v) }
+
+    client := c.HTTP
+    if client == nil { client = &http.Client{Timeout: 60 * time.Second} }
+    resp, err := client.Do(hr)
+    if err != nil { return Completion{}, fmt.Errorf("llm: %w", err) }
+    defer resp.Body.Close()
+    raw, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+    if err != nil { return Completion{}, fmt.Errorf("llm: read response: %w", err) }
+    var out chatResponse
+    jerr := json.Unmarshal(raw, &out)
+    if resp.StatusCode/100 != 2 {
+        msg := strings.TrimSpace(string(raw))
+        if jerr == nil && out.Error != nil { msg = out.Error.Message }
+        if len(msg) > 500 { msg = msg[:500] }
+        return Completion{}, fmt.Errorf("llm: %s: %s", resp.Status, msg)
+    }
+    if jerr != nil { return Completion{}, fmt.Errorf("llm: decode response: %w", jerr) }
+    if out.Error != nil { return Completion{}, fmt.Errorf("llm: %s", out.Error.Message) }
+    if len(out.Choices) == 0 { return Completion{}, fmt.Errorf("llm: response has no choices") }
+    if out.Model == "" { out.Model = body.Model }
+    return Completion{
+        Model: out.Model, Text: out.Choices[0].Message.Content,
+        PromptTokens: out.Usage.PromptTokens, CompletionTokens: out.Usage.CompletionTokens,
+    }, nil
+}
@@ -1,71 +1,495 @@
 package generator
 
 import (
+    "crypto/sha1"
     "encoding/json"
+    "errors"
     "fmt"
+    "regexp"
+    "sort"
+    "strconv"
     "strings"
 )
 
-// ExportToN8N converts pseudo workflow to a minimal n8n JSON.
+// n8nWorkflow is the part of an n8n workflow JSON that export writes and
+// import reads.
+type n8nWorkflow struct {
+    Name        string                                  `json:"name"`
+    Nodes       []n8nNode                               `json:"nodes"`
+    Connections map[string]map[string][][]n8nConnection `json:"connections"`
+    Settings    map[string]any                          `json:"settings,omitempty"`
+    Meta        map[string]any                          `json:"meta,omitempty"`
+}
+
+type n8nNode struct {
+    ID          string         `json:"id"`
+    Name        string         `json:"name"`
+    Type        string         `json:"type"`
+    TypeVersion float64        `json:"typeVersion"`
+    Position    [2]float64     `json:"position"`
+    WebhookID   string         `json:"webhookId,omitempty"`
+    Parameters  map[string]any `json:"parameters"`
+    Credentials map[string]any `json:"credentials,omitempty"`
+    OnError     string         `json:"onError,omitempty"`
+    Notes       string         `json:"notes,omitempty"`
+}
+
+type n8nConnection struct {
+    Node  string `json:"node"`
+    Type  string `json:"type"`
+    Index int    `json:"index"`
+}
+
+// n8n node types with a native equivalent.
+const (
+    n8nWebhook  = "n8n-nodes-base.webhook"
+    n8nSchedule = "n8n-nodes-base.scheduleTrigger"
+    n8nManual   = "n8n-nodes-base.manualTrigger"
+    n8nHTTP     = "n8n-nodes-base.httpRequest"
+    n8nSwitch   = "n8n-nodes-base.switch"
+    n8nMerge    = "n8n-nodes-base.merge"
+    n8nExecute  = "n8n-nodes-base.executeWorkflow"
+    n8nCode     = "n8n-nodes-base.code"
+)
+
+var n8nVersions = map[string]float64{
+    n8nWebhook: 2, n8nSchedule: 1.2, n8nManual: 1, n8nHTTP: 4.2,
+    n8nSwitch: 3, n8nMerge: 3, n8nExecute: 1.1, n8nCode: 2,
+}
+
+// HTTPNode is the node reference n8n httpRequest nodes import as; the EOWR
+// runtime registers it as a native HTTP call.
+const HTTPNode = "http/request"
+
+// Params prefixed n8n_ carry what the pseudo language has no syntax for, so
+// an imported workflow exports back unchanged. Runtime nodes ignore them.
+const (
+    n8nParamID          = "n8n_id"
+    n8nParamPosition    = "n8n_position"    // "x,y" on the canvas
+    n8nParamVersion     = "n8n_version"     // typeVersion when not the default
+    n8nParamParameters  = "n8n_params"      // JSON of parameters export would not write (null removes one)
+    n8nParamCredentials = "n8n_credentials" // JSON of the node's credential references
+    n8nParamNotes       = "n8n_notes"
+    n8nParamWebhookID   = "n8n_webhook_id"
+    n8nParamOnError     = "n8n_on_error"
+    n8nParamInputs      = "n8n_inputs" // JSON list: merge input order when not the connect order
+    n8nParamType        = "n8n_type"   // node type when it is not the one export would pick
+)
+
+// eowrNotes prefixes node notes holding the pseudo declaration of a node n8n
+// cannot express (engine actions, error handlers).
+const eowrNotes = "eowr: "
+
+// n8nUsing prefixes the using reference of imported n8n nodes that have no
+// native equivalent; the rest is the n8n node type.
+const n8nUsing = "n8n/"
+
+// ExportToN8N converts a pseudo workflow to an n8n workflow JSON: webhook,
+// schedule and manual triggers, httpRequest, switch (conditions become
+// rules), merge and executeWorkflow nodes, with on_error connections on the
+// error output. Engine actions become code nodes whose notes keep the
+// declaration. ImportFromN8N reverses it.
 func ExportToN8N(pseudoPath, outPath string) error {
-    wf := ParsePseudo(pseudoPath)
-    var nodes []map[string]any
-
-    for i, n := range wf.NodeMatches {
-        nType, nName := n[1], n[2]
-        node := map[string]any{
-            "id":   fmt.Sprintf("%s_%d", strings.ReplaceAll(nName, " ", "_"), i+1),
-            "name": nName,
-            "type": map[string]string{
-                "trigger":  "n8n-nodes-base.webhook",
-                "action":   "n8n-nodes-base.httpRequest",
-                "switch":   "n8n-nodes-base.switch",
-                "merge":    "n8n-nodes-base.merge",
-                "subflow":  "n8n-nodes-base.executeWorkflow",
-                "on_error": "n8n-nodes-base.code",
-            }[nType],
+    f, err := LoadPseudo(pseudoPath)
+    if err != nil { return err }
+    b, err := ToN8N(f)
+    if err != nil { return err }
+    return writeFile(outPath, b)
+}
+
+// ToN8N returns f as indented n8n workflow JSON.
+func ToN8N(f *PseudoFile) ([]byte, error) {
+    if f == nil || f.Workflow == nil { return nil, errors.New("n8n: no workflow declared") }
+    wf, err := toN8N(f.Workflow)
+    if err != nil { return nil, err }
+    return json.MarshalIndent(wf, "", "  ")
+}
+
+func toN8N(w *WorkflowDecl) (n8nWorkflow, error) {
+    wf := n8nWorkflow{
+        Name:        w.Name,
+        Connections: map[string]map[string][][]n8nConnection{},
+        Settings:    map[string]any{"executionOrder": "v1"},
+        Meta:        map[string]any{"eowrVersion": w.Version},
+    }
+    layout := n8nLayout(w)
+    inputs := mergeInputs(w)
+    for _, d := range w.Nodes {
+        ports, err := n8nPortsFor(w, d)
+        if err != nil { return wf, err }
+        n, err := n8nNodeFor(w, d, ports, inputs[d.Name], layout[d.Name])
+        if err != nil { return wf, err }
+        wf.Nodes = append(wf.Nodes, n)
+
+        var main [][]n8nConnection
+        for _, targets := range ports.main {
+            row := []n8nConnection{}
+            for _, t := range targets {
+                row = append(row, n8nConnection{Node: t, Type: "main", Index: inputIndex(inputs[t], d.Name)})
+            }
+            main = append(main, row)
         }
-        nodes = append(nodes, node)
+        if len(main) > 0 { wf.Connections[d.Name] = map[string][][]n8nConnection{"main": main} }
     }
+    return wf, nil
+}
+
+// n8nPorts lists the targets of each n8n output of a node: one output for
+// most nodes; for a switch one per rule and then the fallback. The error
+// output comes last when the node has on_error connections.
+type n8nPorts struct {
+    main     [][]string
+    rules    []Cond // switch rule conditions, one per leading output
+    fallback bool
+    onError  bool
+}
 
-    // Build simple connections map: source name -> targets
-    connections := map[string]any{}
-    for _, c := range wf.Connections {
-        from := c[1]
-        targetsRaw := c[2]
+func n8nPortsFor(w *WorkflowDecl, d *NodeDecl) (n8nPorts, error) {
+    var ports n8nPorts
+    var normal, fallback, errs []string
+    conditional := false
+    for _, c := range w.Connects {
+        if c.When != nil || c.Otherwise {
+            for _, from := range c.From { conditional = conditional || from.Name == d.Name }
+        }
+    }
+    for _, c := range w.Connects {
+        if !refersTo(c.From, d.Name) { continue }
         var targets []string
-        if strings.HasPrefix(targetsRaw, "[") {
-            // strip [ ] and split
-            inner := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(targetsRaw, "["), "]"))
-            if inner != "" {
-                parts := strings.Split(inner, ",")
-                for _, p := range parts {
-                    t := strings.Trim(strings.TrimSpace(p), "\"")
-                    if t != "" {
-                        targets = append(targets, t)
+        for _, t := range c.To {
+            if n := w.Node(t.Name); n != nil && n.Kind == "on_error" {
+                errs = append(errs, t.Name)
+                continue
+            }
+            targets = append(targets, t.Name)
+        }
+        if len(targets) == 0 { continue }
+        switch {
+        case d.Kind != "switch":
+            normal = append(normal, targets...)
+        case c.When != nil:
+            ports.rules = append(ports.rules, c.When)
+            ports.main = append(ports.main, targets)
+        case c.Otherwise:
+            fallback = append(fallback, targets...)
+        case conditional:
+            return ports, fmt.Errorf("%s: n8n: switch %q mixes conditional and unconditional connections", c.Pos, d.Name)
+        default:
+            // Payload routing: one rule per target on the route field.
+            for _, t := range targets {
+                ports.rules = append(ports.rules, Compare{Field: "route", Op: "==", Value: t})
+                ports.main = append(ports.main, []string{t})
+            }
+        }
+    }
+    if d.Kind != "switch" && (len(normal) > 0 || len(errs) > 0) { ports.main = append(ports.main, normal) }
+    if len(fallback) > 0 {
+        ports.fallback = true
+        ports.main = append(ports.main, fallback)
+    }
+    if len(errs) > 0 {
+        ports.onError = true
+        ports.main = append(ports.main, errs)
+    }
+    return ports, nil
+}
+
+func refersTo(refs []NodeRef, name string) bool {
+    for _, r := range refs {
+        if r.Name == name { return true }
+    }
+    return false
+}
+
+// mergeInputs lists each merge node's sources by n8n input index.
+func mergeInputs(w *WorkflowDecl) map[string][]string {
+    out := map[string][]string{}
+    for _, c := range w.Connects {
+        for _, t := range c.To {
+            n := w.Node(t.Name)
+            if n == nil || n.Kind != "merge" { continue }
+            for _, from := range c.From {
+                if indexOf(out[t.Name], from.Name) < 0 { out[t.Name] = append(out[t.Name], from.Name) }
+            }
+        }
+    }
+    for name, srcs := range out {
+        var order []string
+        if err := json.Unmarshal([]byte(w.Node(name).Params[n8nParamInputs]), &order); err == nil {
+            for _, s := range srcs {
+                if indexOf(order, s) < 0 { order = append(order, s) }
+            }
+            out[name] = order
+        }
+    }
+    return out
+}
+
+// inputIndex is the n8n input of a target that from connects to: its
+// position among a merge's inputs, else 0.
+func inputIndex(inputs []string, from string) int {
+    return max(indexOf(inputs, from), 0)
+}
+
+func indexOf(list []string, s string) int {
+    for i, x := range list {
+        if x == s { return i }
+    }
+    return -1
+}
+
+// n8nNodeFor converts one declaration. The n8n_ params override what the
+// declaration alone would produce.
+func n8nNodeFor(w *WorkflowDecl, d *NodeDecl, ports n8nPorts, inputs []string, pos [2]float64) (n8nNode, error) {
+    p := d.Params
+    n := n8nNode{Name: d.Name, Position: pos, Parameters: map[string]any{}}
+    switch {
+    case p[n8nParamType] != "":
+        n.Type = p[n8nParamType]
+    case d.Kind == "trigger" && d.At != "":
+        n.Type = n8nWebhook
+        n.Parameters["path"] = strings.TrimPrefix(d.At, "/")
+        if m := strings.ToUpper(p["method"]); m != "GET" {
+            if m == "" { m = "POST" }
+            n.Parameters["httpMethod"] = m
+        }
+        n.WebhookID = n8nID(w.Name, "webhook", d.Name)
+    case d.Kind == "trigger" && p["cron"] != "":
+        n.Type = n8nSchedule
+        n.Parameters["rule"] = map[string]any{"interval": []any{map[string]any{"field": "cronExpression", "expression": p["cron"]}}}
+    case d.Kind == "trigger":
+        // Queue triggers have no n8n node; the notes keep them.
+        n.Type = n8nManual
+        if len(stripN8NParams(d).Params) > 0 { n.Notes = eowrNotes + stripN8NParams(d).String() }
+    case d.Using == HTTPNode:
+        n.Type = n8nHTTP
+        if m := strings.ToUpper(p["method"]); m != "" && m != "GET" { n.Parameters["method"] = m }
+        n.Parameters["url"] = toN8NExpr(p["url"])
+        if p["headers"] != "" {
+            var h map[string]string
+            if err := json.Unmarshal([]byte(p["headers"]), &h); err != nil {
+                return n, fmt.Errorf("%s: n8n: %s %q: headers must be a JSON object: %v", d.Pos, d.Kind, d.Name, err)
+            }
+            keys := make([]string, 0, len(h))
+            for k := range h { keys = append(keys, k) }
+            sort.Strings(keys)
+            var list []any
+            for _, k := range keys { list = append(list, map[string]any{"name": k, "value": toN8NExpr(h[k])}) }
+            n.Parameters["sendHeaders"] = true
+            n.Parameters["headerParameters"] = map[string]any{"parameters": list}
+        }
+        if p["body"] != "" {
+            n.Parameters["sendBody"] = true
+            n.Parameters["specifyBody"] = "json"
+            n.Parameters["jsonBody"] = toN8NExpr(p["body"])
+        }
+        n.Parameters["options"] = map[string]any{}
+    case strings.HasPrefix(d.Using, n8nUsing):
+        n.Type = strings.TrimPrefix(d.Using, n8nUsing)
+    case d.Kind == "switch":
+        n.Type = n8nSwitch
+        var rules []any
+        for _, c := range ports.rules {
+            r, err := n8nRule(c)
+            if err != nil { return n, fmt.Errorf("%s: n8n: switch %q: %v", d.Pos, d.Name, err) }
+            rules = append(rules, r)
+        }
+        n.Parameters["rules"] = map[string]any{"values": rules}
+        if ports.fallback { n.Parameters["options"] = map[string]any{"fallbackOutput": "extra"} }
+    case d.Kind == "merge":
+        n.Type = n8nMerge
+        if len(inputs) > 2 { n.Parameters["numberInputs"] = float64(len(inputs)) }
+    case d.Kind == "subflow":
+        n.Type = n8nExecute
+        ref := d.At
+        if ref == "" { ref = d.Using }
+        n.Parameters["source"] = "localFile"
+        n.Parameters["workflowPath"] = ref
+    default:
+        n.Type = n8nCode
+        n.Parameters["jsCode"] = "// Runs natively in EOWR as " + d.Using + ".\nreturn $input.all();"
+        n.Notes = eowrNotes + stripN8NParams(d).String()
+    }
+    if d.Kind == "on_error" && n.Notes == "" { n.Notes = eowrNotes + stripN8NParams(d).String() }
+    if ports.onError { n.OnError = "continueErrorOutput" }
+    n.TypeVersion = n8nVersions[n.Type]
+    if n.TypeVersion == 0 { n.TypeVersion = 1 }
+    n.ID = n8nID(w.Name, "node", d.Name)
+    return n, applyN8NParams(&n, d)
+}
+
+func applyN8NParams(n *n8nNode, d *NodeDecl) error {
+    bad := func(key string, err error) error {
+        return fmt.Errorf("%s: n8n: %s %q: %s: %v", d.Pos, d.Kind, d.Name, key, err)
+    }
+    p := d.Params
+    if v, ok := p[n8nParamID]; ok { n.ID = v }
+    if v, ok := p[n8nParamPosition]; ok {
+        x, y, _ := strings.Cut(v, ",")
+        var err error
+        if n.Position[0], err = strconv.ParseFloat(strings.TrimSpace(x), 64); err != nil { return bad(n8nParamPosition, err) }
+        if n.Position[1], err = strconv.ParseFloat(strings.TrimSpace(y), 64); err != nil { return bad(n8nParamPosition, err) }
+    }
+    if v, ok := p[n8nParamVersion]; ok {
+        f, err := strconv.ParseFloat(v, 64)
+        if err != nil { return bad(n8nParamVersion, err) }
+        n.TypeVersion = f
+    }
+    if v, ok := p[n8nParamParameters]; ok {
+        var extra map[string]any
+        if err := json.Unmarshal([]byte(v), &extra); err != nil { return bad(n8nParamParameters, err) }
+        for k, x := range extra {
+            if x == nil {
+                delete(n.Parameters, k)
+                continue
+            }
+            n.Parameters[k] = x
+        }
+    }
+    if v, ok := p[n8nParamCredentials]; ok {
+        if err := json.Unmarshal([]byte(v), &n.Credentials); err != nil { return bad(n8nParamCredentials, err) }
+    }
+    if v, ok := p[n8nParamNotes]; ok { n.Notes = v }
+    if v, ok := p[n8nParamWebhookID]; ok { n.WebhookID = v }
+    if v, ok := p[n8nParamOnError]; ok { n.OnError = v }
+    return nil
+}
+
+// stripN8NParams returns d without the n8n_ params.
+func stripN8NParams(d *NodeDecl) *NodeDecl {
+    c := *d
+    c.Params = nil
+    for k, v := range d.Params {
+        if strings.HasPrefix(k, "n8n_") { continue }
+        if c.Params == nil { c.Params = map[string]string{} }
+        c.Params[k] = v
+    }
+    return &c
+}
+
+// n8nID derives a stable uuid-shaped id, so exports diff cleanly.
+func n8nID(parts ...string) string {
+    h := sha1.Sum([]byte(strings.Join(parts, "\x00")))
+    return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+// n8nLayout places nodes left to right by depth from the triggers, one row
+// per node at the same depth.
+func n8nLayout(w *WorkflowDecl) map[string][2]float64 {
+    depth := map[string]int{}
+    for range w.Nodes {
+        changed := false
+        for _, c := range w.Connects {
+            for _, from := range c.From {
+                for _, to := range c.To {
+                    if d := depth[from.Name] + 1; d > depth[to.Name] {
+                        depth[to.Name] = d
+                        changed = true
                     }
                 }
             }
-        } else {
-            targets = []string{strings.Trim(targetsRaw, "\"")}
-        }
-        // n8n format: { "main": [[ {"node": "Target"}, ... ]] }
-        var connRow []map[string]any
-        for _, t := range targets {
-            connRow = append(connRow, map[string]any{"node": t})
         }
-        connections[from] = map[string]any{"main": [][]map[string]any{connRow}}
+        if !changed { break }
+    }
+    rows := map[int]int{}
+    out := map[string][2]float64{}
+    for _, n := range w.Nodes {
+        d := depth[n.Name]
+        out[n.Name] = [2]float64{float64(240 + 220*d), float64(300 + 160*rows[d])}
+        rows[d]++
     }
+    return out
+}
+
+var pseudoTemplate = regexp.MustCompile(`\{\{\s*([A-Za-z_][\w.]*)\s*\}\}`)
 
-    out := map[string]any{
-        "name":        wf.Name,
-        "nodes":       nodes,
-        "connections": connections,
+// toN8NExpr turns {{field}} payload templates into an n8n expression.
+func toN8NExpr(s string) string {
+    if !pseudoTemplate.MatchString(s) { return s }
+    return "=" + pseudoTemplate.ReplaceAllString(s, "{{ $$json.$1 }}")
+}
+
+// n8nRule converts a switch condition to an n8n switch (v3) rule. n8n rules
+// combine their conditions with a single and/or.
+func n8nRule(c Cond) (map[string]any, error) {
+    combinator := ""
+    var terms []Compare
+    var flatten func(Cond) error
+    flatten = func(c Cond) error {
+        switch x := c.(type) {
+        case Compare:
+            terms = append(terms, x)
+        case Logical:
+            if combinator != "" && combinator != x.Op {
+                return fmt.Errorf("condition %s mixes and/or; an n8n rule has one combinator", c)
+            }
+            combinator = x.Op
+            if err := flatten(x.L); err != nil { return err }
+            return flatten(x.R)
+        default:
+            return fmt.Errorf("unsupported condition %s", c)
+        }
+        return nil
+    }
+    if err := flatten(c); err != nil { return nil, err }
+    if combinator == "" { combinator = "and" }
+    var conds []any
+    for _, t := range terms {
+        cond, err := n8nCondition(t)
+        if err != nil { return nil, err }
+        conds = append(conds, cond)
     }
+    return map[string]any{"conditions": map[string]any{
+        "options":    map[string]any{"caseSensitive": true, "leftValue": "", "typeValidation": "strict"},
+        "conditions": conds,
+        "combinator": combinator,
+    }}, nil
+}
 
-    b, err := json.MarshalIndent(out, "", "  ")
-    if err != nil {
-        return err
+func n8nCondition(c Compare) (map[string]any, error) {
+    op := func(typ, operation string) map[string]any {
+        return map[string]any{"type": typ, "operation": operation}
     }
-    return writeFile(outPath, b)
+    unary := func(typ, operation string) map[string]any {
+        o := op(typ, operation)
+        o["singleValue"] = true
+        return o
+    }
+    out := map[string]any{"leftValue": "={{ $json." + c.Field + " }}", "rightValue": ""}
+    negate := map[string]string{"==": "equals", "!=": "notEquals"}
+    if c.Op == "contains" {
+        out["operator"] = op("array", "contains")
+        if _, ok := c.Value.(string); ok { out["operator"] = op("string", "contains") }
+        out["rightValue"] = c.Value
+        return out, nil
+    }
+    switch v := c.Value.(type) {
+    case nil:
+        switch c.Op {
+        case "":
+            out["operator"] = unary("boolean", "true")
+        case "==":
+            out["operator"] = unary("string", "notExists")
+        case "!=":
+            out["operator"] = unary("string", "exists")
+        default:
+            return nil, fmt.Errorf("condition %s: null only compares with == or !=", c)
+        }
+    case bool:
+        if negate[c.Op] == "" { return nil, fmt.Errorf("condition %s: booleans only compare with == or !=", c) }
+        out["operator"] = op("boolean", negate[c.Op])
+        out["rightValue"] = v
+    case float64:
+        ops := map[string]string{"==": "equals", "!=": "notEquals", "<": "lt", "<=": "lte", ">": "gt", ">=": "gte"}
+        if ops[c.Op] == "" { return nil, fmt.Errorf("condition %s: %s does not apply to numbers in n8n", c, c.Op) }
+        out["operator"] = op("number", ops[c.Op])
+        out["rightValue"] = v
+    case string:
+        if negate[c.Op] == "" { return nil, fmt.Errorf("condition %s: n8n compares strings with ==, != or contains", c) }
+        out["operator"] = op("string", negate[c.Op])
+        out["rightValue"] = v
+    default:
+        return nil, fmt.Errorf("unsupported condition %s", c)
+    }
+    return out, nil
 }
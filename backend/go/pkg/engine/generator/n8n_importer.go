@@ -0,0 +1,499 @@
+package generator
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "reflect"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// ImportFromN8N converts the n8n workflow JSON at jsonPath to a .pseudo file
+// at outPath. Webhook, schedule and manual triggers, httpRequest, switch,
+// merge and executeWorkflow nodes map to native nodes; nodes an export wrote
+// for engine actions come back from their notes. Anything else becomes an
+// action using "n8n/<type>" that must be registered before it runs. The
+// returned warnings list what did not map cleanly.
+func ImportFromN8N(jsonPath, outPath string) ([]string, error) {
+    data, err := os.ReadFile(jsonPath)
+    if err != nil { return nil, err }
+    f, warnings, err := FromN8N(jsonPath, data)
+    if err != nil { return warnings, err }
+    return warnings, writeFile(outPath, []byte(FormatPseudo(f)))
+}
+
+// FromN8N parses n8n workflow JSON (named file in messages) into a pseudo
+// tree. What the pseudo language cannot say (ids, canvas positions,
+// credentials, extra parameters) is kept in n8n_ params, so ToN8N gives the
+// same workflow back.
+func FromN8N(file string, data []byte) (*PseudoFile, []string, error) {
+    var wf n8nWorkflow
+    if err := json.Unmarshal(data, &wf); err != nil { return nil, nil, fmt.Errorf("%s: %w", file, err) }
+    im := &n8nImport{wf: wf, byName: map[string]*n8nNode{}}
+    w := &WorkflowDecl{Pos: Pos{File: file, Line: 1, Col: 1}, Name: wf.Name, Version: "1.0"}
+    if w.Name == "" { w.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)) }
+    if v, ok := wf.Meta["eowrVersion"].(string); ok && v != "" { w.Version = v }
+
+    for i := range wf.Nodes {
+        n := &wf.Nodes[i]
+        if im.byName[n.Name] != nil { return nil, nil, fmt.Errorf("%s: two nodes named %q", file, n.Name) }
+        im.byName[n.Name] = n
+    }
+    normalIn, errIn := map[string]int{}, map[string]int{}
+    for _, n := range wf.Nodes {
+        errOut := im.errorOutput(n)
+        for i, row := range wf.Connections[n.Name]["main"] {
+            for _, c := range row {
+                if i == errOut {
+                    errIn[c.Node]++
+                } else {
+                    normalIn[c.Node]++
+                }
+            }
+        }
+    }
+    for i := range wf.Nodes {
+        n := &wf.Nodes[i]
+        d := im.node(n)
+        if errIn[n.Name] > 0 {
+            switch {
+            case normalIn[n.Name] > 0:
+                im.warn(n, "receives both error and regular connections; the error connections are dropped")
+            case d.Kind == "action":
+                d.Kind = "on_error"
+            case d.Kind != "on_error":
+                im.warn(n, "%s cannot handle errors; the error connections are dropped", d.Kind)
+            }
+        }
+        w.Nodes = append(w.Nodes, d)
+    }
+    for _, n := range wf.Nodes {
+        w.Connects = append(w.Connects, im.connects(n)...)
+    }
+    im.keepN8NOnly(w)
+    return &PseudoFile{Path: file, Workflow: w}, im.warnings, nil
+}
+
+type n8nImport struct {
+    wf       n8nWorkflow
+    byName   map[string]*n8nNode
+    warnings []string
+}
+
+func (im *n8nImport) warn(n *n8nNode, format string, args ...any) {
+    im.warnings = append(im.warnings, fmt.Sprintf("%s %q: ", n.Type, n.Name)+fmt.Sprintf(format, args...))
+}
+
+// errorOutput is the index of n's error output, or -1.
+func (im *n8nImport) errorOutput(n n8nNode) int {
+    if n.OnError != "continueErrorOutput" { return -1 }
+    return len(im.wf.Connections[n.Name]["main"]) - 1
+}
+
+func (im *n8nImport) node(n *n8nNode) *NodeDecl {
+    d := &NodeDecl{Kind: "action", Name: n.Name, Params: map[string]string{}}
+    p := n.Parameters
+    str := func(key string) string {
+        s, _ := p[key].(string)
+        return s
+    }
+    var noted *NodeDecl
+    if strings.HasPrefix(n.Notes, eowrNotes) {
+        src := "workflow \"notes\" version 1:\n  " + strings.TrimPrefix(n.Notes, eowrNotes) + "\n"
+        if f, diags := ParsePseudoSource(n.Name, src); len(diags) == 0 && len(f.Workflow.Nodes) == 1 {
+            noted = f.Workflow.Nodes[0]
+        }
+    }
+    switch n.Type {
+    case n8nWebhook:
+        d.Kind = "trigger"
+        d.At = "/" + strings.TrimPrefix(str("path"), "/")
+        switch m := strings.ToUpper(str("httpMethod")); m {
+        case "POST":
+        case "":
+            d.Params["method"] = "GET"
+        default:
+            d.Params["method"] = m
+        }
+    case n8nSchedule:
+        d.Kind = "trigger"
+        if cron, ok := scheduleCron(p["rule"]); ok {
+            d.Params["cron"] = cron
+        } else {
+            im.warn(n, "schedule rule has no cron equivalent; add with cron=\"...\"")
+        }
+    case n8nManual:
+        d.Kind = "trigger"
+        if noted != nil {
+            for k, v := range noted.Params { d.Params[k] = v }
+        }
+    case n8nHTTP:
+        d.Using = HTTPNode
+        if m := strings.ToUpper(str("method")); m != "" && m != "GET" { d.Params["method"] = m }
+        d.Params["url"] = im.expr(n, str("url"))
+        if p["sendHeaders"] == true {
+            h := map[string]string{}
+            for _, kv := range nameValues(p["headerParameters"]) { h[kv[0]] = im.expr(n, kv[1]) }
+            if b, err := json.Marshal(h); err == nil && len(h) > 0 { d.Params["headers"] = string(b) }
+        }
+        if p["sendBody"] == true {
+            switch str("specifyBody") {
+            case "json":
+                d.Params["body"] = im.expr(n, str("jsonBody"))
+            default:
+                body := map[string]string{}
+                for _, kv := range nameValues(p["bodyParameters"]) { body[kv[0]] = im.expr(n, kv[1]) }
+                if b, err := json.Marshal(body); err == nil && len(body) > 0 { d.Params["body"] = string(b) }
+            }
+        }
+        if len(n.Credentials) > 0 {
+            im.warn(n, "n8n credentials are not available natively; pass them with headers")
+        }
+    case n8nSwitch:
+        d.Kind = "switch"
+    case n8nMerge:
+        d.Kind = "merge"
+    case n8nExecute:
+        d.Kind = "subflow"
+        if str("source") == "localFile" {
+            d.At = str("workflowPath")
+            break
+        }
+        ref := str("workflowId")
+        if rl, ok := p["workflowId"].(map[string]any); ok {
+            ref, _ = rl["cachedResultName"].(string)
+            if ref == "" { ref = fmt.Sprint(rl["value"]) }
+        }
+        d.At = slug(ref) + ".pseudo"
+        im.warn(n, "runs a workflow stored in n8n; import it to %s next to this file", d.At)
+    case n8nCode:
+        if noted != nil {
+            d.Kind, d.Using, d.At = noted.Kind, noted.Using, noted.At
+            for k, v := range noted.Params { d.Params[k] = v }
+            break
+        }
+        d.Using = n8nUsing + n.Type
+        im.warn(n, "JavaScript does not run natively; register a node for %q", d.Using)
+    default:
+        if strings.HasSuffix(strings.ToLower(n.Type), "trigger") {
+            d.Kind = "trigger"
+            d.Params[n8nParamType] = n.Type
+            im.warn(n, "no native trigger of this type; the workflow starts when run")
+            break
+        }
+        d.Using = n8nUsing + n.Type
+        im.warn(n, "no native equivalent; register a node for %q", d.Using)
+    }
+    if noted != nil && isNodeKind(noted.Kind) { d.Kind = noted.Kind }
+    return d
+}
+
+func (im *n8nImport) connects(n n8nNode) []*ConnectDecl {
+    var out []*ConnectDecl
+    outs := im.wf.Connections[n.Name]["main"]
+    errOut := im.errorOutput(n)
+    var rules []Cond
+    fallback := -1
+    if n.Type == n8nSwitch {
+        rules = im.rules(&n)
+        if opts, ok := n.Parameters["options"].(map[string]any); ok {
+            switch fb := opts["fallbackOutput"].(type) {
+            case string:
+                if fb == "extra" { fallback = len(rules) }
+            case float64:
+                fallback = int(fb)
+            }
+        }
+    }
+    from := []NodeRef{{Name: n.Name}}
+    for i, row := range outs {
+        c := &ConnectDecl{From: from}
+        for _, t := range row {
+            if im.byName[t.Node] == nil {
+                im.warn(&n, "connects to unknown node %q", t.Node)
+                continue
+            }
+            if !refersTo(c.To, t.Node) { c.To = append(c.To, NodeRef{Name: t.Node}) }
+        }
+        if len(c.To) == 0 { continue }
+        switch {
+        case i == errOut || n.Type != n8nSwitch:
+        case i < len(rules):
+            if rules[i] == nil { continue }
+            c.When = rules[i]
+            if i == fallback {
+                out = append(out, c)
+                c = &ConnectDecl{From: from, To: c.To, Otherwise: true}
+            }
+        case i == fallback:
+            c.Otherwise = true
+        default:
+            im.warn(&n, "output %d has no rule; its connections are dropped", i)
+            continue
+        }
+        out = append(out, c)
+    }
+    return out
+}
+
+// rules parses the switch rules; a rule that cannot be expressed is nil and
+// its output is dropped with a warning.
+func (im *n8nImport) rules(n *n8nNode) []Cond {
+    var out []Cond
+    rules, _ := n.Parameters["rules"].(map[string]any)
+    values, _ := rules["values"].([]any)
+    if mode, _ := n.Parameters["mode"].(string); mode != "" && mode != "rules" {
+        im.warn(n, "switch mode %q is not supported; use rules", mode)
+        return nil
+    }
+    for i, v := range values {
+        c, err := fromN8NRule(v)
+        if err != nil {
+            im.warn(n, "rule %d: %v; its connections are dropped", i, err)
+        }
+        out = append(out, c)
+    }
+    return out
+}
+
+// keepN8NOnly stores in n8n_ params whatever ToN8N would not reproduce from
+// the declarations alone.
+func (im *n8nImport) keepN8NOnly(w *WorkflowDecl) {
+    layout := n8nLayout(w)
+    inputs := mergeInputs(w)
+    for i, d := range w.Nodes {
+        orig := im.wf.Nodes[i]
+        ports, err := n8nPortsFor(w, d)
+        if err != nil {
+            im.warn(&orig, "%v", err)
+            continue
+        }
+        regen, err := n8nNodeFor(w, d, ports, inputs[d.Name], layout[d.Name])
+        if err != nil {
+            im.warn(&orig, "%v", err)
+            continue
+        }
+        p := d.Params
+        if orig.Type != regen.Type { p[n8nParamType] = orig.Type }
+        if orig.ID != regen.ID { p[n8nParamID] = orig.ID }
+        if orig.Position != regen.Position {
+            p[n8nParamPosition] = strconv.FormatFloat(orig.Position[0], 'f', -1, 64) + "," + strconv.FormatFloat(orig.Position[1], 'f', -1, 64)
+        }
+        if orig.TypeVersion != regen.TypeVersion { p[n8nParamVersion] = strconv.FormatFloat(orig.TypeVersion, 'f', -1, 64) }
+        if orig.Notes != regen.Notes { p[n8nParamNotes] = orig.Notes }
+        if orig.WebhookID != regen.WebhookID { p[n8nParamWebhookID] = orig.WebhookID }
+        if orig.OnError != regen.OnError { p[n8nParamOnError] = orig.OnError }
+        if len(orig.Credentials) > 0 {
+            b, _ := json.Marshal(orig.Credentials)
+            p[n8nParamCredentials] = string(b)
+        }
+        if extra := paramDiff(orig, regen); len(extra) > 0 {
+            b, _ := json.Marshal(extra)
+            p[n8nParamParameters] = string(b)
+        }
+        if d.Kind == "merge" {
+            if order := im.inputOrder(d.Name); !reflect.DeepEqual(order, inputs[d.Name]) {
+                b, _ := json.Marshal(order)
+                p[n8nParamInputs] = string(b)
+            }
+        }
+        if len(p) == 0 { d.Params = nil }
+    }
+}
+
+// n8nDerived are parameters always rebuilt from the pseudo declaration, so
+// editing the .pseudo wins over what n8n had.
+var n8nDerived = map[string]map[string]bool{
+    n8nSwitch:  {"rules": true},
+    n8nWebhook: {"path": true},
+    n8nHTTP:    {"url": true},
+}
+
+// paramDiff returns the parameters of orig that regen lacks or has
+// differently; keys only regen has map to nil.
+func paramDiff(orig, regen n8nNode) map[string]any {
+    a, b := normalize(orig.Parameters), normalize(regen.Parameters)
+    out := map[string]any{}
+    for k, v := range a {
+        if n8nDerived[orig.Type][k] { continue }
+        if !reflect.DeepEqual(v, b[k]) { out[k] = v }
+    }
+    for k := range b {
+        if _, ok := a[k]; !ok { out[k] = nil }
+    }
+    return out
+}
+
+func normalize(m map[string]any) map[string]any {
+    var out map[string]any
+    b, _ := json.Marshal(m)
+    _ = json.Unmarshal(b, &out)
+    return out
+}
+
+// inputOrder lists the sources of a merge by n8n input index.
+func (im *n8nImport) inputOrder(name string) []string {
+    type input struct {
+        from  string
+        index int
+    }
+    var ins []input
+    for _, n := range im.wf.Nodes {
+        for _, row := range im.wf.Connections[n.Name]["main"] {
+            for _, c := range row {
+                if c.Node == name { ins = append(ins, input{n.Name, c.Index}) }
+            }
+        }
+    }
+    sort.SliceStable(ins, func(i, j int) bool { return ins[i].index < ins[j].index })
+    var out []string
+    for _, in := range ins {
+        if indexOf(out, in.from) < 0 { out = append(out, in.from) }
+    }
+    return out
+}
+
+var n8nJSONRef = regexp.MustCompile(`^\s*\$json\.([A-Za-z_][\w.]*)\s*$`)
+var n8nBraces = regexp.MustCompile(`\{\{(.*?)\}\}`)
+
+// expr turns an n8n expression made only of {{ $json.field }} references
+// into a {{field}} payload template. Other expressions are kept as written
+// and are not evaluated natively.
+func (im *n8nImport) expr(n *n8nNode, s string) string {
+    if !strings.HasPrefix(s, "=") { return s }
+    body, ok := s[1:], true
+    body = n8nBraces.ReplaceAllStringFunc(body, func(m string) string {
+        sub := n8nJSONRef.FindStringSubmatch(m[2 : len(m)-2])
+        if sub == nil {
+            ok = false
+            return m
+        }
+        return "{{" + sub[1] + "}}"
+    })
+    if !ok {
+        im.warn(n, "expression %s is not evaluated natively", s)
+        return s
+    }
+    return body
+}
+
+func nameValues(v any) [][2]string {
+    m, _ := v.(map[string]any)
+    list, _ := m["parameters"].([]any)
+    var out [][2]string
+    for _, x := range list {
+        kv, _ := x.(map[string]any)
+        name, _ := kv["name"].(string)
+        if name == "" { continue }
+        out = append(out, [2]string{name, fmt.Sprint(kv["value"])})
+    }
+    return out
+}
+
+// scheduleCron reads a schedule trigger's first interval as a cron line.
+func scheduleCron(rule any) (string, bool) {
+    m, _ := rule.(map[string]any)
+    list, _ := m["interval"].([]any)
+    if len(list) != 1 { return "", false }
+    iv, _ := list[0].(map[string]any)
+    every := func(key string) int {
+        if n, ok := iv[key].(float64); ok && n > 0 { return int(n) }
+        return 1
+    }
+    at := func(key string) int {
+        n, _ := iv[key].(float64)
+        return int(n)
+    }
+    switch iv["field"] {
+    case "cronExpression":
+        s, ok := iv["expression"].(string)
+        return s, ok && s != ""
+    case "minutes":
+        return fmt.Sprintf("*/%d * * * *", every("minutesInterval")), true
+    case "hours":
+        return fmt.Sprintf("%d */%d * * *", at("triggerAtMinute"), every("hoursInterval")), true
+    case "days":
+        return fmt.Sprintf("%d %d */%d * *", at("triggerAtMinute"), at("triggerAtHour"), every("daysInterval")), true
+    }
+    return "", false
+}
+
+var slugRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slug(s string) string {
+    s = strings.Trim(slugRe.ReplaceAllString(strings.ToLower(s), "_"), "_")
+    if s == "" { return "subflow" }
+    return s
+}
+
+var n8nField = regexp.MustCompile(`^=\{\{\s*\$json\.([A-Za-z_][\w.]*)\s*\}\}$`)
+
+// fromN8NRule parses an n8n switch (v3) rule into a condition.
+func fromN8NRule(v any) (Cond, error) {
+    rule, _ := v.(map[string]any)
+    group, _ := rule["conditions"].(map[string]any)
+    list, _ := group["conditions"].([]any)
+    if len(list) == 0 { return nil, fmt.Errorf("no conditions") }
+    combinator, _ := group["combinator"].(string)
+    if combinator != "or" { combinator = "and" }
+    var out Cond
+    for _, x := range list {
+        c, err := fromN8NCondition(x)
+        if err != nil { return nil, err }
+        if out == nil {
+            out = c
+            continue
+        }
+        out = Logical{Op: combinator, L: out, R: c}
+    }
+    return out, nil
+}
+
+func fromN8NCondition(v any) (Cond, error) {
+    m, _ := v.(map[string]any)
+    left, _ := m["leftValue"].(string)
+    sub := n8nField.FindStringSubmatch(left)
+    if sub == nil { return nil, fmt.Errorf("left value %q is not a {{ $json.field }} reference", left) }
+    c := Compare{Field: sub[1]}
+    op, _ := m["operator"].(map[string]any)
+    typ, _ := op["type"].(string)
+    operation, _ := op["operation"].(string)
+    right := m["rightValue"]
+    if s, ok := right.(string); ok && strings.HasPrefix(s, "=") {
+        return nil, fmt.Errorf("right value %q is an expression", s)
+    }
+    simple := map[string]string{"equals": "==", "notEquals": "!=", "gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+    switch {
+    case operation == "exists":
+        c.Op = "!="
+    case operation == "notExists":
+        c.Op = "=="
+    case typ == "boolean" && operation == "true":
+    case typ == "boolean" && operation == "false":
+        c.Op, c.Value = "==", false
+    case typ == "boolean" && (operation == "equals" || operation == "notEquals"):
+        b, ok := right.(bool)
+        if !ok { b, _ = strconv.ParseBool(fmt.Sprint(right)) }
+        c.Op, c.Value = simple[operation], b
+    case typ == "number" && simple[operation] != "":
+        f, ok := number(right)
+        if !ok { return nil, fmt.Errorf("%s compares with %v, not a number", c.Field, right) }
+        c.Op, c.Value = simple[operation], f
+    case typ == "string" && (operation == "equals" || operation == "notEquals"):
+        c.Op, c.Value = simple[operation], fmt.Sprint(right)
+    case (typ == "string" || typ == "array") && operation == "contains":
+        switch right.(type) {
+        case string, float64, bool:
+            c.Op, c.Value = "contains", right
+        default:
+            return nil, fmt.Errorf("%s contains %v, not a string or number", c.Field, right)
+        }
+    default:
+        return nil, fmt.Errorf("%s operator %q has no native equivalent", typ, operation)
+    }
+    return c, nil
+}
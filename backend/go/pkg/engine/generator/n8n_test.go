@@ -0,0 +1,136 @@
+package generator
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestN8NRoundTripFromPseudo(t *testing.T) {
+    src := `workflow "Leads" version 2:
+  trigger "Hook" at "/lead"
+  trigger "Nightly" with cron="0 2 * * *"
+  trigger "Events" with queue="lead-events"
+  action "Enrich" using "http/request" with body="{\"email\": \"{{email}}\"}", headers="{\"X-Key\":\"{{key}}\"}", method="POST", url="https://api.example.com/enrich/{{id}}"
+  switch "Hot?"
+  action "Push" using "workers/crm_worker"
+  action "Nurture" using "queue/nats_engine" with topic="nurture"
+  subflow "Archive" at "archive.pseudo"
+  merge "Done"
+  on_error "Alert" using "telemetry/sentry_engine"
+  connect "Hook" -> "Enrich"
+  connect "Nightly" -> "Archive"
+  connect "Events" -> "Enrich"
+  connect "Enrich" -> "Hot?"
+  connect "Enrich" -> "Alert"
+  connect "Hot?" -> "Push" when (score >= 50 and tier == "gold")
+  connect "Hot?" -> ["Nurture", "Archive"] when tags contains "trial"
+  connect "Hot?" -> "Done" otherwise
+  connect "Push" -> "Done"
+  connect "Nurture" -> "Done"
+  connect "Archive" -> "Done"
+`
+    f, diags := ParsePseudoSource("leads.pseudo", src)
+    if diags = append(diags, CheckPseudo(f)...); len(diags) > 0 { t.Fatalf("unexpected diagnostics:\n%v", diags) }
+    out, err := ToN8N(f)
+    if err != nil { t.Fatal(err) }
+    back, warnings, err := FromN8N("leads.json", out)
+    if err != nil { t.Fatal(err) }
+    if len(warnings) > 0 { t.Fatalf("unexpected warnings: %v", warnings) }
+    if got := FormatPseudo(back); got != src { t.Fatalf("round trip changed the workflow:\n%s", got) }
+
+    var wf n8nWorkflow
+    if err := json.Unmarshal(out, &wf); err != nil { t.Fatal(err) }
+    types := map[string]string{}
+    for _, n := range wf.Nodes { types[n.Name] = n.Type }
+    want := map[string]string{
+        "Hook": n8nWebhook, "Nightly": n8nSchedule, "Events": n8nManual, "Enrich": n8nHTTP, "Hot?": n8nSwitch, "Push": n8nCode,
+        "Nurture": n8nCode, "Archive": n8nExecute, "Done": n8nMerge, "Alert": n8nCode,
+    }
+    if !reflect.DeepEqual(types, want) { t.Fatalf("types = %v", types) }
+    if outs := wf.Connections["Hot?"]["main"]; len(outs) != 3 || len(outs[1]) != 2 || outs[2][0].Node != "Done" {
+        t.Fatalf("switch outputs = %+v", outs)
+    }
+    if outs := wf.Connections["Enrich"]["main"]; len(outs) != 2 || outs[1][0].Node != "Alert" {
+        t.Fatalf("error output = %+v", outs)
+    }
+}
+
+const n8nLeads = `{
+  "name": "Lead Router",
+  "nodes": [
+    {"id": "a1", "name": "Hook", "type": "n8n-nodes-base.webhook", "typeVersion": 2.1, "position": [0, 0],
+     "webhookId": "w-1", "parameters": {"path": "lead", "httpMethod": "POST", "options": {"rawBody": true}}},
+    {"id": "a2", "name": "Score", "type": "n8n-nodes-base.httpRequest", "typeVersion": 4.2, "position": [220, 0],
+     "parameters": {"method": "POST", "url": "=https://score.example.com/{{ $json.id }}", "authentication": "genericCredentialType",
+       "genericAuthType": "httpHeaderAuth", "options": {"timeout": 5000}},
+     "credentials": {"httpHeaderAuth": {"id": "7", "name": "Scorer"}}, "onError": "continueErrorOutput"},
+    {"id": "a3", "name": "Route", "type": "n8n-nodes-base.switch", "typeVersion": 3, "position": [440, 0],
+     "parameters": {"rules": {"values": [
+       {"conditions": {"options": {"caseSensitive": true, "leftValue": "", "typeValidation": "strict"},
+         "conditions": [{"leftValue": "={{ $json.score }}", "rightValue": 80, "operator": {"type": "number", "operation": "gte"}}],
+         "combinator": "and"}}
+     ]}, "options": {"fallbackOutput": "extra"}}},
+    {"id": "a4", "name": "Sales", "type": "n8n-nodes-base.executeWorkflow", "typeVersion": 1.1, "position": [660, -100],
+     "parameters": {"source": "localFile", "workflowPath": "sales.pseudo"}},
+    {"id": "a5", "name": "Slack", "type": "n8n-nodes-base.slack", "typeVersion": 2.2, "position": [660, 100],
+     "parameters": {"channel": "#leads", "text": "new lead"}},
+    {"id": "a6", "name": "Join", "type": "n8n-nodes-base.merge", "typeVersion": 3, "position": [880, 0], "parameters": {}},
+    {"id": "a7", "name": "Failed", "type": "n8n-nodes-base.httpRequest", "typeVersion": 4.2, "position": [440, 200],
+     "parameters": {"url": "https://alerts.example.com", "options": {}}, "notes": "pager"}
+  ],
+  "connections": {
+    "Hook": {"main": [[{"node": "Score", "type": "main", "index": 0}]]},
+    "Score": {"main": [[{"node": "Route", "type": "main", "index": 0}], [{"node": "Failed", "type": "main", "index": 0}]]},
+    "Route": {"main": [[{"node": "Sales", "type": "main", "index": 0}], [{"node": "Slack", "type": "main", "index": 0}]]},
+    "Sales": {"main": [[{"node": "Join", "type": "main", "index": 1}]]},
+    "Slack": {"main": [[{"node": "Join", "type": "main", "index": 0}]]}
+  }
+}`
+
+func TestN8NRoundTripFromN8N(t *testing.T) {
+    f, warnings, err := FromN8N("leads.json", []byte(n8nLeads))
+    if err != nil { t.Fatal(err) }
+    if len(warnings) != 2 || !strings.Contains(warnings[0], "credentials") || !strings.Contains(warnings[1], `"n8n/n8n-nodes-base.slack"`) {
+        t.Fatalf("warnings = %q", warnings)
+    }
+    src := FormatPseudo(f)
+    for _, want := range []string{
+        `trigger "Hook" at "/lead" with n8n_id="a1"`,
+        `action "Score" using "http/request" with `,
+        `url="https://score.example.com/{{id}}"`,
+        `on_error "Failed" using "http/request"`,
+        `connect "Route" -> "Sales" when score >= 80`,
+        `connect "Route" -> "Slack" otherwise`,
+        `connect "Score" -> "Failed"`,
+    } {
+        if !strings.Contains(src, want) { t.Fatalf("missing %s in\n%s", want, src) }
+    }
+
+    // The .pseudo text must carry everything back to the same n8n workflow.
+    parsed, diags := ParsePseudoSource("leads.pseudo", src)
+    if diags = append(diags, CheckPseudo(parsed)...); len(diags) > 0 { t.Fatalf("unexpected diagnostics:\n%v\n%s", diags, src) }
+    out, err := ToN8N(parsed)
+    if err != nil { t.Fatal(err) }
+    var got, want map[string]any
+    if err := json.Unmarshal(out, &got); err != nil { t.Fatal(err) }
+    if err := json.Unmarshal([]byte(n8nLeads), &want); err != nil { t.Fatal(err) }
+    for _, key := range []string{"name", "nodes", "connections"} {
+        if !reflect.DeepEqual(got[key], want[key]) {
+            g, _ := json.MarshalIndent(got[key], "", "  ")
+            t.Fatalf("%s changed in the round trip:\n%s\n%s", key, g, src)
+        }
+    }
+}
+
+func TestN8NExportRejectsMixedConditions(t *testing.T) {
+    f, _ := ParsePseudoSource("wf.pseudo", `workflow "W" version 1:
+  trigger "T"
+  switch "S"
+  action "A" using "workers/data_worker"
+  connect "T" -> "S"
+  connect "S" -> "A" when a == 1 and (b == 2 or c == 3)
+`)
+    if _, err := ToN8N(f); err == nil || !strings.Contains(err.Error(), "one combinator") { t.Fatalf("err = %v", err) }
+}
@@ -5,46 +5,63 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+	"github.com/bitesinbyte/ferret/pkg/external"
 )
 
-// PlanAndSchedule writes scheduled_posts based on trends + variants with spacing per platform.
+// PlanAndSchedule writes scheduled_posts based on trends + variants. Slots come
+// from a SlotPlanner: local posting windows and quiet hours in the audience's
+// time zone, Spacing between posts per platform, PerDayLimit per local day,
+// and no collisions with the org's rows already scheduled.
 func PlanAndSchedule(ctx context.Context, repo calendarrepo.Repository, in PlanInput) error {
     // Sort trends by score desc
     trends := append([]Trend(nil), in.Trends...)
     sort.Slice(trends, func(i, j int) bool { return trends[i].TrendScore > trends[j].TrendScore })
-    now := in.StartAt
-    spacing := in.Spacing
-    if spacing <= 0 { spacing = 2 * time.Hour }
-    perDay := in.PerDayLimit
-    if perDay <= 0 { perDay = 10 }
+    platforms := make([]string, 0, len(in.Platforms))
+    for _, p := range in.Platforms {
+        name, err := external.CanonicalPlatform(p)
+        if err != nil { return err }
+        platforms = append(platforms, name)
+    }
+    slots, err := planSlots(ctx, repo, in)
+    if err != nil { return err }
 
-    // Per-platform next-available times
-    nextAt := map[string]time.Time{}
-    for _, p := range in.Platforms { nextAt[p] = now }
+    sel, err := newVariantSelector(ctx, repo, in)
+    if err != nil { return err }
 
     var batch []calendarrepo.ScheduleInput
     for _, t := range trends {
         vlist := in.Variants[t.Topic]
         if len(vlist) == 0 { continue }
-        // Use control + top variant (if present) as example
-        toSchedule := pickTopVariants(vlist, 2)
-        for _, v := range toSchedule {
-            for _, p := range in.Platforms {
-                when := nextAt[p]
-                // advance spacing
-                nextAt[p] = when.Add(spacing)
-                caption, tags := CaptionFor(p, t.Topic, v)
-                meta := toJSON(map[string]any{"topic": t.Topic, "variant_id": v.ID, "title": v.Title, "cta": v.CTA, "hashtags": tags})
+        toSchedule, err := sel.pick(vlist)
+        if err != nil { return err }
+        for _, a := range toSchedule {
+            v := a.Variant
+            for _, p := range platforms {
+                when, err := slots.Next(p, in.StartAt)
+                if err != nil { return err }
+                compiled := CompileCaption(p, t.Topic, v)
+                caption, tags := compiled.Text, compiled.Hashtags
+                m := map[string]any{"topic": t.Topic, "variant_id": v.ID, "title": v.Title, "cta": v.CTA, "hashtags": tags}
+                if len(compiled.Warnings) > 0 { m["caption_warnings"] = compiled.Warnings }
+                var armID *string
+                if in.Bandit != "" { m["selection"] = in.Bandit }
+                if a.ArmID != "" {
+                    m["arm_id"], m["experiment_id"] = a.ArmID, in.ExperimentID
+                    armID = ptr(a.ArmID)
+                }
+                meta := toJSON(m)
                 cap := ptr(caption)
                 var hashPtr *string
                 if strings.TrimSpace(tags) != "" { hashPtr = &tags }
                 item := calendarrepo.ScheduleInput{
                     ID: newID(),
+                    OrgID: in.OrgID,
                     CampaignID: nil,
                     ContentID:  nil,
                     Platform:   p,
@@ -52,6 +69,7 @@ func PlanAndSchedule(ctx context.Context, repo calendarrepo.Repository, in PlanI
                     Hashtags:   hashPtr,
                     ScheduledAt: when,
                     MetadataJSON: &meta,
+                    ArmID:        armID,
                 }
                 batch = append(batch, item)
             }
@@ -60,6 +78,55 @@ func PlanAndSchedule(ctx context.Context, repo calendarrepo.Repository, in PlanI
     return repo.BulkSchedule(ctx, batch)
 }
 
+// planSlots returns a SlotPlanner for in with the org's pending rows reserved.
+func planSlots(ctx context.Context, repo calendarrepo.Repository, in PlanInput) (*SlotPlanner, error) {
+    policy, err := slotPolicy(ctx, repo, in)
+    if err != nil { return nil, err }
+    slots := NewSlotPlanner(policy)
+    if in.OrgID != "" {
+        busy, err := repo.ScheduledTimes(ctx, in.OrgID, busySince(policy, in.StartAt), in.StartAt.AddDate(0, 0, maxPlanDays))
+        if err != nil { return nil, fmt.Errorf("load scheduled posts: %w", err) }
+        for p, ts := range busy {
+            for _, t := range ts { slots.Reserve(p, t) }
+        }
+    }
+    return slots, nil
+}
+
+// busySince is where planSlots starts loading pending rows: early enough to
+// count every post already on start's local day toward PerDayLimit, and to
+// keep Spacing from a post just before start.
+func busySince(policy SlotPolicy, start time.Time) time.Time {
+    loc := policy.Location
+    if loc == nil { loc = time.UTC }
+    dayStart := localAt(start.In(loc), 0, 0)
+    if spaced := start.Add(-policy.Spacing); spaced.Before(dayStart) { return spaced }
+    return dayStart
+}
+
+// slotPolicy resolves PlanInput's time zone, windows and limits. Spacing
+// defaults to 2h and PerDayLimit to 10.
+func slotPolicy(ctx context.Context, repo calendarrepo.Repository, in PlanInput) (SlotPolicy, error) {
+    policy := SlotPolicy{Spacing: in.Spacing, PerDayLimit: in.PerDayLimit, Windows: map[string][]Window{}}
+    if policy.Spacing <= 0 { policy.Spacing = 2 * time.Hour }
+    if policy.PerDayLimit <= 0 { policy.PerDayLimit = 10 }
+    tz := in.Timezone
+    if tz == "" && in.OrgID != "" {
+        var err error
+        if tz, err = repo.ICPTimezone(ctx, in.OrgID); err != nil { return policy, fmt.Errorf("load ICP timezone: %w", err) }
+    }
+    loc, err := time.LoadLocation(tz) // "" is UTC
+    if err != nil { return policy, fmt.Errorf("timezone %q: %w", tz, err) }
+    policy.Location = loc
+    if policy.QuietHours, err = ParseWindows(in.QuietHours); err != nil { return policy, err }
+    for p, ws := range in.Windows {
+        name, err := external.CanonicalPlatform(p)
+        if err != nil { return policy, err }
+        if policy.Windows[name], err = ParseWindows(ws); err != nil { return policy, fmt.Errorf("%s: %w", name, err) }
+    }
+    return policy, nil
+}
+
 func pickTopVariants(vs []Variant, n int) []Variant {
     if n >= len(vs) { return vs }
     // Prefer control + first non-control
@@ -70,6 +137,7 @@ func pickTopVariants(vs []Variant, n int) []Variant {
     return out
 }
 
-func newID() string { var b [8]byte; _, _ = rand.Read(b[:]); return "sp_" + hex.EncodeToString(b[:]) }
+func newID() string { return randomID("sp_") }
+func randomID(prefix string) string { var b [8]byte; _, _ = rand.Read(b[:]); return prefix + hex.EncodeToString(b[:]) }
 func toJSON(m map[string]any) string { b, _ := json.Marshal(m); return string(b) }
 func ptr(s string) *string { return &s }
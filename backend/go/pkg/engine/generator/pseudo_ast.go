@@ -0,0 +1,262 @@
+package generator
+
+import (
+    "fmt"
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// PseudoFile is the syntax tree of one .pseudo file.
+type PseudoFile struct {
+    Path     string
+    Workflow *WorkflowDecl // nil when the header is missing
+}
+
+// WorkflowDecl is `workflow "Name" version X:` and its statements.
+type WorkflowDecl struct {
+    Pos      Pos
+    Name     string
+    Version  string
+    Nodes    []*NodeDecl
+    Connects []*ConnectDecl
+}
+
+// Node kinds of the pseudo language.
+var NodeKinds = []string{"trigger", "action", "switch", "merge", "subflow", "on_error"}
+
+// NodeDecl is `<kind> "Name" [using "ref"] [at "path"] [with k=v, ...]`.
+type NodeDecl struct {
+    Pos    Pos
+    Kind   string
+    Name   string
+    Using  string
+    At     string
+    Params map[string]string
+}
+
+// NodeRef is a node name used in a connect statement.
+type NodeRef struct {
+    Pos  Pos
+    Name string
+}
+
+// ConnectDecl is `connect <refs> -> <refs> [when <cond> | otherwise]`. Conditions
+// are only allowed on connections leaving a switch.
+type ConnectDecl struct {
+    Pos       Pos
+    From, To  []NodeRef
+    When      Cond // nil means unconditional
+    Otherwise bool // taken when no `when` of the same switch matched
+}
+
+// Node returns the declaration named name, or nil.
+func (w *WorkflowDecl) Node(name string) *NodeDecl {
+    for _, n := range w.Nodes {
+        if n.Name == name { return n }
+    }
+    return nil
+}
+
+// Flat flattens the tree into the legacy match-slice form used by the Go
+// translator.
+func (f *PseudoFile) Flat() Workflow {
+    var wf Workflow
+    if f == nil || f.Workflow == nil { return wf }
+    d := f.Workflow
+    wf.Name, wf.Version = d.Name, d.Version
+    for _, n := range d.Nodes {
+        wf.NodeMatches = append(wf.NodeMatches, []string{n.String(), n.Kind, n.Name, n.Using, n.At})
+    }
+    for _, c := range d.Connects {
+        to := make([]string, len(c.To))
+        for i, r := range c.To { to[i] = strconv.Quote(r.Name) }
+        raw := to[0]
+        if len(to) > 1 { raw = "[" + strings.Join(to, ", ") + "]" }
+        for _, from := range c.From {
+            wf.Connections = append(wf.Connections, []string{fmt.Sprintf("connect %q -> %s", from.Name, raw), from.Name, raw})
+        }
+    }
+    return wf
+}
+
+func (n *NodeDecl) String() string {
+    s := n.Kind + " " + quote(n.Name)
+    if n.Using != "" { s += " using " + quote(n.Using) }
+    if n.At != "" { s += " at " + quote(n.At) }
+    if len(n.Params) > 0 {
+        keys := make([]string, 0, len(n.Params))
+        for k := range n.Params { keys = append(keys, k) }
+        sort.Strings(keys)
+        for i, k := range keys {
+            keys[i] = k + "=" + quote(n.Params[k])
+        }
+        s += " with " + strings.Join(keys, ", ")
+    }
+    return s
+}
+
+// Cond is a switch condition evaluated against a payload.
+type Cond interface {
+    Eval(vars map[string]any) bool
+    String() string
+}
+
+// Compare is `field op value`; Op "" tests the field for truthiness.
+type Compare struct {
+    Field string // dotted path into the payload, e.g. lead.score
+    Op    string // == != < <= > >= contains
+    Value any    // string, float64, bool or nil
+}
+
+// Logical is `L and R` or `L or R`.
+type Logical struct {
+    Op   string
+    L, R Cond
+}
+
+func (l Logical) Eval(vars map[string]any) bool {
+    if l.Op == "and" { return l.L.Eval(vars) && l.R.Eval(vars) }
+    return l.L.Eval(vars) || l.R.Eval(vars)
+}
+
+func (l Logical) String() string { return "(" + l.L.String() + " " + l.Op + " " + l.R.String() + ")" }
+
+func (c Compare) String() string {
+    if c.Op == "" { return c.Field }
+    return c.Field + " " + c.Op + " " + literal(c.Value)
+}
+
+// literal formats a condition value so the parser reads it back unchanged.
+func literal(v any) string {
+    switch x := v.(type) {
+    case nil:
+        return "null"
+    case string:
+        return quote(x)
+    case float64:
+        return strconv.FormatFloat(x, 'f', -1, 64)
+    }
+    return fmt.Sprint(v)
+}
+
+// quote writes s as a pseudo string literal.
+func quote(s string) string {
+    r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+    return `"` + r.Replace(s) + `"`
+}
+
+func (c Compare) Eval(vars map[string]any) bool {
+    got, ok := lookup(vars, c.Field)
+    switch c.Op {
+    case "":
+        return ok && truthy(got)
+    case "contains":
+        return contains(got, c.Value)
+    case "==":
+        return equal(got, c.Value)
+    case "!=":
+        return !equal(got, c.Value)
+    }
+    if a, ok := number(got); ok {
+        b, ok := number(c.Value)
+        if !ok { return false }
+        return order(c.Op, compareFloat(a, b))
+    }
+    a, aok := got.(string)
+    b, bok := c.Value.(string)
+    if !aok || !bok { return false }
+    return order(c.Op, strings.Compare(a, b))
+}
+
+func lookup(vars map[string]any, path string) (any, bool) {
+    var cur any = vars
+    for _, part := range strings.Split(path, ".") {
+        m, ok := cur.(map[string]any)
+        if !ok { return nil, false }
+        if cur, ok = m[part]; !ok { return nil, false }
+    }
+    return cur, true
+}
+
+func truthy(v any) bool {
+    switch x := v.(type) {
+    case nil:
+        return false
+    case bool:
+        return x
+    case string:
+        return x != ""
+    }
+    if f, ok := number(v); ok { return f != 0 }
+    return true
+}
+
+func number(v any) (float64, bool) {
+    switch x := v.(type) {
+    case float64:
+        return x, true
+    case float32:
+        return float64(x), true
+    case int:
+        return float64(x), true
+    case int64:
+        return float64(x), true
+    case string:
+        f, err := strconv.ParseFloat(x, 64)
+        return f, err == nil
+    }
+    return 0, false
+}
+
+func equal(a, b any) bool {
+    if _, isStr := b.(string); !isStr {
+        if x, ok := number(a); ok {
+            if y, ok := number(b); ok { return x == y }
+        }
+    }
+    if s, ok := b.(string); ok { return fmt.Sprint(a) == s && a != nil }
+    return reflect.DeepEqual(a, b)
+}
+
+func contains(haystack, needle any) bool {
+    switch h := haystack.(type) {
+    case string:
+        s, ok := needle.(string)
+        return ok && strings.Contains(h, s)
+    case []any:
+        for _, x := range h {
+            if equal(x, needle) { return true }
+        }
+    case []string:
+        for _, x := range h {
+            if equal(x, needle) { return true }
+        }
+    }
+    return false
+}
+
+func compareFloat(a, b float64) int {
+    switch {
+    case a < b:
+        return -1
+    case a > b:
+        return 1
+    }
+    return 0
+}
+
+func order(op string, cmp int) bool {
+    switch op {
+    case "<":
+        return cmp < 0
+    case "<=":
+        return cmp <= 0
+    case ">":
+        return cmp > 0
+    case ">=":
+        return cmp >= 0
+    }
+    return false
+}
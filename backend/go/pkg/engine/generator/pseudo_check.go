@@ -0,0 +1,205 @@
+package generator
+
+import (
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/engine/cron"
+)
+
+// CheckPseudo validates the graph of a parsed file: duplicate names, dangling
+// connect targets, conditions outside switches, missing references, cycles and
+// nodes no trigger reaches. Workflow-level on_error handlers (on_error nodes
+// nothing connects to) count as reachable.
+func CheckPseudo(f *PseudoFile) Diagnostics {
+    var ds Diagnostics
+    if f == nil { return ds }
+    w := f.Workflow
+    if w == nil {
+        ds.add(Pos{File: f.Path, Line: 1, Col: 1}, "missing `workflow \"Name\" version X:` header")
+        return ds
+    }
+
+    nodes := map[string]*NodeDecl{}
+    for _, n := range w.Nodes {
+        if first, dup := nodes[n.Name]; dup {
+            ds.add(n.Pos, "duplicate node %q (first declared at %d:%d)", n.Name, first.Pos.Line, first.Pos.Col)
+            continue
+        }
+        nodes[n.Name] = n
+        switch n.Kind {
+        case "action", "on_error":
+            if n.Using == "" { ds.add(n.Pos, "%s %q needs `using \"...\"`", n.Kind, n.Name) }
+        case "subflow":
+            if n.Using == "" && n.At == "" { ds.add(n.Pos, "subflow %q needs `at \"file.pseudo\"`", n.Name) }
+        case "trigger":
+            checkTrigger(&ds, n)
+        }
+    }
+
+    out := map[string][]arc{}
+    indeg := map[string]int{}
+    otherwise := map[string]*ConnectDecl{}
+    for _, c := range w.Connects {
+        var from, to []string
+        for _, r := range c.From {
+            if n, ok := nodes[r.Name]; !ok {
+                ds.add(r.Pos, "connect from unknown node %q", r.Name)
+            } else {
+                if (c.When != nil || c.Otherwise) && n.Kind != "switch" {
+                    ds.add(c.Pos, "conditions are only allowed on connections from a switch; %q is not a switch", r.Name)
+                }
+                from = append(from, r.Name)
+            }
+        }
+        for _, r := range c.To {
+            switch n, ok := nodes[r.Name]; {
+            case !ok:
+                ds.add(r.Pos, "connect to unknown node %q", r.Name)
+            case n.Kind == "trigger":
+                ds.add(r.Pos, "cannot connect into trigger %q", r.Name)
+            default:
+                to = append(to, r.Name)
+            }
+        }
+        if c.Otherwise {
+            for _, f := range from {
+                if prev, dup := otherwise[f]; dup {
+                    ds.add(c.Pos, "switch %q already has an otherwise branch at %d:%d", f, prev.Pos.Line, prev.Pos.Col)
+                }
+                otherwise[f] = c
+            }
+        }
+        for _, a := range from {
+            for _, b := range to {
+                out[a] = append(out[a], arc{to: b, pos: c.Pos})
+                indeg[b]++
+            }
+        }
+    }
+
+    var triggers []string
+    for _, n := range w.Nodes {
+        if n.Kind == "trigger" { triggers = append(triggers, n.Name) }
+    }
+    if len(triggers) == 0 { ds.add(w.Pos, "workflow %q has no trigger", w.Name) }
+
+    ds = append(ds, checkCycles(w, nodes, out)...)
+
+    reached := map[string]bool{}
+    stack := append([]string(nil), triggers...)
+    for len(stack) > 0 {
+        n := stack[len(stack)-1]
+        stack = stack[:len(stack)-1]
+        if reached[n] { continue }
+        reached[n] = true
+        for _, e := range out[n] { stack = append(stack, e.to) }
+    }
+    if len(triggers) > 0 {
+        for _, n := range w.Nodes {
+            if reached[n.Name] || nodes[n.Name] != n { continue }
+            if n.Kind == "on_error" && indeg[n.Name] == 0 { continue }
+            ds.add(n.Pos, "%s %q is unreachable from any trigger", n.Kind, n.Name)
+        }
+    }
+    return ds
+}
+
+// arc is one resolved connection, positioned at its connect statement.
+type arc struct {
+    to  string
+    pos Pos
+}
+
+// checkCycles reports each cycle once, at the connect statement that closes it.
+func checkCycles(w *WorkflowDecl, nodes map[string]*NodeDecl, out map[string][]arc) Diagnostics {
+    var ds Diagnostics
+    const (
+        unvisited = iota
+        visiting
+        done
+    )
+    state := map[string]int{}
+    var path []string
+    var visit func(string)
+    visit = func(n string) {
+        state[n] = visiting
+        path = append(path, n)
+        for _, e := range out[n] {
+            m := e.to
+            switch state[m] {
+            case unvisited:
+                visit(m)
+            case visiting:
+                i := len(path) - 1
+                for path[i] != m { i-- }
+                cycle := append(append([]string(nil), path[i:]...), m)
+                for j := range cycle { cycle[j] = `"` + cycle[j] + `"` }
+                ds.add(e.pos, "cycle: %s", strings.Join(cycle, " -> "))
+            }
+        }
+        path = path[:len(path)-1]
+        state[n] = done
+    }
+    for _, n := range w.Nodes {
+        if nodes[n.Name] == n && state[n.Name] == unvisited { visit(n.Name) }
+    }
+    return ds
+}
+
+// How a trigger fires on its own (see TriggerKind).
+const (
+    TriggerManual  = "manual"  // only when a run is started by hand
+    TriggerWebhook = "webhook" // `at "/path"`: an HTTP request to the path
+    TriggerCron    = "cron"    // `with cron="..."`: on the schedule
+    TriggerQueue   = "queue"   // `with queue="topic"`: per message on the topic
+)
+
+// TriggerKind classifies a trigger declaration by its clauses.
+func TriggerKind(n *NodeDecl) string {
+    switch {
+    case n.At != "":
+        return TriggerWebhook
+    case n.Params["cron"] != "":
+        return TriggerCron
+    case n.Params["queue"] != "":
+        return TriggerQueue
+    }
+    return TriggerManual
+}
+
+func checkTrigger(ds *Diagnostics, n *NodeDecl) {
+    var kinds []string
+    if n.At != "" { kinds = append(kinds, "at") }
+    for _, k := range []string{"cron", "queue"} {
+        if n.Params[k] != "" { kinds = append(kinds, k) }
+    }
+    if len(kinds) > 1 {
+        ds.add(n.Pos, "trigger %q sets %s; a trigger fires one way", n.Name, strings.Join(kinds, " and "))
+        return
+    }
+    switch TriggerKind(n) {
+    case TriggerCron:
+        loc := time.UTC
+        if tz := n.Params["tz"]; tz != "" {
+            l, err := time.LoadLocation(tz)
+            if err != nil {
+                ds.add(n.Pos, "trigger %q: tz: %v", n.Name, err)
+                return
+            }
+            loc = l
+        }
+        if _, err := cron.ParseIn(n.Params["cron"], loc); err != nil { ds.add(n.Pos, "trigger %q: %v", n.Name, err) }
+    case TriggerWebhook:
+        if !strings.HasPrefix(n.At, "/") { ds.add(n.Pos, "trigger %q: webhook path %q must start with /", n.Name, n.At) }
+        switch m := strings.ToUpper(n.Params["method"]); m {
+        case "", "GET", "POST", "PUT", "PATCH", "DELETE":
+        default:
+            ds.add(n.Pos, "trigger %q: unsupported webhook method %q", n.Name, n.Params["method"])
+        }
+        if v, ok := n.Params["signed"]; ok {
+            if _, err := strconv.ParseBool(v); err != nil { ds.add(n.Pos, "trigger %q: signed=%s is not true or false", n.Name, v) }
+        }
+    }
+}
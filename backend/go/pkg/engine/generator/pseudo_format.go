@@ -0,0 +1,43 @@
+package generator
+
+import (
+    "regexp"
+    "strings"
+)
+
+var bareVersion = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// FormatPseudo prints f as .pseudo source: the header, the nodes in order,
+// then the connections. ParsePseudoSource reads the output back to the same
+// tree (positions aside).
+func FormatPseudo(f *PseudoFile) string {
+    if f == nil || f.Workflow == nil { return "" }
+    w := f.Workflow
+    version := w.Version
+    if version == "" { version = "1.0" }
+    if !bareVersion.MatchString(version) { version = quote(version) }
+
+    var b strings.Builder
+    b.WriteString("workflow " + quote(w.Name) + " version " + version + ":\n")
+    for _, n := range w.Nodes {
+        b.WriteString("  " + n.String() + "\n")
+    }
+    for _, c := range w.Connects {
+        b.WriteString("  connect " + formatRefs(c.From) + " -> " + formatRefs(c.To))
+        switch {
+        case c.When != nil:
+            b.WriteString(" when " + c.When.String())
+        case c.Otherwise:
+            b.WriteString(" otherwise")
+        }
+        b.WriteString("\n")
+    }
+    return b.String()
+}
+
+func formatRefs(refs []NodeRef) string {
+    names := make([]string, len(refs))
+    for i, r := range refs { names[i] = quote(r.Name) }
+    if len(names) == 1 { return names[0] }
+    return "[" + strings.Join(names, ", ") + "]"
+}
@@ -0,0 +1,137 @@
+package generator
+
+import (
+    "fmt"
+    "strings"
+    "unicode"
+)
+
+// Pos is a location in a .pseudo file; Line and Col are 1-based (Col counts runes).
+type Pos struct {
+    File      string
+    Line, Col int
+}
+
+func (p Pos) String() string {
+    if p.File == "" { return fmt.Sprintf("%d:%d", p.Line, p.Col) }
+    return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+}
+
+type tokenKind int
+
+const (
+    tokEOF tokenKind = iota
+    tokNewline
+    tokIdent  // keywords, node kinds, parameter names, dotted field paths
+    tokString // "..." with \" \\ \n \t escapes; text holds the unquoted value
+    tokNumber
+    tokArrow  // ->
+    tokLBrack // [
+    tokRBrack // ]
+    tokLParen // (
+    tokRParen // )
+    tokComma
+    tokColon
+    tokAssign // =
+    tokOp     // == != < <= > >=
+)
+
+type token struct {
+    kind tokenKind
+    text string
+    pos  Pos
+}
+
+func (t token) String() string {
+    switch t.kind {
+    case tokEOF:
+        return "end of file"
+    case tokNewline:
+        return "end of line"
+    }
+    return fmt.Sprintf("%q", t.text)
+}
+
+// lexPseudo splits src into tokens. `#` and `//` start comments that run to
+// the end of the line. Lexical errors are reported and the offending input
+// skipped, so the parser still sees the rest of the file.
+func lexPseudo(file, src string) ([]token, Diagnostics) {
+    var toks []token
+    var diags Diagnostics
+    for i, line := range strings.Split(src, "\n") {
+        rs := []rune(strings.TrimSuffix(line, "\r"))
+        pos := func(c int) Pos { return Pos{File: file, Line: i + 1, Col: c + 1} }
+        for c := 0; c < len(rs); {
+            r := rs[c]
+            switch {
+            case r == ' ' || r == '\t':
+                c++
+            case r == '#' || (r == '/' && c+1 < len(rs) && rs[c+1] == '/'):
+                c = len(rs)
+            case r == '"':
+                start := c
+                var b strings.Builder
+                c++
+                closed := false
+                for c < len(rs) {
+                    if rs[c] == '\\' && c+1 < len(rs) {
+                        switch e := rs[c+1]; e {
+                        case 'n':
+                            b.WriteRune('\n')
+                        case 't':
+                            b.WriteRune('\t')
+                        default:
+                            b.WriteRune(e)
+                        }
+                        c += 2
+                        continue
+                    }
+                    if rs[c] == '"' {
+                        closed = true
+                        c++
+                        break
+                    }
+                    b.WriteRune(rs[c])
+                    c++
+                }
+                if !closed { diags.add(pos(start), "unterminated string") }
+                toks = append(toks, token{tokString, b.String(), pos(start)})
+            case unicode.IsDigit(r) || (r == '-' && c+1 < len(rs) && unicode.IsDigit(rs[c+1])):
+                start := c
+                c++
+                for c < len(rs) && (unicode.IsDigit(rs[c]) || rs[c] == '.') { c++ }
+                toks = append(toks, token{tokNumber, string(rs[start:c]), pos(start)})
+            case unicode.IsLetter(r) || r == '_':
+                start := c
+                for c < len(rs) && (unicode.IsLetter(rs[c]) || unicode.IsDigit(rs[c]) || rs[c] == '_' || rs[c] == '.') { c++ }
+                toks = append(toks, token{tokIdent, string(rs[start:c]), pos(start)})
+            default:
+                two := ""
+                if c+1 < len(rs) { two = string(rs[c : c+2]) }
+                switch {
+                case two == "->":
+                    toks = append(toks, token{tokArrow, two, pos(c)})
+                    c += 2
+                case two == "==" || two == "!=" || two == "<=" || two == ">=":
+                    toks = append(toks, token{tokOp, two, pos(c)})
+                    c += 2
+                case r == '<' || r == '>':
+                    toks = append(toks, token{tokOp, string(r), pos(c)})
+                    c++
+                default:
+                    kind, ok := map[rune]tokenKind{'[': tokLBrack, ']': tokRBrack, '(': tokLParen, ')': tokRParen,
+                        ',': tokComma, ':': tokColon, '=': tokAssign}[r]
+                    if ok {
+                        toks = append(toks, token{kind, string(r), pos(c)})
+                    } else {
+                        diags.add(pos(c), "unexpected character %q", r)
+                    }
+                    c++
+                }
+            }
+        }
+        toks = append(toks, token{tokNewline, "", pos(len(rs))})
+    }
+    toks = append(toks, token{kind: tokEOF, pos: Pos{File: file, Line: strings.Count(src, "\n") + 1, Col: 1}})
+    return toks, diags
+}
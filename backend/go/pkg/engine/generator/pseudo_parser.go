@@ -1,12 +1,15 @@
 package generator
 
 import (
-    "bufio"
+    "fmt"
     "os"
-    "regexp"
+    "sort"
+    "strconv"
     "strings"
 )
 
+// Workflow is the flat form of a pseudo workflow used by CompileToGo.
+// New code should use the PseudoFile tree.
 type Workflow struct {
     Name        string
     Version     string
@@ -14,41 +17,301 @@ type Workflow struct {
     Connections [][]string // [full, from, targetsRaw]
 }
 
-// ParsePseudo parses a minimal EOWR pseudo workflow file.
+// ParsePseudo parses a pseudo workflow file on a best-effort basis, ignoring
+// diagnostics. Use LoadPseudo to surface them.
 func ParsePseudo(path string) Workflow {
-    data, _ := os.ReadFile(path)
-    content := string(data)
-
-    nameRe := regexp.MustCompile(`(?m)^\s*workflow\s+"([^"]+)"\s+version\s+([^:]+):`)
-    nameMatch := nameRe.FindStringSubmatch(content)
-    wf := Workflow{}
-    if len(nameMatch) >= 3 {
-        wf.Name = strings.TrimSpace(nameMatch[1])
-        wf.Version = strings.TrimSpace(nameMatch[2])
-    }
-
-    nodeLineRe := regexp.MustCompile(`^\s*(trigger|action|switch|merge|subflow|on_error)\s+"([^"]+)"(?:\s+using\s+"([^"]+)")?(?:\s+at\s+"([^"]+)")?`)
-
-    var nodes [][]string
-    scanner := bufio.NewScanner(strings.NewReader(content))
-    for scanner.Scan() {
-        line := scanner.Text()
-        if m := nodeLineRe.FindStringSubmatch(line); len(m) > 0 {
-            using, pathAttr := "", ""
-            if len(m) >= 4 {
-                using = m[3]
-            }
-            if len(m) >= 5 {
-                pathAttr = m[4]
+    f, _ := LoadPseudo(path)
+    return f.Flat()
+}
+
+// Diagnostic is a problem found in a .pseudo file.
+type Diagnostic struct {
+    Pos Pos
+    Msg string
+}
+
+func (d Diagnostic) Error() string { return d.Pos.String() + ": " + d.Msg }
+
+// Diagnostics is a list of problems; as an error it prints one per line.
+type Diagnostics []Diagnostic
+
+func (ds Diagnostics) Error() string {
+    lines := make([]string, len(ds))
+    for i, d := range ds { lines[i] = d.Error() }
+    return strings.Join(lines, "\n")
+}
+
+// Err returns ds as an error, or nil when it is empty.
+func (ds Diagnostics) Err() error {
+    if len(ds) == 0 { return nil }
+    return ds
+}
+
+func (ds *Diagnostics) add(pos Pos, format string, args ...any) {
+    *ds = append(*ds, Diagnostic{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (ds Diagnostics) sort() {
+    sort.SliceStable(ds, func(i, j int) bool {
+        if ds[i].Pos.Line != ds[j].Pos.Line { return ds[i].Pos.Line < ds[j].Pos.Line }
+        return ds[i].Pos.Col < ds[j].Pos.Col
+    })
+}
+
+// LoadPseudo reads, parses and checks the file at path. The tree is returned
+// even when there are diagnostics (as a Diagnostics error), so tools can show
+// as much as possible.
+func LoadPseudo(path string) (*PseudoFile, error) {
+    data, err := os.ReadFile(path)
+    if err != nil { return &PseudoFile{Path: path}, err }
+    f, diags := ParsePseudoSource(path, string(data))
+    diags = append(diags, CheckPseudo(f)...)
+    diags.sort()
+    return f, diags.Err()
+}
+
+// ParsePseudoSource parses src (named file in positions) into a tree,
+// reporting syntax errors only; CheckPseudo validates the graph.
+//
+//  file     = { newline } header { statement }
+//  header   = "workflow" STRING "version" (NUMBER | IDENT | STRING) ":" newline
+//  node     = KIND STRING { "using" STRING | "at" STRING | "with" param { "," param } } newline
+//  param    = IDENT "=" (STRING | NUMBER | IDENT)
+//  connect  = "connect" refs "->" refs [ "when" cond | "otherwise" ] newline
+//  refs     = STRING | "[" STRING { "," STRING } "]"
+//  cond     = and { "or" and } ; and = term { "and" term }
+//  term     = "(" cond ")" | IDENT [ OP value | "contains" value ]
+func ParsePseudoSource(file, src string) (*PseudoFile, Diagnostics) {
+    toks, diags := lexPseudo(file, src)
+    p := &pseudoParser{toks: toks, diags: diags}
+    f := &PseudoFile{Path: file}
+    for !p.at(tokEOF) {
+        if p.accept(tokNewline) { continue }
+        p.statement(f)
+    }
+    return f, p.diags
+}
+
+type pseudoParser struct {
+    toks  []token
+    i     int
+    diags Diagnostics
+}
+
+// errLine aborts the current statement; statements are one per line, so the
+// parser resumes at the next newline.
+type errLine struct{}
+
+func (p *pseudoParser) peek() token { return p.toks[p.i] }
+func (p *pseudoParser) at(k tokenKind) bool { return p.peek().kind == k }
+func (p *pseudoParser) atWord(w string) bool { return p.at(tokIdent) && p.peek().text == w }
+
+func (p *pseudoParser) next() token {
+    t := p.toks[p.i]
+    if t.kind != tokEOF { p.i++ }
+    return t
+}
+
+func (p *pseudoParser) accept(k tokenKind) bool {
+    if !p.at(k) { return false }
+    p.next()
+    return true
+}
+
+func (p *pseudoParser) fail(pos Pos, format string, args ...any) {
+    p.diags.add(pos, format, args...)
+    panic(errLine{})
+}
+
+func (p *pseudoParser) expect(k tokenKind, what string) token {
+    if !p.at(k) { p.fail(p.peek().pos, "expected %s, found %s", what, p.peek()) }
+    return p.next()
+}
+
+func (p *pseudoParser) expectWord(w string) token {
+    if !p.atWord(w) { p.fail(p.peek().pos, "expected %q, found %s", w, p.peek()) }
+    return p.next()
+}
+
+func (p *pseudoParser) endLine() {
+    if !p.at(tokNewline) && !p.at(tokEOF) { p.fail(p.peek().pos, "unexpected %s", p.peek()) }
+    p.next()
+}
+
+func (p *pseudoParser) statement(f *PseudoFile) {
+    defer func() {
+        if r := recover(); r != nil {
+            if _, ok := r.(errLine); !ok { panic(r) }
+            for !p.at(tokNewline) && !p.at(tokEOF) { p.next() }
+        }
+    }()
+    t := p.peek()
+    if t.kind != tokIdent { p.fail(t.pos, "expected a statement, found %s", t) }
+    if t.text == "workflow" {
+        p.header(f)
+        return
+    }
+    if f.Workflow == nil {
+        f.Workflow = &WorkflowDecl{Pos: t.pos}
+        p.fail(t.pos, "statement before the `workflow \"Name\" version X:` header")
+    }
+    switch {
+    case t.text == "connect":
+        p.connect(f.Workflow)
+    case isNodeKind(t.text):
+        p.node(f.Workflow)
+    default:
+        p.fail(t.pos, "unknown node kind %q (want %s or connect)", t.text, strings.Join(NodeKinds, ", "))
+    }
+}
+
+func isNodeKind(s string) bool {
+    for _, k := range NodeKinds {
+        if k == s { return true }
+    }
+    return false
+}
+
+func (p *pseudoParser) header(f *PseudoFile) {
+    kw := p.next()
+    if f.Workflow != nil {
+        if f.Workflow.Name != "" { p.fail(kw.pos, "only one workflow per file (first at %d:%d)", f.Workflow.Pos.Line, f.Workflow.Pos.Col) }
+    } else {
+        f.Workflow = &WorkflowDecl{}
+    }
+    w := f.Workflow
+    w.Pos = kw.pos
+    w.Name = p.expect(tokString, "workflow name").text
+    if w.Name == "" { p.diags.add(kw.pos, "workflow name is empty") }
+    p.expectWord("version")
+    v := p.next()
+    if v.kind != tokNumber && v.kind != tokIdent && v.kind != tokString { p.fail(v.pos, "expected a version, found %s", v) }
+    w.Version = v.text
+    p.expect(tokColon, `":" after the version`)
+    p.endLine()
+}
+
+func (p *pseudoParser) node(w *WorkflowDecl) {
+    kind := p.next()
+    n := &NodeDecl{Pos: kind.pos, Kind: kind.text}
+    n.Name = p.expect(tokString, "node name").text
+    if n.Name == "" { p.fail(kind.pos, "node name is empty") }
+    seen := map[string]bool{}
+    for p.at(tokIdent) {
+        clause := p.next()
+        if seen[clause.text] { p.fail(clause.pos, "duplicate %q clause", clause.text) }
+        seen[clause.text] = true
+        switch clause.text {
+        case "using":
+            n.Using = p.expect(tokString, `a quoted reference after "using"`).text
+        case "at":
+            n.At = p.expect(tokString, `a quoted path after "at"`).text
+        case "with":
+            n.Params = map[string]string{}
+            for {
+                key := p.expect(tokIdent, "a parameter name")
+                if _, dup := n.Params[key.text]; dup { p.fail(key.pos, "duplicate parameter %q", key.text) }
+                p.expect(tokAssign, `"=" after `+key.text)
+                val := p.next()
+                if val.kind != tokString && val.kind != tokNumber && val.kind != tokIdent {
+                    p.fail(val.pos, "expected a value for %s, found %s", key.text, val)
+                }
+                n.Params[key.text] = val.text
+                if !p.accept(tokComma) { break }
             }
-            nodes = append(nodes, []string{m[0], m[1], m[2], using, pathAttr})
+        default:
+            p.fail(clause.pos, "unknown clause %q (want using, at or with)", clause.text)
         }
     }
-    wf.NodeMatches = nodes
+    p.endLine()
+    w.Nodes = append(w.Nodes, n)
+}
+
+func (p *pseudoParser) connect(w *WorkflowDecl) {
+    kw := p.next()
+    c := &ConnectDecl{Pos: kw.pos}
+    c.From = p.refs()
+    p.expect(tokArrow, `"->"`)
+    c.To = p.refs()
+    switch {
+    case p.atWord("when"):
+        p.next()
+        c.When = p.cond()
+    case p.atWord("otherwise"):
+        p.next()
+        c.Otherwise = true
+    }
+    p.endLine()
+    w.Connects = append(w.Connects, c)
+}
+
+func (p *pseudoParser) refs() []NodeRef {
+    if t := p.peek(); t.kind == tokString {
+        p.next()
+        return []NodeRef{{Pos: t.pos, Name: t.text}}
+    }
+    p.expect(tokLBrack, `a node name or "["`)
+    var out []NodeRef
+    for {
+        t := p.expect(tokString, "a node name")
+        out = append(out, NodeRef{Pos: t.pos, Name: t.text})
+        if !p.accept(tokComma) { break }
+    }
+    p.expect(tokRBrack, `"]"`)
+    return out
+}
 
-    connRe := regexp.MustCompile(`(?m)^\s*connect\s+"([^"]+)"\s*->\s*(\[[^\]]+\]|"[^"]+")`)
-    wf.Connections = connRe.FindAllStringSubmatch(content, -1)
+func (p *pseudoParser) cond() Cond {
+    c := p.condAnd()
+    for p.atWord("or") {
+        p.next()
+        c = Logical{Op: "or", L: c, R: p.condAnd()}
+    }
+    return c
+}
 
-    return wf
+func (p *pseudoParser) condAnd() Cond {
+    c := p.condTerm()
+    for p.atWord("and") {
+        p.next()
+        c = Logical{Op: "and", L: c, R: p.condTerm()}
+    }
+    return c
 }
 
+func (p *pseudoParser) condTerm() Cond {
+    if p.accept(tokLParen) {
+        c := p.cond()
+        p.expect(tokRParen, `")"`)
+        return c
+    }
+    field := p.expect(tokIdent, "a payload field")
+    cmp := Compare{Field: field.text}
+    switch {
+    case p.at(tokOp):
+        cmp.Op = p.next().text
+    case p.atWord("contains"):
+        cmp.Op = p.next().text
+    default:
+        return cmp
+    }
+    v := p.next()
+    switch {
+    case v.kind == tokString:
+        cmp.Value = v.text
+    case v.kind == tokNumber:
+        f, err := strconv.ParseFloat(v.text, 64)
+        if err != nil { p.fail(v.pos, "bad number %q", v.text) }
+        cmp.Value = f
+    case v.kind == tokIdent && (v.text == "true" || v.text == "false"):
+        cmp.Value = v.text == "true"
+    case v.kind == tokIdent && v.text == "null":
+        cmp.Value = nil
+    default:
+        p.fail(v.pos, "expected a string, number, true, false or null, found %s", v)
+    }
+    if cmp.Op != "==" && cmp.Op != "!=" && cmp.Op != "contains" && cmp.Value != nil {
+        if _, isBool := cmp.Value.(bool); isBool { p.fail(v.pos, "%s needs a number or string", cmp.Op) }
+    }
+    return cmp
+}
@@ -0,0 +1,119 @@
+package generator
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestParsePseudoSource(t *testing.T) {
+    src := `# Lead routing
+workflow "Leads" version 2.1:
+  trigger "Hook" at "/lead"   // inbound
+  action "Score" using "workers/ai_worker" with model="small", threshold=0.5
+  switch "Hot?"
+  action "Push" using "workers/crm_worker"
+  action "Nurture" using "queue/nats_engine"
+  merge "Done"
+  connect "Hook" -> "Score"
+  connect "Score" -> "Hot?"
+  connect "Hot?" -> "Push" when score >= 50 and (tier == "gold" or vip)
+  connect "Hot?" -> "Nurture" otherwise
+  connect ["Push", "Nurture"] -> "Done"
+`
+    f, diags := ParsePseudoSource("leads.pseudo", src)
+    diags = append(diags, CheckPseudo(f)...)
+    if len(diags) > 0 { t.Fatalf("unexpected diagnostics:\n%v", diags) }
+    w := f.Workflow
+    if w.Name != "Leads" || w.Version != "2.1" || len(w.Nodes) != 6 || len(w.Connects) != 5 {
+        t.Fatalf("workflow = %+v", w)
+    }
+    if got := w.Node("Score").Params; got["model"] != "small" || got["threshold"] != "0.5" { t.Fatalf("params = %v", got) }
+    if p := w.Node("Hook").Pos; p.Line != 3 || p.Col != 3 { t.Fatalf("pos = %v", p) }
+
+    when := w.Connects[2].When
+    if when == nil || !w.Connects[3].Otherwise { t.Fatal("conditions not parsed") }
+    for _, tc := range []struct {
+        vars map[string]any
+        want bool
+    }{
+        {map[string]any{"score": 70.0, "tier": "gold"}, true},
+        {map[string]any{"score": 70.0, "vip": true}, true},
+        {map[string]any{"score": "80", "tier": "silver"}, false},
+        {map[string]any{"score": 10.0, "tier": "gold"}, false},
+    } {
+        if got := when.Eval(tc.vars); got != tc.want { t.Errorf("%s with %v = %v", when, tc.vars, got) }
+    }
+
+    flat := f.Flat()
+    if len(flat.Connections) != 6 || flat.Connections[4][1] != "Push" || flat.Connections[4][2] != `"Done"` {
+        t.Fatalf("flat connections = %v", flat.Connections)
+    }
+}
+
+func TestPseudoDiagnostics(t *testing.T) {
+    src := `workflow "Bad" version 1:
+  trigger "T"
+  actoin "Oops"
+  action "A" using "x"
+  action "A" using "y"
+  action "B" using "x"
+  action "Lonely" using "x"
+  connect "T" -> "A"
+  connect "A" -> "B" when ok
+  connect "B" -> ["A", "Missing"]
+`
+    f, diags := ParsePseudoSource("bad.pseudo", src)
+    diags = append(diags, CheckPseudo(f)...)
+    want := []string{
+        `bad.pseudo:3:3: unknown node kind "actoin"`,
+        `bad.pseudo:5:3: duplicate node "A" (first declared at 4:3)`,
+        `bad.pseudo:7:3: action "Lonely" is unreachable`,
+        `bad.pseudo:9:3: conditions are only allowed on connections from a switch`,
+        `bad.pseudo:10:24: connect to unknown node "Missing"`,
+        `bad.pseudo:10:3: cycle: "A" -> "B" -> "A"`,
+    }
+    got := diags.Error()
+    for _, w := range want {
+        if !strings.Contains(got, w) { t.Errorf("missing %q in:\n%s", w, got) }
+    }
+    if len(diags) != len(want) { t.Errorf("got %d diagnostics, want %d:\n%s", len(diags), len(want), got) }
+}
+
+func TestPseudoSyntaxErrors(t *testing.T) {
+    for src, want := range map[string]string{
+        `action "A" using "x"`:                                              `1:1: statement before the`,
+        "workflow \"W\" version 1:\n  trigger \"T\" using":                  `2:20: expected a quoted reference`,
+        "workflow \"W\" version 1:\n  trigger \"T\" with a=1, a=2":          `2:25: duplicate parameter "a"`,
+        "workflow \"W\" version 1:\n  trigger \"T\"\n  connect \"T\" \"X\"": `3:15: expected "->"`,
+        "workflow \"W\" version 1:\n  trigger \"T\" @":                      `2:15: unexpected character '@'`,
+    } {
+        _, diags := ParsePseudoSource("", src)
+        if !strings.Contains(diags.Error(), want) { t.Errorf("%q: want %q, got %v", src, want, diags) }
+    }
+}
+
+func TestPseudoTriggerChecks(t *testing.T) {
+    src := `workflow "W" version 1:
+  trigger "Both" at "/a" with cron="@daily"
+  trigger "BadCron" with cron="61 * * * *"
+  trigger "BadZone" with cron="@daily", tz="Mars/Olympus"
+  trigger "Hook" at "hook" with method="TRACE", signed=maybe
+  trigger "Events" with queue="events"
+  action "A" using "x"
+  connect ["Both", "BadCron", "BadZone", "Hook", "Events"] -> "A"
+`
+    f, _ := ParsePseudoSource("t.pseudo", src)
+    got := CheckPseudo(f).Error()
+    for _, w := range []string{
+        `2:3: trigger "Both" sets at and cron; a trigger fires one way`,
+        `3:3: trigger "BadCron": cron "61 * * * *": minute: 61 out of range 0-59`,
+        `4:3: trigger "BadZone": tz: unknown time zone Mars/Olympus`,
+        `5:3: trigger "Hook": webhook path "hook" must start with /`,
+        `5:3: trigger "Hook": unsupported webhook method "TRACE"`,
+        `5:3: trigger "Hook": signed=maybe is not true or false`,
+    } {
+        if !strings.Contains(got, w) { t.Errorf("missing %q in:\n%s", w, got) }
+    }
+    if strings.Contains(got, "Events") { t.Errorf("queue trigger flagged:\n%s", got) }
+    if k := TriggerKind(f.Workflow.Nodes[4]); k != TriggerQueue { t.Errorf("kind = %s", k) }
+}
@@ -0,0 +1,167 @@
+package generator
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+    "github.com/bitesinbyte/ferret/pkg/engine/caption"
+    "github.com/bitesinbyte/ferret/pkg/external"
+)
+
+// Recycling defaults.
+const (
+    DefaultRecyclePercentile = 0.8
+    DefaultReshareInterval   = 30 * 24 * time.Hour
+)
+
+// RecycleOptions select the past posts RecycleAndSchedule re-queues.
+type RecycleOptions struct {
+    // Percentile is the share of the platform's published posts a post must
+    // beat on Metric (default 0.8, the top 20%).
+    Percentile  float64
+    Metric      string                   // RewardEngagement (default), RewardCTR or RewardConversion
+    MinInterval map[string]time.Duration // platform -> minimum time since the last share (DefaultReshareInterval)
+    MaxPosts    int                      // recycled rows per run (default 5)
+}
+
+// recyclePrefixes reframe an original caption when no unused variant is left.
+var recyclePrefixes = []string{"In case you missed it 👇", "Worth another look:", "From the archive:", "Still one of our most-read posts:"}
+
+// RecycleAndSchedule re-queues the org's best published posts. A post is
+// eligible when its latest outcome on opts.Metric beats opts.Percentile of
+// the platform's published posts and neither it nor a copy was shared within
+// the platform's MinInterval. Each copy goes to the original's platform
+// (limited to in.Platforms when set) in the next free slot of in's posting
+// policy, with a caption no earlier share used: the next unused variant of
+// the original's topic from in.Variants, else the original reframed with a
+// rotating intro and hashtag order. Copies carry recycled_from (the original
+// id) and recycle_count in their metadata. It returns the rows scheduled.
+func RecycleAndSchedule(ctx context.Context, repo calendarrepo.Repository, in PlanInput, opts RecycleOptions) (int, error) {
+    if in.OrgID == "" { return 0, fmt.Errorf("recycling needs an OrgID") }
+    if _, err := NewArm(Variant{}, "", calendarrepo.VariantOutcome{}, opts.Metric); err != nil { return 0, err }
+    var only map[string]bool
+    if len(in.Platforms) > 0 {
+        only = map[string]bool{}
+        for _, p := range in.Platforms {
+            name, err := external.CanonicalPlatform(p)
+            if err != nil { return 0, err }
+            only[name] = true
+        }
+    }
+    posts, err := repo.PublishedPosts(ctx, in.OrgID)
+    if err != nil { return 0, fmt.Errorf("load published posts: %w", err) }
+    copies, err := repo.RecycledCopies(ctx, in.OrgID)
+    if err != nil { return 0, fmt.Errorf("load recycled posts: %w", err) }
+    picks := pickRecycled(posts, copies, only, opts, in.StartAt)
+    if len(picks) == 0 { return 0, nil }
+
+    slots, err := planSlots(ctx, repo, in)
+    if err != nil { return 0, err }
+    var batch []calendarrepo.ScheduleInput
+    for _, pk := range picks {
+        meta := map[string]any{}
+        if len(pk.post.Metadata) > 0 { _ = json.Unmarshal(pk.post.Metadata, &meta) }
+        topic, _ := meta["topic"].(string)
+        variantID, _ := meta["variant_id"].(string)
+        res, newVariant, rotation, ok := rotateCaption(pk.post, variantID, pk.copies, topic, in.Variants[topic])
+        if !ok { continue }
+        when, err := slots.Next(pk.post.Platform, in.StartAt)
+        if err != nil { return 0, err }
+
+        for _, k := range []string{"arm_id", "experiment_id", "selection", "caption_warnings"} { delete(meta, k) }
+        meta["recycled_from"], meta["recycle_count"] = pk.post.ID, len(pk.copies)+1
+        meta["recycled_percentile"], meta["caption_rotation"] = pk.percentile, rotation
+        meta["hashtags"] = res.Hashtags
+        if newVariant != "" { meta["variant_id"] = newVariant }
+        if len(res.Warnings) > 0 { meta["caption_warnings"] = res.Warnings }
+        m := toJSON(meta)
+        var tags *string
+        if res.Hashtags != "" { tags = ptr(res.Hashtags) }
+        batch = append(batch, calendarrepo.ScheduleInput{
+            ID: newID(), OrgID: in.OrgID, CampaignID: pk.post.CampaignID, ContentID: pk.post.ContentID,
+            Platform: pk.post.Platform, Caption: ptr(res.Text), Hashtags: tags, ScheduledAt: when, MetadataJSON: &m,
+        })
+    }
+    return len(batch), repo.BulkSchedule(ctx, batch)
+}
+
+type recyclePick struct {
+    post       calendarrepo.PublishedPost
+    copies     []calendarrepo.RecycledCopy
+    percentile float64
+    score      float64
+}
+
+// pickRecycled ranks the eligible posts, best percentile first. A post's
+// percentile is the share of its platform's posts with a lower score.
+func pickRecycled(posts []calendarrepo.PublishedPost, copies map[string][]calendarrepo.RecycledCopy, only map[string]bool, opts RecycleOptions, now time.Time) []recyclePick {
+    if opts.Percentile <= 0 { opts.Percentile = DefaultRecyclePercentile }
+    if opts.MaxPosts <= 0 { opts.MaxPosts = 5 }
+    scores := map[string][]float64{}
+    all := make([]recyclePick, 0, len(posts))
+    for _, p := range posts {
+        a, _ := NewArm(Variant{}, "", p.Outcome, opts.Metric)
+        all = append(all, recyclePick{post: p, copies: copies[p.ID], score: a.Successes})
+        scores[p.Platform] = append(scores[p.Platform], a.Successes)
+    }
+    for _, s := range scores { sort.Float64s(s) }
+
+    var out []recyclePick
+    for _, pk := range all {
+        if only != nil && !only[pk.post.Platform] { continue }
+        s := scores[pk.post.Platform]
+        pk.percentile = float64(sort.SearchFloat64s(s, pk.score)) / float64(len(s))
+        if pk.score <= 0 || pk.percentile < opts.Percentile { continue }
+        interval, ok := opts.MinInterval[pk.post.Platform]
+        if !ok { interval = DefaultReshareInterval }
+        last := pk.post.PublishedAt
+        for _, c := range pk.copies {
+            if c.ScheduledAt.After(last) { last = c.ScheduledAt }
+        }
+        if now.Sub(last) < interval { continue }
+        out = append(out, pk)
+    }
+    sort.SliceStable(out, func(i, j int) bool {
+        if out[i].percentile != out[j].percentile { return out[i].percentile > out[j].percentile }
+        return out[i].score > out[j].score
+    })
+    if len(out) > opts.MaxPosts { out = out[:opts.MaxPosts] }
+    return out
+}
+
+// rotateCaption returns a caption no earlier share of post used: the next
+// unused variant (rotation "variant"), else the original reframed with an
+// intro and rotated hashtags (rotation "reframe"). ok is false when every
+// option was used.
+func rotateCaption(post calendarrepo.PublishedPost, variantID string, copies []calendarrepo.RecycledCopy, topic string, variants []Variant) (res caption.Result, newVariant, rotation string, ok bool) {
+    usedText := map[string]bool{strings.TrimSpace(post.Caption): true}
+    usedVariant := map[string]bool{variantID: true}
+    for _, c := range copies {
+        usedText[strings.TrimSpace(c.Caption)] = true
+        usedVariant[c.VariantID] = true
+    }
+    for i := range variants {
+        v := variants[(len(copies)+i)%len(variants)]
+        if usedVariant[v.ID] { continue }
+        if res = CompileCaption(post.Platform, topic, v); res.Text != "" && !usedText[res.Text] { return res, v.ID, "variant", true }
+    }
+
+    body := strings.TrimSpace(post.Caption)
+    tags := strings.Fields(post.Hashtags)
+    if h := strings.TrimSpace(post.Hashtags); h != "" { body = strings.TrimSpace(strings.TrimSuffix(body, h)) }
+    if len(tags) > 0 {
+        k := (len(copies) + 1) % len(tags)
+        tags = append(append([]string(nil), tags[k:]...), tags[:k]...)
+    }
+    for i := range recyclePrefixes {
+        intro := recyclePrefixes[(len(copies)+i)%len(recyclePrefixes)]
+        res = caption.Compile(post.Platform, caption.Input{Hook: intro, Body: body, Hashtags: tags})
+        if !usedText[res.Text] { return res, "", "reframe", true }
+    }
+    return caption.Result{}, "", "", false
+}
@@ -0,0 +1,56 @@
+package generator
+
+import (
+    "fmt"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/adapters/calendarrepo"
+)
+
+func TestPickRecycled(t *testing.T) {
+    now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+    var posts []calendarrepo.PublishedPost
+    for i := 1; i <= 10; i++ {
+        posts = append(posts, calendarrepo.PublishedPost{
+            ID: fmt.Sprintf("li%d", i), Platform: "linkedin", PublishedAt: now.AddDate(0, -2, 0),
+            Outcome: calendarrepo.VariantOutcome{Posts: 1, Impressions: 1000, Likes: int64(10 * i)},
+        })
+    }
+    posts = append(posts, calendarrepo.PublishedPost{ID: "tw1", Platform: "twitter", PublishedAt: now.AddDate(0, -2, 0), Outcome: calendarrepo.VariantOutcome{Likes: 500}})
+    copies := map[string][]calendarrepo.RecycledCopy{"li10": {{ID: "c1", ScheduledAt: now.AddDate(0, 0, -10)}}}
+
+    ids := func(ps []recyclePick) string {
+        var out []string
+        for _, p := range ps { out = append(out, p.post.ID) }
+        return strings.Join(out, ",")
+    }
+    // li9 and li10 beat 80% of linkedin posts, but li10 was re-shared 10 days
+    // ago; a lone twitter post beats nothing.
+    got := pickRecycled(posts, copies, nil, RecycleOptions{}, now)
+    if ids(got) != "li9" || got[0].percentile != 0.8 { t.Fatalf("picked %s (%+v)", ids(got), got) }
+
+    got = pickRecycled(posts, copies, nil, RecycleOptions{Percentile: 0.5, MinInterval: map[string]time.Duration{"linkedin": 7 * 24 * time.Hour}, MaxPosts: 3}, now)
+    if ids(got) != "li10,li9,li8" { t.Fatalf("picked %s", ids(got)) }
+    if got := pickRecycled(posts, copies, map[string]bool{"twitter": true}, RecycleOptions{Percentile: 0.5}, now); len(got) != 0 { t.Fatalf("picked %s", ids(got)) }
+}
+
+func TestRotateCaption(t *testing.T) {
+    post := calendarrepo.PublishedPost{ID: "p1", Platform: "linkedin", Caption: "Original hook\n\nBody text.\n\n#ai #growth", Hashtags: "#ai #growth"}
+    variants := []Variant{{ID: "v1", Title: "Original hook"}, {ID: "v2", Title: "Second hook", CTA: "Try it"}}
+
+    res, variant, rotation, ok := rotateCaption(post, "v1", nil, "ai growth", variants)
+    if !ok || variant != "v2" || rotation != "variant" || !strings.HasPrefix(res.Text, "Second hook\n\nTry it") { t.Fatalf("rotation = %q %s %s", res.Text, variant, rotation) }
+
+    // Every variant used: the original is reframed, never verbatim.
+    copies := []calendarrepo.RecycledCopy{{VariantID: "v2", Caption: res.Text}}
+    res, variant, rotation, ok = rotateCaption(post, "v1", copies, "ai growth", variants)
+    if !ok || variant != "" || rotation != "reframe" { t.Fatalf("rotation = %q %s %s", res.Text, variant, rotation) }
+    if res.Text != "Worth another look:\n\nOriginal hook\n\nBody text.\n\n#ai #growth" { t.Fatalf("reframed = %q", res.Text) }
+
+    copies = append(copies, calendarrepo.RecycledCopy{Caption: res.Text})
+    res, _, _, ok = rotateCaption(post, "v1", copies, "", nil)
+    // The next intro, with the hashtags rotated.
+    if !ok || res.Text != "From the archive:\n\nOriginal hook\n\nBody text.\n\n#growth #ai" { t.Fatalf("reframed = %q", res.Text) }
+}
@@ -0,0 +1,165 @@
+package generator
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Window is a local time-of-day range [Start, End) in minutes after midnight.
+// End before Start wraps past midnight (22:00-07:00).
+type Window struct {
+    Start, End int
+}
+
+// ParseWindow parses "HH:MM-HH:MM".
+func ParseWindow(s string) (Window, error) {
+    from, to, ok := strings.Cut(strings.TrimSpace(s), "-")
+    if !ok { return Window{}, fmt.Errorf("window %q: want HH:MM-HH:MM", s) }
+    start, err := parseClock(from)
+    if err != nil { return Window{}, fmt.Errorf("window %q: %w", s, err) }
+    end, err := parseClock(to)
+    if err != nil { return Window{}, fmt.Errorf("window %q: %w", s, err) }
+    if start == end { return Window{}, fmt.Errorf("window %q is empty", s) }
+    return Window{Start: start, End: end}, nil
+}
+
+// ParseWindows parses a list of "HH:MM-HH:MM" ranges.
+func ParseWindows(ss []string) ([]Window, error) {
+    out := make([]Window, 0, len(ss))
+    for _, s := range ss {
+        if strings.TrimSpace(s) == "" { continue }
+        w, err := ParseWindow(s)
+        if err != nil { return nil, err }
+        out = append(out, w)
+    }
+    return out, nil
+}
+
+func parseClock(s string) (int, error) {
+    t, err := time.Parse("15:04", strings.TrimSpace(s))
+    if err != nil { return 0, fmt.Errorf("bad time %q", s) }
+    return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w Window) contains(minute int) bool {
+    if w.Start < w.End { return minute >= w.Start && minute < w.End }
+    return minute >= w.Start || minute < w.End
+}
+
+// SlotPolicy describes when a platform may post, in the audience's time zone.
+type SlotPolicy struct {
+    Location    *time.Location      // audience time zone (ICP timezone); nil means UTC
+    Windows     map[string][]Window // posting windows per platform; none means any time
+    QuietHours  []Window            // never post inside these, on any platform
+    Spacing     time.Duration       // minimum gap between two posts on a platform
+    PerDayLimit int                 // posts per platform per local day; 0 means unlimited
+}
+
+// SlotPlanner hands out publish times under a SlotPolicy. Times already taken
+// (rows in scheduled_posts) count against spacing and daily caps.
+type SlotPlanner struct {
+    policy SlotPolicy
+    taken  map[string][]time.Time // per platform, sorted
+    perDay map[string]int         // platform|local date -> posts
+}
+
+// maxPlanDays bounds the slot search so impossible policies fail instead of spinning.
+const maxPlanDays = 366
+
+func NewSlotPlanner(policy SlotPolicy) *SlotPlanner {
+    if policy.Location == nil { policy.Location = time.UTC }
+    return &SlotPlanner{policy: policy, taken: map[string][]time.Time{}, perDay: map[string]int{}}
+}
+
+// Reserve marks t as used on platform, e.g. a row that is already scheduled.
+func (s *SlotPlanner) Reserve(platform string, t time.Time) {
+    platform = strings.ToLower(platform)
+    ts := append(s.taken[platform], t)
+    sort.Slice(ts, func(i, j int) bool { return ts[i].Before(ts[j]) })
+    s.taken[platform] = ts
+    s.perDay[s.dayKey(platform, t)]++
+}
+
+// Next returns the first time at or after `after` that is inside one of the
+// platform's windows, outside quiet hours, at least Spacing away from every
+// taken slot and on a local day below PerDayLimit, and reserves it.
+func (s *SlotPlanner) Next(platform string, after time.Time) (time.Time, error) {
+    platform = strings.ToLower(platform)
+    loc := s.policy.Location
+    t := after.Truncate(time.Minute)
+    if t.Before(after) { t = t.Add(time.Minute) }
+    limit := after.AddDate(0, 0, maxPlanDays)
+    for t.Before(limit) {
+        local := t.In(loc)
+        minute := local.Hour()*60 + local.Minute()
+        if s.policy.PerDayLimit > 0 && s.perDay[s.dayKey(platform, t)] >= s.policy.PerDayLimit {
+            t = localAt(local, 1, 0)
+            continue
+        }
+        if q, ok := findWindow(s.policy.QuietHours, minute); ok {
+            t = windowEnd(local, q, minute)
+            continue
+        }
+        if windows := s.policy.Windows[platform]; len(windows) > 0 {
+            if _, ok := findWindow(windows, minute); !ok {
+                t = nextWindowStart(local, windows, minute)
+                continue
+            }
+        }
+        if next, clash := s.clash(platform, t); clash {
+            t = next
+            continue
+        }
+        s.Reserve(platform, t)
+        return t, nil
+    }
+    return time.Time{}, fmt.Errorf("no %s slot within %d days of %s", platform, maxPlanDays, after.Format(time.RFC3339))
+}
+
+// clash reports whether t is closer than Spacing to a taken slot and, if so,
+// the earliest time after that slot's spacing.
+func (s *SlotPlanner) clash(platform string, t time.Time) (time.Time, bool) {
+    gap := s.policy.Spacing
+    if gap <= 0 { return t, false }
+    for _, b := range s.taken[platform] {
+        if d := t.Sub(b); d < gap && d > -gap { return b.Add(gap), true }
+    }
+    return t, false
+}
+
+func (s *SlotPlanner) dayKey(platform string, t time.Time) string {
+    return platform + "|" + t.In(s.policy.Location).Format("2006-01-02")
+}
+
+func findWindow(ws []Window, minute int) (Window, bool) {
+    for _, w := range ws {
+        if w.contains(minute) { return w, true }
+    }
+    return Window{}, false
+}
+
+// localAt returns the local day `days` after local's date at `minute`.
+// time.Date normalizes DST gaps.
+func localAt(local time.Time, days, minute int) time.Time {
+    y, m, d := local.Date()
+    return time.Date(y, m, d+days, 0, minute, 0, 0, local.Location())
+}
+
+// windowEnd is when w (which contains minute) ends.
+func windowEnd(local time.Time, w Window, minute int) time.Time {
+    if minute < w.End { return localAt(local, 0, w.End) }
+    return localAt(local, 1, w.End)
+}
+
+// nextWindowStart is the earliest window start after minute, today or tomorrow.
+func nextWindowStart(local time.Time, ws []Window, minute int) time.Time {
+    var best time.Time
+    for _, w := range ws {
+        c := localAt(local, 0, w.Start)
+        if w.Start <= minute { c = localAt(local, 1, w.Start) }
+        if best.IsZero() || c.Before(best) { best = c }
+    }
+    return best
+}
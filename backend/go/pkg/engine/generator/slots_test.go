@@ -0,0 +1,59 @@
+package generator
+
+import (
+    "testing"
+    "time"
+)
+
+func TestSlotPlannerWindowsCapsAndCollisions(t *testing.T) {
+    la, err := time.LoadLocation("America/Los_Angeles")
+    if err != nil { t.Skip(err) }
+    windows, _ := ParseWindows([]string{"09:00-17:00"})
+    s := NewSlotPlanner(SlotPolicy{
+        Location:    la,
+        Windows:     map[string][]Window{"linkedin": windows},
+        Spacing:     2 * time.Hour,
+        PerDayLimit: 3,
+    })
+    // An existing row at 09:30 pushes the first slot past its spacing.
+    s.Reserve("linkedin", time.Date(2026, 3, 2, 9, 30, 0, 0, la))
+    start := time.Date(2026, 3, 2, 3, 0, 0, 0, la) // 3am local
+    want := []string{"2026-03-02 11:30", "2026-03-02 13:30", "2026-03-03 09:00", "2026-03-03 11:00"}
+    for i, w := range want {
+        got, err := s.Next("linkedin", start)
+        if err != nil { t.Fatal(err) }
+        if g := got.In(la).Format("2006-01-02 15:04"); g != w { t.Fatalf("slot %d = %s, want %s", i, g, w) }
+    }
+}
+
+func TestSlotPlannerQuietHoursWrapMidnight(t *testing.T) {
+    quiet, err := ParseWindows([]string{"22:00-07:00"})
+    if err != nil { t.Fatal(err) }
+    s := NewSlotPlanner(SlotPolicy{QuietHours: quiet, Spacing: time.Hour})
+    got, err := s.Next("twitter", time.Date(2026, 5, 1, 23, 30, 0, 0, time.UTC))
+    if err != nil { t.Fatal(err) }
+    if want := time.Date(2026, 5, 2, 7, 0, 0, 0, time.UTC); !got.Equal(want) { t.Fatalf("slot = %s, want %s", got, want) }
+    if _, err := ParseWindow("9am-5pm"); err == nil { t.Fatal("expected parse error") }
+}
+
+func TestBusySinceCountsEarlierPostsOnTheSameDay(t *testing.T) {
+    la, err := time.LoadLocation("America/Los_Angeles")
+    if err != nil { t.Skip(err) }
+    policy := SlotPolicy{Location: la, Spacing: 2 * time.Hour, PerDayLimit: 1}
+    noon := time.Date(2026, 3, 2, 12, 0, 0, 0, la)
+    morning := time.Date(2026, 3, 2, 8, 0, 0, 0, la)
+    since := busySince(policy, noon)
+    if want := time.Date(2026, 3, 2, 0, 0, 0, 0, la); !since.Equal(want) { t.Fatalf("since = %s, want %s", since, want) }
+    if morning.Before(since) { t.Fatalf("morning post %s not loaded from %s", morning, since) }
+
+    // Reserved, the morning post uses up the day's cap for a noon run.
+    s := NewSlotPlanner(policy)
+    s.Reserve("linkedin", morning)
+    got, err := s.Next("linkedin", noon)
+    if err != nil { t.Fatal(err) }
+    if g := got.In(la).Format("2006-01-02"); g != "2026-03-03" { t.Fatalf("slot = %s, want next day", got.In(la)) }
+
+    // Just after midnight, Spacing reaches back into the previous day.
+    early := time.Date(2026, 3, 2, 1, 0, 0, 0, la)
+    if got, want := busySince(policy, early), early.Add(-2*time.Hour); !got.Equal(want) { t.Fatalf("since = %s, want %s", got, want) }
+}
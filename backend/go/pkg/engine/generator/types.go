@@ -1,6 +1,9 @@
 package generator
 
-import "time"
+import (
+    "math/rand"
+    "time"
+)
 
 type Trend struct {
     Topic       string  `json:"topic"`
@@ -11,10 +14,12 @@ type Trend struct {
 }
 
 type Variant struct {
-    ID        string `json:"id"`
-    Title     string `json:"title,omitempty"`
-    CTA       string `json:"cta,omitempty"`
-    IsControl bool   `json:"is_control"`
+    ID        string   `json:"id"`
+    Title     string   `json:"title,omitempty"` // hook / opening line
+    Body      string   `json:"body,omitempty"`  // caption text after the hook
+    CTA       string   `json:"cta,omitempty"`
+    Hashtags  []string `json:"hashtags,omitempty"` // explicit hashtags, best first
+    IsControl bool     `json:"is_control"`
 }
 
 type PlanInput struct {
@@ -23,6 +28,22 @@ type PlanInput struct {
     Platforms   []string
     StartAt     time.Time
     Spacing     time.Duration // min spacing per platform
-    PerDayLimit int
+    PerDayLimit int           // posts per platform per local day
+
+    // OrgID owns the rows; it also selects the ICP timezone and the existing
+    // scheduled_posts the plan must not collide with.
+    OrgID      string
+    Timezone   string              // IANA zone; empty uses the org's ICP timezone, then UTC
+    Windows    map[string][]string // platform -> posting windows ("09:00-17:00"), local time
+    QuietHours []string            // local ranges never used, e.g. "22:00-07:00"
+
+    // Variant selection. Without Bandit each topic schedules its control and
+    // first other variant; with it, the VariantsPerTopic best ranked arms
+    // over the org's post_outcomes (see Bandit).
+    Bandit           string     // BanditThompson or BanditUCB
+    BanditMetric     string     // reward: RewardEngagement (default), RewardCTR or RewardConversion
+    ExperimentID     string     // only this experiment's arms and posts count; posts are linked to their arm
+    VariantsPerTopic int        // default 2
+    Rand             *rand.Rand // Thompson sampling source (default time-seeded)
 }
 
@@ -49,6 +49,27 @@ func (c *PulsarClient) NewProducer(topic string) (*PulsarProducer, error) {
     return &PulsarProducer{p: p}, nil
 }
 
+// Subscribe consumes topic on a shared subscription until ctx is done. A
+// message is acked when handle succeeds and negatively acked (redelivered
+// after a delay) when it fails.
+func (c *PulsarClient) Subscribe(ctx context.Context, topic, subscription string, handle func(context.Context, []byte) error) error {
+    cons, err := c.c.Subscribe(pulsar.ConsumerOptions{Topic: topic, SubscriptionName: subscription, Type: pulsar.Shared})
+    if err != nil { return err }
+    defer cons.Close()
+    for {
+        msg, err := cons.Receive(ctx)
+        if err != nil {
+            if ctx.Err() != nil { return nil }
+            return err
+        }
+        if err := handle(ctx, msg.Payload()); err != nil {
+            cons.Nack(msg)
+            continue
+        }
+        if err := cons.Ack(msg); err != nil { return err }
+    }
+}
+
 func (p *PulsarProducer) SendJSON(v any) error {
     b, err := json.Marshal(v)
     if err != nil { return err }
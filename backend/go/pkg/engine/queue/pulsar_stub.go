@@ -1,6 +1,7 @@
 package queue
 
 import (
+    "context"
     "errors"
     "os"
 )
@@ -34,6 +35,10 @@ func (c *PulsarClient) NewProducer(topic string) (*PulsarProducer, error) {
     return nil, errors.New("pulsar: disabled")
 }
 
+func (c *PulsarClient) Subscribe(ctx context.Context, topic, subscription string, handle func(context.Context, []byte) error) error {
+    return errors.New("pulsar: disabled")
+}
+
 func (p *PulsarProducer) SendJSON(v any) error { return errors.New("pulsar: disabled") }
 func (p *PulsarProducer) Close() error         { return nil }
 
@@ -0,0 +1,165 @@
+// Package ratelimit paces publishing with token buckets per platform and per
+// account. Bucket state lives in a Store: MemoryStore for a single process,
+// ValkeyStore to share one budget between poster replicas.
+package ratelimit
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/engine/metrics"
+    "github.com/bitesinbyte/ferret/pkg/engine/telemetry"
+)
+
+// Rate is a token bucket: PerSecond tokens are added each second up to Burst.
+type Rate struct {
+    PerSecond float64
+    Burst     int
+}
+
+func (r Rate) enabled() bool { return r.PerSecond > 0 }
+
+func (r Rate) burst() int {
+    if r.Burst > 0 { return r.Burst }
+    if r.PerSecond >= 1 { return int(r.PerSecond) }
+    return 1
+}
+
+// Store holds bucket state.
+type Store interface {
+    // Take removes one token from the bucket at key, or reports how long until
+    // one is available (wait > 0, nothing taken). remaining is the budget left.
+    Take(ctx context.Context, key string, rate Rate) (wait time.Duration, remaining float64, err error)
+    // Block empties the bucket at key for d, e.g. a platform's Retry-After.
+    Block(ctx context.Context, key string, rate Rate, d time.Duration) error
+    // Refund puts back one token taken from the bucket at key (up to Burst).
+    Refund(ctx context.Context, key string, rate Rate) error
+}
+
+// Limiter hands out publish slots. Platform buckets are shared by every
+// account (app-level limits); account buckets apply per social account when
+// AccountRates has an entry for the platform.
+type Limiter struct {
+    Store        Store
+    Rates        map[string]Rate // per platform (lower-case)
+    AccountRates map[string]Rate // per account on a platform; missing means none
+    Default      Rate            // platforms without an entry in Rates
+    Prefix       string          // bucket key prefix; default "ratelimit:"
+    // MaxWait bounds how long Wait sleeps; a longer wait returns a *WaitError
+    // so the caller can requeue instead. Zero waits as long as needed.
+    MaxWait time.Duration
+}
+
+// WaitError is returned by Wait when the next token is further away than MaxWait.
+type WaitError struct {
+    Key   string
+    Until time.Time
+}
+
+func (e *WaitError) Error() string {
+    return fmt.Sprintf("ratelimit: %s has no budget until %s", e.Key, e.Until.Format(time.RFC3339))
+}
+
+// RateLimitedUntil lets callers treat the error like a platform rate limit.
+func (e *WaitError) RateLimitedUntil() (time.Time, bool) { return e.Until, true }
+
+// rateLimited is satisfied by errors carrying a platform reset time
+// (external.PostError); it is declared here to keep this package standalone.
+type rateLimited interface {
+    RateLimitedUntil() (until time.Time, limited bool)
+}
+
+// Wait blocks until platform (and account, when non-empty) have budget for one
+// post, ctx ends or MaxWait would be exceeded. A failing store is logged and lets the post through: the
+// platform's own 429 and Retry-After still apply. When a later bucket fails
+// the tokens already taken are refunded, so posts that never go out do not
+// drain the shared platform budget.
+func (l *Limiter) Wait(ctx context.Context, platform, account string) (err error) {
+    platform = strings.ToLower(platform)
+    var taken []bucket
+    defer func() {
+        if err == nil { return }
+        for _, b := range taken { l.refund(ctx, b) }
+    }()
+    for _, b := range l.buckets(platform, account) {
+        start := time.Now()
+        for {
+            wait, remaining, err := l.Store.Take(ctx, b.key, b.rate)
+            if err != nil {
+                log.Printf("ratelimit: %s: %v (not limiting)", b.key, err)
+                break
+            }
+            if wait <= 0 {
+                taken = append(taken, b)
+                metrics.NewGauge("ratelimit_budget_" + b.metric).Set(remaining)
+                break
+            }
+            if l.MaxWait > 0 && time.Since(start)+wait > l.MaxWait {
+                return &WaitError{Key: b.key, Until: time.Now().Add(wait)}
+            }
+            t := time.NewTimer(wait)
+            select {
+            case <-ctx.Done():
+                t.Stop()
+                return ctx.Err()
+            case <-t.C:
+            }
+        }
+        if waited := time.Since(start).Seconds(); waited > 0.001 {
+            metrics.NewHistogram("ratelimit_wait_seconds").Observe(waited)
+            telemetry.RecordHistogram(ctx, "ratelimit_wait_seconds", waited, map[string]string{"platform": platform})
+        }
+    }
+    return nil
+}
+
+// Observe applies a platform's Retry-After from err, so every runner sharing
+// the store holds off until the reset. The account bucket is blocked when
+// there is one, otherwise the platform bucket.
+func (l *Limiter) Observe(ctx context.Context, platform, account string, err error) {
+    var rl rateLimited
+    if !errors.As(err, &rl) { return }
+    until, limited := rl.RateLimitedUntil()
+    if !limited || until.IsZero() { return }
+    d := time.Until(until)
+    if d <= 0 { return }
+    platform = strings.ToLower(platform)
+    bs := l.buckets(platform, account)
+    if len(bs) == 0 { return }
+    b := bs[len(bs)-1]
+    if berr := l.Store.Block(ctx, b.key, b.rate, d); berr != nil {
+        log.Printf("ratelimit: block %s: %v", b.key, berr)
+        return
+    }
+    metrics.NewGauge("ratelimit_budget_" + b.metric).Set(0)
+    telemetry.RecordCounter(ctx, "ratelimit_blocked_total", 1, map[string]string{"platform": platform})
+}
+
+// refund returns a token Wait took; ctx may already be cancelled.
+func (l *Limiter) refund(ctx context.Context, b bucket) {
+    if err := l.Store.Refund(context.WithoutCancel(ctx), b.key, b.rate); err != nil {
+        log.Printf("ratelimit: refund %s: %v", b.key, err)
+    }
+}
+
+type bucket struct {
+    key, metric string
+    rate        Rate
+}
+
+func (l *Limiter) buckets(platform, account string) []bucket {
+    prefix := l.Prefix
+    if prefix == "" { prefix = "ratelimit:" }
+    var out []bucket
+    rate, ok := l.Rates[platform]
+    if !ok { rate = l.Default }
+    if rate.enabled() { out = append(out, bucket{key: prefix + platform, metric: platform, rate: rate}) }
+    if ar, ok := l.AccountRates[platform]; ok && ar.enabled() && account != "" {
+        out = append(out, bucket{key: prefix + platform + ":" + account, metric: platform + "_account", rate: ar})
+    }
+    return out
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+    "bufio"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/engine/cache"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestMemoryStoreRefillsAndBlocks(t *testing.T) {
+    c := &clock{t: time.Unix(1000, 0)}
+    s := &MemoryStore{Now: c.now}
+    ctx := context.Background()
+    rate := Rate{PerSecond: 2, Burst: 2}
+    for i := 0; i < 2; i++ {
+        if wait, _, _ := s.Take(ctx, "k", rate); wait != 0 { t.Fatalf("take %d waited %s", i, wait) }
+    }
+    if wait, _, _ := s.Take(ctx, "k", rate); wait != 500*time.Millisecond { t.Fatalf("empty bucket wait = %s", wait) }
+    c.t = c.t.Add(500 * time.Millisecond)
+    if wait, _, _ := s.Take(ctx, "k", rate); wait != 0 { t.Fatalf("refilled bucket waited %s", wait) }
+
+    _ = s.Block(ctx, "k", rate, time.Minute)
+    c.t = c.t.Add(30 * time.Second)
+    if wait, _, _ := s.Take(ctx, "k", rate); wait != 30*time.Second { t.Fatalf("blocked wait = %s", wait) }
+    c.t = c.t.Add(31 * time.Second)
+    if wait, _, _ := s.Take(ctx, "k", rate); wait != 0 { t.Fatalf("after block waited %s", wait) }
+}
+
+type limitedErr struct{ until time.Time }
+
+func (e limitedErr) Error() string                         { return "429" }
+func (e limitedErr) RateLimitedUntil() (time.Time, bool) { return e.until, true }
+
+func TestLimiterObserveAndMaxWait(t *testing.T) {
+    l := &Limiter{
+        Store:        NewMemoryStore(),
+        Rates:        map[string]Rate{"twitter": {PerSecond: 100}},
+        AccountRates: map[string]Rate{"twitter": {PerSecond: 100}},
+        MaxWait:      time.Second,
+    }
+    ctx := context.Background()
+    if err := l.Wait(ctx, "Twitter", "acct-1"); err != nil { t.Fatal(err) }
+
+    // A 429 on acct-1 holds that account back but not others.
+    l.Observe(ctx, "twitter", "acct-1", fmt.Errorf("publish: %w", limitedErr{until: time.Now().Add(time.Hour)}))
+    var we *WaitError
+    if err := l.Wait(ctx, "twitter", "acct-1"); !errors.As(err, &we) || we.Key != "ratelimit:twitter:acct-1" {
+        t.Fatalf("wait = %v", err)
+    }
+    if err := l.Wait(ctx, "twitter", "acct-2"); err != nil { t.Fatalf("other account: %v", err) }
+    // Platforms without a rate (and no Default) are not limited.
+    if err := l.Wait(ctx, "mastodon", ""); err != nil { t.Fatal(err) }
+}
+
+func TestLimiterRefundsPlatformTokenWhenAccountIsSaturated(t *testing.T) {
+    now := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+    l := &Limiter{
+        Store:        &MemoryStore{Now: func() time.Time { return now }},
+        Rates:        map[string]Rate{"twitter": {PerSecond: 0.001, Burst: 2}},
+        AccountRates: map[string]Rate{"twitter": {PerSecond: 0.001, Burst: 1}},
+        MaxWait:      time.Second,
+    }
+    ctx := context.Background()
+    if err := l.Wait(ctx, "twitter", "acct-1"); err != nil { t.Fatal(err) }
+    // acct-1 is saturated; its refused waits must not spend platform budget.
+    for range 3 {
+        var we *WaitError
+        if err := l.Wait(ctx, "twitter", "acct-1"); !errors.As(err, &we) || we.Key != "ratelimit:twitter:acct-1" { t.Fatalf("wait = %v", err) }
+    }
+    if err := l.Wait(ctx, "twitter", "acct-2"); err != nil { t.Fatalf("platform budget drained by refused waits: %v", err) }
+    // The platform bucket is now empty for everyone.
+    if err := l.Wait(ctx, "twitter", "acct-3"); err == nil { t.Fatal("platform over budget") }
+}
+
+// TestValkeyStoreEval checks the EVAL request and reply decoding against a
+// fake RESP server.
+func TestValkeyStoreEval(t *testing.T) {
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil { t.Skip(err) }
+    defer ln.Close()
+    got := make(chan []string, 1)
+    go func() {
+        conn, err := ln.Accept()
+        if err != nil { return }
+        defer conn.Close()
+        r := bufio.NewReader(conn)
+        var n int
+        _, _ = fmt.Fscanf(r, "*%d\r\n", &n)
+        args := make([]string, n)
+        for i := range args {
+            var size int
+            _, _ = fmt.Fscanf(r, "$%d\r\n", &size)
+            buf := make([]byte, size+2)
+            if _, err := io.ReadFull(r, buf); err != nil { return }
+            args[i] = string(buf[:size])
+        }
+        got <- args
+        _, _ = conn.Write([]byte("*2\r\n:250\r\n$3\r\n1.5\r\n"))
+    }()
+
+    t.Setenv("VALKEY_PASSWORD", "")
+    t.Setenv("VALKEY_DB", "")
+    vc, err := cache.NewValkey(cache.ValkeyConfig{Addr: ln.Addr().String()})
+    if err != nil { t.Fatal(err) }
+    defer vc.Close()
+    wait, remaining, err := ValkeyStore{Valkey: vc}.Take(context.Background(), "ratelimit:x", Rate{PerSecond: 0.5, Burst: 3})
+    if err != nil { t.Fatal(err) }
+    if wait != 250*time.Millisecond || remaining != 1.5 { t.Fatalf("wait=%s remaining=%v", wait, remaining) }
+    args := <-got
+    if args[0] != "EVAL" || args[2] != "1" || args[3] != "ratelimit:x" || strings.Join(args[4:], ",") != "0.5,3,0" {
+        t.Fatalf("command = %q", args)
+    }
+}
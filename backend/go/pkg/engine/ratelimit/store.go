@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+    "context"
+    "fmt"
+    "math"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/engine/cache"
+)
+
+// MemoryStore keeps buckets in process memory. Refills are computed on Take,
+// so no goroutines or tickers are involved.
+type MemoryStore struct {
+    // Now overrides the clock (tests); nil uses time.Now.
+    Now func() time.Time
+
+    mu      sync.Mutex
+    buckets map[string]*memBucket
+}
+
+type memBucket struct {
+    tokens  float64
+    last    time.Time
+    blocked time.Time
+}
+
+func NewMemoryStore() *MemoryStore { return &MemoryStore{} }
+
+func (m *MemoryStore) Take(_ context.Context, key string, rate Rate) (time.Duration, float64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    now := m.now()
+    b := m.refill(key, rate, now)
+    if now.Before(b.blocked) { return b.blocked.Sub(now), 0, nil }
+    if b.tokens >= 1 {
+        b.tokens--
+        return 0, b.tokens, nil
+    }
+    return time.Duration((1 - b.tokens) / rate.PerSecond * float64(time.Second)), b.tokens, nil
+}
+
+func (m *MemoryStore) Block(_ context.Context, key string, rate Rate, d time.Duration) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    now := m.now()
+    b := m.refill(key, rate, now)
+    if until := now.Add(d); until.After(b.blocked) { b.blocked = until }
+    b.tokens, b.last = 0, b.blocked
+    return nil
+}
+
+func (m *MemoryStore) Refund(_ context.Context, key string, rate Rate) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    b := m.refill(key, rate, m.now())
+    b.tokens = math.Min(float64(rate.burst()), b.tokens+1)
+    return nil
+}
+
+func (m *MemoryStore) refill(key string, rate Rate, now time.Time) *memBucket {
+    if m.buckets == nil { m.buckets = map[string]*memBucket{} }
+    b, ok := m.buckets[key]
+    if !ok {
+        b = &memBucket{tokens: float64(rate.burst()), last: now}
+        m.buckets[key] = b
+    }
+    if now.After(b.last) {
+        b.tokens = math.Min(float64(rate.burst()), b.tokens+now.Sub(b.last).Seconds()*rate.PerSecond)
+        b.last = now
+    }
+    return b
+}
+
+func (m *MemoryStore) now() time.Time {
+    if m.Now != nil { return m.Now() }
+    return time.Now()
+}
+
+// ValkeyStore keeps buckets in Valkey so poster replicas share one budget.
+// Each Take/Block is a single Lua script using the server clock, so runners
+// with skewed clocks still agree.
+type ValkeyStore struct {
+    Valkey *cache.Valkey
+}
+
+// takeScript refills and takes from (or, with ARGV[3] > 0, blocks, and with
+// ARGV[3] < 0, refunds one token to) the bucket hash at KEYS[1]. ARGV: tokens
+// per second, burst, block milliseconds. Returns {wait ms, remaining tokens as
+// a string}.
+const takeScript = `
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local block = tonumber(ARGV[3])
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts', 'blocked')
+local tokens = tonumber(b[1]) or burst
+local ts = tonumber(b[2]) or now
+local blocked = tonumber(b[3]) or 0
+if now > ts then
+  tokens = math.min(burst, tokens + (now - ts) / 1000 * rate)
+  ts = now
+end
+local wait = 0
+if block > 0 then
+  if now + block > blocked then blocked = now + block end
+  tokens = 0
+  ts = blocked
+elseif block < 0 then
+  tokens = math.min(burst, tokens + 1)
+elseif now < blocked then
+  wait = blocked - now
+elseif tokens >= 1 then
+  tokens = tokens - 1
+else
+  wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts), 'blocked', tostring(blocked))
+redis.call('PEXPIRE', KEYS[1], math.max(blocked - now, 0) + math.ceil(burst / rate * 1000) + 1000)
+return {wait, tostring(tokens)}
+`
+
+func (v ValkeyStore) Take(_ context.Context, key string, rate Rate) (time.Duration, float64, error) {
+    return v.eval(key, rate, 0)
+}
+
+func (v ValkeyStore) Block(_ context.Context, key string, rate Rate, d time.Duration) error {
+    ms := d.Milliseconds()
+    if ms < 1 { ms = 1 }
+    _, _, err := v.eval(key, rate, ms)
+    return err
+}
+
+func (v ValkeyStore) Refund(_ context.Context, key string, rate Rate) error {
+    _, _, err := v.eval(key, rate, -1)
+    return err
+}
+
+func (v ValkeyStore) eval(key string, rate Rate, blockMs int64) (time.Duration, float64, error) {
+    reply, err := v.Valkey.Eval(takeScript, []string{key},
+        strconv.FormatFloat(rate.PerSecond, 'f', -1, 64), strconv.Itoa(rate.burst()), strconv.FormatInt(blockMs, 10))
+    if err != nil { return 0, 0, err }
+    parts, ok := reply.([]any)
+    if !ok || len(parts) != 2 { return 0, 0, fmt.Errorf("ratelimit: unexpected script reply %v", reply) }
+    waitMs, _ := parts[0].(int64)
+    remaining, _ := strconv.ParseFloat(fmt.Sprint(parts[1]), 64)
+    return time.Duration(waitMs) * time.Millisecond, remaining, nil
+}
@@ -1,6 +1,7 @@
 //go:build otel
 // +build otel
 
+package telemetry
 
 import (
     "context"
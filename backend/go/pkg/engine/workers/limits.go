@@ -0,0 +1,52 @@
+package workers
+
+import (
+    "errors"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/engine/ratelimit"
+    "github.com/bitesinbyte/ferret/pkg/external"
+)
+
+// DefaultMaxRateWait is how long PosterWorker waits for budget before giving
+// the row back as rate limited.
+const DefaultMaxRateWait = 2 * time.Minute
+
+// PlatformLimiter builds a limiter from the platform registry's RatePerSecond,
+// overridden by POSTER_RATE_<PLATFORM>. POSTER_ACCOUNT_RATE_<PLATFORM> adds a
+// per-account bucket. Both accept fractions (0.2 = one post every 5s). A nil
+// store keeps state in memory.
+func PlatformLimiter(store ratelimit.Store) *ratelimit.Limiter {
+    if store == nil { store = ratelimit.NewMemoryStore() }
+    l := &ratelimit.Limiter{
+        Store:        store,
+        Rates:        map[string]ratelimit.Rate{},
+        AccountRates: map[string]ratelimit.Rate{},
+        Default:      ratelimit.Rate{PerSecond: 2},
+        MaxWait:      DefaultMaxRateWait,
+    }
+    for _, p := range external.Platforms() {
+        name := strings.ToUpper(p.Name)
+        l.Rates[p.Name] = ratelimit.Rate{PerSecond: envRate("POSTER_RATE_"+name, float64(p.RatePerSecond))}
+        if r := envRate("POSTER_ACCOUNT_RATE_"+name, 0); r > 0 { l.AccountRates[p.Name] = ratelimit.Rate{PerSecond: r} }
+    }
+    return l
+}
+
+func envRate(key string, def float64) float64 {
+    if v := os.Getenv(key); v != "" {
+        if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 { return f }
+    }
+    return def
+}
+
+// rateLimitError turns a limiter timeout into a rate-limited PostError so
+// retry loops and the scheduler requeue the row for when budget returns.
+func rateLimitError(platform string, err error) error {
+    var we *ratelimit.WaitError
+    if !errors.As(err, &we) { return err }
+    return &external.PlatformError{Platform: platform, Kind: external.KindRateLimited, RetryAt: we.Until, Message: we.Error(), Err: we}
+}
@@ -0,0 +1,70 @@
+package workers
+
+import (
+    "encoding/json"
+    "strings"
+
+    "github.com/bitesinbyte/ferret/pkg/calendar"
+    "github.com/bitesinbyte/ferret/pkg/external"
+)
+
+// rowMetadata is the subset of scheduled_posts.metadata that shapes the post.
+// Media items accept our {url,type,alt} form and Postiz's {id,path}; Postiz
+// drafts call the list "image".
+type rowMetadata struct {
+    Media        []rowMedia        `json:"media"`
+    Image        []rowMedia        `json:"image"`
+    FirstComment string            `json:"first_comment"`
+    Overrides    map[string]string `json:"overrides"`
+    Thread       []struct {
+        Body  string     `json:"body"`
+        Media []rowMedia `json:"media"`
+    } `json:"thread"`
+}
+
+type rowMedia struct {
+    URL  string `json:"url"`
+    Path string `json:"path"`
+    Type string `json:"type"`
+    Alt  string `json:"alt"`
+}
+
+// PostFromRow builds the external.Post for a claimed row: the planner caption
+// becomes Body (with hashtags appended when the caption lacks them) and media,
+// first comment, per-platform overrides and thread come from metadata.
+func PostFromRow(row calendar.ScheduledPostRow) external.Post {
+    post := external.Post{
+        Title:    firstNonEmpty(row.ContentTitle.String, row.CampaignName),
+        Link:     row.ContentURL.String,
+        HashTags: row.Hashtags.String,
+    }
+    if body := strings.TrimSpace(row.Caption.String); body != "" {
+        if tags := strings.TrimSpace(post.HashTags); tags != "" && !strings.Contains(body, tags) {
+            body += "\n\n" + tags
+        }
+        post.Body = body
+    }
+    if len(row.Metadata) == 0 { return post }
+    var meta rowMetadata
+    if err := json.Unmarshal(row.Metadata, &meta); err != nil { return post }
+    post.Media = toMedia(append(meta.Media, meta.Image...))
+    post.FirstComment = meta.FirstComment
+    if len(meta.Overrides) > 0 {
+        post.Overrides = make(map[string]string, len(meta.Overrides))
+        for k, v := range meta.Overrides { post.Overrides[strings.ToLower(k)] = v }
+    }
+    for _, t := range meta.Thread {
+        post.Thread = append(post.Thread, external.Post{Body: t.Body, Media: toMedia(t.Media)})
+    }
+    return post
+}
+
+func toMedia(in []rowMedia) []external.Media {
+    var out []external.Media
+    for _, m := range in {
+        u := firstNonEmpty(m.URL, m.Path)
+        if u == "" { continue }
+        out = append(out, external.Media{URL: u, Type: external.MediaType(strings.ToLower(m.Type)), AltText: m.Alt})
+    }
+    return out
+}
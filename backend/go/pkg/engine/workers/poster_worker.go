@@ -2,29 +2,104 @@ package workers
 
 import (
     "context"
+    "database/sql"
     "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
     "time"
 
     "github.com/bitesinbyte/ferret/pkg/calendar"
     "github.com/bitesinbyte/ferret/pkg/config"
+    "github.com/bitesinbyte/ferret/pkg/engine/ratelimit"
     "github.com/bitesinbyte/ferret/pkg/external"
-    "github.com/bitesinbyte/ferret/pkg/factory"
+    "github.com/bitesinbyte/ferret/pkg/ledger"
 )
 
 type PosterWorker struct {
     DB        DB
     Now       func() time.Time
+    // Accounts resolves rows' social_account_id to credentials (e.g.
+    // accounts.Store). Rows without an account, or a nil Accounts, post with
+    // the env credentials.
+    Accounts  CredentialSource
+    // HTTP is handed to posters from the platform registry; nil uses their default.
+    HTTP      *http.Client
+    // Ledger makes publishing exactly-once (e.g. ledger.Store); nil publishes
+    // without recording intent, as before.
+    Ledger    Ledger
+    // Limits paces publishes per platform and account (see PlatformLimiter);
+    // nil publishes without waiting.
+    Limits    *ratelimit.Limiter
 }
 
+// Ledger records publish intent and outcome per idempotency key (see pkg/ledger).
+type Ledger interface {
+    Begin(ctx context.Context, key, scheduledPostID, platform, accountID string) (prev ledger.Entry, found bool, err error)
+    Complete(ctx context.Context, key, externalID string, response json.RawMessage) error
+    Reject(ctx context.Context, key string, cause error) error
+}
+
+// ErrOutcomeUnknown fails a row whose earlier publish attempt may have reached
+// a platform that cannot be reconciled; retrying could post twice, so the row
+// waits for someone to check the account.
+var ErrOutcomeUnknown = errors.New("outcome of an earlier publish attempt is unknown and the platform cannot be checked; verify the account before rescheduling")
+
+// CredentialSource resolves a social_accounts id to posting credentials.
+type CredentialSource interface {
+    Credentials(ctx context.Context, accountID string) (external.Credentials, error)
+}
+
+// CredentialsFor returns the credentials row should be published with. An
+// account whose token is missing or expired yields an auth-expired PostError
+// so the row fails without retries and flags the account for reconnecting.
+func CredentialsFor(ctx context.Context, src CredentialSource, row calendar.ScheduledPostRow, now time.Time) (external.Credentials, error) {
+    platform := string(row.Platform)
+    if src == nil || !row.SocialAccountID.Valid || row.SocialAccountID.String == "" {
+        return external.CredentialsFromEnv(platform), nil
+    }
+    creds, err := src.Credentials(ctx, row.SocialAccountID.String)
+    if err != nil { return external.Credentials{}, err }
+    if creds.AccessToken == "" {
+        return creds, external.NewPlatformError(platform, external.KindAuthExpired, fmt.Errorf("account %s has no access token", creds.AccountID))
+    }
+    if creds.Expired(now) {
+        return creds, external.NewPlatformError(platform, external.KindAuthExpired, fmt.Errorf("account %s token expired at %s", creds.AccountID, creds.ExpiresAt.Format(time.RFC3339)))
+    }
+    return creds, nil
+}
+
+// DB records post outcomes. metadata is merged into the row's existing
+// metadata rather than replacing it.
 type DB interface {
     UpdateStatus(ctx context.Context, id string, status calendar.ScheduledStatus, externalID *string, publishedAt *time.Time, metadata json.RawMessage) error
 }
 
+// CalendarDB adapts a Postgres handle to the DB interface via calendar.UpdatePostStatus.
+type CalendarDB struct {
+    SQL *sql.DB
+}
+
+func (d CalendarDB) UpdateStatus(ctx context.Context, id string, status calendar.ScheduledStatus, externalID *string, publishedAt *time.Time, metadata json.RawMessage) error {
+    return calendar.UpdatePostStatus(ctx, d.SQL, id, status, externalID, publishedAt, metadata)
+}
+
 func (w *PosterWorker) Post(ctx context.Context, row calendar.ScheduledPostRow, cfg config.Config) error {
-    poster := factory.CreateSocialPoster(string(row.Platform))
-    title := firstNonEmpty(row.ContentTitle.String, row.CampaignName)
-    post := external.Post{Title: title, Link: row.ContentURL.String, Description: "", HashTags: row.Hashtags.String}
+    poster, err := external.NewPoster(string(row.Platform), w.HTTP)
+    if err != nil { return external.NewPlatformError(string(row.Platform), external.KindPermanent, err) }
+    post := PostFromRow(row)
     publishedAt := w.clockNow()
+    if cp, ok := poster.(external.ContextPoster); ok {
+        creds, err := CredentialsFor(ctx, w.Accounts, row, publishedAt)
+        if err != nil { return err }
+        if w.Limits == nil { return w.publish(ctx, row, cfg, poster, cp, creds, post, publishedAt) }
+        platform := string(row.Platform)
+        if err := w.Limits.Wait(ctx, platform, creds.AccountID); err != nil { return rateLimitError(platform, err) }
+        err = w.publish(ctx, row, cfg, poster, cp, creds, post, publishedAt)
+        if err != nil { w.Limits.Observe(ctx, platform, creds.AccountID, err) }
+        return err
+    }
     if pwid, ok := poster.(external.PosterWithID); ok {
         id, err := pwid.PostWithID(cfg, post)
         if err != nil { return err }
@@ -34,6 +109,81 @@ func (w *PosterWorker) Post(ctx context.Context, row calendar.ScheduledPostRow,
     return w.DB.UpdateStatus(ctx, row.ID, calendar.StatusPublished, nil, &publishedAt, nil)
 }
 
+func (w *PosterWorker) publish(ctx context.Context, row calendar.ScheduledPostRow, cfg config.Config, poster external.Poster, cp external.ContextPoster, creds external.Credentials, post external.Post, publishedAt time.Time) error {
+    if w.Ledger != nil { return w.publishOnce(ctx, row, cfg, poster, cp, creds, post, publishedAt) }
+    id, err := cp.Publish(ctx, cfg, creds, post)
+    if err != nil { return err }
+    return w.DB.UpdateStatus(ctx, row.ID, calendar.StatusPublished, &id, &publishedAt, nil)
+}
+
+// publishOnce publishes row under its ledger key. A key already published only
+// has its status written again (the crash-after-accept case); a key left
+// pending by an earlier attempt is reconciled against the platform before
+// publishing again.
+func (w *PosterWorker) publishOnce(ctx context.Context, row calendar.ScheduledPostRow, cfg config.Config, poster external.Poster, cp external.ContextPoster, creds external.Credentials, post external.Post, publishedAt time.Time) error {
+    platform := string(row.Platform)
+    key := ledger.Key(row.ID)
+    prev, found, err := w.Ledger.Begin(ctx, key, row.ID, platform, creds.AccountID)
+    if err != nil { return err }
+    if found && prev.State == ledger.StatePublished {
+        at := prev.UpdatedAt
+        return w.DB.UpdateStatus(ctx, row.ID, calendar.StatusPublished, &prev.ExternalID, &at, nil)
+    }
+    if found && prev.State == ledger.StatePending {
+        rec, ok := poster.(external.Reconciler)
+        if !ok { return external.NewPlatformError(platform, external.KindPermanent, fmt.Errorf("%s: %w", key, ErrOutcomeUnknown)) }
+        id, published, err := rec.Reconcile(ctx, creds, post, prev.CreatedAt)
+        if err != nil { return err }
+        if published {
+            resp, _ := json.Marshal(map[string]any{"id": id, "reconciled": true})
+            if err := w.Ledger.Complete(ctx, key, id, resp); err != nil { return err }
+            return w.DB.UpdateStatus(ctx, row.ID, calendar.StatusPublished, &id, &publishedAt, nil)
+        }
+    }
+    id, err := cp.Publish(external.WithIdempotencyKey(ctx, key), cfg, creds, post)
+    if err != nil {
+        if notCreated(err) {
+            if lerr := w.Ledger.Reject(ctx, key, err); lerr != nil { log.Printf("ledger: %v", lerr) }
+        }
+        return err
+    }
+    resp, _ := json.Marshal(map[string]any{"id": id, "published_at": publishedAt})
+    if err := w.Ledger.Complete(ctx, key, id, resp); err != nil {
+        // The post is live; keep going so the row is not retried on the status alone.
+        log.Printf("ledger: %s published as %s but not recorded: %v", key, id, err)
+    }
+    return w.DB.UpdateStatus(ctx, row.ID, calendar.StatusPublished, &id, &publishedAt, nil)
+}
+
+// MarkFailed records a terminal failure for the row, adding the error to its
+// metadata alongside the keys already there.
+// Typed poster errors also record whether the account needs reconnecting.
+func (w *PosterWorker) MarkFailed(ctx context.Context, row calendar.ScheduledPostRow, cause error) error {
+    meta := map[string]any{"error": cause.Error()}
+    var pe external.PostError
+    if errors.As(cause, &pe) {
+        meta["auth_expired"] = pe.AuthExpired()
+        meta["quota_exceeded"] = pe.QuotaExceeded()
+    }
+    b, _ := json.Marshal(meta)
+    return w.DB.UpdateStatus(ctx, row.ID, calendar.StatusFailed, nil, nil, b)
+}
+
+// notCreated reports whether a failed publish proves nothing was created: a
+// 4xx client error other than 408 and 429. Gateway 5xx answers, timeouts and
+// dropped connections can follow an accepted create (or Instagram's container
+// step), so those keys stay pending and are reconciled on retry.
+func notCreated(err error) bool {
+    var pe *external.PlatformError
+    if !errors.As(err, &pe) { return false }
+    switch code := pe.StatusCode; {
+    case code == http.StatusRequestTimeout, code == http.StatusTooManyRequests:
+        return false
+    default:
+        return code >= 400 && code < 500
+    }
+}
+
 func (w *PosterWorker) clockNow() time.Time {
     if w.Now != nil { return w.Now() }
     return time.Now().UTC()
@@ -45,4 +195,3 @@ func firstNonEmpty(vals ...string) string {
     }
     return ""
 }
-
@@ -0,0 +1,190 @@
+package workers
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "testing"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/calendar"
+    "github.com/bitesinbyte/ferret/pkg/config"
+    "github.com/bitesinbyte/ferret/pkg/external"
+    "github.com/bitesinbyte/ferret/pkg/ledger"
+)
+
+type memLedger map[string]ledger.Entry
+
+func (m memLedger) Begin(_ context.Context, key, postID, platform, accountID string) (ledger.Entry, bool, error) {
+    prev, found := m[key]
+    if found && prev.State == ledger.StatePublished { return prev, true, nil }
+    next := ledger.Entry{Key: key, ScheduledPostID: postID, Platform: platform, State: ledger.StatePending, Attempts: prev.Attempts + 1, CreatedAt: prev.CreatedAt}
+    if !found { next.CreatedAt = time.Now() }
+    m[key] = next
+    return prev, found, nil
+}
+
+func (m memLedger) Complete(_ context.Context, key, externalID string, _ json.RawMessage) error {
+    e := m[key]
+    e.State, e.ExternalID = ledger.StatePublished, externalID
+    m[key] = e
+    return nil
+}
+
+func (m memLedger) Reject(_ context.Context, key string, cause error) error {
+    e := m[key]
+    e.State, e.Error = ledger.StateRejected, cause.Error()
+    m[key] = e
+    return nil
+}
+
+type statusLog []string
+
+func (s *statusLog) UpdateStatus(_ context.Context, id string, status calendar.ScheduledStatus, externalID *string, _ *time.Time, _ json.RawMessage) error {
+    ext := ""
+    if externalID != nil { ext = *externalID }
+    *s = append(*s, string(status)+":"+ext)
+    return nil
+}
+
+func TestPosterWorkerPublishesOnce(t *testing.T) {
+    creates := 0
+    timeline := `[]`
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.URL.Path {
+        case "/api/v1/statuses":
+            creates++
+            _, _ = io.WriteString(w, `{"id":"s1"}`)
+        case "/api/v1/accounts/verify_credentials":
+            _, _ = io.WriteString(w, `{"id":"7"}`)
+        case "/api/v1/accounts/7/statuses":
+            _, _ = io.WriteString(w, timeline)
+        }
+    }))
+    defer srv.Close()
+    t.Setenv("MASTODON_INSTANCE_URL", srv.URL)
+    t.Setenv("MASTODON_ACCESS_TOKEN", "t")
+
+    var db statusLog
+    l := memLedger{}
+    w := &PosterWorker{DB: &db, Ledger: l, HTTP: srv.Client()}
+    row := calendar.ScheduledPostRow{ID: "p1", Platform: calendar.PlatformMastodon}
+    row.Caption.String, row.Caption.Valid = "hello", true
+    ctx := context.Background()
+
+    if err := w.Post(ctx, row, config.Config{}); err != nil { t.Fatal(err) }
+    // A rerun after the status write was lost only rewrites the status.
+    if err := w.Post(ctx, row, config.Config{}); err != nil { t.Fatal(err) }
+    if creates != 1 { t.Fatalf("creates = %d, want 1", creates) }
+
+    // An attempt that crashed after sending is found on the timeline.
+    l[ledger.Key("p2")] = ledger.Entry{State: ledger.StatePending, CreatedAt: time.Now()}
+    timeline = `[{"id":"s0","content":"<p>hello</p>","created_at":"` + time.Now().UTC().Format(time.RFC3339) + `"}]`
+    row.ID = "p2"
+    if err := w.Post(ctx, row, config.Config{}); err != nil { t.Fatal(err) }
+    if creates != 1 { t.Fatalf("reconciled post was published again (creates = %d)", creates) }
+    if got := l[ledger.Key("p2")]; got.State != ledger.StatePublished || got.ExternalID != "s0" { t.Fatalf("ledger = %+v", got) }
+
+    want := []string{"published:s1", "published:s1", "published:s0"}
+    for i := range want {
+        if i >= len(db) || db[i] != want[i] { t.Fatalf("status writes = %v, want %v", db, want) }
+    }
+
+    // LinkedIn is reconciled against the author's recent posts.
+    li := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.URL.Path {
+        case "/v2/userinfo":
+            _, _ = io.WriteString(w, `{"sub":"abc"}`)
+        case "/rest/posts":
+            _, _ = fmt.Fprintf(w, `{"elements":[{"id":"urn:li:share:9","commentary":"hello","createdAt":%d}]}`, time.Now().UnixMilli())
+        default:
+            t.Errorf("unexpected LinkedIn call %s %s", r.Method, r.URL.Path)
+        }
+    }))
+    defer li.Close()
+    w.HTTP = &http.Client{Transport: rewriteHost{li}}
+    l[ledger.Key("p3")] = ledger.Entry{State: ledger.StatePending, CreatedAt: time.Now()}
+    row.ID, row.Platform = "p3", calendar.PlatformLinkedIn
+    t.Setenv("LINKEDIN_ACCESS_TOKEN", "t")
+    if err := w.Post(ctx, row, config.Config{}); err != nil { t.Fatal(err) }
+    if got := l[ledger.Key("p3")]; got.State != ledger.StatePublished || got.ExternalID != "urn:li:share:9" { t.Fatalf("ledger = %+v", got) }
+}
+
+// rewriteHost sends every request to srv, so posters with fixed API hosts
+// can be tested.
+type rewriteHost struct{ srv *httptest.Server }
+
+func (r rewriteHost) RoundTrip(req *http.Request) (*http.Response, error) {
+    u, _ := url.Parse(r.srv.URL)
+    req.URL.Scheme, req.URL.Host = u.Scheme, u.Host
+    return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestPosterWorkerReconcilesAfterGatewayError(t *testing.T) {
+    creates := 0
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.URL.Path {
+        case "/api/v1/statuses":
+            // The post is created, but the gateway answers 502.
+            creates++
+            w.WriteHeader(http.StatusBadGateway)
+        case "/api/v1/accounts/verify_credentials":
+            _, _ = io.WriteString(w, `{"id":"7"}`)
+        case "/api/v1/accounts/7/statuses":
+            if creates == 0 { _, _ = io.WriteString(w, `[]`); return }
+            _, _ = io.WriteString(w, `[{"id":"s1","content":"<p>hello</p>","created_at":"`+time.Now().UTC().Format(time.RFC3339)+`"}]`)
+        }
+    }))
+    defer srv.Close()
+    t.Setenv("MASTODON_INSTANCE_URL", srv.URL)
+    t.Setenv("MASTODON_ACCESS_TOKEN", "t")
+
+    var db statusLog
+    l := memLedger{}
+    w := &PosterWorker{DB: &db, Ledger: l, HTTP: srv.Client()}
+    row := calendar.ScheduledPostRow{ID: "p1", Platform: calendar.PlatformMastodon}
+    row.Caption.String, row.Caption.Valid = "hello", true
+    ctx := context.Background()
+
+    if err := w.Post(ctx, row, config.Config{}); err == nil { t.Fatal("502 reported as published") }
+    if got := l[ledger.Key("p1")]; got.State != ledger.StatePending { t.Fatalf("ledger after 502 = %+v, want pending", got) }
+    if err := w.Post(ctx, row, config.Config{}); err != nil { t.Fatal(err) }
+    if creates != 1 { t.Fatalf("creates = %d, want 1", creates) }
+    if got := l[ledger.Key("p1")]; got.State != ledger.StatePublished || got.ExternalID != "s1" { t.Fatalf("ledger = %+v", got) }
+}
+
+func TestNotCreatedOnlyForClientErrors(t *testing.T) {
+    for code, want := range map[int]bool{400: true, 403: true, 422: true, 408: false, 429: false, 500: false, 502: false, 504: false} {
+        err := &external.PlatformError{Platform: "x", StatusCode: code}
+        if got := notCreated(err); got != want { t.Errorf("notCreated(%d) = %v, want %v", code, got, want) }
+    }
+    if notCreated(errors.New("timeout")) { t.Error("transport error treated as not created") }
+}
+
+// metaDB applies UpdatePostStatus's top-level merge to an in-memory row.
+type metaDB map[string]map[string]any
+
+func (m metaDB) UpdateStatus(_ context.Context, id string, _ calendar.ScheduledStatus, _ *string, _ *time.Time, metadata json.RawMessage) error {
+    if metadata == nil { return nil }
+    var patch map[string]any
+    if err := json.Unmarshal(metadata, &patch); err != nil { return err }
+    if m[id] == nil { m[id] = map[string]any{} }
+    for k, v := range patch { m[id][k] = v }
+    return nil
+}
+
+func TestMarkFailedKeepsExistingMetadata(t *testing.T) {
+    db := metaDB{"p1": {"variant_id": "v1", "arm_id": "a1", "recycled_from": "p0", "media": []any{"m.png"}}}
+    w := &PosterWorker{DB: db}
+    if err := w.MarkFailed(context.Background(), calendar.ScheduledPostRow{ID: "p1"}, errors.New("boom")); err != nil { t.Fatal(err) }
+    got := db["p1"]
+    for _, k := range []string{"variant_id", "arm_id", "recycled_from", "media"} {
+        if _, ok := got[k]; !ok { t.Errorf("%s dropped by MarkFailed: %v", k, got) }
+    }
+    if got["error"] != "boom" { t.Errorf("error = %v", got["error"]) }
+}
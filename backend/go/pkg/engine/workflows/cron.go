@@ -2,25 +2,33 @@ package workflows
 
 import (
     "time"
+
+    "github.com/bitesinbyte/ferret/pkg/engine/cron"
 )
 
 // ScheduleInstagramTrendAnalysis runs the provided fetch function every 24 hours in a background goroutine.
 // It immediately invokes the function once, then on each tick.
 func ScheduleInstagramTrendAnalysis(fetch func()) func() {
+    return ScheduleCron(cron.Every(24*time.Hour), fetch, true)
+}
+
+// ScheduleCron runs fn in a background goroutine whenever s fires (once
+// first when immediate) until the returned stop function is called. Firings
+// due while fn is still running are skipped.
+func ScheduleCron(s cron.Schedule, fn func(), immediate bool) func() {
     stop := make(chan struct{})
     go func() {
-        ticker := time.NewTicker(24 * time.Hour)
-        defer ticker.Stop()
-        fetch()
-        for {
+        if immediate { fn() }
+        for next := s.Next(time.Now()); !next.IsZero(); next = s.Next(time.Now()) {
+            timer := time.NewTimer(time.Until(next))
             select {
-            case <-ticker.C:
-                fetch()
+            case <-timer.C:
+                fn()
             case <-stop:
+                timer.Stop()
                 return
             }
         }
     }()
     return func() { close(stop) }
 }
-
@@ -0,0 +1,97 @@
+package workflows
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/calendar"
+    "github.com/bitesinbyte/ferret/pkg/engine/eowr"
+    "github.com/bitesinbyte/ferret/pkg/engine/metrics"
+)
+
+func (s *Scheduler) runs() RunStore {
+    return RunStore{DB: s.DB, LeaseTTL: s.LeaseTTL}
+}
+
+func (s *Scheduler) owner() string {
+    if s.Owner != "" { return s.Owner }
+    return calendar.DefaultLeaseOwner()
+}
+
+// StartWorkflow runs the .pseudo workflow at source durably: the run and
+// every node checkpoint are stored in workflow_runs/workflow_run_nodes so a
+// crash or failure can be resumed with ResumeWorkflow.
+func (s *Scheduler) StartWorkflow(ctx context.Context, source, trigger string, in eowr.Payload) (WorkflowRun, *eowr.Result, error) {
+    g, err := eowr.Load(source, s.Nodes)
+    if err != nil { return WorkflowRun{}, nil, err }
+    run := WorkflowRun{Workflow: g.Name, Version: g.Version, Source: source, Trigger: trigger, Input: in}
+    if err := s.runs().Create(ctx, &run, s.owner()); err != nil { return run, nil, fmt.Errorf("workflows: create run: %w", err) }
+    return s.execute(ctx, run, g, nil)
+}
+
+// ResumeWorkflow claims run id (failed, or running with an expired lease) and
+// runs it again from its original input. Nodes that already succeeded are not
+// repeated; their saved outputs feed the rest of the graph.
+func (s *Scheduler) ResumeWorkflow(ctx context.Context, id string) (WorkflowRun, *eowr.Result, error) {
+    run, err := s.runs().Claim(ctx, id, s.owner())
+    if err != nil { return run, nil, err }
+    return s.resume(ctx, run)
+}
+
+func (s *Scheduler) resume(ctx context.Context, run WorkflowRun) (WorkflowRun, *eowr.Result, error) {
+    g, err := eowr.Load(run.Source, s.Nodes)
+    if err != nil {
+        if ferr := s.runs().Finish(ctx, run.ID, RunFailed, nil, err); ferr != nil { log.Printf("workflows: run %s: %v", run.ID, ferr) }
+        return run, nil, err
+    }
+    nodes, err := s.runs().Nodes(ctx, run.ID)
+    if err != nil { return run, nil, fmt.Errorf("workflows: run %s checkpoints: %w", run.ID, err) }
+    prev := make(map[string]eowr.NodeState, len(nodes))
+    for _, n := range nodes { prev[n.Node] = n }
+    metrics.NewCounter("workflow_runs_resumed_total").Inc(1)
+    log.Printf("workflows: resuming run %s (%s) invocation %d", run.ID, run.Workflow, run.Invocations)
+    return s.execute(ctx, run, g, prev)
+}
+
+func (s *Scheduler) execute(ctx context.Context, run WorkflowRun, g *eowr.Graph, prev map[string]eowr.NodeState) (WorkflowRun, *eowr.Result, error) {
+    res, err := g.Execute(ctx, eowr.Options{
+        Trigger:    run.Trigger,
+        Input:      run.Input,
+        RunID:      run.ID,
+        Checkpoint: s.runs(),
+        Previous:   prev,
+        Retry:      s.NodeRetry,
+    })
+    run.Status = RunSucceeded
+    if err != nil { run.Status = RunFailed }
+    if res != nil { run.Output = res.Output }
+    // The outcome must be recorded even when ctx was canceled mid-run.
+    if ferr := s.runs().Finish(context.WithoutCancel(ctx), run.ID, run.Status, run.Output, err); ferr != nil {
+        log.Printf("workflows: finish run %s: %v", run.ID, ferr)
+    }
+    metrics.NewCounter("workflow_runs_" + run.Status + "_total").Inc(1)
+    return run, res, err
+}
+
+// resumeStale resumes runs whose runner died (lease expired) every interval
+// until ctx is done; resumed runs finish on workCtx.
+func (s *Scheduler) resumeStale(ctx, workCtx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        runs, err := s.runs().ClaimStale(ctx, s.owner(), 10)
+        if err != nil && ctx.Err() == nil { log.Printf("workflows: claim stale runs: %v", err) }
+        for _, run := range runs {
+            if _, _, err := s.resume(workCtx, run); err != nil {
+                log.Printf("workflows: run %s (%s) failed: %v", run.ID, run.Workflow, err)
+            }
+        }
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
@@ -0,0 +1,258 @@
+package workflows
+
+import (
+    "context"
+    "crypto/rand"
+    "database/sql"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/engine/eowr"
+)
+
+// Workflow run states in workflow_runs.
+const (
+    RunRunning   = "running"
+    RunSucceeded = "succeeded"
+    RunFailed    = "failed"
+)
+
+// ErrRunNotFound is returned when no workflow run has the given id.
+var ErrRunNotFound = errors.New("workflows: run not found")
+
+// ErrRunBusy is returned when a run cannot be claimed: it already succeeded
+// or another runner holds its lease.
+var ErrRunBusy = errors.New("workflows: run finished or leased by another runner")
+
+// WorkflowRun is one workflow_runs row.
+type WorkflowRun struct {
+    ID          string
+    Workflow    string
+    Version     string
+    Source      string
+    Trigger     string
+    Input       eowr.Payload
+    Output      eowr.Payload
+    Status      string
+    Error       string
+    Invocations int
+    ClaimedBy   string
+    LeaseUntil  time.Time
+    CreatedAt   time.Time
+    UpdatedAt   time.Time
+    FinishedAt  time.Time
+}
+
+// RunFilter narrows RunStore.List; zero fields match everything.
+type RunFilter struct {
+    Workflow string
+    Status   string
+    Limit    int // default 50
+}
+
+// RunStore reads and writes workflow_runs and workflow_run_nodes. Node saves
+// renew the run's lease, so a run whose lease expired is not making progress.
+type RunStore struct {
+    DB       *sql.DB
+    LeaseTTL time.Duration // default 15m
+}
+
+var _ eowr.Checkpointer = RunStore{}
+
+func (s RunStore) ttl() time.Duration {
+    if s.LeaseTTL > 0 { return s.LeaseTTL }
+    return 15 * time.Minute
+}
+
+func newRunID() string {
+    var b [8]byte
+    _, _ = rand.Read(b[:])
+    return "wr_" + hex.EncodeToString(b[:])
+}
+
+func marshalPayload(p eowr.Payload) ([]byte, error) {
+    if p == nil { return nil, nil }
+    return json.Marshal(p)
+}
+
+func unmarshalPayload(b []byte) (eowr.Payload, error) {
+    if len(b) == 0 { return nil, nil }
+    var p eowr.Payload
+    err := json.Unmarshal(b, &p)
+    return p, err
+}
+
+const runColumns = `id, workflow, COALESCE(version, ''), source, COALESCE(trigger, ''), input, output, status,
+       COALESCE(error, ''), invocations, COALESCE(claimed_by, ''), lease_expires_at, created_at, updated_at, finished_at`
+
+func scanRun(row interface{ Scan(...any) error }) (WorkflowRun, error) {
+    var r WorkflowRun
+    var input, output []byte
+    var lease, finished sql.NullTime
+    if err := row.Scan(&r.ID, &r.Workflow, &r.Version, &r.Source, &r.Trigger, &input, &output, &r.Status,
+        &r.Error, &r.Invocations, &r.ClaimedBy, &lease, &r.CreatedAt, &r.UpdatedAt, &finished); err != nil {
+        return r, err
+    }
+    r.LeaseUntil, r.FinishedAt = lease.Time, finished.Time
+    var err error
+    if r.Input, err = unmarshalPayload(input); err != nil { return r, fmt.Errorf("run %s input: %w", r.ID, err) }
+    if r.Output, err = unmarshalPayload(output); err != nil { return r, fmt.Errorf("run %s output: %w", r.ID, err) }
+    return r, nil
+}
+
+// Create inserts a running run leased to owner and fills in its id.
+func (s RunStore) Create(ctx context.Context, r *WorkflowRun, owner string) error {
+    if r.ID == "" { r.ID = newRunID() }
+    input, err := marshalPayload(r.Input)
+    if err != nil { return err }
+    q := `
+INSERT INTO workflow_runs (id, workflow, version, source, trigger, input, status, claimed_by, lease_expires_at)
+VALUES ($1, $2, NULLIF($3, ''), $4, NULLIF($5, ''), $6, 'running', $7, NOW() + $8 * INTERVAL '1 second')
+RETURNING ` + runColumns
+    row := s.DB.QueryRowContext(ctx, q, r.ID, r.Workflow, r.Version, r.Source, r.Trigger, input, owner, s.ttl().Seconds())
+    created, err := scanRun(row)
+    if err != nil { return err }
+    *r = created
+    return nil
+}
+
+// Get returns the run with id.
+func (s RunStore) Get(ctx context.Context, id string) (WorkflowRun, error) {
+    r, err := scanRun(s.DB.QueryRowContext(ctx, `SELECT `+runColumns+` FROM workflow_runs WHERE id = $1`, id))
+    if errors.Is(err, sql.ErrNoRows) { return r, ErrRunNotFound }
+    return r, err
+}
+
+// List returns runs, newest first.
+func (s RunStore) List(ctx context.Context, f RunFilter) ([]WorkflowRun, error) {
+    limit := f.Limit
+    if limit <= 0 { limit = 50 }
+    q := `
+SELECT ` + runColumns + `
+FROM workflow_runs
+WHERE ($1 = '' OR workflow = $1) AND ($2 = '' OR status = $2)
+ORDER BY created_at DESC
+LIMIT $3`
+    rows, err := s.DB.QueryContext(ctx, q, f.Workflow, f.Status, limit)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var out []WorkflowRun
+    for rows.Next() {
+        r, err := scanRun(rows)
+        if err != nil { return nil, err }
+        out = append(out, r)
+    }
+    return out, rows.Err()
+}
+
+// Claim leases a run that is not finished for another invocation: a failed
+// run, or a running one whose lease expired (its runner died). The run goes
+// back to running with the invocation counted.
+func (s RunStore) Claim(ctx context.Context, id, owner string) (WorkflowRun, error) {
+    q := `
+UPDATE workflow_runs
+SET status = 'running', error = NULL, finished_at = NULL, invocations = invocations + 1,
+    claimed_by = $2, lease_expires_at = NOW() + $3 * INTERVAL '1 second', updated_at = NOW()
+WHERE id = $1
+  AND (status = 'failed' OR (status = 'running' AND (lease_expires_at IS NULL OR lease_expires_at < NOW())))
+RETURNING ` + runColumns
+    r, err := scanRun(s.DB.QueryRowContext(ctx, q, id, owner, s.ttl().Seconds()))
+    if errors.Is(err, sql.ErrNoRows) {
+        if _, gerr := s.Get(ctx, id); gerr != nil { return r, gerr }
+        return r, ErrRunBusy
+    }
+    return r, err
+}
+
+// ClaimStale leases up to limit running runs whose lease expired.
+func (s RunStore) ClaimStale(ctx context.Context, owner string, limit int) ([]WorkflowRun, error) {
+    q := `
+WITH stale AS (
+    SELECT id AS stale_id FROM workflow_runs
+    WHERE status = 'running' AND lease_expires_at < NOW()
+    ORDER BY lease_expires_at
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE workflow_runs r
+SET invocations = r.invocations + 1, claimed_by = $1,
+    lease_expires_at = NOW() + $2 * INTERVAL '1 second', updated_at = NOW()
+FROM stale WHERE r.id = stale.stale_id
+RETURNING ` + runColumns
+    rows, err := s.DB.QueryContext(ctx, q, owner, s.ttl().Seconds(), limit)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var out []WorkflowRun
+    for rows.Next() {
+        r, err := scanRun(rows)
+        if err != nil { return nil, err }
+        out = append(out, r)
+    }
+    return out, rows.Err()
+}
+
+// Finish records the outcome of an invocation and releases the lease.
+func (s RunStore) Finish(ctx context.Context, id, status string, output eowr.Payload, cause error) error {
+    out, err := marshalPayload(output)
+    if err != nil { return err }
+    msg := ""
+    if cause != nil { msg = cause.Error() }
+    _, err = s.DB.ExecContext(ctx, `
+UPDATE workflow_runs
+SET status = $2, output = $3, error = NULLIF($4, ''), finished_at = NOW(), updated_at = NOW(),
+    claimed_by = NULL, lease_expires_at = NULL
+WHERE id = $1`, id, status, out, msg)
+    return err
+}
+
+// SaveNode upserts a node checkpoint and renews the run's lease.
+func (s RunStore) SaveNode(ctx context.Context, runID string, st eowr.NodeState) error {
+    input, err := marshalPayload(st.Input)
+    if err != nil { return err }
+    output, err := marshalPayload(st.Output)
+    if err != nil { return err }
+    var started, finished sql.NullTime
+    if !st.StartedAt.IsZero() { started = sql.NullTime{Time: st.StartedAt, Valid: true} }
+    if !st.FinishedAt.IsZero() { finished = sql.NullTime{Time: st.FinishedAt, Valid: true} }
+    q := `
+WITH node AS (
+    INSERT INTO workflow_run_nodes (run_id, node, status, input, output, error, attempts, started_at, finished_at)
+    VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9)
+    ON CONFLICT (run_id, node) DO UPDATE
+    SET status = EXCLUDED.status, input = COALESCE(EXCLUDED.input, workflow_run_nodes.input),
+        output = EXCLUDED.output, error = EXCLUDED.error, attempts = GREATEST(EXCLUDED.attempts, workflow_run_nodes.attempts),
+        started_at = COALESCE(EXCLUDED.started_at, workflow_run_nodes.started_at),
+        finished_at = EXCLUDED.finished_at, updated_at = NOW()
+)
+UPDATE workflow_runs SET lease_expires_at = NOW() + $10 * INTERVAL '1 second', updated_at = NOW()
+WHERE id = $1 AND status = 'running'`
+    _, err = s.DB.ExecContext(ctx, q, runID, st.Node, st.Status, input, output, st.Error, st.Attempts, started, finished, s.ttl().Seconds())
+    return err
+}
+
+// Nodes returns the node checkpoints of a run in the order they started.
+func (s RunStore) Nodes(ctx context.Context, runID string) ([]eowr.NodeState, error) {
+    q := `
+SELECT node, status, input, output, COALESCE(error, ''), attempts, started_at, finished_at
+FROM workflow_run_nodes
+WHERE run_id = $1
+ORDER BY started_at NULLS LAST, node`
+    rows, err := s.DB.QueryContext(ctx, q, runID)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var out []eowr.NodeState
+    for rows.Next() {
+        var st eowr.NodeState
+        var input, output []byte
+        var started, finished sql.NullTime
+        if err := rows.Scan(&st.Node, &st.Status, &input, &output, &st.Error, &st.Attempts, &started, &finished); err != nil { return nil, err }
+        st.StartedAt, st.FinishedAt = started.Time, finished.Time
+        if st.Input, err = unmarshalPayload(input); err != nil { return nil, err }
+        if st.Output, err = unmarshalPayload(output); err != nil { return nil, err }
+        out = append(out, st)
+    }
+    return out, rows.Err()
+}
@@ -3,17 +3,182 @@ package workflows
 import (
     "context"
     "database/sql"
+    "fmt"
+    "log"
+    "sync"
     "time"
 
+    "github.com/bitesinbyte/ferret/pkg/accounts"
     "github.com/bitesinbyte/ferret/pkg/calendar"
+    "github.com/bitesinbyte/ferret/pkg/config"
+    "github.com/bitesinbyte/ferret/pkg/engine/eowr"
+    "github.com/bitesinbyte/ferret/pkg/engine/metrics"
+    "github.com/bitesinbyte/ferret/pkg/engine/ratelimit"
+    "github.com/bitesinbyte/ferret/pkg/engine/telemetry"
+    "github.com/bitesinbyte/ferret/pkg/engine/workers"
+    "github.com/bitesinbyte/ferret/pkg/external"
+    "github.com/bitesinbyte/ferret/pkg/ledger"
 )
 
 type Scheduler struct {
     DB *sql.DB
+
+    // Daemon settings used by Run; zero values fall back to defaults.
+    Worker      *workers.PosterWorker
+    Config      config.Config
+    Interval    time.Duration   // poll interval (default 30s)
+    Within      time.Duration   // claim window ahead of now (default 1m)
+    BatchSize   int             // max rows claimed per poll (default 50)
+    Concurrency int             // number of publishing goroutines (default 4)
+    Owner       string          // lease owner recorded on claims (default host:pid)
+    LeaseTTL    time.Duration   // claim lease (default calendar.DefaultLeaseTTL)
+    MaxAttempts int             // claims before the reaper fails a row (default 3)
+    Lookback    time.Duration   // how overdue a claimed post may be (default calendar.DefaultClaimLookback; < 0: none)
+    RateLimits  ratelimit.Store // default worker's rate limit state (default in memory)
+
+    // Durable workflow runs (see durable.go).
+    Nodes      *eowr.Registry   // node implementations (default eowr.DefaultRegistry)
+    NodeRetry  eowr.RetryPolicy // retry policy of nodes without `with retries=..`
+    ResumeRuns bool             // Run also resumes workflow runs whose lease expired
 }
 
-// Claim returns up to limit due posts within the window and moves them to processing.
+// Claim returns up to limit due posts within the window, or up to Lookback
+// overdue, and moves them to processing.
 func (s *Scheduler) Claim(ctx context.Context, within time.Duration, limit int) ([]calendar.ScheduledPostRow, error) {
-    return calendar.FetchAndClaimDuePosts(ctx, s.DB, within, limit)
+    owner := s.Owner
+    if owner == "" { owner = calendar.DefaultLeaseOwner() }
+    lookback := s.Lookback
+    if lookback == 0 { lookback = calendar.DefaultClaimLookback }
+    return calendar.FetchAndClaimDuePostsWithLease(ctx, s.DB, within, limit, owner, s.LeaseTTL, lookback)
+}
+
+// Reap returns rows with expired leases to 'scheduled' (or 'failed' after
+// MaxAttempts claims) so a crashed runner does not strand them in 'processing'.
+func (s *Scheduler) Reap(ctx context.Context) (requeued, failed int, err error) {
+    requeued, failed, err = calendar.ReapExpiredLeases(ctx, s.DB, s.MaxAttempts)
+    if err != nil { return requeued, failed, err }
+    metrics.NewCounter("scheduler_reaped_requeued_total").Inc(float64(requeued))
+    metrics.NewCounter("scheduler_reaped_failed_total").Inc(float64(failed))
+    if requeued+failed > 0 {
+        log.Printf("scheduler: reaped expired leases requeued=%d failed=%d", requeued, failed)
+    }
+    return requeued, failed, nil
 }
 
+// Run polls for due posts every Interval, claims them with SKIP LOCKED and
+// publishes them through a bounded pool of PosterWorkers until ctx is done.
+// On shutdown it stops claiming, releases rows that never reached a worker
+// back to 'scheduled' and waits for in-flight posts to finish.
+func (s *Scheduler) Run(ctx context.Context) error {
+    interval, within, batch, n := s.Interval, s.Within, s.BatchSize, s.Concurrency
+    if interval <= 0 { interval = 30 * time.Second }
+    if within <= 0 { within = time.Minute }
+    if batch <= 0 { batch = 50 }
+    if n <= 0 { n = 4 }
+    w := s.Worker
+    if w == nil { w = &workers.PosterWorker{DB: workers.CalendarDB{SQL: s.DB}, Accounts: accounts.Store{DB: s.DB}, Ledger: ledger.Store{DB: s.DB}, Limits: workers.PlatformLimiter(s.RateLimits)} }
+
+    // In-flight posts and status writes must survive the shutdown signal.
+    workCtx := context.WithoutCancel(ctx)
+    jobs := make(chan calendar.ScheduledPostRow)
+    var wg sync.WaitGroup
+    for i := 0; i < n; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for row := range jobs { s.publish(workCtx, w, row) }
+        }()
+    }
+    defer func() {
+        close(jobs)
+        wg.Wait()
+    }()
+    if s.ResumeRuns {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            s.resumeStale(ctx, workCtx, interval)
+        }()
+    }
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        s.poll(ctx, workCtx, jobs, within, batch)
+        select {
+        case <-ctx.Done():
+            log.Printf("scheduler: shutting down, waiting for in-flight posts")
+            return nil
+        case <-ticker.C:
+        }
+    }
+}
+
+// poll reaps expired leases, claims one batch and hands it to the pool,
+// releasing whatever is left undispatched when ctx is canceled mid-batch.
+func (s *Scheduler) poll(ctx, workCtx context.Context, jobs chan<- calendar.ScheduledPostRow, within time.Duration, batch int) {
+    if ctx.Err() != nil { return }
+    if _, _, err := s.Reap(ctx); err != nil && ctx.Err() == nil {
+        log.Printf("scheduler: reap failed: %v", err)
+    }
+    var rows []calendar.ScheduledPostRow
+    var err error
+    metrics.NewHistogram("scheduler_claim_seconds").Time(func() {
+        cctx, end := telemetry.StartSpan(ctx, "scheduler.claim", map[string]string{"unit": "post"})
+        rows, err = s.Claim(cctx, within, batch)
+        end()
+    })
+    if err != nil {
+        if ctx.Err() == nil { log.Printf("scheduler: claim failed: %v", err) }
+        return
+    }
+    metrics.NewCounter("scheduler_claimed_total").Inc(float64(len(rows)))
+    for i, row := range rows {
+        select {
+        case jobs <- row:
+        case <-ctx.Done():
+            ids := make([]string, 0, len(rows)-i)
+            for _, r := range rows[i:] { ids = append(ids, r.ID) }
+            if err := calendar.ReleaseClaimedPosts(workCtx, s.DB, ids); err != nil {
+                log.Printf("scheduler: release of %d rows failed: %v", len(ids), err)
+            }
+            return
+        }
+    }
+}
+
+// publish posts a single row. Retryable failures (see external.PostError) are
+// requeued after their backoff until MaxAttempts claims are used; everything
+// else is marked failed. Posters may panic, so a panic is converted into a
+// failure instead of killing the daemon.
+func (s *Scheduler) publish(ctx context.Context, w *workers.PosterWorker, row calendar.ScheduledPostRow) {
+    platform := string(row.Platform)
+    ctx, end := telemetry.StartSpan(ctx, "scheduler.publish", map[string]string{"platform": platform})
+    defer end()
+    err := func() (err error) {
+        defer func() {
+            if r := recover(); r != nil { err = fmt.Errorf("poster panic: %v", r) }
+        }()
+        return w.Post(ctx, row, s.Config)
+    }()
+    if err == nil {
+        metrics.NewCounter("poster_posted_total").Inc(1)
+        telemetry.RecordCounter(ctx, "poster_posted_total", 1, map[string]string{"platform": platform})
+        return
+    }
+    maxAttempts := s.MaxAttempts
+    if maxAttempts <= 0 { maxAttempts = 3 }
+    if wait, retry := external.RetryBackoff(err, row.Attempts-1); retry && row.Attempts < maxAttempts {
+        log.Printf("scheduler: post %s (%s) attempt %d failed, retrying in %s: %v", row.ID, platform, row.Attempts, wait, err)
+        metrics.NewCounter("poster_requeued_total").Inc(1)
+        rerr := calendar.RequeuePost(ctx, s.DB, row.ID, time.Now().UTC().Add(wait))
+        if rerr == nil { return }
+        log.Printf("scheduler: requeue %s: %v", row.ID, rerr)
+    }
+    log.Printf("scheduler: post %s (%s) failed: %v", row.ID, platform, err)
+    metrics.NewCounter("poster_failed_total").Inc(1)
+    telemetry.RecordCounter(ctx, "poster_failed_total", 1, map[string]string{"platform": platform})
+    if merr := w.MarkFailed(ctx, row, err); merr != nil {
+        log.Printf("scheduler: mark failed %s: %v", row.ID, merr)
+    }
+}
@@ -0,0 +1,297 @@
+package workflows
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/engine/cron"
+    "github.com/bitesinbyte/ferret/pkg/engine/eowr"
+    gen "github.com/bitesinbyte/ferret/pkg/engine/generator"
+    "github.com/bitesinbyte/ferret/pkg/engine/metrics"
+    "github.com/bitesinbyte/ferret/pkg/engine/telemetry"
+    "github.com/gin-gonic/gin"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of a webhook body as
+// "sha256=<hex>" (see SignWebhook).
+const SignatureHeader = "X-Signature-256"
+
+// DefaultWebhookSecretEnv names the variable holding the webhook secret of
+// triggers without `with secret_env=...`.
+const DefaultWebhookSecretEnv = "EOWR_WEBHOOK_SECRET"
+
+const maxWebhookBody = 1 << 20
+
+// TriggerBinding is a trigger of a .pseudo workflow that fires on its own.
+type TriggerBinding struct {
+    Source   string // .pseudo path
+    Workflow string
+    Trigger  string // trigger node name
+    Kind     string // gen.TriggerWebhook, gen.TriggerCron or gen.TriggerQueue
+
+    Path      string // webhook: `at` path
+    Method    string // webhook: `with method=...` (POST)
+    SecretEnv string // webhook: variable holding the signing secret
+    Signed    bool   // webhook: requests must carry a valid signature
+
+    Schedule cron.Schedule // cron: `with cron="...", tz="..."`
+
+    Topic        string // queue: `with queue="..."`
+    Subscription string // queue: `with subscription="..."` (eowr-<workflow>-<trigger>)
+}
+
+// Subscriber delivers the messages of topic to handle until ctx is done. A
+// message whose handle returns an error must be redelivered later.
+// queue.PulsarClient implements it.
+type Subscriber interface {
+    Subscribe(ctx context.Context, topic, subscription string, handle func(ctx context.Context, msg []byte) error) error
+}
+
+// TriggerHost binds the cron, webhook and queue triggers of .pseudo
+// workflows. Every firing starts a run of the workflow from that trigger
+// with the firing's payload.
+type TriggerHost struct {
+    Runs          *Scheduler              // runs are durable when Runs.DB is set, in process otherwise
+    Queue         Subscriber              // required by queue triggers
+    WebhookPrefix string                  // route prefix of webhook paths (default "/hooks")
+    Secret        func(env string) string // webhook secret lookup (default os.Getenv)
+
+    bindings []TriggerBinding
+    inflight sync.WaitGroup
+}
+
+// LoadDir loads every .pseudo file in dir (see Load).
+func (h *TriggerHost) LoadDir(dir string) error {
+    paths, err := filepath.Glob(filepath.Join(dir, "*.pseudo"))
+    if err != nil { return err }
+    if len(paths) == 0 { return fmt.Errorf("workflows: no .pseudo files in %s", dir) }
+    return h.Load(paths...)
+}
+
+// Load checks the workflows at paths and binds their triggers. Manual
+// triggers (no `at`, cron or queue) are skipped; two webhooks on the same
+// method and path are an error.
+func (h *TriggerHost) Load(paths ...string) error {
+    for _, p := range paths {
+        if _, err := eowr.Load(p, h.nodes()); err != nil { return err }
+        f, err := gen.LoadPseudo(p)
+        if err != nil { return err }
+        for _, n := range f.Workflow.Nodes {
+            if n.Kind != eowr.KindTrigger { continue }
+            b, err := binding(p, f.Workflow.Name, n)
+            if err != nil { return err }
+            if b.Kind == gen.TriggerManual { continue }
+            for _, o := range h.bindings {
+                if b.Kind == gen.TriggerWebhook && o.Kind == b.Kind && o.Method == b.Method && o.Path == b.Path {
+                    return fmt.Errorf("workflows: webhook %s %s is bound by %s and %s", b.Method, b.Path, o.Source, p)
+                }
+            }
+            h.bindings = append(h.bindings, b)
+        }
+    }
+    return nil
+}
+
+func binding(source, workflow string, n *gen.NodeDecl) (TriggerBinding, error) {
+    b := TriggerBinding{Source: source, Workflow: workflow, Trigger: n.Name, Kind: gen.TriggerKind(n)}
+    switch b.Kind {
+    case gen.TriggerWebhook:
+        b.Path, b.Method, b.SecretEnv, b.Signed = n.At, strings.ToUpper(n.Params["method"]), n.Params["secret_env"], true
+        if b.Method == "" { b.Method = http.MethodPost }
+        if b.SecretEnv == "" { b.SecretEnv = DefaultWebhookSecretEnv }
+        if v, ok := n.Params["signed"]; ok { b.Signed, _ = strconv.ParseBool(v) }
+    case gen.TriggerCron:
+        loc := time.UTC
+        if tz := n.Params["tz"]; tz != "" {
+            l, err := time.LoadLocation(tz)
+            if err != nil { return b, err }
+            loc = l
+        }
+        s, err := cron.ParseIn(n.Params["cron"], loc)
+        if err != nil { return b, err }
+        b.Schedule = s
+    case gen.TriggerQueue:
+        b.Topic, b.Subscription = n.Params["queue"], n.Params["subscription"]
+        if b.Subscription == "" { b.Subscription = "eowr-" + slug(workflow) + "-" + slug(n.Name) }
+    }
+    return b, nil
+}
+
+func slug(s string) string {
+    return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+        return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+    }), "-")
+}
+
+// Bindings returns the loaded triggers in load order.
+func (h *TriggerHost) Bindings() []TriggerBinding { return h.bindings }
+
+func (h *TriggerHost) nodes() *eowr.Registry {
+    if h.Runs != nil { return h.Runs.Nodes }
+    return nil
+}
+
+// Mount registers a route per webhook trigger at WebhookPrefix + path. A
+// signed trigger (the default) rejects requests without a valid
+// SignatureHeader, and every request while its secret is unset. A JSON
+// object body is the run's payload; any other body goes to "body". Query
+// parameters go to "query". The run starts in the background and the
+// request is answered with 202.
+func (h *TriggerHost) Mount(r gin.IRoutes) {
+    prefix := h.WebhookPrefix
+    if prefix == "" { prefix = "/hooks" }
+    for _, b := range h.bindings {
+        if b.Kind != gen.TriggerWebhook { continue }
+        r.Handle(b.Method, strings.TrimSuffix(prefix, "/")+b.Path, h.webhook(b))
+    }
+}
+
+func (h *TriggerHost) webhook(b TriggerBinding) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBody))
+        if err != nil {
+            c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+            return
+        }
+        if b.Signed {
+            secret := h.secret(b.SecretEnv)
+            if secret == "" {
+                log.Printf("workflows: webhook %s: %s is not set, rejecting", b.Path, b.SecretEnv)
+                c.JSON(http.StatusServiceUnavailable, gin.H{"error": "webhook secret not configured"})
+                return
+            }
+            if !hmac.Equal([]byte(c.GetHeader(SignatureHeader)), []byte(SignWebhook(secret, body))) {
+                metrics.NewCounter("workflow_webhooks_rejected_total").Inc(1)
+                c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+                return
+            }
+        }
+        in := decodePayload(body, "body")
+        if q := c.Request.URL.Query(); len(q) > 0 {
+            query := make(map[string]any, len(q))
+            for k := range q { query[k] = q.Get(k) }
+            in["query"] = query
+        }
+        ctx := context.WithoutCancel(c.Request.Context())
+        h.inflight.Add(1)
+        go func() {
+            defer h.inflight.Done()
+            h.Fire(ctx, b, in)
+        }()
+        c.JSON(http.StatusAccepted, gin.H{"status": "accepted", "workflow": b.Workflow, "trigger": b.Trigger})
+    }
+}
+
+func (h *TriggerHost) secret(env string) string {
+    if h.Secret != nil { return h.Secret(env) }
+    return os.Getenv(env)
+}
+
+// SignWebhook returns the SignatureHeader value for body.
+func SignWebhook(secret string, body []byte) string {
+    m := hmac.New(sha256.New, []byte(secret))
+    m.Write(body)
+    return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+// decodePayload returns a JSON object as is and anything else under key.
+func decodePayload(data []byte, key string) eowr.Payload {
+    in := eowr.Payload{}
+    if len(data) == 0 { return in }
+    var v any
+    if err := json.Unmarshal(data, &v); err != nil { v = string(data) }
+    if obj, ok := v.(map[string]any); ok { return obj }
+    in[key] = v
+    return in
+}
+
+// Run fires the cron triggers on their schedules and subscribes the queue
+// triggers until ctx is done, then waits for the runs in flight, webhook
+// runs included. Cron firings missed while a run is still going are
+// skipped. A queue message is acknowledged once its run is recorded (durable
+// runs, which can be resumed) or has succeeded (in process runs).
+func (h *TriggerHost) Run(ctx context.Context) error {
+    for _, b := range h.bindings {
+        if b.Kind == gen.TriggerQueue && h.Queue == nil {
+            return fmt.Errorf("workflows: trigger %q of %s needs a queue subscriber", b.Trigger, b.Source)
+        }
+    }
+    // Runs that started must finish after the shutdown signal.
+    workCtx := context.WithoutCancel(ctx)
+    var wg sync.WaitGroup
+    for _, b := range h.bindings {
+        switch b.Kind {
+        case gen.TriggerCron:
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                h.runCron(ctx, workCtx, b)
+            }()
+        case gen.TriggerQueue:
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                err := h.Queue.Subscribe(ctx, b.Topic, b.Subscription, func(_ context.Context, msg []byte) error {
+                    in := decodePayload(msg, "message")
+                    in["topic"] = b.Topic
+                    run, err := h.Fire(workCtx, b, in)
+                    if err != nil && run.ID == "" { return err }
+                    return nil
+                })
+                if err != nil && ctx.Err() == nil { log.Printf("workflows: subscribe %s for %q: %v", b.Topic, b.Trigger, err) }
+            }()
+        }
+    }
+    <-ctx.Done()
+    wg.Wait()
+    h.inflight.Wait()
+    return nil
+}
+
+func (h *TriggerHost) runCron(ctx, workCtx context.Context, b TriggerBinding) {
+    for next := b.Schedule.Next(time.Now()); !next.IsZero(); next = b.Schedule.Next(time.Now()) {
+        timer := time.NewTimer(time.Until(next))
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return
+        case <-timer.C:
+        }
+        h.Fire(workCtx, b, eowr.Payload{"fired_at": next.UTC().Format(time.RFC3339)})
+    }
+}
+
+// Fire starts a run of b's workflow from b's trigger with in as its
+// payload and waits for it. With a database the run is durable and
+// returned even when it failed; otherwise the zero WorkflowRun is returned.
+func (h *TriggerHost) Fire(ctx context.Context, b TriggerBinding, in eowr.Payload) (WorkflowRun, error) {
+    ctx, end := telemetry.StartSpan(ctx, "workflows.trigger", map[string]string{"workflow": b.Workflow, "trigger": b.Trigger, "kind": b.Kind})
+    defer end()
+    metrics.NewCounter("workflow_triggers_fired_total").Inc(1)
+    var run WorkflowRun
+    var err error
+    if h.Runs != nil && h.Runs.DB != nil {
+        run, _, err = h.Runs.StartWorkflow(ctx, b.Source, b.Trigger, in)
+    } else {
+        var g *eowr.Graph
+        if g, err = eowr.Load(b.Source, h.nodes()); err == nil { _, err = g.Run(ctx, b.Trigger, in) }
+    }
+    if err != nil {
+        metrics.NewCounter("workflow_triggers_failed_total").Inc(1)
+        log.Printf("workflows: %s trigger %q of %s failed: %v", b.Kind, b.Trigger, b.Workflow, err)
+    }
+    return run, err
+}
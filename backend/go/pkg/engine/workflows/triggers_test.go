@@ -0,0 +1,129 @@
+package workflows
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/engine/eowr"
+    "github.com/gin-gonic/gin"
+)
+
+const triggerSrc = `workflow "Hooks" version 1:
+  trigger "Lead" at "/lead"
+  trigger "Open" at "/open" with method="PUT", signed=false
+  trigger "Tick" with cron="@every 20ms"
+  trigger "Events" with queue="events"
+  action "Record" using "test/record"
+  connect ["Lead", "Open", "Tick", "Events"] -> "Record"
+`
+
+// recordingHost loads triggerSrc with a node that sends every payload it
+// receives to the returned channel.
+func recordingHost(t *testing.T) (*TriggerHost, string, <-chan eowr.Payload) {
+    t.Helper()
+    got := make(chan eowr.Payload, 16)
+    reg := eowr.NewRegistry()
+    reg.Register("test/record", func(map[string]string) (eowr.Node, error) {
+        return eowr.NodeFunc(func(_ context.Context, in eowr.Payload) (eowr.Payload, error) {
+            got <- in.Clone()
+            return in, nil
+        }), nil
+    })
+    p := filepath.Join(t.TempDir(), "hooks.pseudo")
+    if err := os.WriteFile(p, []byte(triggerSrc), 0o644); err != nil { t.Fatal(err) }
+    h := &TriggerHost{Runs: &Scheduler{Nodes: reg}}
+    if err := h.Load(p); err != nil { t.Fatal(err) }
+    return h, p, got
+}
+
+func receive(t *testing.T, got <-chan eowr.Payload) eowr.Payload {
+    t.Helper()
+    select {
+    case in := <-got:
+        return in
+    case <-time.After(2 * time.Second):
+        t.Fatal("the workflow did not run")
+        return nil
+    }
+}
+
+func TestWebhookTriggers(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    h, p, got := recordingHost(t)
+    secret := "s3cret"
+    h.Secret = func(env string) string {
+        if env == DefaultWebhookSecretEnv { return secret }
+        return ""
+    }
+    r := gin.New()
+    h.Mount(r)
+    send := func(method, path, body, sig string) int {
+        req := httptest.NewRequest(method, path, strings.NewReader(body))
+        if sig != "" { req.Header.Set(SignatureHeader, sig) }
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        return w.Code
+    }
+
+    body := `{"email": "a@b.co"}`
+    if code := send("POST", "/hooks/lead", body, ""); code != http.StatusUnauthorized { t.Fatalf("unsigned: %d", code) }
+    if code := send("POST", "/hooks/lead", body, SignWebhook("wrong", []byte(body))); code != http.StatusUnauthorized { t.Fatalf("bad signature: %d", code) }
+    if code := send("POST", "/hooks/lead?src=ads", body, SignWebhook(secret, []byte(body))); code != http.StatusAccepted { t.Fatalf("signed: %d", code) }
+    in := receive(t, got)
+    if in["email"] != "a@b.co" || in["query"].(map[string]any)["src"] != "ads" { t.Fatalf("payload = %v", in) }
+
+    if code := send("PUT", "/hooks/open", "ping", ""); code != http.StatusAccepted { t.Fatalf("unsigned trigger: %d", code) }
+    if in := receive(t, got); in["body"] != "ping" { t.Fatalf("payload = %v", in) }
+
+    secret = ""
+    if code := send("POST", "/hooks/lead", body, SignWebhook("", []byte(body))); code != http.StatusServiceUnavailable { t.Fatalf("no secret: %d", code) }
+
+    if err := h.Load(p); err == nil || !strings.Contains(err.Error(), "webhook POST /lead is bound by") { t.Fatalf("err = %v", err) }
+}
+
+type fakeSubscriber struct {
+    msgs [][]byte
+    errs chan error
+}
+
+func (f *fakeSubscriber) Subscribe(ctx context.Context, topic, subscription string, handle func(context.Context, []byte) error) error {
+    if topic != "events" || subscription != "eowr-hooks-events" { return nil }
+    for _, m := range f.msgs { f.errs <- handle(ctx, m) }
+    <-ctx.Done()
+    return nil
+}
+
+func TestCronAndQueueTriggers(t *testing.T) {
+    h, _, got := recordingHost(t)
+    if err := h.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "needs a queue subscriber") { t.Fatalf("err = %v", err) }
+
+    sub := &fakeSubscriber{msgs: [][]byte{[]byte(`{"id": 7}`), []byte("plain")}, errs: make(chan error, 2)}
+    h.Queue = sub
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan error)
+    go func() { done <- h.Run(ctx) }()
+
+    var ticks, messages int
+    for ticks < 2 || messages < 2 {
+        in := receive(t, got)
+        switch {
+        case in["fired_at"] != nil:
+            ticks++
+        case in["topic"] == "events" && (in["id"] == 7.0 || in["message"] == "plain"):
+            messages++
+        default:
+            t.Fatalf("unexpected payload %v", in)
+        }
+    }
+    for range sub.msgs {
+        if err := <-sub.errs; err != nil { t.Fatalf("message not acked: %v", err) }
+    }
+    cancel()
+    if err := <-done; err != nil { t.Fatal(err) }
+}
@@ -0,0 +1,108 @@
+package external
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "os"
+    "strings"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/config"
+)
+
+// ContextPoster is the v2 poster contract. Unlike Poster it honours ctx
+// (cancellation, deadlines, tracing), publishes with the account in creds
+// rather than process env, and returns the created post id. One process can
+// therefore post for many orgs. Implementations accept an injectable
+// *http.Client (their HTTP field) so tests can point them at a fake server.
+type ContextPoster interface {
+    Publish(ctx context.Context, configData config.Config, creds Credentials, post Post) (string, error)
+}
+
+// Credentials is the per-account bundle a ContextPoster publishes with. The
+// scheduler resolves it from social_accounts/oauth_accounts (see
+// pkg/accounts); CredentialsFromEnv builds the legacy single-account bundle.
+type Credentials struct {
+    Platform  string
+    AccountID string // social_accounts.id; empty for env credentials
+    // ExternalID is the page / profile / user id posts are made as
+    // (Facebook page, Threads and Instagram user, LinkedIn person).
+    ExternalID        string
+    AccessToken       string
+    AccessTokenSecret string // OAuth 1.0a (Twitter)
+    RefreshToken      string
+    ConsumerKey       string // app credentials (Twitter)
+    ConsumerSecret    string
+    InstanceURL       string // Mastodon server base URL
+    ExpiresAt         time.Time
+    // Extra holds non-secret platform settings from social_accounts.auth_meta.
+    Extra map[string]string
+}
+
+// Expired reports whether the access token has a known expiry before now.
+func (c Credentials) Expired(now time.Time) bool {
+    return !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt)
+}
+
+// CredentialsFromEnv returns the credentials the v1 posters read from env.
+func CredentialsFromEnv(platform string) Credentials {
+    c := Credentials{Platform: strings.ToLower(platform)}
+    switch c.Platform {
+    case "mastodon":
+        c.InstanceURL = os.Getenv("MASTODON_INSTANCE_URL")
+        c.AccessToken = os.Getenv("MASTODON_ACCESS_TOKEN")
+    case "twitter":
+        c.ConsumerKey = os.Getenv("TWITTER_CONSUMER_KEY")
+        c.ConsumerSecret = os.Getenv("TWITTER_CONSUMER_SECRET")
+        c.AccessToken = os.Getenv("TWITTER_ACCESS_TOKEN")
+        c.AccessTokenSecret = os.Getenv("TWITTER_ACCESS_TOKEN_SECRET")
+    case "facebook":
+        c.AccessToken = os.Getenv("FACEBOOK_ACCESS_TOKEN")
+        c.ExternalID = os.Getenv("FACEBOOK_PAGE_ID")
+    case "thread":
+        c.AccessToken = os.Getenv("THREAD_ACCESS_TOKEN")
+        c.ExternalID = os.Getenv("THREAD_USER_ID")
+    case "linkedin":
+        c.AccessToken = os.Getenv("LINKEDIN_ACCESS_TOKEN")
+    case "instagram":
+        c.AccessToken = os.Getenv("IG_ACCESS_TOKEN")
+        c.ExternalID = os.Getenv("IG_USER_ID")
+        if v := os.Getenv("IG_GRAPH_VERSION"); v != "" { c.Extra = map[string]string{"graph_version": v} }
+    }
+    return c
+}
+
+var defaultHTTPClient = &http.Client{Timeout: 2 * time.Minute}
+
+// session carries one Publish call's context, account and HTTP client through
+// a poster's helpers.
+type session struct {
+    ctx      context.Context
+    platform string
+    creds    Credentials
+    http     *http.Client
+}
+
+func newSession(ctx context.Context, platform string, creds Credentials, hc *http.Client) session {
+    if ctx == nil { ctx = context.Background() }
+    if hc == nil { hc = defaultHTTPClient }
+    return session{ctx: ctx, platform: platform, creds: creds, http: hc}
+}
+
+func (s session) newRequest(method, url string, body io.Reader) (*http.Request, error) {
+    return http.NewRequestWithContext(s.ctx, method, url, body)
+}
+
+// do sends req and classifies network failures as transport errors.
+func (s session) do(req *http.Request) (*http.Response, error) {
+    resp, err := s.http.Do(req)
+    if err != nil { return nil, TransportError(s.platform, err) }
+    return resp, nil
+}
+
+func (s session) get(url string) (*http.Response, error) {
+    req, err := s.newRequest(http.MethodGet, url, nil)
+    if err != nil { return nil, err }
+    return s.do(req)
+}
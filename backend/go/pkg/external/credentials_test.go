@@ -0,0 +1,81 @@
+package external
+
+import (
+    "context"
+    "encoding/json"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "testing"
+
+    "github.com/bitesinbyte/ferret/pkg/config"
+)
+
+var (
+    _ ContextPoster = Mastodon{}
+    _ ContextPoster = Twitter{}
+    _ ContextPoster = Facebook{}
+    _ ContextPoster = Thread{}
+    _ ContextPoster = Linkedin{}
+    _ ContextPoster = Instagram{}
+)
+
+// rewriteHost sends every request to srv, for posters with fixed API hosts.
+type rewriteHost struct{ srv *httptest.Server }
+
+func (r rewriteHost) RoundTrip(req *http.Request) (*http.Response, error) {
+    u, _ := url.Parse(r.srv.URL)
+    req.URL.Scheme, req.URL.Host = u.Scheme, u.Host
+    return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestMastodonPublishUsesCredentials(t *testing.T) {
+    var replies []string
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if got := r.Header.Get("Authorization"); got != "Bearer acct-token" {
+            t.Errorf("authorization = %q", got)
+        }
+        _ = r.ParseForm()
+        replies = append(replies, r.PostForm.Get("in_reply_to_id"))
+        _, _ = io.WriteString(w, `{"id":"`+r.PostForm.Get("status")+`"}`)
+    }))
+    defer srv.Close()
+
+    creds := Credentials{Platform: "mastodon", InstanceURL: srv.URL, AccessToken: "acct-token"}
+    post := Post{Body: "root", Thread: []Post{{Body: "second"}, {Body: "third"}}}
+    id, err := Mastodon{HTTP: srv.Client()}.Publish(context.Background(), config.Config{}, creds, post)
+    if err != nil { t.Fatalf("publish: %v", err) }
+    if id != "root" { t.Fatalf("id = %q", id) }
+    if strings.Join(replies, ",") != ",root,second" { t.Fatalf("reply chain = %v", replies) }
+}
+
+func TestFacebookPublishUsesAccountPage(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/v19.0/page-42/feed" { t.Errorf("path = %s", r.URL.Path) }
+        var body facebookCreatePost
+        _ = json.NewDecoder(r.Body).Decode(&body)
+        if body.AccessToken != "page-token" { t.Errorf("access_token = %q", body.AccessToken) }
+        _, _ = io.WriteString(w, `{"id":"page-42_1"}`)
+    }))
+    defer srv.Close()
+
+    fb := Facebook{HTTP: &http.Client{Transport: rewriteHost{srv}}}
+    creds := Credentials{Platform: "facebook", ExternalID: "page-42", AccessToken: "page-token"}
+    id, err := fb.Publish(context.Background(), config.Config{}, creds, Post{Body: "hello"})
+    if err != nil || id != "page-42_1" { t.Fatalf("publish = %q, %v", id, err) }
+}
+
+func TestPublishHonoursCanceledContext(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        t.Error("request sent despite canceled context")
+    }))
+    defer srv.Close()
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    creds := Credentials{Platform: "mastodon", InstanceURL: srv.URL, AccessToken: "t"}
+    if _, err := (Mastodon{HTTP: srv.Client()}).Publish(ctx, config.Config{}, creds, Post{Body: "x"}); err == nil {
+        t.Fatal("expected error")
+    }
+}
@@ -0,0 +1,251 @@
+package external
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "net/url"
+    "regexp"
+    "strings"
+    "sync"
+
+    "github.com/bitesinbyte/ferret/pkg/config"
+)
+
+// redacted replaces secrets in dry-run output.
+const redacted = "[REDACTED]"
+
+// secretKeys are query, form and JSON keys whose values never leave a dry run.
+var secretKeys = map[string]bool{
+    "access_token": true, "client_secret": true, "refresh_token": true,
+    "api_key": true, "apikey": true, "token": true, "password": true,
+}
+
+// secretHeaders are redacted wholesale, except Authorization keeps its scheme.
+var secretHeaders = map[string]bool{"Cookie": true, "X-Api-Key": true, "Proxy-Authorization": true}
+
+var oauthParam = regexp.MustCompile(`(oauth_(?:consumer_key|token|signature|nonce))="[^"]*"`)
+
+// apiHosts answer with JSON in a dry run; other GETs (article pages, media)
+// get an HTML page whose og:image points back into the dry run.
+var apiHosts = map[string]bool{
+    "api.twitter.com": true, "upload.twitter.com": true, "api.linkedin.com": true,
+    "graph.facebook.com": true, "graph.threads.net": true,
+}
+
+// RecordedRequest is one HTTP request a poster would have sent.
+type RecordedRequest struct {
+    Method  string            `json:"method"`
+    URL     string            `json:"url"`
+    Headers map[string]string `json:"headers,omitempty"`
+    // Body is the JSON body as-is (secrets redacted), a form/text body as a
+    // string, or a "multipart ... bytes" summary for uploads.
+    Body any `json:"body,omitempty"`
+}
+
+// DryRun is an http.RoundTripper that records requests instead of sending
+// them and answers with synthetic success responses, so posters run their real
+// code paths without touching the network. Respond overrides the synthetic
+// response for clients with other response shapes (e.g. Postiz).
+type DryRun struct {
+    Respond func(req *http.Request, seq int) *http.Response
+
+    mu       sync.Mutex
+    seq      int
+    requests []RecordedRequest
+}
+
+// Client returns an *http.Client that sends through d.
+func (d *DryRun) Client() *http.Client { return &http.Client{Transport: d} }
+
+// Take returns the requests recorded since the last call and resets the log.
+func (d *DryRun) Take() []RecordedRequest {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    out := d.requests
+    d.requests = nil
+    return out
+}
+
+func (d *DryRun) RoundTrip(req *http.Request) (*http.Response, error) {
+    if err := req.Context().Err(); err != nil { return nil, err }
+    var body []byte
+    if req.Body != nil {
+        b, err := io.ReadAll(req.Body)
+        _ = req.Body.Close()
+        if err != nil { return nil, err }
+        body = b
+    }
+    d.mu.Lock()
+    d.seq++
+    seq := d.seq
+    d.requests = append(d.requests, recordRequest(req, body))
+    d.mu.Unlock()
+
+    if d.Respond != nil {
+        if resp := d.Respond(req, seq); resp != nil {
+            resp.Request = req
+            return resp, nil
+        }
+    }
+    return syntheticResponse(req, seq), nil
+}
+
+func recordRequest(req *http.Request, body []byte) RecordedRequest {
+    rec := RecordedRequest{Method: req.Method, URL: redactURL(req.URL)}
+    for k := range req.Header {
+        if rec.Headers == nil { rec.Headers = map[string]string{} }
+        v := req.Header.Get(k)
+        switch {
+        case k == "Authorization":
+            v = redactAuthorization(v)
+        case secretHeaders[k]:
+            v = redacted
+        }
+        rec.Headers[k] = v
+    }
+    if len(body) > 0 { rec.Body = redactBody(req.Header.Get("Content-Type"), body) }
+    return rec
+}
+
+func redactURL(u *url.URL) string {
+    c := *u
+    q := c.Query()
+    for k := range q {
+        if secretKeys[strings.ToLower(k)] { q.Set(k, redacted) }
+    }
+    c.RawQuery = q.Encode()
+    return c.String()
+}
+
+// redactAuthorization keeps the scheme and OAuth 1.0a parameter names, which
+// is what a reviewer needs to check the header shape.
+func redactAuthorization(v string) string {
+    if strings.HasPrefix(v, "OAuth ") { return oauthParam.ReplaceAllString(v, `$1="`+redacted+`"`) }
+    if scheme, _, ok := strings.Cut(v, " "); ok { return scheme + " " + redacted }
+    return redacted
+}
+
+func redactBody(ctype string, body []byte) any {
+    mt, _, _ := mime.ParseMediaType(ctype)
+    switch {
+    case strings.HasPrefix(mt, "multipart/"):
+        return fmt.Sprintf("%s (%d bytes)", mt, len(body))
+    case mt == "application/x-www-form-urlencoded":
+        form, err := url.ParseQuery(string(body))
+        if err != nil { return string(body) }
+        for k := range form {
+            if secretKeys[strings.ToLower(k)] { form.Set(k, redacted) }
+        }
+        return form.Encode()
+    }
+    var v any
+    if json.Unmarshal(body, &v) == nil { return redactJSON(v) }
+    if mt == "" || strings.HasPrefix(mt, "text/") { return string(body) }
+    return fmt.Sprintf("%s (%d bytes)", mt, len(body))
+}
+
+func redactJSON(v any) any {
+    switch t := v.(type) {
+    case map[string]any:
+        for k, val := range t {
+            if secretKeys[strings.ToLower(k)] { t[k] = redacted; continue }
+            t[k] = redactJSON(val)
+        }
+    case []any:
+        for i := range t { t[i] = redactJSON(t[i]) }
+    }
+    return v
+}
+
+// JSONResponse builds a synthetic JSON response for DryRun.Respond hooks.
+func JSONResponse(status int, v any) *http.Response {
+    b, _ := json.Marshal(v)
+    return &http.Response{
+        Status:     http.StatusText(status),
+        StatusCode: status,
+        Header:     http.Header{"Content-Type": []string{"application/json"}},
+        Body:       io.NopCloser(bytes.NewReader(b)),
+    }
+}
+
+// syntheticResponse satisfies every poster's success path: one JSON object
+// carrying the id fields each API returns, with the status codes they check.
+func syntheticResponse(req *http.Request, seq int) *http.Response {
+    id := fmt.Sprintf("dryrun-%d", seq)
+    status := http.StatusOK
+    if req.Method == http.MethodPut ||
+        (req.URL.Host == "api.twitter.com" && strings.HasSuffix(req.URL.Path, "/tweets")) ||
+        (req.URL.Host == "api.linkedin.com" && strings.HasSuffix(req.URL.Path, "/posts")) {
+        status = http.StatusCreated
+    }
+    header := http.Header{}
+    var body string
+    if req.Method == http.MethodGet && !apiHosts[req.URL.Host] {
+        header.Set("Content-Type", "text/html; charset=utf-8")
+        body = `<html><head><meta property="og:image" content="https://dry-run.invalid/og-image.png"></head></html>`
+    } else {
+        header.Set("Content-Type", "application/json")
+        header.Set("x-restli-id", "urn:li:share:"+id)
+        b, _ := json.Marshal(map[string]any{
+            "id": id, "data": map[string]string{"id": id}, "sub": "dryrun",
+            "media_id_string": id, "status": "FINISHED", "status_code": "FINISHED",
+            "value": map[string]string{"uploadUrl": "https://dry-run.invalid/upload/" + id, "image": "urn:li:image:" + id},
+        })
+        body = string(b)
+    }
+    return &http.Response{
+        Status:     http.StatusText(status),
+        StatusCode: status,
+        Header:     header,
+        Body:       io.NopCloser(bytes.NewBufferString(body)),
+        Request:    req,
+    }
+}
+
+// Preview is one dry-run record: what publishing a post would send.
+type Preview struct {
+    RowID     string            `json:"row_id,omitempty"`
+    Platform  string            `json:"platform"`
+    AccountID string            `json:"account_id,omitempty"`
+    Text      string            `json:"text"`
+    HashTags  string            `json:"hashtags,omitempty"`
+    Media     []string          `json:"media,omitempty"`
+    Requests  []RecordedRequest `json:"requests"`
+    Error     string            `json:"error,omitempty"`
+}
+
+// TextRenderer is implemented by posters that can report the text they would
+// publish for a post.
+type TextRenderer interface {
+    RenderText(post Post) string
+}
+
+// PreviewPost runs the registered poster for platform against a DryRun and
+// returns what it would have sent. Nothing leaves the process.
+func PreviewPost(ctx context.Context, configData config.Config, platform string, creds Credentials, post Post) Preview {
+    pv := Preview{Platform: platform, AccountID: creds.AccountID, HashTags: post.HashTags}
+    for _, m := range post.Media { pv.Media = append(pv.Media, m.URL) }
+    spec, ok := LookupPlatform(platform)
+    if !ok {
+        pv.Error = fmt.Sprintf("%v: %q", ErrUnknownPlatform, platform)
+        return pv
+    }
+    pv.Platform = spec.Name
+    dry := &DryRun{}
+    poster := spec.New(dry.Client())
+    if r, ok := poster.(TextRenderer); ok { pv.Text = r.RenderText(post) }
+    var err error
+    if cp, ok := poster.(ContextPoster); ok {
+        _, err = cp.Publish(ctx, configData, creds, post)
+    } else {
+        err = fmt.Errorf("%s poster does not support dry runs", spec.Name)
+    }
+    if err != nil { pv.Error = err.Error() }
+    pv.Requests = dry.Take()
+    return pv
+}
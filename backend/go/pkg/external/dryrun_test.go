@@ -0,0 +1,38 @@
+package external
+
+import (
+    "context"
+    "encoding/json"
+    "strings"
+    "testing"
+
+    "github.com/bitesinbyte/ferret/pkg/config"
+)
+
+func TestPreviewPostAllPlatformsRedactsSecrets(t *testing.T) {
+    creds := Credentials{
+        AccessToken: "sekret-token", AccessTokenSecret: "sekret-secret",
+        ConsumerKey: "sekret-ck", ConsumerSecret: "sekret-cs",
+        InstanceURL: "https://mastodon.example", ExternalID: "acct-1",
+    }
+    post := Post{
+        Title: "T", Link: "https://blog.example/p", HashTags: "#go",
+        Media:  []Media{{URL: "https://cdn.example/a.png", AltText: "alt"}},
+        Thread: []Post{{Body: "follow-up"}},
+    }
+    for _, p := range Platforms() {
+        pv := PreviewPost(context.Background(), config.Config{}, p.Name, creds, post)
+        if pv.Error != "" { t.Errorf("%s: %s", p.Name, pv.Error); continue }
+        if len(pv.Requests) == 0 || pv.Text == "" { t.Errorf("%s: empty preview %+v", p.Name, pv) }
+        b, _ := json.Marshal(pv)
+        if strings.Contains(string(b), "sekret") { t.Errorf("%s: secret leaked: %s", p.Name, b) }
+    }
+}
+
+func TestRedactAuthorizationKeepsShape(t *testing.T) {
+    got := redactAuthorization(buildOAuth1Header("https://api.twitter.com/2/tweets", "POST", "ck", "cs", "tok", "ts"))
+    for _, want := range []string{`oauth_consumer_key="[REDACTED]"`, `oauth_signature="[REDACTED]"`, `oauth_token="[REDACTED]"`, `oauth_signature_method="HMAC-SHA1"`} {
+        if !strings.Contains(got, want) { t.Errorf("missing %s in %s", want, got) }
+    }
+    if got := redactAuthorization("Bearer abc"); got != "Bearer [REDACTED]" { t.Errorf("bearer = %q", got) }
+}
@@ -0,0 +1,203 @@
+package external
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// PostError is the error contract shared by every platform poster. Callers
+// decide on retry and backoff from these fields instead of matching
+// err.Error(). Platform client packages (linkedin, instagram, postiz) satisfy
+// it structurally so they do not need to import this package.
+type PostError interface {
+    error
+    // Retryable reports whether the same request may succeed later.
+    Retryable() bool
+    // Permanent reports that retrying is pointless until something changes.
+    Permanent() bool
+    // AuthExpired reports that credentials must be refreshed or reconnected.
+    AuthExpired() bool
+    // RateLimitedUntil reports a rate limit; until is zero when the platform
+    // did not say when the window resets.
+    RateLimitedUntil() (until time.Time, limited bool)
+    // QuotaExceeded reports an exhausted daily/app quota.
+    QuotaExceeded() bool
+}
+
+// ErrorKind classifies a PlatformError.
+type ErrorKind string
+
+const (
+    KindTransient     ErrorKind = "transient"
+    KindRateLimited   ErrorKind = "rate_limited"
+    KindQuotaExceeded ErrorKind = "quota_exceeded"
+    KindAuthExpired   ErrorKind = "auth_expired"
+    KindPermanent     ErrorKind = "permanent"
+)
+
+// PlatformError is the PostError returned by the posters in this package.
+type PlatformError struct {
+    Platform   string
+    Kind       ErrorKind
+    StatusCode int       // HTTP status when the error came from a response
+    RetryAt    time.Time // from Retry-After / rate limit reset headers
+    Message    string
+    Err        error
+}
+
+func (e *PlatformError) Error() string {
+    msg := e.Message
+    if msg == "" && e.Err != nil { msg = e.Err.Error() }
+    if e.StatusCode != 0 {
+        return fmt.Sprintf("%s: %s (status=%d kind=%s)", e.Platform, msg, e.StatusCode, e.Kind)
+    }
+    return fmt.Sprintf("%s: %s (kind=%s)", e.Platform, msg, e.Kind)
+}
+
+func (e *PlatformError) Unwrap() error { return e.Err }
+
+func (e *PlatformError) Retryable() bool {
+    return e.Kind == KindTransient || e.Kind == KindRateLimited
+}
+
+func (e *PlatformError) Permanent() bool { return !e.Retryable() }
+
+func (e *PlatformError) AuthExpired() bool { return e.Kind == KindAuthExpired }
+
+func (e *PlatformError) RateLimitedUntil() (time.Time, bool) {
+    return e.RetryAt, e.Kind == KindRateLimited
+}
+
+func (e *PlatformError) QuotaExceeded() bool { return e.Kind == KindQuotaExceeded }
+
+// NewPlatformError wraps err with an explicit kind.
+func NewPlatformError(platform string, kind ErrorKind, err error) *PlatformError {
+    return &PlatformError{Platform: platform, Kind: kind, Err: err}
+}
+
+// HTTPError classifies a non-2xx response. It reads (and does not close) the
+// body to pick up Graph/Google style error payloads.
+func HTTPError(platform string, resp *http.Response) error {
+    if resp == nil {
+        return &PlatformError{Platform: platform, Kind: KindTransient, Message: "no response"}
+    }
+    body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+    e := &PlatformError{
+        Platform:   platform,
+        StatusCode: resp.StatusCode,
+        Kind:       kindForStatus(resp.StatusCode),
+        RetryAt:    retryAtFromHeaders(resp.Header, time.Now()),
+        Message:    fmt.Sprintf("unexpected status code: %d", resp.StatusCode),
+    }
+    var payload struct {
+        Error struct {
+            Message string `json:"message"`
+            Code    int    `json:"code"`
+            Errors  []struct {
+                Reason string `json:"reason"`
+            } `json:"errors"`
+        } `json:"error"`
+        Detail string `json:"detail"`
+    }
+    if json.Unmarshal(body, &payload) == nil {
+        if payload.Error.Message != "" { e.Message = payload.Error.Message }
+        if payload.Error.Message == "" && payload.Detail != "" { e.Message = payload.Detail }
+        // Meta Graph codes arrive with 400s: 190 expired token, 4/17/32/613 throttling.
+        switch payload.Error.Code {
+        case 190:
+            e.Kind = KindAuthExpired
+        case 4, 17, 32, 613:
+            e.Kind = KindRateLimited
+        }
+        for _, r := range payload.Error.Errors {
+            if r.Reason == "quotaExceeded" || r.Reason == "dailyLimitExceeded" { e.Kind = KindQuotaExceeded }
+        }
+    }
+    return e
+}
+
+// TransportError classifies a failure from http.Client.Do or body reads.
+func TransportError(platform string, err error) error {
+    if err == nil { return nil }
+    return &PlatformError{Platform: platform, Kind: kindForTransport(err), Err: err}
+}
+
+// AsPostError wraps err as a PostError unless it already is one. Network
+// errors are transient; anything else unclassified is permanent.
+func AsPostError(platform string, err error) error {
+    if err == nil { return nil }
+    var pe PostError
+    if errors.As(err, &pe) { return err }
+    return &PlatformError{Platform: platform, Kind: kindForTransport(err), Err: err}
+}
+
+// IsRetryable reports whether err is worth retrying.
+func IsRetryable(err error) bool {
+    var pe PostError
+    if errors.As(err, &pe) { return pe.Retryable() }
+    return kindForTransport(err) == KindTransient
+}
+
+// RetryBackoff reports whether err should be retried and how long to wait
+// before attempt n (0-based). Rate limits honor the platform's reset time;
+// other transient errors back off exponentially from 500ms, capped at 30s.
+func RetryBackoff(err error, attempt int) (time.Duration, bool) {
+    if err == nil || !IsRetryable(err) { return 0, false }
+    var pe PostError
+    if errors.As(err, &pe) {
+        if until, limited := pe.RateLimitedUntil(); limited && !until.IsZero() {
+            if d := time.Until(until); d > 0 { return d, true }
+        }
+    }
+    d := 500 * time.Millisecond
+    for i := 0; i < attempt && d < 30*time.Second; i++ { d *= 2 }
+    if d > 30*time.Second { d = 30 * time.Second }
+    return d, true
+}
+
+func kindForStatus(status int) ErrorKind {
+    switch {
+    case status == http.StatusUnauthorized:
+        return KindAuthExpired
+    case status == http.StatusTooManyRequests:
+        return KindRateLimited
+    case status == http.StatusRequestTimeout || status == http.StatusTooEarly:
+        return KindTransient
+    case status >= 500:
+        return KindTransient
+    default:
+        return KindPermanent
+    }
+}
+
+func kindForTransport(err error) ErrorKind {
+    if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+        return KindTransient
+    }
+    var ne net.Error
+    if errors.As(err, &ne) { return KindTransient }
+    return KindPermanent
+}
+
+// retryAtFromHeaders reads Retry-After (seconds or HTTP date) and falls back
+// to X-Rate-Limit-Reset / X-RateLimit-Reset (unix seconds).
+func retryAtFromHeaders(h http.Header, now time.Time) time.Time {
+    if v := strings.TrimSpace(h.Get("Retry-After")); v != "" {
+        if secs, err := strconv.Atoi(v); err == nil { return now.Add(time.Duration(secs) * time.Second) }
+        if t, err := http.ParseTime(v); err == nil { return t }
+    }
+    for _, k := range []string{"X-Rate-Limit-Reset", "X-RateLimit-Reset"} {
+        if v := strings.TrimSpace(h.Get(k)); v != "" {
+            if unix, err := strconv.ParseInt(v, 10, 64); err == nil { return time.Unix(unix, 0) }
+        }
+    }
+    return time.Time{}
+}
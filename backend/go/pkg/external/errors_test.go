@@ -0,0 +1,79 @@
+package external
+
+import (
+    "errors"
+    "io"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+
+    apipostiz "github.com/bitesinbyte/ferret/pkg/api/postiz"
+    ig "github.com/bitesinbyte/ferret/pkg/external/instagram"
+    li "github.com/bitesinbyte/ferret/pkg/external/linkedin"
+    extpostiz "github.com/bitesinbyte/ferret/pkg/external/postiz"
+)
+
+// Platform client errors must satisfy PostError without importing this package.
+var (
+    _ PostError = li.ErrRateLimited
+    _ PostError = li.APIError{}
+    _ PostError = ig.ErrServer
+    _ PostError = &ig.HTTPError{}
+    _ PostError = &apipostiz.APIError{}
+    _ PostError = &extpostiz.APIError{}
+)
+
+func mkResp(code int, body string, h http.Header) *http.Response {
+    if h == nil { h = http.Header{} }
+    return &http.Response{StatusCode: code, Header: h, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func TestHTTPErrorClassification(t *testing.T) {
+    tests := []struct {
+        name      string
+        resp      *http.Response
+        retryable bool
+        auth      bool
+        limited   bool
+        quota     bool
+    }{
+        {"401", mkResp(401, "{}", nil), false, true, false, false},
+        {"429", mkResp(429, "{}", http.Header{"Retry-After": {"30"}}), true, false, true, false},
+        {"503", mkResp(503, "", nil), true, false, false, false},
+        {"400", mkResp(400, `{"error":{"message":"bad"}}`, nil), false, false, false, false},
+        {"graph expired token", mkResp(400, `{"error":{"message":"expired","code":190}}`, nil), false, true, false, false},
+        {"graph throttle", mkResp(400, `{"error":{"message":"slow down","code":613}}`, nil), true, false, true, false},
+        {"google quota", mkResp(403, `{"error":{"errors":[{"reason":"quotaExceeded"}]}}`, nil), false, false, false, true},
+    }
+    for _, tt := range tests {
+        err := HTTPError("test", tt.resp)
+        var pe PostError
+        if !errors.As(err, &pe) { t.Fatalf("%s: not a PostError: %T", tt.name, err) }
+        _, limited := pe.RateLimitedUntil()
+        if pe.Retryable() != tt.retryable || pe.AuthExpired() != tt.auth || limited != tt.limited || pe.QuotaExceeded() != tt.quota {
+            t.Fatalf("%s: got retryable=%v auth=%v limited=%v quota=%v", tt.name, pe.Retryable(), pe.AuthExpired(), limited, pe.QuotaExceeded())
+        }
+        if pe.Permanent() == pe.Retryable() { t.Fatalf("%s: permanent must be !retryable", tt.name) }
+    }
+}
+
+func TestRetryBackoff(t *testing.T) {
+    limited := HTTPError("test", mkResp(429, "", http.Header{"Retry-After": {"20"}}))
+    if d, ok := RetryBackoff(limited, 0); !ok || d < 19*time.Second || d > 20*time.Second {
+        t.Fatalf("rate limit backoff got %s ok=%v", d, ok)
+    }
+    server := HTTPError("test", mkResp(500, "", nil))
+    if d, ok := RetryBackoff(server, 2); !ok || d != 2*time.Second {
+        t.Fatalf("server backoff got %s ok=%v", d, ok)
+    }
+    if _, ok := RetryBackoff(HTTPError("test", mkResp(400, "", nil)), 0); ok {
+        t.Fatal("400 should not be retried")
+    }
+    if _, ok := RetryBackoff(li.ErrRateLimited, 0); !ok {
+        t.Fatal("linkedin rate limit should be retried")
+    }
+    if _, ok := RetryBackoff(errors.New("boom"), 0); ok {
+        t.Fatal("unclassified errors should not be retried")
+    }
+}
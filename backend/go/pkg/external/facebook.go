@@ -2,49 +2,194 @@ package external
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/bitesinbyte/ferret/pkg/config"
 	"io"
 	"net/http"
-	"os"
+	"net/url"
+	"time"
 )
 
 const FacebookCreatePostUrl = "https://graph.facebook.com/v19.0/%s/feed"
+const FacebookPhotosUrl = "https://graph.facebook.com/v19.0/%s/photos"
+const FacebookVideosUrl = "https://graph.facebook.com/v19.0/%s/videos"
+const FacebookCommentsUrl = "https://graph.facebook.com/v19.0/%s/comments"
+const FacebookRecentPostsUrl = "https://graph.facebook.com/v19.0/%s/feed?fields=id,message,created_time&limit=10&access_token=%s"
+const FacebookPostCountsUrl = "https://graph.facebook.com/v19.0/%s?fields=reactions.summary(total_count).limit(0),comments.summary(total_count).limit(0),shares&access_token=%s"
+const FacebookPostInsightsUrl = "https://graph.facebook.com/v19.0/%s/insights?metric=post_impressions,post_impressions_unique,post_clicks&access_token=%s"
 
 type Facebook struct {
+	// HTTP is the client used for Graph API calls; nil uses a default.
+	HTTP *http.Client
+}
+
+func init() {
+	Register(Platform{
+		Name:    "facebook",
+		Aliases: []string{"fb"},
+		Capabilities: Capabilities{
+			MaxChars:   63206,
+			MediaTypes: []MediaType{MediaImage, MediaVideo},
+			MaxMedia:   10,
+			Threads:    false,
+			ReturnsID:  true,
+		},
+		RatePerSecond: 2,
+		New:           func(hc *http.Client) Poster { return Facebook{HTTP: hc} },
+	})
 }
 
 type facebookCreatePost struct {
-	Message     string `json:"message"`
-	Link        string `json:"link"`
-	AccessToken string `json:"access_token"`
+	Message       string              `json:"message"`
+	Link          string              `json:"link,omitempty"`
+	AttachedMedia []facebookMediaFbid `json:"attached_media,omitempty"`
+	AccessToken   string              `json:"access_token"`
+}
+
+type facebookMediaFbid struct {
+	MediaFbid string `json:"media_fbid"`
+}
+
+type facebookIdResponse struct {
+	Id string `json:"id"`
 }
 
 func (m Facebook) Post(configData config.Config, post Post) error {
-	var accessToken = os.Getenv("FACEBOOK_ACCESS_TOKEN")
-	var url = fmt.Sprintf(FacebookCreatePostUrl, os.Getenv("FACEBOOK_PAGE_ID"))
-	content := facebookCreatePost{
-		Message:     fmt.Sprintf("Just posted a new blog \n%s\n%s", post.Title, post.HashTags),
-		AccessToken: accessToken,
-		Link:        post.Link,
-	}
-	reqBody, err := json.Marshal(content)
+	_, err := m.PostWithID(configData, post)
+	return err
+}
+
+// PostWithID publishes with the FACEBOOK_* env credentials.
+func (m Facebook) PostWithID(configData config.Config, post Post) (string, error) {
+	return m.Publish(context.Background(), configData, CredentialsFromEnv("facebook"), post)
+}
+
+// RenderText returns the text Publish sends for post.
+func (m Facebook) RenderText(post Post) string {
+	return post.TextFor("facebook", fmt.Sprintf("Just posted a new blog \n%s\n%s", post.Title, post.HashTags))
+}
+
+// Publish posts to the page feed (creds.ExternalID is the page id). Images are
+// uploaded unpublished and attached; a video is published through /videos
+// instead. FirstComment and Thread entries become comments in order (see
+// publishFollowUps). Returns the post id.
+func (m Facebook) Publish(ctx context.Context, configData config.Config, creds Credentials, post Post) (string, error) {
+	s := newSession(ctx, "facebook", creds, m.HTTP)
+	accessToken := creds.AccessToken
+	pageId := creds.ExternalID
+	message := m.RenderText(post)
+
+	var id string
+	var err error
+	if len(post.Media) > 0 && post.Media[0].IsVideo() {
+		id, err = s.facebookGraphPost(fmt.Sprintf(FacebookVideosUrl, pageId), map[string]any{
+			"file_url":     post.Media[0].URL,
+			"description":  message,
+			"access_token": accessToken,
+		})
+	} else {
+		content := facebookCreatePost{Message: message, AccessToken: accessToken, Link: post.Link}
+		for _, item := range post.Media {
+			if item.IsVideo() {
+				continue
+			}
+			photoId, err := s.facebookGraphPost(fmt.Sprintf(FacebookPhotosUrl, pageId), map[string]any{
+				"url":             item.URL,
+				"published":       false,
+				"alt_text_custom": item.AltText,
+				"access_token":    accessToken,
+			})
+			if err != nil {
+				return "", err
+			}
+			content.AttachedMedia = append(content.AttachedMedia, facebookMediaFbid{MediaFbid: photoId})
+		}
+		if len(content.AttachedMedia) > 0 {
+			// Graph ignores link previews when media is attached.
+			content.Link = ""
+		}
+		id, err = s.facebookGraphPost(fmt.Sprintf(FacebookCreatePostUrl, pageId), content)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	publishFollowUps("facebook", id, post, false, func(text string, _ []Media, parentId string) (string, error) {
+		return s.facebookGraphPost(fmt.Sprintf(FacebookCommentsUrl, parentId), map[string]any{"message": text, "access_token": accessToken})
+	})
+	return id, nil
+}
+
+// Reconcile looks for post among the page's latest feed posts.
+func (m Facebook) Reconcile(ctx context.Context, creds Credentials, post Post, since time.Time) (string, bool, error) {
+	s := newSession(ctx, "facebook", creds, m.HTTP)
+	recent, err := s.graphRecent(fmt.Sprintf(FacebookRecentPostsUrl, creds.ExternalID, url.QueryEscape(creds.AccessToken)), "message", "created_time")
+	if err != nil {
+		return "", false, err
+	}
+	id, found := matchRecent(recent, m.RenderText(post), since)
+	return id, found, nil
+}
+
+// Metrics reads the reaction, comment and share counts of a Page post and
+// its lifetime insights (impressions, unique impressions as reach, clicks).
+func (m Facebook) Metrics(ctx context.Context, creds Credentials, externalID string) (PostMetrics, error) {
+	s := newSession(ctx, "facebook", creds, m.HTTP)
+	id, token := url.PathEscape(externalID), url.QueryEscape(creds.AccessToken)
+	type summary struct {
+		Summary struct {
+			TotalCount float64 `json:"total_count"`
+		} `json:"summary"`
+	}
+	var counts struct {
+		Reactions summary `json:"reactions"`
+		Comments  summary `json:"comments"`
+		Shares    struct {
+			Count float64 `json:"count"`
+		} `json:"shares"`
+	}
+	req, err := s.newRequest(http.MethodGet, fmt.Sprintf(FacebookPostCountsUrl, id, token), nil)
 	if err != nil {
-		return err
+		return PostMetrics{}, err
 	}
+	if err := s.fetchJSON(req, &counts); err != nil {
+		return PostMetrics{}, err
+	}
+	raw, err := s.graphInsights(fmt.Sprintf(FacebookPostInsightsUrl, id, token))
+	if err != nil {
+		return PostMetrics{}, err
+	}
+	raw["reactions"], raw["comments"], raw["shares"] = counts.Reactions.Summary.TotalCount, counts.Comments.Summary.TotalCount, counts.Shares.Count
+	return PostMetrics{
+		Impressions: int64(raw["post_impressions"]),
+		Reach:       int64(raw["post_impressions_unique"]),
+		Likes:       int64(raw["reactions"]),
+		Comments:    int64(raw["comments"]),
+		Shares:      int64(raw["shares"]),
+		Clicks:      int64(raw["post_clicks"]),
+		Raw:         raw,
+	}, nil
+}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(reqBody))
+// facebookGraphPost sends a JSON Graph API POST and returns the created object id.
+func (s session) facebookGraphPost(url string, payload any) (string, error) {
+	reqBody, err := json.Marshal(payload)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	req, err := s.newRequest(http.MethodPost, url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return "", err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	client := http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -54,8 +199,11 @@ func (m Facebook) Post(configData config.Config, post Post) error {
 	}(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return "", HTTPError("facebook", resp)
 	}
-
-	return nil
+	var created facebookIdResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", err
+	}
+	return created.Id, nil
 }
@@ -3,47 +3,145 @@ package external
 import (
     "context"
     "fmt"
+    "net/http"
+    "net/url"
     "os"
     "strings"
+    "time"
 
     ig "github.com/bitesinbyte/ferret/pkg/external/instagram"
     "github.com/bitesinbyte/ferret/pkg/config"
 )
 
-type Instagram struct{}
+type Instagram struct {
+    // HTTP is the client used for Graph API calls; nil uses a default.
+    HTTP *http.Client
+}
+
+func init() {
+    Register(Platform{
+        Name:    "instagram",
+        Aliases: []string{"ig"},
+        Capabilities: Capabilities{
+            MaxChars:   2200,
+            MediaTypes: []MediaType{MediaImage, MediaVideo},
+            MaxMedia:   10,
+            Threads:    false,
+            ReturnsID:  true,
+        },
+        RatePerSecond: 1,
+        New:           func(hc *http.Client) Poster { return Instagram{HTTP: hc} },
+    })
+}
 
 func (m Instagram) Post(configData config.Config, post Post) error {
-    // Build caption: title + hashtags (no clickable links in captions)
-    caption := strings.TrimSpace(fmt.Sprintf("%s\n\n%s", post.Title, post.HashTags))
-    // Try to resolve an OG image from the link
-    imageURL, err := getOGImageURL(post.Link, configData)
-    if err != nil || imageURL == "" {
-        return err
-    }
-    cfg := ig.NewFromEnv()
-    client := ig.New(cfg)
-    ctx := context.Background()
-    id, err := client.PostFeedImage(ctx, imageURL, caption)
-    if err != nil { return err }
-    // Optional: Post a first comment if provided via env
-    if fc := strings.TrimSpace(os.Getenv("IG_FIRST_COMMENT_TEXT")); fc != "" {
-        _ = client.PostFirstComment(ctx, id, fc)
-    }
-    return nil
+    _, err := m.PostWithID(configData, post)
+    return err
 }
 
-// PostWithID posts to Instagram feed and returns the created media ID.
+// PostWithID posts with the IG_* env credentials and returns the created media ID.
 func (m Instagram) PostWithID(configData config.Config, post Post) (string, error) {
-    caption := strings.TrimSpace(fmt.Sprintf("%s\n\n%s", post.Title, post.HashTags))
-    imageURL, err := getOGImageURL(post.Link, configData)
-    if err != nil || imageURL == "" { return "", err }
+    return m.Publish(context.Background(), configData, CredentialsFromEnv("instagram"), post)
+}
+
+// RenderText returns the caption Publish sends: title + hashtags (no clickable
+// links in captions) unless the post carries its own text.
+func (m Instagram) RenderText(post Post) string {
+    return post.TextFor("instagram", strings.TrimSpace(fmt.Sprintf("%s\n\n%s", post.Title, post.HashTags)))
+}
+
+// Reconcile looks for post among the account's latest media by caption.
+func (m Instagram) Reconcile(ctx context.Context, creds Credentials, post Post, since time.Time) (string, bool, error) {
+    s := newSession(ctx, "instagram", creds, m.HTTP)
+    version := ig.NewFromEnv().Version
+    if v := creds.Extra["graph_version"]; v != "" { version = v }
+    u := fmt.Sprintf("https://graph.facebook.com/%s/%s/media?fields=id,caption,timestamp&limit=10&access_token=%s",
+        version, creds.ExternalID, url.QueryEscape(creds.AccessToken))
+    recent, err := s.graphRecent(u, "caption", "timestamp")
+    if err != nil { return "", false, err }
+    id, found := matchRecent(recent, m.RenderText(post), since)
+    return id, found, nil
+}
+
+// InstagramInsightMetrics are the lifetime media insights Metrics requests;
+// IG_INSIGHT_METRICS (comma-separated) overrides them, e.g. for reels.
+var InstagramInsightMetrics = []string{"impressions", "reach", "saved", "shares"}
+
+// Metrics reads the like and comment counts of a media object and its
+// lifetime insights (saved counts as saves).
+func (m Instagram) Metrics(ctx context.Context, creds Credentials, externalID string) (PostMetrics, error) {
+    s := newSession(ctx, "instagram", creds, m.HTTP)
+    version := ig.NewFromEnv().Version
+    if v := creds.Extra["graph_version"]; v != "" { version = v }
+    base := fmt.Sprintf("https://graph.facebook.com/%s/%s", version, url.PathEscape(externalID))
+    token := url.QueryEscape(creds.AccessToken)
+    var counts struct {
+        LikeCount     float64 `json:"like_count"`
+        CommentsCount float64 `json:"comments_count"`
+    }
+    req, err := s.newRequest(http.MethodGet, base+"?fields=like_count,comments_count&access_token="+token, nil)
+    if err != nil { return PostMetrics{}, err }
+    if err := s.fetchJSON(req, &counts); err != nil { return PostMetrics{}, err }
+    metrics := InstagramInsightMetrics
+    if v := os.Getenv("IG_INSIGHT_METRICS"); v != "" { metrics = strings.Split(v, ",") }
+    raw, err := s.graphInsights(base + "/insights?metric=" + url.QueryEscape(strings.Join(metrics, ",")) + "&access_token=" + token)
+    if err != nil { return PostMetrics{}, err }
+    raw["like_count"], raw["comments_count"] = counts.LikeCount, counts.CommentsCount
+    impressions := raw["impressions"]
+    if impressions == 0 { impressions = raw["views"] }
+    return PostMetrics{
+        Impressions: int64(impressions),
+        Reach:       int64(raw["reach"]),
+        Likes:       int64(counts.LikeCount),
+        Comments:    int64(counts.CommentsCount),
+        Shares:      int64(raw["shares"]),
+        Saves:       int64(raw["saved"]),
+        Raw:         raw,
+    }, nil
+}
+
+// Publish posts to the creds.ExternalID feed and returns the created media ID.
+// One image posts to the feed, one video posts as a reel and several items
+// become a carousel; without media the link's OG image is used. FirstComment
+// (or IG_FIRST_COMMENT_TEXT) and Thread entries are added as comments.
+func (m Instagram) Publish(ctx context.Context, configData config.Config, creds Credentials, post Post) (string, error) {
+    s := newSession(ctx, "instagram", creds, m.HTTP)
+    caption := m.RenderText(post)
     cfg := ig.NewFromEnv()
+    if v := creds.Extra["graph_version"]; v != "" {
+        cfg.Version = v
+        cfg.BaseURL = "https://graph.facebook.com/" + v
+    }
+    cfg.IGUserID = creds.ExternalID
+    cfg.AccessToken = creds.AccessToken
+    cfg.HTTPClient = m.HTTP
     client := ig.New(cfg)
-    ctx := context.Background()
-    id, err := client.PostFeedImage(ctx, imageURL, caption)
-    if err != nil { return "", err }
-    if fc := strings.TrimSpace(os.Getenv("IG_FIRST_COMMENT_TEXT")); fc != "" {
-        _ = client.PostFirstComment(ctx, id, fc)
+
+    var id string
+    var err error
+    switch {
+    case len(post.Media) > 1:
+        urls := make([]string, 0, len(post.Media))
+        for _, item := range post.Media { urls = append(urls, item.URL) }
+        id, err = client.PostCarousel(ctx, urls, caption)
+    case len(post.Media) == 1 && post.Media[0].IsVideo():
+        id, err = client.PostReel(ctx, post.Media[0].URL, caption)
+    case len(post.Media) == 1:
+        id, err = client.PostFeedImage(ctx, post.Media[0].URL, caption)
+    default:
+        // Try to resolve an OG image from the link
+        imageURL, ogErr := s.getOGImageURL(post.Link, configData)
+        if ogErr != nil || imageURL == "" { return "", AsPostError("instagram", ogErr) }
+        id, err = client.PostFeedImage(ctx, imageURL, caption)
+    }
+    if err != nil { return "", AsPostError("instagram", err) }
+
+    if post.FirstComment == "" {
+        // Optional: Post a first comment if provided via env
+        post.FirstComment = strings.TrimSpace(os.Getenv("IG_FIRST_COMMENT_TEXT"))
     }
+    publishFollowUps("instagram", id, post, false, func(text string, _ []Media, mediaID string) (string, error) {
+        return "", client.PostFirstComment(ctx, mediaID, text)
+    })
     return id, nil
 }
@@ -32,6 +32,9 @@ type MediaItem struct {
 }
 
 func New(cfg Config) *Client {
+    if cfg.HTTPClient != nil {
+        return &Client{httpClient: cfg.HTTPClient, cfg: cfg}
+    }
     timeout := cfg.HTTPTimeout
     if timeout <= 0 {
         timeout = 30 * time.Second
@@ -295,35 +298,47 @@ func mapHTTPError(resp *http.Response) error {
     if resp == nil {
         return ErrServer
     }
-    if resp.StatusCode == http.StatusUnauthorized {
-        return ErrUnauthorized
-    }
-    if resp.StatusCode == http.StatusForbidden {
-        return ErrForbidden
-    }
-    if resp.StatusCode == http.StatusTooManyRequests {
-        return ErrRateLimited
-    }
+    e := &HTTPError{StatusCode: resp.StatusCode, RetryAt: retryAfter(resp.Header.Get("Retry-After"), time.Now())}
     body, _ := io.ReadAll(resp.Body)
     var ge graphErrorResponse
     if err := json.Unmarshal(body, &ge); err == nil && ge.Error.Message != "" {
-        switch resp.StatusCode {
-        case http.StatusBadRequest:
-            return ErrValidation
-        case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
-            return ErrServer
-        default:
-            return errors.New(ge.Error.Message)
-        }
+        e.Message = ge.Error.Message
     }
     switch {
+    case resp.StatusCode == http.StatusUnauthorized || ge.Error.Code == 190:
+        e.Err = ErrUnauthorized
+    case resp.StatusCode == http.StatusTooManyRequests || isGraphThrottle(ge.Error.Code):
+        e.Err = ErrRateLimited
+    case resp.StatusCode == http.StatusForbidden:
+        e.Err = ErrForbidden
+    case resp.StatusCode == http.StatusNotFound:
+        e.Err = ErrNotFound
     case resp.StatusCode >= 500:
-        return ErrServer
+        e.Err = ErrServer
     case resp.StatusCode >= 400:
-        return ErrValidation
+        e.Err = ErrValidation
     default:
-        return ErrServer
+        e.Err = ErrServer
     }
+    return e
+}
+
+// isGraphThrottle reports Graph API throttling codes, which arrive as 400s.
+func isGraphThrottle(code int) bool {
+    switch code {
+    case 4, 17, 32, 613:
+        return true
+    }
+    return false
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(v string, now time.Time) time.Time {
+    v = strings.TrimSpace(v)
+    if v == "" { return time.Time{} }
+    if secs, err := strconv.Atoi(v); err == nil { return now.Add(time.Duration(secs) * time.Second) }
+    if t, err := http.ParseTime(v); err == nil { return t }
+    return time.Time{}
 }
 
 func isVideoURL(u string) bool {
@@ -1,6 +1,7 @@
 package instagram
 
 import (
+    "net/http"
     "os"
     "time"
 )
@@ -11,6 +12,8 @@ type Config struct {
     IGUserID    string
     AccessToken string
     HTTPTimeout time.Duration
+    // HTTPClient overrides the default client (HTTPTimeout is then ignored).
+    HTTPClient *http.Client
 }
 
 func NewFromEnv() Config {
@@ -1,14 +1,60 @@
 package instagram
 
-import "errors"
+import (
+    "fmt"
+    "time"
+)
+
+// Error is the typed sentinel behind the Err* values. Besides errors.Is
+// identity it reports its retry class, matching external.PostError.
+type Error struct {
+    msg       string
+    retryable bool
+    auth      bool
+    limited   bool
+}
+
+func (e *Error) Error() string { return e.msg }
+
+func (e *Error) Retryable() bool                     { return e.retryable }
+func (e *Error) Permanent() bool                     { return !e.retryable }
+func (e *Error) AuthExpired() bool                   { return e.auth }
+func (e *Error) RateLimitedUntil() (time.Time, bool) { return time.Time{}, e.limited }
+func (e *Error) QuotaExceeded() bool                 { return false }
 
 var (
-    ErrUnauthorized       = errors.New("instagram: unauthorized or invalid token")
-    ErrForbidden          = errors.New("instagram: insufficient permissions")
-    ErrRateLimited        = errors.New("instagram: rate limited")
-    ErrValidation         = errors.New("instagram: validation error")
-    ErrNotFound           = errors.New("instagram: resource not found")
-    ErrServer             = errors.New("instagram: server error")
-    ErrUnsupportedFeature = errors.New("instagram: feature unsupported in current API/permissions")
+    ErrUnauthorized       = &Error{msg: "instagram: unauthorized or invalid token", auth: true}
+    ErrForbidden          = &Error{msg: "instagram: insufficient permissions"}
+    ErrRateLimited        = &Error{msg: "instagram: rate limited", retryable: true, limited: true}
+    ErrValidation         = &Error{msg: "instagram: validation error"}
+    ErrNotFound           = &Error{msg: "instagram: resource not found"}
+    ErrServer             = &Error{msg: "instagram: server error", retryable: true}
+    ErrUnsupportedFeature = &Error{msg: "instagram: feature unsupported in current API/permissions"}
 )
 
+// HTTPError is what mapHTTPError returns: one of the sentinels above plus the
+// response status, Graph message and Retry-After, if any.
+type HTTPError struct {
+    StatusCode int
+    Message    string
+    RetryAt    time.Time
+    Err        *Error
+}
+
+func (e *HTTPError) Error() string {
+    if e.Message != "" {
+        return fmt.Sprintf("%s: %s (status=%d)", e.Err.Error(), e.Message, e.StatusCode)
+    }
+    return fmt.Sprintf("%s (status=%d)", e.Err.Error(), e.StatusCode)
+}
+
+func (e *HTTPError) Unwrap() error { return e.Err }
+
+func (e *HTTPError) Retryable() bool   { return e.Err.Retryable() }
+func (e *HTTPError) Permanent() bool   { return e.Err.Permanent() }
+func (e *HTTPError) AuthExpired() bool { return e.Err.AuthExpired() }
+func (e *HTTPError) QuotaExceeded() bool { return false }
+func (e *HTTPError) RateLimitedUntil() (time.Time, bool) {
+    _, limited := e.Err.RateLimitedUntil()
+    return e.RetryAt, limited
+}
@@ -2,130 +2,289 @@ package external
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/bitesinbyte/ferret/pkg/config"
+	li "github.com/bitesinbyte/ferret/pkg/external/linkedin"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
+	"time"
 )
 
 const (
-	LinkedinCreatePostUrl = "https://api.linkedin.com/v2/posts"
-	LinkedinProfileUrl    = "https://api.linkedin.com/v2/userinfo"
-	LinkedinImageUrl      = "https://api.linkedin.com/rest/images?action=initializeUpload"
+	LinkedinCreatePostUrl  = "https://api.linkedin.com/v2/posts"
+	LinkedinProfileUrl     = "https://api.linkedin.com/v2/userinfo"
+	LinkedinImageUrl       = "https://api.linkedin.com/rest/images?action=initializeUpload"
+	LinkedinCommentsUrl    = "https://api.linkedin.com/rest/socialActions/%s/comments"
+	LinkedinAuthorPostsUrl = "https://api.linkedin.com/rest/posts?q=author&author=%s&count=20&sortBy=CREATED"
 )
 
 type Linkedin struct {
+	// HTTP is the client used for API calls and media downloads; nil uses a default.
+	HTTP *http.Client
 }
 
-func (m Linkedin) Post(configData config.Config, post Post) error {
-	var accessToken = fmt.Sprintf("Bearer %s", os.Getenv("LINKEDIN_ACCESS_TOKEN"))
-	err, userInfo := fetchProfile(accessToken)
-	if err != nil {
-		return err
-	}
+func init() {
+	Register(Platform{
+		Name:    "linkedin",
+		Aliases: []string{"li", "ln"},
+		Capabilities: Capabilities{
+			MaxChars:   3000,
+			MediaTypes: []MediaType{MediaImage},
+			MaxMedia:   20,
+			Threads:    false,
+			ReturnsID:  true,
+		},
+		RatePerSecond: 1,
+		New:           func(hc *http.Client) Poster { return Linkedin{HTTP: hc} },
+	})
+}
 
-	content := fmt.Sprintf("Just posted a new blog\n\n%s", post.HashTags)
-    err = createPost(configData, post, content, userInfo.Sub, accessToken)
-    return err
+func (m Linkedin) Post(configData config.Config, post Post) error {
+	_, err := m.PostWithID(configData, post)
+	return err
 }
-// PostWithID creates a LinkedIn post and returns the created post URN via response headers.
+
+// PostWithID creates a LinkedIn post with LINKEDIN_ACCESS_TOKEN and returns
+// the created post URN.
 func (m Linkedin) PostWithID(configData config.Config, post Post) (string, error) {
-    var accessToken = fmt.Sprintf("Bearer %s", os.Getenv("LINKEDIN_ACCESS_TOKEN"))
-    err, userInfo := fetchProfile(accessToken)
-    if err != nil { return "", err }
-    content := fmt.Sprintf("Just posted a new blog\n\n%s", post.HashTags)
-    urn, err := createPostWithID(configData, post, content, userInfo.Sub, accessToken)
-    return urn, err
+	return m.Publish(context.Background(), configData, CredentialsFromEnv("linkedin"), post)
+}
+
+// RenderText returns the text Publish sends for post.
+func (m Linkedin) RenderText(post Post) string {
+	return post.TextFor("linkedin", fmt.Sprintf("Just posted a new blog\n\n%s", post.HashTags))
+}
+
+// Metrics reads the statistics of a post URN. Unique impressions count as
+// reach and reactions as likes.
+func (m Linkedin) Metrics(ctx context.Context, creds Credentials, externalID string) (PostMetrics, error) {
+	client := li.New(li.Config{AccessToken: creds.AccessToken, HTTPClient: m.HTTP})
+	stats, err := client.GetPostStatistics(ctx, externalID)
+	if err != nil {
+		return PostMetrics{}, AsPostError("linkedin", err)
+	}
+	return PostMetrics{
+		Impressions: int64(stats.Impressions),
+		Reach:       int64(stats.UniqueImpressions),
+		Likes:       int64(stats.Reactions),
+		Comments:    int64(stats.Comments),
+		Shares:      int64(stats.Shares),
+		Raw: map[string]float64{
+			"impressions": float64(stats.Impressions), "uniqueImpressions": float64(stats.UniqueImpressions),
+			"reactions": float64(stats.Reactions), "comments": float64(stats.Comments), "shares": float64(stats.Shares),
+		},
+	}, nil
 }
-func createPost(configData config.Config, post Post, content string, authorId string, accessToken string) error {
-	err, thumbnail := getThumbnail(configData, post.Link, authorId, accessToken)
+
+// Reconcile looks for post among the author's latest posts (the posts
+// finder needs the r_member_social scope). The author is creds.ExternalID, or
+// the token's profile when unset, as in Publish.
+func (m Linkedin) Reconcile(ctx context.Context, creds Credentials, post Post, since time.Time) (string, bool, error) {
+	s := newSession(ctx, "linkedin", creds, m.HTTP)
+	authorId := creds.ExternalID
+	if authorId == "" {
+		err, userInfo := s.fetchProfile()
+		if err != nil {
+			return "", false, AsPostError("linkedin", err)
+		}
+		authorId = userInfo.Sub
+	}
+	author := url.QueryEscape(fmt.Sprintf("urn:li:person:%s", authorId))
+	req, err := s.newRequest(http.MethodGet, fmt.Sprintf(LinkedinAuthorPostsUrl, author), nil)
 	if err != nil {
-		return err
+		return "", false, err
+	}
+	req.Header.Set("Authorization", s.bearer())
+	req.Header.Set("LinkedIn-Version", "202401")
+	req.Header.Set("X-Restli-Protocol-Version", "2.0.0")
+	var page struct {
+		Elements []struct {
+			Id         string `json:"id"`
+			Commentary string `json:"commentary"`
+			CreatedAt  int64  `json:"createdAt"` // epoch milliseconds
+		} `json:"elements"`
+	}
+	if err := s.fetchJSON(req, &page); err != nil {
+		return "", false, err
+	}
+	recent := make([]recentPost, 0, len(page.Elements))
+	for _, e := range page.Elements {
+		p := recentPost{ID: e.Id, Text: e.Commentary}
+		if e.CreatedAt > 0 {
+			p.CreatedAt = time.UnixMilli(e.CreatedAt)
+		}
+		recent = append(recent, p)
 	}
+	id, found := matchRecent(recent, m.RenderText(post), since)
+	return id, found, nil
+}
 
-	body, err := json.Marshal(linkedinPost{
-		Author:             fmt.Sprintf("urn:li:person:%s", authorId),
-		Commentary:         content,
-		ContentLandingPage: post.Link,
-		Visibility:         "PUBLIC",
+// Publish creates a LinkedIn post and returns the created post URN via response headers.
+// The author is creds.ExternalID, or the token's profile when unset.
+// Image media is uploaded and attached; without media a Link becomes an article
+// card. FirstComment and Thread entries are added as comments in order.
+func (m Linkedin) Publish(ctx context.Context, configData config.Config, creds Credentials, post Post) (string, error) {
+	s := newSession(ctx, "linkedin", creds, m.HTTP)
+	authorId := creds.ExternalID
+	if authorId == "" {
+		err, userInfo := s.fetchProfile()
+		if err != nil {
+			return "", AsPostError("linkedin", err)
+		}
+		authorId = userInfo.Sub
+	}
+	content := m.RenderText(post)
+	urn, err := s.createPostWithID(configData, post, content, authorId)
+	if err != nil {
+		return "", AsPostError("linkedin", err)
+	}
+
+	publishFollowUps("linkedin", urn, post, false, func(text string, _ []Media, parentUrn string) (string, error) {
+		return "", s.createComment(parentUrn, text, authorId)
+	})
+	return urn, nil
+}
+
+// bearer is the Authorization header value for the session's token.
+func (s session) bearer() string {
+	return "Bearer " + s.creds.AccessToken
+}
+
+// createPostWithID publishes the post and returns the created post URN from headers.
+func (s session) createPostWithID(configData config.Config, post Post, content string, authorId string) (string, error) {
+	request := linkedinPost{
+		Author:     fmt.Sprintf("urn:li:person:%s", authorId),
+		Commentary: content,
+		Visibility: "PUBLIC",
 		Distribution: struct {
 			FeedDistribution string `json:"feedDistribution"`
 		}{
 			FeedDistribution: "MAIN_FEED",
 		},
-		Content: struct {
-			Article postArticleContent `json:"article"`
-		}{
-			Article: postArticleContent{
-				Source:      post.Link,
-				Thumbnail:   thumbnail,
-				Title:       post.Title,
-				Description: post.Description,
-			},
-		},
-		LifecycleState:           "PUBLISHED",
-		ContentCallToActionLabel: "SEE_MORE",
-	})
-	if err != nil {
-		return err
+		LifecycleState: "PUBLISHED",
+	}
+	switch {
+	case len(post.Media) > 0:
+		images := make([]linkedinMedia, 0, len(post.Media))
+		for _, item := range post.Media {
+			imageUrn, err := s.uploadLinkedinMedia(item, authorId)
+			if err != nil {
+				return "", err
+			}
+			images = append(images, linkedinMedia{Id: imageUrn, AltText: item.AltText})
+		}
+		if len(images) == 1 {
+			request.Content = &linkedinContent{Media: &images[0]}
+		} else {
+			request.Content = &linkedinContent{MultiImage: &linkedinMultiImage{Images: images}}
+		}
+	case post.Link != "":
+		err, thumbnail := s.getThumbnail(configData, post.Link, authorId)
+		if err != nil {
+			return "", err
+		}
+		request.ContentLandingPage = post.Link
+		request.ContentCallToActionLabel = "SEE_MORE"
+		request.Content = &linkedinContent{Article: &postArticleContent{
+			Source:      post.Link,
+			Thumbnail:   thumbnail,
+			Title:       post.Title,
+			Description: post.Description,
+		}}
 	}
 
-	req, err := http.NewRequest(http.MethodPost, LinkedinCreatePostUrl, bytes.NewBuffer(body))
+	body, err := json.Marshal(request)
 	if err != nil {
-		return err
+		return "", err
 	}
-	req.Header.Set("Authorization", accessToken)
-	client := http.Client{}
-	resp, err := client.Do(req)
+	req, err := s.newRequest(http.MethodPost, LinkedinCreatePostUrl, bytes.NewBuffer(body))
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			panic(err)
-		}
-	}(resp.Body)
+	req.Header.Set("Authorization", s.bearer())
+	resp, err := s.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func(Body io.ReadCloser) { _ = Body.Close() }(resp.Body)
 	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return "", HTTPError("linkedin", resp)
+	}
+	// LinkedIn returns the created URN in the x-restli-id header or Location
+	urn := resp.Header.Get("x-restli-id")
+	if urn == "" {
+		urn = resp.Header.Get("Location")
 	}
+	return urn, nil
+}
 
-	return nil
+// uploadLinkedinMedia registers an image upload and PUTs the downloaded bytes.
+func (s session) uploadLinkedinMedia(item Media, authorId string) (string, error) {
+	if item.IsVideo() {
+		return "", NewPlatformError("linkedin", KindPermanent, fmt.Errorf("video upload is not supported: %s", item.URL))
+	}
+	b, ctype, _, err := s.fetchMedia(item)
+	if err != nil {
+		return "", err
+	}
+	err, upload := s.initializeUpload(authorId)
+	if err != nil {
+		return "", err
+	}
+	req, err := s.newRequest(http.MethodPut, upload.Value.UploadUrl, bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", s.bearer())
+	req.Header.Set("Content-Type", ctype)
+	resp, err := s.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", HTTPError("linkedin", resp)
+	}
+	return upload.Value.Image, nil
 }
 
-// createPostWithID is identical to createPost but returns the created post URN from headers.
-func createPostWithID(configData config.Config, post Post, content string, authorId string, accessToken string) (string, error) {
-    err, thumbnail := getThumbnail(configData, post.Link, authorId, accessToken)
-    if err != nil { return "", err }
-    body, err := json.Marshal(linkedinPost{
-        Author:             fmt.Sprintf("urn:li:person:%s", authorId),
-        Commentary:         content,
-        ContentLandingPage: post.Link,
-        Visibility:         "PUBLIC",
-        Distribution: struct { FeedDistribution string `json:"feedDistribution"` }{ FeedDistribution: "MAIN_FEED" },
-        Content: struct { Article postArticleContent `json:"article"` }{ Article: postArticleContent{ Source: post.Link, Thumbnail: thumbnail, Title: post.Title, Description: post.Description } },
-        LifecycleState:           "PUBLISHED",
-        ContentCallToActionLabel: "SEE_MORE",
-    })
-    if err != nil { return "", err }
-    req, err := http.NewRequest(http.MethodPost, LinkedinCreatePostUrl, bytes.NewBuffer(body))
-    if err != nil { return "", err }
-    req.Header.Set("Authorization", accessToken)
-    client := http.Client{}
-    resp, err := client.Do(req)
-    if err != nil { return "", err }
-    defer func(Body io.ReadCloser) { _ = Body.Close() }(resp.Body)
-    if resp.StatusCode != http.StatusCreated { return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode) }
-    // LinkedIn returns the created URN in the x-restli-id header or Location
-    urn := resp.Header.Get("x-restli-id")
-    if urn == "" { urn = resp.Header.Get("Location") }
-    return urn, nil
+type linkedinComment struct {
+	Actor   string `json:"actor"`
+	Object  string `json:"object"`
+	Message struct {
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+func (s session) createComment(postUrn string, text string, authorId string) error {
+	comment := linkedinComment{Actor: fmt.Sprintf("urn:li:person:%s", authorId), Object: postUrn}
+	comment.Message.Text = text
+	body, err := json.Marshal(comment)
+	if err != nil {
+		return err
+	}
+	req, err := s.newRequest(http.MethodPost, fmt.Sprintf(LinkedinCommentsUrl, url.PathEscape(postUrn)), bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", s.bearer())
+	req.Header.Set("LinkedIn-Version", "202401")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return HTTPError("linkedin", resp)
+	}
+	return nil
 }
 
 type postArticleContent struct {
@@ -134,20 +293,30 @@ type postArticleContent struct {
 	Description string `json:"description"`
 	Thumbnail   string `json:"thumbnail"`
 }
+type linkedinMedia struct {
+	Id      string `json:"id"`
+	AltText string `json:"altText,omitempty"`
+}
+type linkedinMultiImage struct {
+	Images []linkedinMedia `json:"images"`
+}
+type linkedinContent struct {
+	Article    *postArticleContent `json:"article,omitempty"`
+	Media      *linkedinMedia      `json:"media,omitempty"`
+	MultiImage *linkedinMultiImage `json:"multiImage,omitempty"`
+}
 type linkedinPost struct {
 	Author             string `json:"author"`
 	Commentary         string `json:"commentary"`
 	Visibility         string `json:"visibility"`
-	ContentLandingPage string `json:"contentLandingPage"`
+	ContentLandingPage string `json:"contentLandingPage,omitempty"`
 	Distribution       struct {
 		FeedDistribution string `json:"feedDistribution"`
 	} `json:"distribution"`
-	Content struct {
-		Article postArticleContent `json:"article"`
-	} `json:"content"`
-	LifecycleState            string `json:"lifecycleState"`
-	IsReshareDisabledByAuthor bool   `json:"isReshareDisabledByAuthor"`
-	ContentCallToActionLabel  string `json:"contentCallToActionLabel"`
+	Content                   *linkedinContent `json:"content,omitempty"`
+	LifecycleState            string           `json:"lifecycleState"`
+	IsReshareDisabledByAuthor bool             `json:"isReshareDisabledByAuthor"`
+	ContentCallToActionLabel  string           `json:"contentCallToActionLabel,omitempty"`
 }
 
 // thumbnail
@@ -164,12 +333,12 @@ type initializeUploadRequest struct {
 	} `json:"initializeUploadRequest"`
 }
 
-func getThumbnail(configData config.Config, articleUrl string, userId string, accessToken string) (error, string) {
-	err, initializeUpload := initializeUpload(accessToken, userId)
+func (s session) getThumbnail(configData config.Config, articleUrl string, userId string) (error, string) {
+	err, initializeUpload := s.initializeUpload(userId)
 	if err != nil {
 		return err, ""
 	}
-	err = uploadImage(articleUrl, configData, initializeUpload)
+	err = s.uploadImage(articleUrl, configData, initializeUpload)
 	if err != nil {
 		return err, ""
 	}
@@ -189,13 +358,13 @@ func getMetaOgHasRelativePath(imageUrl string, configData config.Config) string
 	}
 	return imageUrl
 }
-func uploadImage(articleUrl string, configData config.Config, initializeUpload *initializeUploadResponse) error {
-	imageUrl, err := getOGImageURL(articleUrl, configData)
+func (s session) uploadImage(articleUrl string, configData config.Config, initializeUpload *initializeUploadResponse) error {
+	imageUrl, err := s.getOGImageURL(articleUrl, configData)
 	if err != nil {
 		return err
 	}
 
-	resp, err := http.Get(imageUrl)
+	resp, err := s.get(imageUrl)
 	if err != nil {
 		return err
 	}
@@ -221,14 +390,13 @@ func uploadImage(articleUrl string, configData config.Config, initializeUpload *
 		return err
 	}
 
-	req, err := http.NewRequest("PUT", initializeUpload.Value.UploadUrl, body)
+	req, err := s.newRequest(http.MethodPut, initializeUpload.Value.UploadUrl, body)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	client := &http.Client{}
-	resp, err = client.Do(req)
+	resp, err = s.do(req)
 	if err != nil {
 		return err
 	}
@@ -240,14 +408,14 @@ func uploadImage(articleUrl string, configData config.Config, initializeUpload *
 	}(resp.Body)
 
 	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("HTTP request failed with status code %d", resp.StatusCode)
+		return HTTPError("linkedin", resp)
 	}
 	return nil
 }
 
 // GetOGImageURL retrieves the og:image URL from the HTTP header of a given URL
-func getOGImageURL(url string, configData config.Config) (string, error) {
-	resp, err := http.Get(url)
+func (s session) getOGImageURL(url string, configData config.Config) (string, error) {
+	resp, err := s.get(url)
 	if err != nil {
 		return "", err
 	}
@@ -282,7 +450,7 @@ func getOGImageURL(url string, configData config.Config) (string, error) {
 
 	return ogImageURL, nil
 }
-func initializeUpload(accessToken string, userId string) (error, *initializeUploadResponse) {
+func (s session) initializeUpload(userId string) (error, *initializeUploadResponse) {
 	request := initializeUploadRequest{
 		InitializeUploadRequest: struct {
 			Owner string `json:"owner"`
@@ -294,14 +462,13 @@ func initializeUpload(accessToken string, userId string) (error, *initializeUplo
 	if err != nil {
 		return err, nil
 	}
-	req, err := http.NewRequest(http.MethodPost, LinkedinImageUrl, bytes.NewBuffer(body))
+	req, err := s.newRequest(http.MethodPost, LinkedinImageUrl, bytes.NewBuffer(body))
 	if err != nil {
 		return err, nil
 	}
-	req.Header.Set("Authorization", accessToken)
+	req.Header.Set("Authorization", s.bearer())
 	req.Header.Set("LinkedIn-Version", "202401")
-	client := http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return err, nil
 	}
@@ -312,7 +479,7 @@ func initializeUpload(accessToken string, userId string) (error, *initializeUplo
 		}
 	}(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode), nil
+		return HTTPError("linkedin", resp), nil
 	}
 	var uploadResponse initializeUploadResponse
 	body, err = io.ReadAll(resp.Body)
@@ -320,7 +487,7 @@ func initializeUpload(accessToken string, userId string) (error, *initializeUplo
 		return err, nil
 	}
 	if err := json.Unmarshal(body, &uploadResponse); err != nil {
-		panic(err)
+		return err, nil
 	}
 	return nil, &uploadResponse
 }
@@ -329,20 +496,20 @@ type userInfo struct {
 	Sub string `json:"sub"`
 }
 
-func fetchProfile(accessToken string) (error, *userInfo) {
-	req, err := http.NewRequest(http.MethodGet, LinkedinProfileUrl, nil)
+func (s session) fetchProfile() (error, *userInfo) {
+	req, err := s.newRequest(http.MethodGet, LinkedinProfileUrl, nil)
 	if err != nil {
 		return err, nil
 	}
-	req.Header.Set("Authorization", accessToken)
+	req.Header.Set("Authorization", s.bearer())
 
-	client := http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return err, nil
 	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode), nil
+		defer resp.Body.Close()
+		return HTTPError("linkedin", resp), nil
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
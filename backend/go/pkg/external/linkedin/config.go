@@ -1,6 +1,7 @@
 package linkedin
 
 import (
+    "net/http"
     "os"
     "time"
 )
@@ -8,6 +9,8 @@ import (
 type Config struct {
     AccessToken string
     HTTPTimeout time.Duration
+    // HTTPClient overrides the default client (HTTPTimeout is then ignored).
+    HTTPClient *http.Client
 }
 
 func NewFromEnv() Config {
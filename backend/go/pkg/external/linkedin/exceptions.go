@@ -2,19 +2,36 @@ package linkedin
 
 import (
     "encoding/json"
-    "errors"
     "fmt"
     "io"
     "net/http"
+    "time"
 )
 
+// Error is the typed sentinel behind the Err* values. Besides identity
+// comparison it reports its retry class, matching external.PostError.
+type Error struct {
+    msg       string
+    retryable bool
+    auth      bool
+    limited   bool
+}
+
+func (e *Error) Error() string { return e.msg }
+
+func (e *Error) Retryable() bool                     { return e.retryable }
+func (e *Error) Permanent() bool                     { return !e.retryable }
+func (e *Error) AuthExpired() bool                   { return e.auth }
+func (e *Error) RateLimitedUntil() (time.Time, bool) { return time.Time{}, e.limited }
+func (e *Error) QuotaExceeded() bool                 { return false }
+
 var (
-    ErrUnauthorized = errors.New("linkedin: unauthorized or invalid token")
-    ErrForbidden    = errors.New("linkedin: insufficient permissions or scope")
-    ErrRateLimited  = errors.New("linkedin: rate limited")
-    ErrValidation   = errors.New("linkedin: validation error")
-    ErrNotFound     = errors.New("linkedin: resource not found")
-    ErrServer       = errors.New("linkedin: server error")
+    ErrUnauthorized = &Error{msg: "linkedin: unauthorized or invalid token", auth: true}
+    ErrForbidden    = &Error{msg: "linkedin: insufficient permissions or scope"}
+    ErrRateLimited  = &Error{msg: "linkedin: rate limited", retryable: true, limited: true}
+    ErrValidation   = &Error{msg: "linkedin: validation error"}
+    ErrNotFound     = &Error{msg: "linkedin: resource not found"}
+    ErrServer       = &Error{msg: "linkedin: server error", retryable: true}
 )
 
 // APIError captures LinkedIn REST error payload fields when available.
@@ -36,6 +53,16 @@ func (e APIError) Error() string {
     return "linkedin: unknown error"
 }
 
+// APIError is only returned for non-retryable 4xx responses (see MapHTTPError),
+// so its retry class follows the status it carries.
+func (e APIError) Retryable() bool   { return e.Status >= 500 || e.Status == http.StatusTooManyRequests }
+func (e APIError) Permanent() bool   { return !e.Retryable() }
+func (e APIError) AuthExpired() bool { return e.Status == http.StatusUnauthorized }
+func (e APIError) QuotaExceeded() bool { return false }
+func (e APIError) RateLimitedUntil() (time.Time, bool) {
+    return time.Time{}, e.Status == http.StatusTooManyRequests
+}
+
 // MapHTTPError maps an HTTP response to a sentinel error, preserving APIError details when present.
 func MapHTTPError(resp *http.Response) error {
     if resp == nil {
@@ -1,38 +1,106 @@
 package external
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/bitesinbyte/ferret/pkg/config"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
+	"time"
 )
 
 type Mastodon struct {
+	// HTTP is the client used for API calls and media downloads; nil uses a default.
+	HTTP *http.Client
+}
+
+func init() {
+	Register(Platform{
+		Name:    "mastodon",
+		Aliases: []string{"masto"},
+		Capabilities: Capabilities{
+			MaxChars:   500,
+			MediaTypes: []MediaType{MediaImage, MediaVideo},
+			MaxMedia:   4,
+			Threads:    true,
+			ReturnsID:  true,
+		},
+		RatePerSecond: 2,
+		New:           func(hc *http.Client) Poster { return Mastodon{HTTP: hc} },
+	})
+}
+
+type mastodonStatus struct {
+	Id string `json:"id"`
 }
 
 func (m Mastodon) Post(configData config.Config, post Post) error {
-	content := fmt.Sprintf("Just posted a new blog \n%s \n%s\n%s", post.Title, post.Link, post.HashTags)
-	var instanceURL = os.Getenv("MASTODON_INSTANCE_URL")
-	var accessToken = os.Getenv("MASTODON_ACCESS_TOKEN")
+	_, err := m.PostWithID(configData, post)
+	return err
+}
+
+// PostWithID publishes with the MASTODON_* env credentials.
+func (m Mastodon) PostWithID(configData config.Config, post Post) (string, error) {
+	return m.Publish(context.Background(), configData, CredentialsFromEnv("mastodon"), post)
+}
 
-	apiUrl := fmt.Sprintf("%s/api/v1/statuses?access_token=%s", instanceURL, accessToken)
+// RenderText returns the text Publish sends for post.
+func (m Mastodon) RenderText(post Post) string {
+	return post.TextFor("mastodon", fmt.Sprintf("Just posted a new blog \n%s \n%s\n%s", post.Title, post.Link, post.HashTags))
+}
+
+// Publish posts the status (with media), then FirstComment and Thread as a
+// reply chain, and returns the id of the first status.
+func (m Mastodon) Publish(ctx context.Context, configData config.Config, creds Credentials, post Post) (string, error) {
+	s := newSession(ctx, "mastodon", creds, m.HTTP)
+	content := m.RenderText(post)
+	id, err := s.publishMastodonStatus(content, post.Media, "")
+	if err != nil {
+		return "", err
+	}
+	publishFollowUps("mastodon", id, post, true, s.publishMastodonStatus)
+	return id, nil
+}
+
+func (s session) publishMastodonStatus(content string, media []Media, inReplyTo string) (string, error) {
+	apiUrl := fmt.Sprintf("%s/api/v1/statuses", strings.TrimRight(s.creds.InstanceURL, "/"))
 	data := url.Values{}
 	data.Set("status", content)
+	if inReplyTo != "" {
+		data.Set("in_reply_to_id", inReplyTo)
+	}
+	for _, item := range media {
+		mediaID, err := s.uploadMastodonMedia(item)
+		if err != nil {
+			return "", err
+		}
+		data.Add("media_ids[]", mediaID)
+	}
 
-	req, err := http.NewRequest(http.MethodPost, apiUrl, strings.NewReader(data.Encode()))
+	req, err := s.newRequest(http.MethodPost, apiUrl, strings.NewReader(data.Encode()))
 	if err != nil {
-		return err
+		return "", err
 	}
 
+	req.Header.Set("Authorization", "Bearer "+s.creds.AccessToken)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Mastodon returns the original status for a repeated key (kept for an
+	// hour); replies get their own key derived from the parent.
+	if key := IdempotencyKey(s.ctx); key != "" {
+		if inReplyTo != "" {
+			key += ":" + inReplyTo
+		}
+		req.Header.Set("Idempotency-Key", key)
+	}
 
-	client := http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -42,8 +110,110 @@ func (m Mastodon) Post(configData config.Config, post Post) error {
 	}(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return "", HTTPError("mastodon", resp)
 	}
+	var status mastodonStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return "", err
+	}
+	return status.Id, nil
+}
 
-	return nil
+// Reconcile looks for post among the account's latest statuses.
+func (m Mastodon) Reconcile(ctx context.Context, creds Credentials, post Post, since time.Time) (string, bool, error) {
+	s := newSession(ctx, "mastodon", creds, m.HTTP)
+	base := strings.TrimRight(creds.InstanceURL, "/")
+	var account mastodonStatus
+	if err := s.mastodonGet(base+"/api/v1/accounts/verify_credentials", &account); err != nil {
+		return "", false, err
+	}
+	var statuses []struct {
+		Id        string `json:"id"`
+		Content   string `json:"content"`
+		CreatedAt string `json:"created_at"`
+	}
+	if err := s.mastodonGet(base+"/api/v1/accounts/"+url.PathEscape(account.Id)+"/statuses?limit=20&exclude_replies=true", &statuses); err != nil {
+		return "", false, err
+	}
+	recent := make([]recentPost, 0, len(statuses))
+	for _, st := range statuses {
+		recent = append(recent, recentPost{ID: st.Id, Text: st.Content, CreatedAt: parsePlatformTime(st.CreatedAt)})
+	}
+	id, found := matchRecent(recent, m.RenderText(post), since)
+	return id, found, nil
+}
+
+// Metrics reads the counters of a status. Mastodon reports no impressions:
+// favourites count as likes and boosts as shares.
+func (m Mastodon) Metrics(ctx context.Context, creds Credentials, externalID string) (PostMetrics, error) {
+	s := newSession(ctx, "mastodon", creds, m.HTTP)
+	var status struct {
+		Replies    float64 `json:"replies_count"`
+		Reblogs    float64 `json:"reblogs_count"`
+		Favourites float64 `json:"favourites_count"`
+	}
+	base := strings.TrimRight(creds.InstanceURL, "/")
+	if err := s.mastodonGet(base+"/api/v1/statuses/"+url.PathEscape(externalID), &status); err != nil {
+		return PostMetrics{}, err
+	}
+	return PostMetrics{
+		Likes:    int64(status.Favourites),
+		Comments: int64(status.Replies),
+		Shares:   int64(status.Reblogs),
+		Raw:      map[string]float64{"replies_count": status.Replies, "reblogs_count": status.Reblogs, "favourites_count": status.Favourites},
+	}, nil
+}
+
+func (s session) mastodonGet(apiUrl string, out any) error {
+	req, err := s.newRequest(http.MethodGet, apiUrl, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.creds.AccessToken)
+	return s.fetchJSON(req, out)
+}
+
+// uploadMastodonMedia uploads one attachment via /api/v2/media and returns its id.
+func (s session) uploadMastodonMedia(item Media) (string, error) {
+	b, _, name, err := s.fetchMedia(item)
+	if err != nil {
+		return "", err
+	}
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(b); err != nil {
+		return "", err
+	}
+	if item.AltText != "" {
+		_ = writer.WriteField("description", item.AltText)
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := s.newRequest(http.MethodPost, strings.TrimRight(s.creds.InstanceURL, "/")+"/api/v2/media", body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.creds.AccessToken)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := s.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	// 202 means the file is still processing; the id is already usable in a status.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return "", HTTPError("mastodon", resp)
+	}
+	var attachment mastodonStatus
+	if err := json.NewDecoder(resp.Body).Decode(&attachment); err != nil {
+		return "", err
+	}
+	return attachment.Id, nil
 }
@@ -0,0 +1,35 @@
+package external
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+    "path"
+    "strings"
+)
+
+// maxMediaBytes caps downloads for platforms that need the raw file.
+const maxMediaBytes = 200 << 20
+
+// fetchMedia downloads m for upload-based APIs and returns the bytes, content
+// type and a file name derived from the URL.
+func (s session) fetchMedia(m Media) ([]byte, string, string, error) {
+    platform := s.platform
+    resp, err := s.get(m.URL)
+    if err != nil { return nil, "", "", err }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, "", "", &PlatformError{Platform: platform, Kind: kindForStatus(resp.StatusCode), StatusCode: resp.StatusCode,
+            Message: fmt.Sprintf("media download %s failed", m.URL)}
+    }
+    b, err := io.ReadAll(io.LimitReader(resp.Body, maxMediaBytes+1))
+    if err != nil { return nil, "", "", TransportError(platform, err) }
+    if len(b) > maxMediaBytes {
+        return nil, "", "", &PlatformError{Platform: platform, Kind: KindPermanent, Message: fmt.Sprintf("media %s exceeds %d bytes", m.URL, maxMediaBytes)}
+    }
+    ctype := resp.Header.Get("Content-Type")
+    if ctype == "" { ctype = http.DetectContentType(b) }
+    name := path.Base(strings.Split(m.URL, "?")[0])
+    if name == "" || name == "/" || name == "." { name = "media" }
+    return b, ctype, name, nil
+}
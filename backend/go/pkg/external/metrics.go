@@ -0,0 +1,100 @@
+package external
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+)
+
+// ErrNoMetrics is returned for platforms whose poster cannot read metrics back.
+var ErrNoMetrics = errors.New("platform does not report post metrics")
+
+// PostMetrics are the counters a platform reports for one published post.
+// Counters the platform does not expose stay zero; Raw keeps every value
+// under the platform's own name.
+type PostMetrics struct {
+    Impressions int64
+    Reach       int64
+    Likes       int64
+    Comments    int64
+    Shares      int64
+    Clicks      int64
+    Saves       int64
+    Conversions int64
+    Raw         map[string]float64
+}
+
+// MetricsFetcher is implemented by posters that can read the counters of a
+// post back, by the id Publish returned.
+type MetricsFetcher interface {
+    Metrics(ctx context.Context, creds Credentials, externalID string) (PostMetrics, error)
+}
+
+// metricsFetchers serve platforms that have metrics but no Poster.
+var metricsFetchers = map[string]func(hc *http.Client) MetricsFetcher{
+    "youtube": func(hc *http.Client) MetricsFetcher { return YouTube{HTTP: hc} },
+}
+
+// FetchMetrics reads the counters of externalID from the poster registered
+// under platform, or from the metrics-only fetcher of a platform without one
+// (YouTube). The error wraps ErrNoMetrics when that poster has no
+// MetricsFetcher.
+func FetchMetrics(ctx context.Context, platform string, creds Credentials, externalID string, hc *http.Client) (PostMetrics, error) {
+    var mf MetricsFetcher
+    if newFetcher, ok := metricsFetchers[strings.ToLower(strings.TrimSpace(platform))]; ok {
+        mf = newFetcher(hc)
+    } else {
+        p, err := NewPoster(platform, hc)
+        if err != nil { return PostMetrics{}, err }
+        var ok bool
+        if mf, ok = p.(MetricsFetcher); !ok { return PostMetrics{}, fmt.Errorf("%w: %s", ErrNoMetrics, platform) }
+    }
+    if externalID == "" { return PostMetrics{}, fmt.Errorf("%s: metrics need the published post id", platform) }
+    return mf.Metrics(ctx, creds, externalID)
+}
+
+// graphInsights reads a Graph API /insights response (Facebook, Threads)
+// into name -> value. Lifetime metrics carry their value in values[0] or,
+// on Threads, in total_value.
+func (s session) graphInsights(url string) (map[string]float64, error) {
+    req, err := s.newRequest(http.MethodGet, url, nil)
+    if err != nil { return nil, err }
+    var page struct {
+        Data []struct {
+            Name   string `json:"name"`
+            Values []struct {
+                Value any `json:"value"`
+            } `json:"values"`
+            TotalValue *struct {
+                Value any `json:"value"`
+            } `json:"total_value"`
+        } `json:"data"`
+    }
+    if err := s.fetchJSON(req, &page); err != nil { return nil, err }
+    out := make(map[string]float64, len(page.Data))
+    for _, d := range page.Data {
+        switch {
+        case d.TotalValue != nil:
+            out[d.Name] = metricValue(d.TotalValue.Value)
+        case len(d.Values) > 0:
+            out[d.Name] = metricValue(d.Values[0].Value)
+        }
+    }
+    return out, nil
+}
+
+// metricValue turns an insights value into a number. Breakdown objects
+// ({"like": 3, "love": 1}) are summed.
+func metricValue(v any) float64 {
+    switch x := v.(type) {
+    case float64:
+        return x
+    case map[string]any:
+        var sum float64
+        for _, n := range x { sum += metricValue(n) }
+        return sum
+    }
+    return 0
+}
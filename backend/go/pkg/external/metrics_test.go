@@ -0,0 +1,89 @@
+package external
+
+import (
+    "context"
+    "errors"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "testing"
+)
+
+var (
+    _ MetricsFetcher = Mastodon{}
+    _ MetricsFetcher = Twitter{}
+    _ MetricsFetcher = Facebook{}
+    _ MetricsFetcher = Thread{}
+    _ MetricsFetcher = Linkedin{}
+    _ MetricsFetcher = Instagram{}
+    _ MetricsFetcher = YouTube{}
+)
+
+func TestMetricsMapPlatformCounters(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.URL.Path {
+        case "/2/tweets/t1":
+            _, _ = io.WriteString(w, `{"data":{"public_metrics":{"impression_count":900,"like_count":12,"reply_count":3,"retweet_count":4,"quote_count":1,"bookmark_count":2}}}`)
+        case "/api/v1/statuses/m1":
+            _, _ = io.WriteString(w, `{"replies_count":2,"reblogs_count":5,"favourites_count":7}`)
+        case "/v1.0/th1/insights":
+            _, _ = io.WriteString(w, `{"data":[{"name":"views","values":[{"value":300}]},{"name":"likes","total_value":{"value":9}},{"name":"replies","values":[{"value":1}]},{"name":"reposts","values":[{"value":2}]},{"name":"quotes","values":[{"value":1}]}]}`)
+        case "/v19.0/page_1":
+            _, _ = io.WriteString(w, `{"reactions":{"data":[],"summary":{"total_count":20}},"comments":{"data":[],"summary":{"total_count":4}},"shares":{"count":3}}`)
+        case "/v19.0/page_1/insights":
+            _, _ = io.WriteString(w, `{"data":[{"name":"post_impressions","values":[{"value":1000}]},{"name":"post_impressions_unique","values":[{"value":800}]},{"name":"post_clicks","values":[{"value":40}]}]}`)
+        case "/v19.0/ig1":
+            _, _ = io.WriteString(w, `{"like_count":30,"comments_count":6}`)
+        case "/v19.0/ig1/insights":
+            _, _ = io.WriteString(w, `{"data":[{"name":"impressions","values":[{"value":700}]},{"name":"reach","values":[{"value":500}]},{"name":"saved","values":[{"value":11}]},{"name":"shares","values":[{"value":5}]}]}`)
+        case "/youtube/v3/videos":
+            if r.URL.Query().Get("id") != "yt1" || r.URL.Query().Get("key") != "yt-key" { t.Errorf("youtube query = %s", r.URL.RawQuery) }
+            _, _ = io.WriteString(w, `{"items":[{"statistics":{"viewCount":"1200","likeCount":"80","commentCount":"9"}}]}`)
+        case "/rest/posts/urn:li:share:1/statistics":
+            _, _ = io.WriteString(w, `{"totalImpressionStatistics":{"impressionCount":400,"uniqueImpressionsCount":350},"totalReactionStatistics":{"count":15},"totalCommentStatistics":{"commentCount":2},"totalShareStatistics":{"shareCount":1}}`)
+        default:
+            t.Errorf("unexpected %s", r.URL.Path)
+            http.NotFound(w, r)
+        }
+    }))
+    defer srv.Close()
+    hc := &http.Client{Transport: rewriteHost{srv}}
+    ctx := context.Background()
+
+    cases := []struct {
+        platform, id string
+        creds        Credentials
+        want         PostMetrics
+    }{
+        {"x", "t1", Credentials{}, PostMetrics{Impressions: 900, Likes: 12, Comments: 3, Shares: 5, Saves: 2}},
+        {"mastodon", "m1", Credentials{InstanceURL: srv.URL}, PostMetrics{Likes: 7, Comments: 2, Shares: 5}},
+        {"threads", "th1", Credentials{}, PostMetrics{Impressions: 300, Likes: 9, Comments: 1, Shares: 3}},
+        {"facebook", "page_1", Credentials{}, PostMetrics{Impressions: 1000, Reach: 800, Likes: 20, Comments: 4, Shares: 3, Clicks: 40}},
+        {"instagram", "ig1", Credentials{}, PostMetrics{Impressions: 700, Reach: 500, Likes: 30, Comments: 6, Shares: 5, Saves: 11}},
+        {"linkedin", "urn:li:share:1", Credentials{}, PostMetrics{Impressions: 400, Reach: 350, Likes: 15, Comments: 2, Shares: 1}},
+        {"youtube", "yt1", Credentials{Extra: map[string]string{"api_key": "yt-key"}}, PostMetrics{Impressions: 1200, Likes: 80, Comments: 9}},
+    }
+    for _, c := range cases {
+        got, err := FetchMetrics(ctx, c.platform, c.creds, c.id, hc)
+        if err != nil { t.Errorf("%s: %v", c.platform, err); continue }
+        if len(got.Raw) == 0 { t.Errorf("%s: no raw metrics", c.platform) }
+        got.Raw = nil
+        if !reflect.DeepEqual(got, c.want) { t.Errorf("%s: metrics = %+v, want %+v", c.platform, got, c.want) }
+    }
+}
+
+func TestFetchMetricsErrors(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusUnauthorized)
+    }))
+    defer srv.Close()
+    ctx := context.Background()
+    _, err := FetchMetrics(ctx, "mastodon", Credentials{InstanceURL: srv.URL}, "m1", srv.Client())
+    var pe PostError
+    if !errors.As(err, &pe) || !pe.AuthExpired() { t.Fatalf("err = %v", err) }
+    _, err = FetchMetrics(ctx, "youtube", Credentials{Extra: map[string]string{"api_key": "k"}}, "yt1", &http.Client{Transport: rewriteHost{srv}})
+    if !errors.As(err, &pe) || !pe.AuthExpired() { t.Fatalf("youtube err = %v", err) }
+    if _, err := FetchMetrics(ctx, "mastodon", Credentials{InstanceURL: srv.URL}, "", srv.Client()); err == nil { t.Fatal("empty id accepted") }
+    if _, err := FetchMetrics(ctx, "myspace", Credentials{}, "1", nil); !errors.Is(err, ErrUnknownPlatform) { t.Fatalf("err = %v", err) }
+}
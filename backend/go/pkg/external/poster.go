@@ -1,13 +1,88 @@
 package external
 
-import "github.com/bitesinbyte/ferret/pkg/config"
+import (
+    "log"
+    "strings"
 
+    "github.com/bitesinbyte/ferret/pkg/config"
+)
+
+// Post is what every Poster publishes. Title/Link/Description/HashTags are the
+// original blog-announcement fields; the rest carry planner captions, media
+// and reply chains. When Body is empty posters fall back to their legacy
+// "Just posted a new blog" text so RSS-driven runs behave as before.
 type Post struct {
-	Title       string
-	Link        string
-	Description string
-	HashTags    string
+    Title       string
+    Link        string
+    Description string
+    HashTags    string
+
+    // Body is the post text (e.g. scheduled_posts.caption from the planner).
+    Body string `json:"body,omitempty"`
+    // Media is attached in order. Platforms without multi-media support use the first item.
+    Media []Media `json:"media,omitempty"`
+    // FirstComment is published as a comment (or reply) right after the post.
+    FirstComment string `json:"first_comment,omitempty"`
+    // Overrides maps a platform name ("twitter", "linkedin", ...) to text that replaces Body there.
+    Overrides map[string]string `json:"overrides,omitempty"`
+    // Thread holds follow-ups published as a reply chain under the post.
+    // Platforms without threads publish them as comments in order.
+    Thread []Post `json:"thread,omitempty"`
 }
+
+// MediaType distinguishes images from videos.
+type MediaType string
+
+const (
+    MediaImage MediaType = "image"
+    MediaVideo MediaType = "video"
+)
+
+// Media is a publicly reachable image or video. Posters that need bytes
+// (Mastodon, Twitter, LinkedIn) download it; Graph APIs fetch the URL themselves.
+type Media struct {
+    URL     string    `json:"url"`
+    Type    MediaType `json:"type,omitempty"`
+    AltText string    `json:"alt,omitempty"`
+}
+
+// IsVideo reports whether m is a video, inferring from the URL when Type is unset.
+func (m Media) IsVideo() bool {
+    if m.Type != "" { return m.Type == MediaVideo }
+    u := strings.ToLower(strings.Split(m.URL, "?")[0])
+    return strings.HasSuffix(u, ".mp4") || strings.HasSuffix(u, ".mov") || strings.HasSuffix(u, ".m4v") || strings.HasSuffix(u, ".webm")
+}
+
+// TextFor returns the text to publish on platform: its override, else Body,
+// else legacy (the poster's historical announcement format).
+func (p Post) TextFor(platform, legacy string) string {
+    if v := strings.TrimSpace(p.Overrides[strings.ToLower(platform)]); v != "" { return v }
+    if strings.TrimSpace(p.Body) != "" { return p.Body }
+    return legacy
+}
+
+// publishFollowUps publishes FirstComment and then each Thread entry under
+// rootID. With chain set, each Thread entry replies to the previous one
+// (reply chains); otherwise all hang off the root (comments). The root post is
+// already live, so failures are logged rather than returned: a retry would
+// publish the root a second time.
+func publishFollowUps(platform, rootID string, post Post, chain bool, reply func(text string, media []Media, parentID string) (string, error)) {
+    if post.FirstComment != "" {
+        if _, err := reply(post.FirstComment, nil, rootID); err != nil {
+            log.Printf("%s: first comment on %s failed: %v", platform, rootID, err)
+        }
+    }
+    parent := rootID
+    for i, item := range post.Thread {
+        id, err := reply(item.TextFor(platform, ""), item.Media, parent)
+        if err != nil {
+            log.Printf("%s: thread item %d under %s failed, stopping chain: %v", platform, i+1, rootID, err)
+            return
+        }
+        if chain { parent = id }
+    }
+}
+
 type Poster interface {
     Post(configData config.Config, post Post) error
 }
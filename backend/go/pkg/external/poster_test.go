@@ -0,0 +1,22 @@
+package external
+
+import "testing"
+
+func TestPostTextFor(t *testing.T) {
+    p := Post{Body: "body", Overrides: map[string]string{"twitter": "short"}}
+    if got := p.TextFor("twitter", "legacy"); got != "short" { t.Fatalf("override: got %q", got) }
+    if got := p.TextFor("linkedin", "legacy"); got != "body" { t.Fatalf("body: got %q", got) }
+    if got := (Post{}).TextFor("linkedin", "legacy"); got != "legacy" { t.Fatalf("legacy: got %q", got) }
+}
+
+func TestMediaIsVideo(t *testing.T) {
+    cases := map[Media]bool{
+        {URL: "https://x/a.mp4?sig=1"}:                 true,
+        {URL: "https://x/a.png"}:                       false,
+        {URL: "https://x/a.bin", Type: MediaVideo}:     true,
+        {URL: "https://x/a.mov", Type: MediaImage}:     false,
+    }
+    for m, want := range cases {
+        if m.IsVideo() != want { t.Errorf("%s: want %v", m.URL, want) }
+    }
+}
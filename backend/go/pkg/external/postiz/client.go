@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // Client provides typed helpers for the Postiz Public API.
@@ -31,6 +32,23 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("postiz: status=%d", e.Status)
 }
 
+// Retryable reports 429 and 5xx responses; together with the methods below it
+// satisfies external.PostError.
+func (e *APIError) Retryable() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status >= 500
+}
+
+func (e *APIError) Permanent() bool { return !e.Retryable() }
+
+func (e *APIError) AuthExpired() bool { return e.Status == http.StatusUnauthorized }
+
+func (e *APIError) QuotaExceeded() bool { return false }
+
+// RateLimitedUntil reports 429s; Postiz does not send a reset time.
+func (e *APIError) RateLimitedUntil() (time.Time, bool) {
+	return time.Time{}, e.Status == http.StatusTooManyRequests
+}
+
 // New creates a Client from the provided Config.
 func New(cfg Config) (*Client, error) {
 	if cfg.APIKey == "" {
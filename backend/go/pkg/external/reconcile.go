@@ -0,0 +1,116 @@
+package external
+
+import (
+    "context"
+    "encoding/json"
+    "html"
+    "net/http"
+    "regexp"
+    "strings"
+    "time"
+)
+
+type idempotencyKeyCtx struct{}
+
+// WithIdempotencyKey attaches the publish ledger key of a scheduled post to
+// ctx. Posters send it where the platform deduplicates creates (Mastodon's
+// Idempotency-Key header), so a retried request cannot create a second post.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+    return context.WithValue(ctx, idempotencyKeyCtx{}, key)
+}
+
+// IdempotencyKey returns the key set by WithIdempotencyKey, or "".
+func IdempotencyKey(ctx context.Context) string {
+    if ctx == nil { return "" }
+    key, _ := ctx.Value(idempotencyKeyCtx{}).(string)
+    return key
+}
+
+// Reconciler is implemented by posters that can tell whether a post already
+// went out. It is used before retrying an attempt whose outcome is unknown
+// (a timeout, or a crash after the platform accepted the request): Reconcile
+// scans the account's recent posts created since `since` and returns the id
+// of one whose text matches post.
+type Reconciler interface {
+    Reconcile(ctx context.Context, creds Credentials, post Post, since time.Time) (id string, found bool, err error)
+}
+
+// reconcileSkew widens the since window for clock differences with the platform.
+const reconcileSkew = 5 * time.Minute
+
+// recentPost is one timeline entry as seen by Reconcile.
+type recentPost struct {
+    ID        string
+    Text      string
+    CreatedAt time.Time // zero when the platform's timestamp did not parse
+}
+
+// matchRecent returns the newest entry of recent whose text matches text and
+// that is not older than since. An empty text never matches: media-only posts
+// cannot be told apart safely.
+func matchRecent(recent []recentPost, text string, since time.Time) (string, bool) {
+    want := normalizeText(text)
+    if want == "" { return "", false }
+    var best *recentPost
+    for i := range recent {
+        p := &recent[i]
+        if !p.CreatedAt.IsZero() && p.CreatedAt.Before(since.Add(-reconcileSkew)) { continue }
+        if normalizeText(p.Text) != want { continue }
+        if best == nil || p.CreatedAt.After(best.CreatedAt) { best = p }
+    }
+    if best == nil { return "", false }
+    return best.ID, true
+}
+
+var (
+    htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>|</p>`)
+    htmlTag   = regexp.MustCompile(`<[^>]*>`)
+    textURL   = regexp.MustCompile(`https?://\S+`)
+)
+
+// normalizeText reduces text to what survives platform rewriting: markup
+// (Mastodon returns HTML), links (shortened to t.co, truncated in display) and
+// whitespace are dropped and case is folded.
+func normalizeText(s string) string {
+    s = htmlBreak.ReplaceAllString(s, " ")
+    s = html.UnescapeString(htmlTag.ReplaceAllString(s, ""))
+    s = textURL.ReplaceAllString(s, "")
+    return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// parsePlatformTime accepts the timestamp layouts the platforms return
+// (RFC 3339 and Graph API's "2006-01-02T15:04:05-0700"); zero on failure.
+func parsePlatformTime(v string) time.Time {
+    for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05-0700"} {
+        if t, err := time.Parse(layout, v); err == nil { return t }
+    }
+    return time.Time{}
+}
+
+// fetchJSON sends req and decodes a 200 response into out.
+func (s session) fetchJSON(req *http.Request, out any) error {
+    resp, err := s.do(req)
+    if err != nil { return err }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK { return HTTPError(s.platform, resp) }
+    return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// graphRecent lists recent Graph API objects (Facebook, Instagram, Threads):
+// url must select the id, text field and timestamp field of each entry.
+func (s session) graphRecent(url, textField, timeField string) ([]recentPost, error) {
+    req, err := s.newRequest(http.MethodGet, url, nil)
+    if err != nil { return nil, err }
+    var page struct {
+        Data []map[string]any `json:"data"`
+    }
+    if err := s.fetchJSON(req, &page); err != nil { return nil, err }
+    out := make([]recentPost, 0, len(page.Data))
+    for _, d := range page.Data {
+        id, _ := d["id"].(string)
+        text, _ := d[textField].(string)
+        ts, _ := d[timeField].(string)
+        out = append(out, recentPost{ID: id, Text: text, CreatedAt: parsePlatformTime(ts)})
+    }
+    return out, nil
+}
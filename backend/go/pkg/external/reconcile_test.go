@@ -0,0 +1,95 @@
+package external
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/config"
+)
+
+var (
+    _ Reconciler = Mastodon{}
+    _ Reconciler = Twitter{}
+    _ Reconciler = Facebook{}
+    _ Reconciler = Thread{}
+    _ Reconciler = Instagram{}
+    _ Reconciler = Linkedin{}
+)
+
+func TestMatchRecentIgnoresPlatformRewriting(t *testing.T) {
+    since := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+    text := "New post\nhttps://blog.example/a-long-slug\n#go #ferret"
+    recent := []recentPost{
+        {ID: "old", Text: text, CreatedAt: since.Add(-time.Hour)},
+        {ID: "other", Text: "something else", CreatedAt: since.Add(time.Minute)},
+        {ID: "hit", CreatedAt: since.Add(2 * time.Minute),
+            Text: `<p>New post<br /><a href="https://blog.example/a-long-slug"><span class="invisible">https://</span><span class="ellipsis">blog.example/a-long</span><span class="invisible">-slug</span></a><br /><a href="https://m.example/tags/go">#<span>go</span></a> #ferret</p>`},
+    }
+    if id, ok := matchRecent(recent, text, since); !ok || id != "hit" { t.Fatalf("match = %q, %v", id, ok) }
+    if _, ok := matchRecent(recent[:1], text, since); ok { t.Fatal("post older than since matched") }
+    if _, ok := matchRecent([]recentPost{{ID: "x", Text: ""}}, "", since); ok { t.Fatal("empty text matched") }
+}
+
+func TestMastodonIdempotencyKeyAndReconcile(t *testing.T) {
+    var keys []string
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.URL.Path {
+        case "/api/v1/statuses":
+            _ = r.ParseForm()
+            keys = append(keys, r.Header.Get("Idempotency-Key"))
+            _, _ = io.WriteString(w, `{"id":"`+r.PostForm.Get("status")+`"}`)
+        case "/api/v1/accounts/verify_credentials":
+            _, _ = io.WriteString(w, `{"id":"7"}`)
+        case "/api/v1/accounts/7/statuses":
+            _, _ = io.WriteString(w, `[{"id":"101","content":"<p>root</p>","created_at":"2026-05-01T12:01:00.000Z"}]`)
+        default:
+            t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+        }
+    }))
+    defer srv.Close()
+
+    m := Mastodon{HTTP: srv.Client()}
+    creds := Credentials{Platform: "mastodon", InstanceURL: srv.URL, AccessToken: "t"}
+    post := Post{Body: "root", Thread: []Post{{Body: "second"}}}
+    ctx := WithIdempotencyKey(context.Background(), "sp:1")
+    if _, err := m.Publish(ctx, config.Config{}, creds, post); err != nil { t.Fatal(err) }
+    if len(keys) != 2 || keys[0] != "sp:1" || keys[1] != "sp:1:root" { t.Fatalf("idempotency keys = %v", keys) }
+
+    since := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+    id, found, err := m.Reconcile(context.Background(), creds, post, since)
+    if err != nil || !found || id != "101" { t.Fatalf("reconcile = %q, %v, %v", id, found, err) }
+    if _, found, _ = m.Reconcile(context.Background(), creds, Post{Body: "never posted"}, since); found {
+        t.Fatal("unrelated post reconciled")
+    }
+}
+
+func TestLinkedinReconcile(t *testing.T) {
+    since := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.URL.Path {
+        case "/v2/userinfo":
+            _, _ = io.WriteString(w, `{"sub":"abc"}`)
+        case "/rest/posts":
+            if got := r.URL.Query().Get("author"); got != "urn:li:person:abc" { t.Errorf("author = %q", got) }
+            if r.Header.Get("Authorization") != "Bearer t" { t.Errorf("authorization = %q", r.Header.Get("Authorization")) }
+            old, hit := since.Add(-time.Hour).UnixMilli(), since.Add(time.Minute).UnixMilli()
+            _, _ = fmt.Fprintf(w, `{"elements":[{"id":"urn:li:share:1","commentary":"Hello","createdAt":%d},{"id":"urn:li:share:2","commentary":"Hello","createdAt":%d}]}`, old, hit)
+        default:
+            t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+        }
+    }))
+    defer srv.Close()
+
+    m := Linkedin{HTTP: &http.Client{Transport: rewriteHost{srv}}}
+    creds := Credentials{Platform: "linkedin", AccessToken: "t"}
+    id, found, err := m.Reconcile(context.Background(), creds, Post{Body: "Hello"}, since)
+    if err != nil || !found || id != "urn:li:share:2" { t.Fatalf("reconcile = %q, %v, %v", id, found, err) }
+    if _, found, _ = m.Reconcile(context.Background(), creds, Post{Body: "never posted"}, since); found {
+        t.Fatal("unrelated post reconciled")
+    }
+}
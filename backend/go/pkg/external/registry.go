@@ -0,0 +1,99 @@
+package external
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// ErrUnknownPlatform is returned for platform names no poster registered.
+var ErrUnknownPlatform = errors.New("unknown platform")
+
+// Capabilities describes what a platform accepts, so callers can validate or
+// shape a Post before publishing.
+type Capabilities struct {
+    MaxChars   int         // text limit of a single post
+    MediaTypes []MediaType // attachable media kinds; empty means text/link only
+    MaxMedia   int         // attachments per post
+    Threads    bool        // Thread entries become a reply chain (else comments)
+    ReturnsID  bool        // Publish returns an id usable for analytics
+}
+
+// Supports reports whether media of type t can be attached.
+func (c Capabilities) Supports(t MediaType) bool {
+    for _, mt := range c.MediaTypes {
+        if mt == t { return true }
+    }
+    return false
+}
+
+// Platform is one registry entry. Posters register themselves from init.
+type Platform struct {
+    Name         string   // canonical name stored in scheduled_posts.platform
+    Aliases      []string // accepted alternatives ("x", "ig", ...)
+    Capabilities Capabilities
+    // RatePerSecond is the default publish pace; POSTER_RATE_<NAME> overrides it.
+    RatePerSecond int
+    // New builds the poster. hc is the HTTP client to use; nil means the default.
+    New func(hc *http.Client) Poster
+}
+
+var (
+    registryMu sync.RWMutex
+    registry   = map[string]Platform{} // canonical name -> entry
+    aliases    = map[string]string{}   // lower-case name or alias -> canonical name
+)
+
+// Register adds p to the registry. It panics on an empty name, a missing
+// constructor or a name/alias that is already taken, like database/sql.Register.
+func Register(p Platform) {
+    registryMu.Lock()
+    defer registryMu.Unlock()
+    p.Name = strings.ToLower(strings.TrimSpace(p.Name))
+    if p.Name == "" || p.New == nil { panic("external: Register requires Name and New") }
+    keys := make([]string, 0, len(p.Aliases)+1)
+    for _, n := range append([]string{p.Name}, p.Aliases...) {
+        key := strings.ToLower(strings.TrimSpace(n))
+        if prev, dup := aliases[key]; dup { panic(fmt.Sprintf("external: platform name %q already registered by %s", key, prev)) }
+        keys = append(keys, key)
+    }
+    for _, key := range keys { aliases[key] = p.Name }
+    registry[p.Name] = p
+}
+
+// LookupPlatform resolves a name or alias (case-insensitive).
+func LookupPlatform(name string) (Platform, bool) {
+    registryMu.RLock()
+    defer registryMu.RUnlock()
+    canonical, ok := aliases[strings.ToLower(strings.TrimSpace(name))]
+    if !ok { return Platform{}, false }
+    return registry[canonical], true
+}
+
+// CanonicalPlatform returns the registered name for name or an
+// ErrUnknownPlatform error.
+func CanonicalPlatform(name string) (string, error) {
+    p, ok := LookupPlatform(name)
+    if !ok { return "", fmt.Errorf("%w: %q", ErrUnknownPlatform, name) }
+    return p.Name, nil
+}
+
+// Platforms lists registered entries sorted by name.
+func Platforms() []Platform {
+    registryMu.RLock()
+    defer registryMu.RUnlock()
+    out := make([]Platform, 0, len(registry))
+    for _, p := range registry { out = append(out, p) }
+    sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+    return out
+}
+
+// NewPoster builds the poster registered under name (or an alias).
+func NewPoster(name string, hc *http.Client) (Poster, error) {
+    p, ok := LookupPlatform(name)
+    if !ok { return nil, fmt.Errorf("%w: %q", ErrUnknownPlatform, name) }
+    return p.New(hc), nil
+}
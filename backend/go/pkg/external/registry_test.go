@@ -0,0 +1,34 @@
+package external
+
+import (
+    "errors"
+    "net/http"
+    "testing"
+)
+
+func TestRegistryResolvesAliases(t *testing.T) {
+    cases := map[string]string{"X": "twitter", "ig": "instagram", "masto": "mastodon", "Threads": "thread", " LinkedIn ": "linkedin", "fb": "facebook"}
+    for in, want := range cases {
+        got, err := CanonicalPlatform(in)
+        if err != nil || got != want { t.Errorf("CanonicalPlatform(%q) = %q, %v; want %q", in, got, err, want) }
+    }
+    if _, err := CanonicalPlatform("myspace"); !errors.Is(err, ErrUnknownPlatform) {
+        t.Fatalf("unknown platform err = %v", err)
+    }
+}
+
+func TestRegistryBuildsPosterWithClient(t *testing.T) {
+    hc := &http.Client{}
+    p, err := NewPoster("x", hc)
+    if err != nil { t.Fatal(err) }
+    tw, ok := p.(Twitter)
+    if !ok || tw.HTTP != hc { t.Fatalf("NewPoster(x) = %#v", p) }
+    if got := len(Platforms()); got != 6 { t.Fatalf("registered platforms = %d", got) }
+}
+
+func TestRegisterRejectsDuplicateAlias(t *testing.T) {
+    defer func() {
+        if recover() == nil { t.Fatal("expected panic for duplicate alias") }
+    }()
+    Register(Platform{Name: "twitter-clone", Aliases: []string{"x"}, New: func(*http.Client) Poster { return Twitter{} }})
+}
@@ -1,20 +1,43 @@
 package external
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/bitesinbyte/ferret/pkg/config"
 )
 
-const ThreadCreatePostUrl = "https://graph.threads.net/v1.0/%s/threads?media_type=Text&text=%s&access_token=%s"
-const ThreadPublishPostUrl = "https://graph.threads.net/v1.0/%s/threads_publish?creation_id=%s&access_token=%s"
+const ThreadCreatePostUrl = "https://graph.threads.net/v1.0/%s/threads"
+const ThreadPublishPostUrl = "https://graph.threads.net/v1.0/%s/threads_publish"
+const ThreadContainerStatusUrl = "https://graph.threads.net/v1.0/%s?fields=status,error_message&access_token=%s"
+const ThreadRecentPostsUrl = "https://graph.threads.net/v1.0/%s/threads?fields=id,text,timestamp&limit=10&access_token=%s"
+const ThreadInsightsUrl = "https://graph.threads.net/v1.0/%s/insights?metric=views,likes,replies,reposts,quotes,shares&access_token=%s"
 
 type Thread struct {
+	// HTTP is the client used for API calls; nil uses a default.
+	HTTP *http.Client
+}
+
+func init() {
+	Register(Platform{
+		Name:    "thread",
+		Aliases: []string{"threads"},
+		Capabilities: Capabilities{
+			MaxChars:   500,
+			MediaTypes: []MediaType{MediaImage, MediaVideo},
+			MaxMedia:   20,
+			Threads:    true,
+			ReturnsID:  true,
+		},
+		RatePerSecond: 2,
+		New:           func(hc *http.Client) Poster { return Thread{HTTP: hc} },
+	})
 }
 
 type threadPostResponse struct {
@@ -22,28 +45,139 @@ type threadPostResponse struct {
 }
 
 func (t Thread) Post(configData config.Config, post Post) error {
+	_, err := t.PostWithID(configData, post)
+	return err
+}
+
+// PostWithID publishes with the THREAD_* env credentials.
+func (t Thread) PostWithID(configData config.Config, post Post) (string, error) {
+	return t.Publish(context.Background(), configData, CredentialsFromEnv("thread"), post)
+}
+
+// RenderText returns the text Publish sends for post.
+func (t Thread) RenderText(post Post) string {
+	return post.TextFor("thread", fmt.Sprintf("Just posted a new blog \n%s \n%s\n%s", post.Title, post.Link, post.HashTags))
+}
 
-	postData, err := createThreadPost(post)
+// Publish posts as creds.ExternalID (text, image, video or carousel), then
+// FirstComment and Thread as a reply chain, and returns the first post id.
+func (t Thread) Publish(ctx context.Context, configData config.Config, creds Credentials, post Post) (string, error) {
+	s := newSession(ctx, "thread", creds, t.HTTP)
+	text := t.RenderText(post)
+	id, err := s.publishThreadsPost(text, post.Media, "")
 	if err != nil {
-		return err
+		return "", err
 	}
-	return publishPost(postData)
+	publishFollowUps("thread", id, post, true, s.publishThreadsPost)
+	return id, nil
 }
 
-func createThreadPost(post Post) (*threadPostResponse, error) {
-	var postUrl = fmt.Sprintf(ThreadCreatePostUrl, os.Getenv("THREAD_USER_ID"),
-		url.QueryEscape(fmt.Sprintf("Just posted a new blog \n%s \n%s\n%s", post.Title, post.Link, post.HashTags)),
-		os.Getenv("THREAD_ACCESS_TOKEN"))
+// Reconcile looks for post among the profile's latest threads.
+func (t Thread) Reconcile(ctx context.Context, creds Credentials, post Post, since time.Time) (string, bool, error) {
+	s := newSession(ctx, "thread", creds, t.HTTP)
+	recent, err := s.graphRecent(fmt.Sprintf(ThreadRecentPostsUrl, creds.ExternalID, url.QueryEscape(creds.AccessToken)), "text", "timestamp")
+	if err != nil {
+		return "", false, err
+	}
+	id, found := matchRecent(recent, t.RenderText(post), since)
+	return id, found, nil
+}
+
+// Metrics reads the lifetime insights of a Threads post. Views count as
+// impressions; reposts, quotes and shares as shares.
+func (t Thread) Metrics(ctx context.Context, creds Credentials, externalID string) (PostMetrics, error) {
+	s := newSession(ctx, "thread", creds, t.HTTP)
+	raw, err := s.graphInsights(fmt.Sprintf(ThreadInsightsUrl, url.PathEscape(externalID), url.QueryEscape(creds.AccessToken)))
+	if err != nil {
+		return PostMetrics{}, err
+	}
+	return PostMetrics{
+		Impressions: int64(raw["views"]),
+		Likes:       int64(raw["likes"]),
+		Comments:    int64(raw["replies"]),
+		Shares:      int64(raw["reposts"] + raw["quotes"] + raw["shares"]),
+		Raw:         raw,
+	}, nil
+}
 
-	req, err := http.NewRequest(http.MethodPost, postUrl, nil)
+func (s session) publishThreadsPost(text string, media []Media, replyTo string) (string, error) {
+	params := url.Values{}
+	params.Set("text", text)
+	if replyTo != "" {
+		params.Set("reply_to_id", replyTo)
+	}
+	switch len(media) {
+	case 0:
+		params.Set("media_type", "TEXT")
+	case 1:
+		setThreadsMedia(params, media[0])
+	default:
+		children := make([]string, 0, len(media))
+		for _, item := range media {
+			child := url.Values{}
+			child.Set("is_carousel_item", "true")
+			setThreadsMedia(child, item)
+			container, err := s.createThreadPost(child)
+			if err != nil {
+				return "", err
+			}
+			children = append(children, container.Id)
+		}
+		params.Set("media_type", "CAROUSEL")
+		params.Set("children", strings.Join(children, ","))
+	}
+	container, err := s.createThreadPost(params)
+	if err != nil {
+		return "", err
+	}
+	if hasVideo(media) {
+		if err := s.waitForThreadContainer(container.Id); err != nil {
+			return "", err
+		}
+	}
+	published, err := s.publishPost(container)
+	if err != nil {
+		return "", err
+	}
+	return published.Id, nil
+}
+
+func setThreadsMedia(params url.Values, item Media) {
+	if item.IsVideo() {
+		params.Set("media_type", "VIDEO")
+		params.Set("video_url", item.URL)
+	} else {
+		params.Set("media_type", "IMAGE")
+		params.Set("image_url", item.URL)
+	}
+	if item.AltText != "" {
+		params.Set("alt_text", item.AltText)
+	}
+}
+
+func (s session) createThreadPost(params url.Values) (*threadPostResponse, error) {
+	params.Set("access_token", s.creds.AccessToken)
+	var postUrl = fmt.Sprintf(ThreadCreatePostUrl, s.creds.ExternalID) + "?" + params.Encode()
+	return s.threadsPost(postUrl)
+}
+
+func (s session) publishPost(post *threadPostResponse) (*threadPostResponse, error) {
+	params := url.Values{}
+	params.Set("creation_id", post.Id)
+	params.Set("access_token", s.creds.AccessToken)
+	var postUrl = fmt.Sprintf(ThreadPublishPostUrl, s.creds.ExternalID) + "?" + params.Encode()
+	return s.threadsPost(postUrl)
+}
+
+func (s session) threadsPost(postUrl string) (*threadPostResponse, error) {
+	req, err := s.newRequest(http.MethodPost, postUrl, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	client := http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -55,49 +189,69 @@ func createThreadPost(post Post) (*threadPostResponse, error) {
 	}(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, HTTPError("thread", resp)
 	}
 	jsonData, err := io.ReadAll(resp.Body)
 
 	if err != nil {
-		panic(err)
+		return nil, TransportError("thread", err)
 	}
 
 	var data threadPostResponse
 	err = json.Unmarshal([]byte(jsonData), &data)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	return &data, nil
 }
 
-func publishPost(post *threadPostResponse) error {
-	var postUrl = fmt.Sprintf(ThreadPublishPostUrl, os.Getenv("THREAD_USER_ID"),
-		post.Id,
-		os.Getenv("THREAD_ACCESS_TOKEN"))
-
-	req, err := http.NewRequest(http.MethodPost, postUrl, nil)
-	if err != nil {
-		return err
+func hasVideo(media []Media) bool {
+	for _, item := range media {
+		if item.IsVideo() {
+			return true
+		}
 	}
+	return false
+}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	client := http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+// waitForThreadContainer polls a container until Threads finishes processing
+// its video.
+func (s session) waitForThreadContainer(id string) error {
+	statusUrl := fmt.Sprintf(ThreadContainerStatusUrl, id, url.QueryEscape(s.creds.AccessToken))
+	deadline := time.Now().Add(5 * time.Minute)
+	for {
+		resp, err := s.get(statusUrl)
 		if err != nil {
-			panic(err)
+			return err
+		}
+		var status struct {
+			Status       string `json:"status"`
+			ErrorMessage string `json:"error_message"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := HTTPError("thread", resp)
+			_ = resp.Body.Close()
+			return err
+		}
+		err = json.NewDecoder(resp.Body).Decode(&status)
+		_ = resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		switch status.Status {
+		case "FINISHED", "PUBLISHED", "":
+			return nil
+		case "ERROR", "EXPIRED":
+			return NewPlatformError("thread", KindPermanent, fmt.Errorf("container %s %s: %s", id, strings.ToLower(status.Status), status.ErrorMessage))
+		}
+		if time.Now().After(deadline) {
+			return NewPlatformError("thread", KindTransient, fmt.Errorf("container %s still processing", id))
+		}
+		select {
+		case <-s.ctx.Done():
+			return TransportError("thread", s.ctx.Err())
+		case <-time.After(5 * time.Second):
 		}
-	}(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
-	return nil
 }
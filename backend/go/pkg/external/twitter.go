@@ -2,6 +2,7 @@ package external
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha1"
 	"encoding/base64"
@@ -10,42 +11,182 @@ import (
 	"github.com/bitesinbyte/ferret/pkg/config"
 	"io"
 	"math/rand"
+	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type Twitter struct {
+	// HTTP is the client used for API calls and media downloads; nil uses a default.
+	HTTP *http.Client
+}
+
+func init() {
+	Register(Platform{
+		Name:    "twitter",
+		Aliases: []string{"x"},
+		Capabilities: Capabilities{
+			MaxChars:   280,
+			MediaTypes: []MediaType{MediaImage},
+			MaxMedia:   4,
+			Threads:    true,
+			ReturnsID:  true,
+		},
+		RatePerSecond: 2,
+		New:           func(hc *http.Client) Poster { return Twitter{HTTP: hc} },
+	})
+}
+
+const (
+	TwitterCreateTweetUrl   = "https://api.twitter.com/2/tweets"
+	TwitterMediaUploadUrl   = "https://upload.twitter.com/1.1/media/upload.json"
+	TwitterMediaMetadataUrl = "https://upload.twitter.com/1.1/media/metadata/create.json"
+	TwitterMeUrl            = "https://api.twitter.com/2/users/me"
+	TwitterUserTweetsUrl    = "https://api.twitter.com/2/users/%s/tweets?max_results=10&exclude=replies&tweet.fields=created_at"
+	TwitterTweetMetricsUrl  = "https://api.twitter.com/2/tweets/%s?tweet.fields=public_metrics"
+)
+
+type tweetRequest struct {
+	Text  string      `json:"text"`
+	Reply *tweetReply `json:"reply,omitempty"`
+	Media *tweetMedia `json:"media,omitempty"`
+}
+type tweetReply struct {
+	InReplyToTweetID string `json:"in_reply_to_tweet_id"`
+}
+type tweetMedia struct {
+	MediaIDs []string `json:"media_ids"`
+}
+type tweetResponse struct {
+	Data struct {
+		Id string `json:"id"`
+	} `json:"data"`
 }
 
 func (m Twitter) Post(configData config.Config, post Post) error {
-	content := fmt.Sprintf("Just posted a new blog \n%s \n%s\n%s", post.Title, post.Link, post.HashTags)
-	var consumerKey = os.Getenv("TWITTER_CONSUMER_KEY")
-	var consumerSecret = os.Getenv("TWITTER_CONSUMER_SECRET")
-	var accessToken = os.Getenv("TWITTER_ACCESS_TOKEN")
-	var accessTokenSecret = os.Getenv("TWITTER_ACCESS_TOKEN_SECRET")
+	_, err := m.PostWithID(configData, post)
+	return err
+}
 
+// PostWithID tweets with the TWITTER_* env credentials.
+func (m Twitter) PostWithID(configData config.Config, post Post) (string, error) {
+	return m.Publish(context.Background(), configData, CredentialsFromEnv("twitter"), post)
+}
+
+// RenderText returns the text Publish sends for post.
+func (m Twitter) RenderText(post Post) string {
+	return post.TextFor("twitter", fmt.Sprintf("Just posted a new blog \n%s \n%s\n%s", post.Title, post.Link, post.HashTags))
+}
+
+// Publish tweets the post (up to 4 images), then FirstComment and Thread as
+// a reply chain, and returns the id of the first tweet.
+func (m Twitter) Publish(ctx context.Context, configData config.Config, creds Credentials, post Post) (string, error) {
+	s := newSession(ctx, "twitter", creds, m.HTTP)
+	content := m.RenderText(post)
+	id, err := s.createTweet(content, post.Media, "")
+	if err != nil {
+		return "", err
+	}
+	publishFollowUps("twitter", id, post, true, s.createTweet)
+	return id, nil
+}
+
+// Reconcile looks for post among the account's latest tweets.
+func (m Twitter) Reconcile(ctx context.Context, creds Credentials, post Post, since time.Time) (string, bool, error) {
+	s := newSession(ctx, "twitter", creds, m.HTTP)
+	var me tweetResponse
+	if err := s.twitterGet(TwitterMeUrl, &me); err != nil {
+		return "", false, err
+	}
+	var timeline struct {
+		Data []struct {
+			Id        string `json:"id"`
+			Text      string `json:"text"`
+			CreatedAt string `json:"created_at"`
+		} `json:"data"`
+	}
+	if err := s.twitterGet(fmt.Sprintf(TwitterUserTweetsUrl, url.PathEscape(me.Data.Id)), &timeline); err != nil {
+		return "", false, err
+	}
+	recent := make([]recentPost, 0, len(timeline.Data))
+	for _, tw := range timeline.Data {
+		recent = append(recent, recentPost{ID: tw.Id, Text: tw.Text, CreatedAt: parsePlatformTime(tw.CreatedAt)})
+	}
+	id, found := matchRecent(recent, m.RenderText(post), since)
+	return id, found, nil
+}
+
+// Metrics reads the public metrics of a tweet. Retweets and quotes count as
+// shares and bookmarks as saves; link clicks are not public.
+func (m Twitter) Metrics(ctx context.Context, creds Credentials, externalID string) (PostMetrics, error) {
+	s := newSession(ctx, "twitter", creds, m.HTTP)
+	var tweet struct {
+		Data struct {
+			PublicMetrics map[string]float64 `json:"public_metrics"`
+		} `json:"data"`
+	}
+	if err := s.twitterGet(fmt.Sprintf(TwitterTweetMetricsUrl, url.PathEscape(externalID)), &tweet); err != nil {
+		return PostMetrics{}, err
+	}
+	raw := tweet.Data.PublicMetrics
+	return PostMetrics{
+		Impressions: int64(raw["impression_count"]),
+		Likes:       int64(raw["like_count"]),
+		Comments:    int64(raw["reply_count"]),
+		Shares:      int64(raw["retweet_count"] + raw["quote_count"]),
+		Saves:       int64(raw["bookmark_count"]),
+		Raw:         raw,
+	}, nil
+}
+
+func (s session) twitterGet(apiUrl string, out any) error {
+	req, err := s.newRequest(http.MethodGet, apiUrl, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", s.oauth1Header(apiUrl, http.MethodGet))
+	return s.fetchJSON(req, out)
+}
+
+func (s session) createTweet(content string, media []Media, inReplyTo string) (string, error) {
 	// Twitter API endpoint
-	apiUrl := "https://api.twitter.com/2/tweets"
+	apiUrl := TwitterCreateTweetUrl
 
 	// HTTP method
 	method := http.MethodPost
-	body, err := json.Marshal(map[string]string{"text": content})
+	tweet := tweetRequest{Text: content}
+	if inReplyTo != "" {
+		tweet.Reply = &tweetReply{InReplyToTweetID: inReplyTo}
+	}
+	if len(media) > 4 {
+		media = media[:4]
+	}
+	for _, item := range media {
+		mediaID, err := s.uploadTweetMedia(item)
+		if err != nil {
+			return "", err
+		}
+		if tweet.Media == nil {
+			tweet.Media = &tweetMedia{}
+		}
+		tweet.Media.MediaIDs = append(tweet.Media.MediaIDs, mediaID)
+	}
+	body, err := json.Marshal(tweet)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// Construct and send the HTTP request with OAuth1 signature
-	req, err := http.NewRequest(http.MethodPost, apiUrl, bytes.NewBuffer(body))
+	req, err := s.newRequest(http.MethodPost, apiUrl, bytes.NewBuffer(body))
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// Add OAuth1 Authorization header
-	authHeader := buildOAuth1Header(apiUrl, method, consumerKey, consumerSecret, accessToken, accessTokenSecret)
+	authHeader := s.oauth1Header(apiUrl, method)
 
 	req.Header.Set("Authorization", authHeader)
 
@@ -53,10 +194,9 @@ func (m Twitter) Post(configData config.Config, post Post) error {
 	req.Header.Set("Content-Type", "application/json")
 
 	// Send the request
-	client := http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
-		return fmt.Errorf("error sending HTTP request: %v", err)
+		return "", err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -67,10 +207,86 @@ func (m Twitter) Post(configData config.Config, post Post) error {
 
 	// Check the response status
 	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return "", HTTPError("twitter", resp)
+	}
+	var created tweetResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", err
+	}
+	return created.Data.Id, nil
+}
+
+// uploadTweetMedia uses the v1.1 simple upload (images only) and sets alt text.
+func (s session) uploadTweetMedia(item Media) (string, error) {
+	if item.IsVideo() {
+		return "", NewPlatformError("twitter", KindPermanent, fmt.Errorf("video upload requires the chunked media API, not supported: %s", item.URL))
+	}
+	b, _, name, err := s.fetchMedia(item)
+	if err != nil {
+		return "", err
+	}
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("media", name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(b); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	req, err := s.newRequest(http.MethodPost, TwitterMediaUploadUrl, body)
+	if err != nil {
+		return "", err
+	}
+	// Multipart bodies are not part of the OAuth1 signature base.
+	req.Header.Set("Authorization", s.oauth1Header(TwitterMediaUploadUrl, http.MethodPost))
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, err := s.do(req)
+	if err != nil {
+		return "", err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", HTTPError("twitter", resp)
+	}
+	var uploaded struct {
+		MediaIdString string `json:"media_id_string"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&uploaded); err != nil {
+		return "", err
+	}
+	if item.AltText == "" {
+		return uploaded.MediaIdString, nil
+	}
+
+	meta, err := json.Marshal(map[string]any{"media_id": uploaded.MediaIdString, "alt_text": map[string]string{"text": item.AltText}})
+	if err != nil {
+		return "", err
+	}
+	req, err = s.newRequest(http.MethodPost, TwitterMediaMetadataUrl, bytes.NewBuffer(meta))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", s.oauth1Header(TwitterMediaMetadataUrl, http.MethodPost))
+	req.Header.Set("Content-Type", "application/json")
+	metaResp, err := s.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer metaResp.Body.Close()
+	if metaResp.StatusCode != http.StatusOK && metaResp.StatusCode != http.StatusCreated {
+		return "", HTTPError("twitter", metaResp)
+	}
+	return uploaded.MediaIdString, nil
+}
 
-	return nil
+// oauth1Header signs a request with the session's consumer and access tokens.
+func (s session) oauth1Header(path string, method string) string {
+	c := s.creds
+	return buildOAuth1Header(path, method, c.ConsumerKey, c.ConsumerSecret, c.AccessToken, c.AccessTokenSecret)
 }
 
 func buildOAuth1Header(path string, method string, consumerKey string, consumerSecret string, accessToken string, accessTokenSecret string) string {
@@ -82,8 +298,20 @@ func buildOAuth1Header(path string, method string, consumerKey string, consumerS
 	vals.Add("oauth_token", accessToken)
 	vals.Add("oauth_version", "1.0")
 
+	// Query parameters are part of the signed parameter string but not of the header
+	params := url.Values{}
+	for k, v := range vals {
+		params[k] = v
+	}
+	if _, query, ok := strings.Cut(path, "?"); ok {
+		q, _ := url.ParseQuery(query)
+		for k, v := range q {
+			params[k] = append(params[k], v...)
+		}
+	}
+
 	// net/url package QueryEscape escapes " " into "+", this replaces it with the percentage encoding of " "
-	parameterString := strings.Replace(vals.Encode(), "+", "%20", -1)
+	parameterString := strings.Replace(params.Encode(), "+", "%20", -1)
 
 	// Calculating Signature Base String and Signing Key
 	signatureBase := strings.ToUpper(method) + "&" + url.QueryEscape(strings.Split(path, "?")[0]) + "&" + url.QueryEscape(parameterString)
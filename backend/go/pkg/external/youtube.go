@@ -0,0 +1,61 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	yt "github.com/bitesinbyte/ferret/pkg/external/youtube"
+)
+
+// YouTube reads video statistics for the analytics collector. Videos are not
+// published through this package, so YouTube has no Poster and is not in the
+// registry; FetchMetrics looks it up in metricsFetchers instead.
+type YouTube struct {
+	// HTTP is the client used for API calls; nil uses a default.
+	HTTP *http.Client
+}
+
+// Metrics reads a video's statistics with the YouTube Data API key in
+// creds.Extra["api_key"] (default YOUTUBE_API_KEY). Views count as
+// impressions; YouTube reports no reach, shares or clicks.
+func (y YouTube) Metrics(ctx context.Context, creds Credentials, externalID string) (PostMetrics, error) {
+	cfg := yt.NewFromEnv()
+	if key := creds.Extra["api_key"]; key != "" {
+		cfg.APIKey = key
+	}
+	cfg.HTTPClient = y.HTTP
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = defaultHTTPClient
+	}
+	stats, err := yt.New(cfg).GetVideoStatistics(ctx, externalID)
+	if err != nil {
+		return PostMetrics{}, youtubeError(err)
+	}
+	return PostMetrics{
+		Impressions: stats.ViewCount,
+		Likes:       stats.LikeCount,
+		Comments:    stats.CommentCount,
+		Raw:         map[string]float64{"viewCount": float64(stats.ViewCount), "likeCount": float64(stats.LikeCount), "commentCount": float64(stats.CommentCount)},
+	}, nil
+}
+
+// youtubeError classifies the youtube client's sentinel errors. YouTube
+// answers 403 when the daily quota is spent.
+func youtubeError(err error) error {
+	kind := KindPermanent
+	switch {
+	case errors.Is(err, yt.ErrUnauthorized):
+		kind = KindAuthExpired
+	case errors.Is(err, yt.ErrForbidden):
+		kind = KindQuotaExceeded
+	case errors.Is(err, yt.ErrRateLimited):
+		kind = KindRateLimited
+	case errors.Is(err, yt.ErrServer):
+		kind = KindTransient
+	case errors.Is(err, yt.ErrValidation), errors.Is(err, yt.ErrNotFound):
+	default:
+		kind = kindForTransport(err)
+	}
+	return NewPlatformError("youtube", kind, err)
+}
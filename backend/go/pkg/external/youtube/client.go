@@ -11,6 +11,7 @@ type Client struct {
 }
 
 func New(cfg Config) *Client {
+    if cfg.HTTPClient != nil { return &Client{httpClient: cfg.HTTPClient, cfg: cfg} }
     to := cfg.HTTPTimeout
     if to <= 0 { to = 30 * time.Second }
     return &Client{httpClient: &http.Client{Timeout: to}, cfg: cfg}
@@ -1,6 +1,7 @@
 package youtube
 
 import (
+    "net/http"
     "os"
     "time"
 )
@@ -9,6 +10,8 @@ type Config struct {
     APIKey      string
     ChannelID   string
     HTTPTimeout time.Duration
+    // HTTPClient overrides the client built from HTTPTimeout.
+    HTTPClient  *http.Client
 }
 
 func NewFromEnv() Config {
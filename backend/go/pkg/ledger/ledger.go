@@ -0,0 +1,130 @@
+// Package ledger records scheduled post publishes in publish_ledger so a post
+// is never published twice. Intent is written before the platform call and the
+// outcome after it; an entry still pending when the post is retried means the
+// earlier attempt may or may not have reached the platform and has to be
+// reconciled before publishing again.
+package ledger
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/bitesinbyte/ferret/pkg/external"
+)
+
+// ErrNotFound is returned when no ledger entry has the given key.
+var ErrNotFound = errors.New("ledger: entry not found")
+
+type State string
+
+const (
+    StatePending   State = "pending"   // intent recorded, outcome unknown
+    StatePublished State = "published" // platform accepted the post
+    StateRejected  State = "rejected"  // platform refused it; nothing was published
+)
+
+// Entry is one publish_ledger row.
+type Entry struct {
+    Key             string
+    ScheduledPostID string
+    Platform        string
+    AccountID       string
+    State           State
+    Attempts        int
+    ExternalID      string
+    Response        json.RawMessage
+    Error           string
+    CreatedAt       time.Time
+    UpdatedAt       time.Time
+}
+
+// Key returns the idempotency key for a scheduled post. It only depends on the
+// row id, so every claim, retry and process agrees on it.
+func Key(scheduledPostID string) string { return "sp:" + scheduledPostID }
+
+// Store reads and writes publish_ledger.
+type Store struct {
+    DB *sql.DB
+}
+
+const entryColumns = `key, scheduled_post_id, platform, COALESCE(account_id, ''), state, attempts,
+       COALESCE(external_id, ''), response, COALESCE(error, ''), created_at, updated_at`
+
+func scanEntry(row interface{ Scan(...any) error }) (Entry, error) {
+    var e Entry
+    var response []byte
+    err := row.Scan(&e.Key, &e.ScheduledPostID, &e.Platform, &e.AccountID, &e.State, &e.Attempts,
+        &e.ExternalID, &response, &e.Error, &e.CreatedAt, &e.UpdatedAt)
+    if len(response) > 0 { e.Response = response }
+    return e, err
+}
+
+// Begin records intent to publish under key and returns the entry as it was
+// before the call; found is false on a first attempt. Pending and rejected
+// entries go back to pending with the attempt counted, published entries are
+// left as they are so the caller can reuse their external id.
+func (s Store) Begin(ctx context.Context, key, scheduledPostID, platform, accountID string) (prev Entry, found bool, err error) {
+    q := `
+WITH prev AS (
+    SELECT ` + entryColumns + `
+    FROM publish_ledger WHERE key = $1
+), up AS (
+    INSERT INTO publish_ledger (key, scheduled_post_id, platform, account_id, state, attempts)
+    VALUES ($1, $2, $3, NULLIF($4, ''), 'pending', 1)
+    ON CONFLICT (key) DO UPDATE
+    SET state = 'pending', attempts = publish_ledger.attempts + 1, error = NULL, updated_at = NOW()
+    WHERE publish_ledger.state <> 'published'
+)
+SELECT * FROM prev`
+    prev, err = scanEntry(s.DB.QueryRowContext(ctx, q, key, scheduledPostID, platform, accountID))
+    if errors.Is(err, sql.ErrNoRows) { return Entry{}, false, nil }
+    if err != nil { return Entry{}, false, fmt.Errorf("ledger: begin %s: %w", key, err) }
+    return prev, true, nil
+}
+
+// Complete marks key published with the platform's id and response.
+func (s Store) Complete(ctx context.Context, key, externalID string, response json.RawMessage) error {
+    const q = `
+UPDATE publish_ledger
+SET state = 'published', external_id = NULLIF($2, ''), response = $3, error = NULL, updated_at = NOW()
+WHERE key = $1`
+    return s.exec(ctx, key, q, key, externalID, nullJSON(response))
+}
+
+// Reject marks key rejected: the platform answered with an error, so nothing
+// was published and the next attempt can publish without reconciling.
+func (s Store) Reject(ctx context.Context, key string, cause error) error {
+    var response json.RawMessage
+    var pe *external.PlatformError
+    if errors.As(cause, &pe) {
+        response, _ = json.Marshal(map[string]any{"status_code": pe.StatusCode, "kind": pe.Kind})
+    }
+    const q = `
+UPDATE publish_ledger
+SET state = 'rejected', response = $2, error = $3, updated_at = NOW()
+WHERE key = $1`
+    return s.exec(ctx, key, q, key, nullJSON(response), cause.Error())
+}
+
+// Get loads the entry for key.
+func (s Store) Get(ctx context.Context, key string) (Entry, error) {
+    e, err := scanEntry(s.DB.QueryRowContext(ctx, `SELECT `+entryColumns+` FROM publish_ledger WHERE key = $1`, key))
+    if errors.Is(err, sql.ErrNoRows) { return Entry{}, fmt.Errorf("%w: %s", ErrNotFound, key) }
+    return e, err
+}
+
+func (s Store) exec(ctx context.Context, key, q string, args ...any) error {
+    res, err := s.DB.ExecContext(ctx, q, args...)
+    if err != nil { return fmt.Errorf("ledger: %s: %w", key, err) }
+    if n, err := res.RowsAffected(); err == nil && n == 0 { return fmt.Errorf("%w: %s", ErrNotFound, key) }
+    return nil
+}
+
+func nullJSON(b json.RawMessage) any {
+    if len(b) == 0 { return nil }
+    return []byte(b)
+}
@@ -37,7 +37,7 @@ type Subscription struct {
 	Status             string     `json:"status"`
 	CurrentPeriodEnd   time.Time  `json:"current_period_end"`
 	CancelAtPeriodEnd  bool       `json:"cancel_at_period_end"`
-	TrialEndsAt        *time.Time `json:	rial_ends_at,omitempty"`
+	TrialEndsAt        *time.Time `json:"trial_ends_at,omitempty"`
 	BillingEmail       string     `json:"billing_email"`
 	BillingName        string     `json:"billing_name"`
 	BillingAddress     string     `json:"billing_address"`
@@ -76,7 +76,7 @@ type CreditTransaction struct {
 	ID            string    `json:"id"`
 	OrgID         string    `json:"org_id"`
 	Amount        int64     `json:"amount"` // Positive for credits, negative for debits
-	Type          string    `json:	ype"`    // "purchase", "usage", "refund", "adjustment"
+	Type          string    `json:"type"`    // "purchase", "usage", "refund", "adjustment"
 	Status        string    `json:"status"`  // "pending", "completed", "failed", "refunded"
 	Description   string    `json:"description"`
 	ReferenceID   string    `json:"reference_id,omitempty"` // External reference ID
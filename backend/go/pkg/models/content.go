@@ -10,7 +10,7 @@ import (
 type Content struct {
 	ID           string    `json:"id"`
 	OrgID        string    `json:"org_id"`
-	Title        string    `json:	itle"`
+	Title        string    `json:"title"`
 	Slug         string    `json:"slug"`
 	ContentType  string    `json:"content_type"` // article, video, podcast, etc.
 	Status       string    `json:"status"`       // draft, published, archived
@@ -17,8 +17,7 @@ type ContentSource struct {
 	ID          string          `json:"id"`
 	OrgID       string          `json:"org_id"`
 	TeamID      *string         `json:"team_id,omitempty"`
-	Name        string          `json:
-ame"`
+	Name        string          `json:"name"`
 	Type        ContentSourceType `json:"type"`
 	URL         string          `json:"url"`
 	Description string          `json:"description"`
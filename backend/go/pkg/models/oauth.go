@@ -28,6 +28,7 @@ type OAuthAccount struct {
 	ProviderUserID string    `json:"provider_user_id" gorm:"index"`
 	AccessToken    string    `json:"-" gorm:"type:text"`
 	RefreshToken   string    `json:"-" gorm:"type:text"`
+	TokenSecret    string    `json:"-" gorm:"type:text"` // OAuth 1.0a (Twitter)
 	TokenType      string    `json:"token_type"`
 	ExpiresAt      time.Time `json:"expires_at"`
 	Scope          string    `json:"scope"`